@@ -0,0 +1,643 @@
+// Command stack generates Kubernetes manifests from component definitions
+// (see pkg/component) without writing Go code:
+//   stack [--debug] [--error-format text|json] <command> [flags] [path]
+// path is a component definition file or a directory containing a
+// component.yaml and defaults to the working directory.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/evanlouie/go/pkg/component"
+	"github.com/evanlouie/go/pkg/errcode"
+	"github.com/evanlouie/go/pkg/helm"
+	"github.com/evanlouie/go/pkg/helm/install"
+	"github.com/evanlouie/go/pkg/logger"
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+const usage = `usage: stack [--debug] [--error-format text|json] <command> [flags] [path]
+
+commands:
+  generate      render a component tree into yaml manifests
+  validate      validate a component definition
+  diff          compare a render with a snapshot of a previous render
+  cost          estimate the monthly cost of a render from a pricing table
+  sbom          list the charts and container images of a render as a CycloneDX or SPDX document
+  state         render the releases of a helmfile-style state file, optionally selected by label
+  vendor        pull the helm charts of a component tree into a directory
+  install-helm  download the latest helm 3 release if helm 3 is not on $PATH
+  capabilities  snapshot the version and API versions of a cluster for offline renders
+
+run "stack <command> --help" for the flags of a command
+`
+
+// errChanges is returned by diff when --exit-code is set and the render
+// differs from the snapshot.
+var errChanges = errors.New("render differs from snapshot")
+
+// shutdownTimeout is how long in-flight helm operations are waited for after
+// SIGTERM or an interrupt.
+const shutdownTimeout = 10 * time.Second
+
+// interrupted is cancelled on SIGTERM or an interrupt, killing the kubectl and
+// image scanner subprocesses of the command. helm and git subprocesses are
+// killed by helm.Shutdown.
+var interrupted, interrupt = context.WithCancel(context.Background())
+
+func main() {
+	// stop helm (e.g. when a container is stopped) so the command fails with
+	// helm.ErrShutdown instead of leaving helm subprocesses and temporary
+	// directories behind
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-signals
+		interrupt()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		helm.Shutdown(ctx)
+	}()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command of args, returning the exit code.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	global := flag.NewFlagSet("stack", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	logger.BindFlags(global)
+	errorFormat := global.String("error-format", "text", `format of errors written to stderr: "text", prefixed with the error code, or "json" objects with "command", "code" and "message"`)
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+	if *errorFormat != "text" && *errorFormat != "json" {
+		fmt.Fprintf(stderr, "unknown --error-format \"%s\"\n\n%s", *errorFormat, usage)
+		return 2
+	}
+	if global.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func([]string, io.Writer) error{
+		"generate":     generate,
+		"validate":     validate,
+		"diff":         diff,
+		"cost":         cost,
+		"sbom":         sbom,
+		"state":        state,
+		"vendor":       vendor,
+		"install-helm": installHelm,
+		"capabilities": capabilities,
+	}
+	name := global.Arg(0)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command \"%s\"\n\n%s", name, usage)
+		return 2
+	}
+	switch err := command(global.Args()[1:], stdout); {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errChanges):
+		return 1
+	case err != nil:
+		writeError(stderr, *errorFormat, name, err)
+		return 1
+	}
+	return 0
+}
+
+// writeError writes the error of command to stderr in format, including its
+// error code (see pkg/errcode) so automation can branch on the failure.
+func writeError(stderr io.Writer, format string, command string, err error) {
+	if format == "json" {
+		json.NewEncoder(stderr).Encode(struct {
+			Command string `json:"command"`
+			errcode.Report
+		}{command, errcode.NewReport(err)})
+		return
+	}
+	fmt.Fprintf(stderr, "stack %s: %s\n", command, errcode.Format(err))
+}
+
+// newFlagSet creates the flag set of a command, binding the logger flags so
+// they may also follow the command.
+func newFlagSet(name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: stack %s [flags] %s\n\nflags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	logger.BindFlags(fs)
+	return fs
+}
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// renderFlags are the flags of the commands which load or render a component
+// tree.
+type renderFlags struct {
+	environments stringsFlag
+	gitCache     string
+	helmBinary   string
+	isolated     bool
+	capabilities string
+	templateEnv  stringsFlag
+
+	sandboxTimeout time.Duration
+	sandboxCPU     time.Duration
+	sandboxMemory  uint64
+}
+
+func (f *renderFlags) bind(fs *flag.FlagSet) {
+	fs.Var(&f.environments, "env", "environment whose config is applied after the common config. may be repeated")
+	fs.StringVar(&f.gitCache, "git-cache", "", "directory caching checkouts of git sources. defaults to a temporary directory")
+	fs.StringVar(&f.helmBinary, "helm", "", "helm binary to use. defaults to helm on $PATH")
+	fs.BoolVar(&f.isolated, "isolated", false, "run helm with a temporary config so the host helm config is neither used nor modified")
+	fs.StringVar(&f.capabilities, "capabilities", "", `cluster capabilities snapshot (see "stack capabilities") rendering charts as if for that cluster`)
+	fs.Var(&f.templateEnv, "template-env", "environment variable .gotmpl values files may read. may be repeated")
+	fs.DurationVar(&f.sandboxTimeout, "sandbox-timeout", 0, "kill helm if templating a chart takes longer. runs helm in a read-only working directory")
+	fs.DurationVar(&f.sandboxCPU, "sandbox-cpu", 0, "kill helm if templating a chart uses more CPU time (linux only). runs helm in a read-only working directory")
+	fs.Uint64Var(&f.sandboxMemory, "sandbox-memory", 0, "maximum memory of helm in MiB (linux only). runs helm in a read-only working directory")
+}
+
+// options configures the helm client and returns the RenderOptions of the
+// flags.
+func (f *renderFlags) options() component.RenderOptions {
+	if f.helmBinary != "" {
+		client := helm.CurrentClient()
+		client.HelmBinary = f.helmBinary
+		helm.SetClient(client)
+	}
+	opts := component.RenderOptions{Environments: f.environments}
+	opts.TemplateOptions.IsolatedConfig = f.isolated
+	opts.TemplateOptions.CapabilitiesFile = f.capabilities
+	opts.TemplateOptions.ValuesTemplate = valuesTemplate(f.templateEnv)
+	if f.sandboxTimeout > 0 || f.sandboxCPU > 0 || f.sandboxMemory > 0 {
+		opts.TemplateOptions.Sandbox = &helm.Sandbox{
+			Timeout:     f.sandboxTimeout,
+			CPUTime:     f.sandboxCPU,
+			MemoryBytes: f.sandboxMemory << 20,
+			ReadOnlyDir: true,
+		}
+	}
+	if f.gitCache != "" {
+		opts.GitCache = &component.GitCache{Dir: f.gitCache}
+	}
+	return opts
+}
+
+// valuesTemplate returns the data of values templates allowed to read the
+// environment variables names.
+func valuesTemplate(names []string) *helm.ValuesTemplateData {
+	data := &helm.ValuesTemplateData{Env: map[string]string{}}
+	for _, name := range names {
+		data.Env[name] = os.Getenv(name)
+	}
+	return data
+}
+
+// load parses the flags of fs and loads the component definition given as
+// its only argument.
+func load(fs *flag.FlagSet, args []string) (component.Component, error) {
+	if err := fs.Parse(args); err != nil {
+		return component.Component{}, err
+	}
+	path := "."
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		path = fs.Arg(0)
+	default:
+		return component.Component{}, fmt.Errorf(`expected a single component path, got %d`, fs.NArg())
+	}
+	logger.Debugf("loading component %s", path)
+	return component.Load(path)
+}
+
+// render renders c, returning its manifests in helm install order.
+func render(c component.Component, opts component.RenderOptions) ([]manifest.Manifest, error) {
+	rendered, err := component.Render(c, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rendered {
+		logger.Debugf("rendered %s: %d manifests", r.Path, len(r.Manifests))
+	}
+	return manifest.FromMaps(helm.SortByKind(component.Manifests(rendered))), nil
+}
+
+func generate(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("generate", "[path]")
+	flags.bind(fs)
+	output := fs.String("o", "-", `output path. "-" writes a single yaml stream to stdout`)
+	format := fs.String("format", string(manifest.OutputFile), "output format: file, directory or bundle")
+	provenancePath := fs.String("provenance", "", "write the source, version, digest and license of every chart pulled to this file as SLSA provenance")
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	opts := flags.options()
+	if *provenancePath != "" {
+		opts.TemplateOptions.Provenance = &helm.Provenance{BuilderID: "https://github.com/evanlouie/go/cmd/stack"}
+	}
+	manifests, err := render(c, opts)
+	if err != nil {
+		return err
+	}
+	content, err := manifest.Encode(manifests)
+	if err != nil {
+		return err
+	}
+	if *provenancePath != "" {
+		outputPath := *output
+		if outputPath == "-" {
+			outputPath = "manifests.yaml"
+		}
+		if err := opts.TemplateOptions.Provenance.WriteFile(*provenancePath, outputPath, content); err != nil {
+			return err
+		}
+	}
+
+	if *output == "-" {
+		_, err = stdout.Write(content)
+		return err
+	}
+	return manifest.WriteOutput(manifests, manifest.OutputSpec{Format: manifest.OutputFormat(*format), Path: *output})
+}
+
+func validate(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("validate", "[path]")
+	flags.bind(fs)
+	schemas := fs.String("schemas", "", `directory or URL of the JSON schemas the rendered manifests are validated against. "default" downloads the schemas of the built-in kinds`)
+	strict := fs.Bool("strict", false, "reject fields not declared by the schemas")
+	dryRun := fs.Bool("dry-run", false, "send the rendered manifests to the cluster of the current kubectl context as a server-side dry-run, reporting resources rejected by admission")
+	quotas := fs.String("quotas", "", `directory of ResourceQuota and LimitRange yaml files the render is simulated against, reporting projected usage. "cluster" uses those of the cluster, including their current usage`)
+	scan := fs.String("scan", "", "scan the container images of the render for known vulnerabilities with trivy or grype, failing on HIGH and CRITICAL vulnerabilities")
+	scanSeverity := fs.String("scan-severity", "", "lowest severity of the vulnerabilities reported by --scan. e.g: MEDIUM. defaults to all")
+	kubeContext := fs.String("context", "", "kubectl context of the cluster of --dry-run and --quotas")
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	opts := flags.options()
+	// walking loads and validates the definitions of nested components
+	count := 0
+	if err := component.Walk(c, opts, func(string, component.Component) error {
+		count++
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %d components valid\n", c.Name, count)
+	var scanner manifest.ImageScanner
+	switch *scan {
+	case "":
+	case "trivy":
+		scanner = manifest.TrivyScanner{Ctx: interrupted}
+	case "grype":
+		scanner = manifest.GrypeScanner{Ctx: interrupted}
+	default:
+		return fmt.Errorf(`unknown --scan "%s": expected trivy or grype`, *scan)
+	}
+	if *schemas == "" && !*dryRun && *quotas == "" && scanner == nil {
+		return nil
+	}
+	manifests, err := render(c, opts)
+	if err != nil {
+		return err
+	}
+
+	if *schemas != "" {
+		var source manifest.SchemaSource
+		switch {
+		case *schemas == "default":
+			source = &manifest.URLSchemaSource{BaseURL: manifest.DefaultSchemaURL}
+		case strings.HasPrefix(*schemas, "http://") || strings.HasPrefix(*schemas, "https://"):
+			source = &manifest.URLSchemaSource{BaseURL: *schemas}
+		default:
+			source = manifest.FSSchemaSource{FS: os.DirFS(*schemas)}
+		}
+		results, err := manifest.ValidateSchemas(manifests, manifest.SchemaOptions{Source: source, Strict: *strict})
+		if err != nil {
+			return err
+		}
+		counts := map[manifest.SchemaStatus]int{}
+		for _, result := range results {
+			counts[result.Status]++
+		}
+		for _, finding := range manifest.SchemaFindings(results) {
+			fmt.Fprintln(stdout, finding)
+		}
+		fmt.Fprintf(stdout, "%d manifests: %d valid, %d invalid, %d skipped\n", len(results), counts[manifest.SchemaValid], counts[manifest.SchemaInvalid], counts[manifest.SchemaSkipped])
+		if counts[manifest.SchemaInvalid] > 0 {
+			return fmt.Errorf(`%d manifests do not conform to their schema`, counts[manifest.SchemaInvalid])
+		}
+	}
+
+	if *dryRun {
+		results, err := manifest.DryRun(manifests, manifest.DryRunOptions{Context: *kubeContext, Ctx: interrupted})
+		if err != nil {
+			return err
+		}
+		counts := map[manifest.DryRunStatus]int{}
+		for _, result := range results {
+			counts[result.Status]++
+		}
+		for _, finding := range manifest.DryRunFindings(results) {
+			fmt.Fprintln(stdout, finding)
+		}
+		fmt.Fprintf(stdout, "%d manifests: %d accepted, %d rejected, %d skipped\n", len(results), counts[manifest.DryRunAccepted], counts[manifest.DryRunRejected], counts[manifest.DryRunSkipped])
+		if counts[manifest.DryRunRejected] > 0 {
+			return fmt.Errorf(`%d manifests were rejected by the cluster`, counts[manifest.DryRunRejected])
+		}
+	}
+
+	if *quotas != "" {
+		quotaOpts := manifest.QuotaOptions{IncludeUsed: *quotas == "cluster"}
+		if *quotas == "cluster" {
+			quotaOpts.Quotas, err = manifest.ClusterQuotas(manifest.DryRunOptions{Context: *kubeContext, Ctx: interrupted})
+		} else {
+			quotaOpts.Quotas, err = manifest.LoadDirectory(*quotas)
+		}
+		if err != nil {
+			return err
+		}
+		usages, err := manifest.SimulateQuotas(manifests, quotaOpts)
+		if err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			fmt.Fprintf(stdout, "%s/%s %s\n", usage.Quota.Namespace, usage.Quota.Name, usage)
+		}
+		violations := 0
+		for _, finding := range manifest.QuotaFindings(usages, 0.8) {
+			fmt.Fprintln(stdout, finding)
+			if finding.Severity == manifest.SeverityError {
+				violations++
+			}
+		}
+		if violations > 0 {
+			return fmt.Errorf(`%d quota violations`, violations)
+		}
+	}
+
+	if scanner != nil {
+		findings, err := manifest.ScanImages(manifests, manifest.ScanOptions{Scanner: scanner, MinSeverity: *scanSeverity})
+		if err != nil {
+			return err
+		}
+		vulnerable := 0
+		for _, finding := range findings {
+			fmt.Fprintln(stdout, finding)
+			if finding.Severity == manifest.SeverityError {
+				vulnerable++
+			}
+		}
+		if vulnerable > 0 {
+			return fmt.Errorf(`%d findings of HIGH or CRITICAL vulnerabilities in referenced images`, vulnerable)
+		}
+	}
+	return nil
+}
+
+func diff(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("diff", "--snapshot <dir> [path]")
+	flags.bind(fs)
+	snapshot := fs.String("snapshot", "", "directory of yaml files of a previous render to compare with (required)")
+	exitCode := fs.Bool("exit-code", false, "exit with 1 if the render differs from the snapshot")
+	unified := fs.Bool("unified", true, "print the unified diff of each changed resource")
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	if *snapshot == "" {
+		return fmt.Errorf(`--snapshot is required`)
+	}
+	manifests, err := render(c, flags.options())
+	if err != nil {
+		return err
+	}
+
+	changes, err := manifest.CompareWithSnapshot(*snapshot, manifests)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, changes.Summary())
+	if *unified {
+		content, err := changes.UnifiedDiff()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, content)
+	}
+	if *exitCode && !changes.Empty() {
+		return errChanges
+	}
+	return nil
+}
+
+func cost(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("cost", "--pricing <file> [path]")
+	flags.bind(fs)
+	pricingPath := fs.String("pricing", "", "yaml file of the monthly rates per core, GiB of memory and storage and load balancer (required)")
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	if *pricingPath == "" {
+		return fmt.Errorf(`--pricing is required`)
+	}
+	pricing, err := manifest.LoadPricing(*pricingPath)
+	if err != nil {
+		return err
+	}
+	manifests, err := render(c, flags.options())
+	if err != nil {
+		return err
+	}
+	estimates, err := manifest.EstimateCost(manifests, manifest.CostOptions{Pricing: pricing})
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, manifest.CostReport(estimates, pricing.Currency))
+	return nil
+}
+
+func sbom(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("sbom", "[path]")
+	flags.bind(fs)
+	format := fs.String("format", string(helm.SBOMCycloneDX), "document format: cyclonedx or spdx")
+	output := fs.String("o", "-", `output path. "-" writes to stdout`)
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	if f := helm.SBOMFormat(*format); f != helm.SBOMCycloneDX && f != helm.SBOMSPDX {
+		return fmt.Errorf(`unknown --format "%s": expected cyclonedx or spdx`, *format)
+	}
+	opts := flags.options()
+	provenance := &helm.Provenance{}
+	opts.TemplateOptions.Provenance = provenance
+	manifests, err := render(c, opts)
+	if err != nil {
+		return err
+	}
+	content, err := helm.SBOM(helm.SBOMOptions{
+		Format:    helm.SBOMFormat(*format),
+		Name:      c.Name,
+		Charts:    provenance.Charts(),
+		Manifests: manifests,
+	})
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+	if *output == "-" {
+		_, err = stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		return fmt.Errorf(`writing SBOM to %s: %w`, *output, err)
+	}
+	return nil
+}
+
+func state(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("state", "<state file>")
+	flags.bind(fs)
+	var selectors stringsFlag
+	fs.Var(&selectors, "l", `render only the releases matching this selector. e.g: "env=prod,tier!=frontend". repeatable: releases matching any selector are rendered`)
+	concurrency := fs.Int("concurrency", 4, "number of releases rendered in parallel")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf(`expected a single state file, got %d`, fs.NArg())
+	}
+	// state files have no component environments or git sources
+	if len(flags.environments) > 0 || flags.gitCache != "" {
+		return fmt.Errorf(`--env and --git-cache do not apply to state files`)
+	}
+	results, err := helm.TemplateState(fs.Arg(0), flags.options().TemplateOptions, selectors, *concurrency)
+	if err != nil {
+		return err
+	}
+	var rendered []map[string]interface{}
+	for _, result := range results {
+		logger.Debugf("rendered release %s: %d manifests", result.Release, len(result.Manifests))
+		rendered = append(rendered, result.Manifests...)
+	}
+	content, err := manifest.Encode(manifest.FromMaps(helm.SortByKind(rendered)))
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(content)
+	return err
+}
+
+func vendor(args []string, stdout io.Writer) error {
+	var flags renderFlags
+	fs := newFlagSet("vendor", "[path]")
+	flags.bind(fs)
+	into := fs.String("o", "charts", "directory the charts are pulled into, as <dir>/<component path>/<chart>")
+	archives := fs.Bool("archives", false, "write chart archives (.tgz) as <dir>/<component path>/<chart>-<version>.tgz instead of extracting them")
+	c, err := load(fs, args)
+	if err != nil {
+		return err
+	}
+	opts := flags.options()
+	return component.Walk(c, opts, func(path string, c component.Component) error {
+		if c.ResolvedType() != component.TypeHelm || (c.Repo == "" && !helm.IsOCI(c.Chart)) {
+			return nil // local and host repository charts are not vendored
+		}
+		dir := filepath.Join(*into, filepath.FromSlash(path))
+		logger.Debugf("pulling chart %s of %s into %s", c.Chart, path, dir)
+		pullOpts := helm.PullOptions{
+			RepoURL:        c.Repo,
+			Chart:          c.Chart,
+			Version:        c.Version,
+			Into:           dir,
+			IsolatedConfig: opts.TemplateOptions.IsolatedConfig,
+		}
+		if *archives {
+			archive, err := helm.PullArchiveWithOptions(pullOpts)
+			if err != nil {
+				return fmt.Errorf(`vendoring component %s: %w`, path, err)
+			}
+			fmt.Fprintf(stdout, "%s: %s sha256:%s\n", path, archive.Path, archive.Digest)
+			return nil
+		}
+		if err := helm.PullWithOptions(pullOpts); err != nil {
+			return fmt.Errorf(`vendoring component %s: %w`, path, err)
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", path, dir)
+		return nil
+	})
+}
+
+func installHelm(args []string, stdout io.Writer) error {
+	fs := newFlagSet("install-helm", "")
+	force := fs.Bool("force", false, "download the latest release even if helm 3 is on $PATH")
+	dir := fs.String("dir", "", "install into this directory, which may be shared between processes, instead of a temporary file. an existing install is reused")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	get := install.GetHelm
+	if *dir != "" {
+		get = func() (string, error) { return install.InstallTo(*dir) }
+	} else if *force {
+		get = install.Install
+	}
+	path, err := get()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, path)
+	return nil
+}
+
+func capabilities(args []string, stdout io.Writer) error {
+	fs := newFlagSet("capabilities", "")
+	kubeconfig := fs.String("kubeconfig", "", "kubeconfig of the cluster. defaults to the kubectl configuration")
+	output := fs.String("o", "capabilities.yaml", "path the snapshot is written to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	snapshot, err := helm.SnapshotCapabilities(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.WriteFile(*output); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: kubernetes %s, %d api versions\n", *output, snapshot.KubeVersion, len(snapshot.APIVersions))
+	return nil
+}
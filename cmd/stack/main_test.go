@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"component.yaml":            "name: stack\nsubcomponents:\n  - name: config\n    path: manifests\n",
+		"manifests/configmap.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: web\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\n",
+		"snapshot/all.yaml":         "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\n",
+		"helmfile.yaml":             "releases:\n  - name: web\n    chart: charts/web\n    labels:\n      env: prod\n",
+		"schemas/configmap-v1.json": `{"type": "object", "properties": {"apiVersion": {"type": "string"}, "kind": {"type": "string"}, "metadata": {"type": "object"}, "data": {"type": "object"}}, "additionalProperties": false}`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "no-command",
+			wantCode:   2,
+			wantStderr: "usage: stack",
+		},
+		{
+			name:       "unknown-command",
+			args:       []string{"deploy"},
+			wantCode:   2,
+			wantStderr: `unknown command "deploy"`,
+		},
+		{
+			name:       "generate",
+			args:       []string{"--debug", "generate", dir},
+			wantStdout: "kind: Namespace\nmetadata:\n    name: web\n---\napiVersion: v1\nkind: ConfigMap\n",
+		},
+		{
+			name:       "validate",
+			args:       []string{"validate", dir},
+			wantStdout: "stack: 2 components valid\n",
+		},
+		{
+			name:       "validate-schemas",
+			args:       []string{"validate", "--schemas", filepath.Join(dir, "schemas"), dir},
+			wantStdout: "2 manifests: 1 valid, 0 invalid, 1 skipped\n",
+		},
+		{
+			name:       "diff",
+			args:       []string{"diff", "--snapshot", filepath.Join(dir, "snapshot"), "--exit-code", dir},
+			wantCode:   1,
+			wantStdout: "1 added, 0 changed, 0 removed\n+ v1, Kind=ConfigMap web/config\n",
+		},
+		{
+			name:       "sbom",
+			args:       []string{"sbom", "--format", "spdx", dir},
+			wantStdout: `"spdxVersion": "SPDX-2.2"`,
+		},
+		{
+			name:       "sbom-unknown-format",
+			args:       []string{"sbom", "--format", "swid", dir},
+			wantCode:   1,
+			wantStderr: `stack sbom: unknown --format "swid"`,
+		},
+		{
+			name:       "sbom-unknown-format-json",
+			args:       []string{"--error-format", "json", "sbom", "--format", "swid", dir},
+			wantCode:   1,
+			wantStderr: `{"command":"sbom","message":"unknown --format \"swid\": expected cyclonedx or spdx"}`,
+		},
+		{
+			name: "state-no-releases-selected",
+			args: []string{"state", "-l", "env=dev", filepath.Join(dir, "helmfile.yaml")},
+		},
+		{
+			name: "state-render-flags",
+			args: []string{"state", "--isolated", "--sandbox-timeout", "1m", "-l", "env=dev", filepath.Join(dir, "helmfile.yaml")},
+		},
+		{
+			name:       "state-component-flags",
+			args:       []string{"state", "--env", "prod", filepath.Join(dir, "helmfile.yaml")},
+			wantCode:   1,
+			wantStderr: "stack state: --env and --git-cache do not apply to state files",
+		},
+		{
+			name:       "state-invalid-selector",
+			args:       []string{"state", "-l", "env", filepath.Join(dir, "helmfile.yaml")},
+			wantCode:   1,
+			wantStderr: `stack state: parsing selector "env"`,
+		},
+		{
+			name:       "diff-missing-snapshot",
+			args:       []string{"diff", dir},
+			wantCode:   1,
+			wantStderr: "stack diff: --snapshot is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run() = %d, want %d. stderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("run() stdout = %q, want it to contain %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Package cache defines the Cache interface used to cache chart archives,
+// repository indexes and rendered templates, with in-memory and filesystem
+// implementations. Shared backends (e.g. Redis or S3, so CI runners share a
+// cache) implement Cache directly or adapt a client with Funcs, and can be
+// layered behind a local cache with Layered.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMiss is returned by Cache.Get when a key is not cached or has expired.
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores values by key. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value of key or an error wrapping ErrMiss if it is not
+	// cached.
+	Get(key string) ([]byte, error)
+	// Set stores value as key, expiring after ttl. A ttl of 0 never expires.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a key which is not cached is not an error.
+	Delete(key string) error
+}
+
+// Key returns a key identifying parts, safe for use as a file name or object
+// key: the hex encoded sha256 of the parts.
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// now is the clock of Memory and Dir, replaced in tests.
+var now = time.Now
+
+// expiry returns when a value set with ttl expires. The zero time never
+// expires.
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now().Add(ttl)
+}
+
+// expired determines if a value expiring at expires has expired.
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && !now().Before(expires)
+}
+
+// Memory is an in-memory Cache. The zero value is ready to use.
+type Memory struct {
+	lock    sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Get returns the value of key.
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok || expired(entry.expires) {
+		return nil, fmt.Errorf(`getting %s: %w`, key, ErrMiss)
+	}
+	return append([]byte{}, entry.value...), nil
+}
+
+// Set stores value as key.
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.entries == nil {
+		m.entries = map[string]memoryEntry{}
+	}
+	m.entries[key] = memoryEntry{value: append([]byte{}, value...), expires: expiry(ttl)}
+	return nil
+}
+
+// Delete removes key.
+func (m *Memory) Delete(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Dir is a Cache storing values as files in a directory, so the cache
+// persists across processes (e.g. in a CI cache directory restored between
+// jobs). Files are named by the Key of their key and written atomically, so
+// concurrent processes may share a Dir.
+type Dir struct {
+	Path string
+}
+
+// DefaultDir returns a Dir in the user cache directory (e.g.
+// ~/.cache/evanlouie/cache on Linux).
+func DefaultDir() (*Dir, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf(`determining user cache directory: %w`, err)
+	}
+	return &Dir{Path: filepath.Join(cacheDir, "evanlouie", "cache")}, nil
+}
+
+// path returns the path of the file of key.
+func (d *Dir) path(key string) string {
+	return filepath.Join(d.Path, Key(key))
+}
+
+// Get returns the value of key. Expired files are removed.
+func (d *Dir) Get(key string) ([]byte, error) {
+	content, err := os.ReadFile(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`getting %s: %w`, key, ErrMiss)
+	} else if err != nil {
+		return nil, fmt.Errorf(`getting %s: %w`, key, err)
+	}
+	// files are prefixed with the unix nanoseconds they expire at. 0 never expires
+	if len(content) < 8 {
+		return nil, fmt.Errorf(`getting %s: %w: truncated file %s`, key, ErrMiss, d.path(key))
+	}
+	var expires time.Time
+	if nanos := int64(binary.BigEndian.Uint64(content[:8])); nanos != 0 {
+		expires = time.Unix(0, nanos)
+	}
+	if expired(expires) {
+		_ = os.Remove(d.path(key))
+		return nil, fmt.Errorf(`getting %s: %w`, key, ErrMiss)
+	}
+	return content[8:], nil
+}
+
+// Set stores value as key.
+func (d *Dir) Set(key string, value []byte, ttl time.Duration) error {
+	if err := os.MkdirAll(d.Path, 0755); err != nil {
+		return fmt.Errorf(`creating cache directory %s: %w`, d.Path, err)
+	}
+	header := make([]byte, 8)
+	if expires := expiry(ttl); !expires.IsZero() {
+		binary.BigEndian.PutUint64(header, uint64(expires.UnixNano()))
+	}
+
+	// write to a temporary file and rename it into place so readers never
+	// observe a partially written file
+	tmp, err := os.CreateTemp(d.Path, ".set-")
+	if err != nil {
+		return fmt.Errorf(`setting %s: %w`, key, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(header, value...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf(`setting %s: %w`, key, err)
+	}
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		return fmt.Errorf(`setting %s: %w`, key, err)
+	}
+	return nil
+}
+
+// Delete removes key.
+func (d *Dir) Delete(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(`deleting %s: %w`, key, err)
+	}
+	return nil
+}
+
+// Purge removes all values from the cache.
+func (d *Dir) Purge() error {
+	if err := os.RemoveAll(d.Path); err != nil {
+		return fmt.Errorf(`purging cache %s: %w`, d.Path, err)
+	}
+	return nil
+}
+
+// Funcs adapts functions into a Cache, so a shared backend can be plugged in
+// without declaring a type. e.g. with a Redis client:
+//   shared := cache.Funcs{
+//     GetFunc: func(key string) ([]byte, error) {
+//       value, err := rdb.Get(ctx, key).Bytes()
+//       if errors.Is(err, redis.Nil) {
+//         return nil, cache.ErrMiss
+//       }
+//       return value, err
+//     },
+//     SetFunc: func(key string, value []byte, ttl time.Duration) error {
+//       return rdb.Set(ctx, key, value, ttl).Err()
+//     },
+//   }
+// A nil GetFunc always misses and a nil SetFunc or DeleteFunc does nothing,
+// so read-only or write-only caches only set the functions they support.
+type Funcs struct {
+	GetFunc    func(key string) ([]byte, error)
+	SetFunc    func(key string, value []byte, ttl time.Duration) error
+	DeleteFunc func(key string) error
+}
+
+// Get calls GetFunc.
+func (f Funcs) Get(key string) ([]byte, error) {
+	if f.GetFunc == nil {
+		return nil, fmt.Errorf(`getting %s: %w`, key, ErrMiss)
+	}
+	return f.GetFunc(key)
+}
+
+// Set calls SetFunc.
+func (f Funcs) Set(key string, value []byte, ttl time.Duration) error {
+	if f.SetFunc == nil {
+		return nil
+	}
+	return f.SetFunc(key, value, ttl)
+}
+
+// Delete calls DeleteFunc.
+func (f Funcs) Delete(key string) error {
+	if f.DeleteFunc == nil {
+		return nil
+	}
+	return f.DeleteFunc(key)
+}
+
+// Layered is a Cache checking each of its caches in order, typically a fast
+// local cache in front of a shared one:
+//   cache.Layered{&cache.Memory{}, &cache.Dir{Path: ".cache"}, shared}
+// A value found in a later cache is copied into the caches before it, without
+// an expiry as the ttl it was set with is unknown. Set and Delete apply to
+// every cache.
+type Layered []Cache
+
+// Get returns the value of key from the first cache containing it.
+func (l Layered) Get(key string) ([]byte, error) {
+	for idx, c := range l {
+		value, err := c.Get(key)
+		if errors.Is(err, ErrMiss) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, earlier := range l[:idx] {
+			if err := earlier.Set(key, value, 0); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf(`getting %s: %w`, key, ErrMiss)
+}
+
+// Set stores value as key in every cache.
+func (l Layered) Set(key string, value []byte, ttl time.Duration) error {
+	for _, c := range l {
+		if err := c.Set(key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes key from every cache.
+func (l Layered) Delete(key string) error {
+	for _, c := range l {
+		if err := c.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
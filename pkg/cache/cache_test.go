@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name  string
+		cache Cache
+	}{
+		{"memory", &Memory{}},
+		{"dir", &Dir{Path: filepath.Join(t.TempDir(), "cache")}},
+		{"layered", Layered{&Memory{}, &Dir{Path: t.TempDir()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock = start
+			if _, err := tt.cache.Get("missing"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() missing error = %v, want ErrMiss", err)
+			}
+
+			if err := tt.cache.Set("forever", []byte("a"), 0); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if err := tt.cache.Set("hour", []byte("b"), time.Hour); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			for key, want := range map[string]string{"forever": "a", "hour": "b"} {
+				if got, err := tt.cache.Get(key); err != nil || string(got) != want {
+					t.Errorf("Get(%s) = %s, %v, want %s", key, got, err, want)
+				}
+			}
+
+			clock = start.Add(time.Hour)
+			if _, err := tt.cache.Get("hour"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() expired error = %v, want ErrMiss", err)
+			}
+			if err := tt.cache.Delete("forever"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err := tt.cache.Get("forever"); !errors.Is(err, ErrMiss) {
+				t.Errorf("Get() deleted error = %v, want ErrMiss", err)
+			}
+			if err := tt.cache.Delete("forever"); err != nil {
+				t.Errorf("Delete() missing error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLayered_Get(t *testing.T) {
+	local := &Memory{}
+	var sets []string
+	shared := Funcs{
+		GetFunc: func(key string) ([]byte, error) {
+			if key == "chart" {
+				return []byte("archive"), nil
+			}
+			return nil, ErrMiss
+		},
+		SetFunc: func(key string, value []byte, ttl time.Duration) error {
+			sets = append(sets, key)
+			return nil
+		},
+	}
+	layered := Layered{local, shared}
+
+	got, err := layered.Get("chart")
+	if err != nil || string(got) != "archive" {
+		t.Fatalf("Get() = %s, %v, want archive", got, err)
+	}
+	if got, err := local.Get("chart"); err != nil || string(got) != "archive" {
+		t.Errorf("Get() did not copy the value into the local cache: %s, %v", got, err)
+	}
+	if _, err := layered.Get("missing"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get() missing error = %v, want ErrMiss", err)
+	}
+
+	if err := layered.Set("index", []byte("entries"), time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !reflect.DeepEqual(sets, []string{"index"}) {
+		t.Errorf("Set() shared sets = %v, want [index]", sets)
+	}
+	if err := (Funcs{}).Delete("index"); err != nil {
+		t.Errorf("Funcs.Delete() without DeleteFunc error = %v", err)
+	}
+}
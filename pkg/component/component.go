@@ -0,0 +1,184 @@
+// Package component loads declarative component definitions describing a
+// tree of helm charts, static manifests and nested components, and renders
+// the whole tree into Kubernetes manifests; a fabrikate-style stack
+// generator built on pkg/helm.
+package component
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the name of the component definition loaded when Load is
+// given a directory.
+const DefaultFileName = "component.yaml"
+
+// Type determines how a Component is rendered.
+type Type string
+
+const (
+	TypeHelm      Type = "helm"      // a helm chart templated via helm.TemplateWithCRDs
+	TypeStatic    Type = "static"    // a directory of yaml manifests outputted as-is
+	TypeComponent Type = "component" // only renders its subcomponents. when Path or Source is set, the component definition at Path (of the Source repository) is rendered in its place, followed by its subcomponents
+)
+
+// Component is a declarative description of a unit of a stack. e.g:
+//   name: my-stack
+//   subcomponents:
+//     - name: ingress-nginx
+//       repo: https://kubernetes.github.io/ingress-nginx
+//       chart: ingress-nginx
+//       version: 4.0.6
+//       namespace: ingress
+//       values:
+//         controller:
+//           replicaCount: 2
+//     - name: dashboards
+//       type: static
+//       path: ./manifests/dashboards
+//     - name: observability
+//       source: https://github.com/my-org/observability-stack.git
+//       ref: v1.4.0
+//       path: stacks/prometheus
+type Component struct {
+	Name string `yaml:"name"`
+	Type Type   `yaml:"type,omitempty"` // defaults to TypeHelm when Chart is set, TypeComponent when Source is set, TypeStatic when Path is set, TypeComponent otherwise
+
+	Chart       string                 `yaml:"chart,omitempty"`       // chart name in Repo, oci:// reference or path to a local chart relative to the component definition
+	Repo        string                 `yaml:"repo,omitempty"`        // chart repository URL
+	Version     string                 `yaml:"version,omitempty"`     // chart version
+	Release     string                 `yaml:"release,omitempty"`     // helm release name. defaults to Name
+	Namespace   string                 `yaml:"namespace,omitempty"`   // namespace the chart is templated into
+	Values      map[string]interface{} `yaml:"values,omitempty"`      // chart values
+	ValuesFiles []string               `yaml:"valuesFiles,omitempty"` // chart values files relative to the component definition, applied before Values. files ending in .gotmpl are templates of the release (see helm.ValuesTemplateData)
+
+	Path string `yaml:"path,omitempty"` // directory of manifests for TypeStatic or of a component definition for TypeComponent, relative to the component definition, or to the root of Source when set
+
+	Source string `yaml:"source,omitempty"` // URL of a git repository hosting the component definition of a TypeComponent
+	Ref    string `yaml:"ref,omitempty"`    // branch, tag or commit of Source. defaults to the default branch
+
+	Subcomponents []Component `yaml:"subcomponents,omitempty"`
+
+	dir string // directory of the definition the component was loaded from. relative paths are resolved against it
+}
+
+// Load reads the component definition at path, which may be a yaml file or a
+// directory containing a component.yaml. Relative paths of the component and
+// its subcomponents are resolved against the directory of the definition.
+// Unknown fields are rejected to catch typos.
+func Load(path string) (Component, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Component{}, fmt.Errorf(`loading component %s: %w`, path, err)
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, DefaultFileName)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Component{}, fmt.Errorf(`loading component %s: %w`, path, err)
+	}
+	c, err := Parse(content)
+	if err != nil {
+		return Component{}, fmt.Errorf(`loading component %s: %w`, path, err)
+	}
+	c.setDir(filepath.Dir(path))
+	return c, nil
+}
+
+// Parse decodes and validates a component definition. Relative paths are
+// resolved against the working directory; see Load to resolve them against
+// the location of the definition.
+func Parse(content []byte) (Component, error) {
+	var c Component
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil {
+		return Component{}, fmt.Errorf(`parsing component definition: %w`, err)
+	}
+	if err := c.Validate(); err != nil {
+		return Component{}, err
+	}
+	return c, nil
+}
+
+// Validate checks that the component and its subcomponents are named
+// uniquely amongst their siblings and have the fields required by their type.
+func (c Component) Validate() error {
+	return c.validate(c.Name)
+}
+
+func (c Component) validate(path string) error {
+	if c.Name == "" {
+		return fmt.Errorf(`component %s: name is required`, path)
+	}
+	if c.Ref != "" && c.Source == "" {
+		return fmt.Errorf(`component %s: ref requires source`, path)
+	}
+	if c.Source != "" && c.ResolvedType() != TypeComponent {
+		return fmt.Errorf(`component %s: source is only supported for type %s`, path, TypeComponent)
+	}
+	switch c.ResolvedType() {
+	case TypeHelm:
+		if c.Chart == "" {
+			return fmt.Errorf(`component %s: chart is required for type %s`, path, TypeHelm)
+		}
+	case TypeStatic:
+		if c.Path == "" {
+			return fmt.Errorf(`component %s: path is required for type %s`, path, TypeStatic)
+		}
+	case TypeComponent:
+	default:
+		return fmt.Errorf(`component %s: unknown type "%s"`, path, c.Type)
+	}
+
+	names := map[string]bool{}
+	for _, sub := range c.Subcomponents {
+		if names[sub.Name] {
+			return fmt.Errorf(`component %s: duplicate subcomponent "%s"`, path, sub.Name)
+		}
+		names[sub.Name] = true
+		if err := sub.validate(path + "/" + sub.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolvedType returns the Type of the component, inferring it from the set
+// fields when not explicitly set.
+func (c Component) ResolvedType() Type {
+	switch {
+	case c.Type != "":
+		return c.Type
+	case c.Chart != "":
+		return TypeHelm
+	case c.Source != "":
+		return TypeComponent
+	case c.Path != "":
+		return TypeStatic
+	default:
+		return TypeComponent
+	}
+}
+
+// setDir sets the directory relative paths of c and its subcomponents are
+// resolved against.
+func (c *Component) setDir(dir string) {
+	c.dir = dir
+	for idx := range c.Subcomponents {
+		c.Subcomponents[idx].setDir(dir)
+	}
+}
+
+// resolve returns path relative to the directory of the component definition.
+func (c Component) resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
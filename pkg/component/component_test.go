@@ -0,0 +1,213 @@
+package component
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm"
+)
+
+// writeFiles writes files (relative path to content) to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		want       Component
+		wantErr    string
+	}{
+		{
+			name: "tree",
+			definition: `
+name: stack
+subcomponents:
+  - name: nginx
+    repo: https://charts.example.com
+    chart: nginx
+    version: 1.0.0
+    values:
+      replicaCount: 2
+  - name: dashboards
+    path: ./dashboards
+`,
+			want: Component{
+				Name: "stack",
+				Subcomponents: []Component{
+					{Name: "nginx", Repo: "https://charts.example.com", Chart: "nginx", Version: "1.0.0", Values: map[string]interface{}{"replicaCount": 2}},
+					{Name: "dashboards", Path: "./dashboards"},
+				},
+			},
+		},
+		{
+			name:       "missing-name",
+			definition: "chart: nginx",
+			wantErr:    "name is required",
+		},
+		{
+			name:       "missing-chart",
+			definition: "name: nginx\ntype: helm",
+			wantErr:    "chart is required",
+		},
+		{
+			name:       "unknown-type",
+			definition: "name: nginx\ntype: kustomize",
+			wantErr:    `unknown type "kustomize"`,
+		},
+		{
+			name:       "duplicate-subcomponent",
+			definition: "name: stack\nsubcomponents:\n  - name: a\n  - name: a",
+			wantErr:    `component stack: duplicate subcomponent "a"`,
+		},
+		{
+			name:       "ref-without-source",
+			definition: "name: stack\nref: v1.0.0",
+			wantErr:    "ref requires source",
+		},
+		{
+			name:       "source-of-helm-component",
+			definition: "name: nginx\nchart: nginx\nsource: https://git.example.com/stack.git",
+			wantErr:    "source is only supported for type component",
+		},
+		{
+			name:       "unknown-field",
+			definition: "name: stack\nchrt: nginx",
+			wantErr:    "field chrt not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.definition))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"component.yaml": `
+name: stack
+subcomponents:
+  - name: config
+    path: manifests
+  - name: monitoring
+    path: monitoring
+    type: component
+`,
+		"manifests/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+		"monitoring/component.yaml": `
+name: monitoring
+subcomponents:
+  - name: dashboards
+    path: dashboards
+`,
+		"monitoring/dashboards/a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: dashboard-a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: dashboard-b\n",
+	})
+
+	root, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	rendered, err := Render(root, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	var paths []string
+	for _, r := range rendered {
+		paths = append(paths, r.Path)
+	}
+	wantPaths := []string{"stack", "stack/config", "stack/monitoring", "stack/monitoring/dashboards"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Render() paths = %v, want %v", paths, wantPaths)
+	}
+
+	var names []string
+	for _, m := range Manifests(rendered) {
+		names = append(names, m["metadata"].(map[string]interface{})["name"].(string))
+	}
+	if want := []string{"config", "dashboard-a", "dashboard-b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Manifests() names = %v, want %v", names, want)
+	}
+}
+
+func TestComponent_templateOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"charts/local/Chart.yaml": "name: local\n"})
+	base := helm.TemplateOptions{Values: []string{"/base.yaml"}, IsolatedConfig: true}
+
+	tests := []struct {
+		name      string
+		component Component
+		want      helm.TemplateOptions
+	}{
+		{
+			name: "repo-chart",
+			component: Component{
+				Name: "nginx", Repo: "https://charts.example.com", Chart: "nginx", Version: "1.0.0", Namespace: "web",
+				ValuesFiles: []string{"values/nginx.yaml"},
+				Values:      map[string]interface{}{"replicaCount": 2},
+				dir:         dir,
+			},
+			want: helm.TemplateOptions{
+				Release: "nginx", Repo: "https://charts.example.com", Chart: "nginx", Version: "1.0.0", Namespace: "web",
+				Values:         []string{"/base.yaml", filepath.Join(dir, "values/nginx.yaml")},
+				ValuesMap:      []map[string]interface{}{{"replicaCount": 2}},
+				IsolatedConfig: true,
+			},
+		},
+		{
+			name:      "local-chart",
+			component: Component{Name: "local", Release: "my-release", Chart: "charts/local", dir: dir},
+			want: helm.TemplateOptions{
+				Release: "my-release", Chart: filepath.Join(dir, "charts/local"),
+				Values:         []string{"/base.yaml"},
+				ValuesMap:      []map[string]interface{}{},
+				IsolatedConfig: true,
+			},
+		},
+		{
+			name:      "host-repo-chart",
+			component: Component{Name: "nginx", Chart: "bitnami/nginx", dir: dir},
+			want: helm.TemplateOptions{
+				Release: "nginx", Chart: "bitnami/nginx",
+				Values:         []string{"/base.yaml"},
+				ValuesMap:      []map[string]interface{}{},
+				IsolatedConfig: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.component.templateOptions(base); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Component.templateOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
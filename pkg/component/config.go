@@ -0,0 +1,95 @@
+package component
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigDir is the directory, next to a component definition, holding the
+// config files of each environment (e.g. config/common.yaml, config/prod.yaml).
+const ConfigDir = "config"
+
+// CommonEnvironment is the environment whose config is applied to every
+// render, before the config of the environments of RenderOptions.
+const CommonEnvironment = "common"
+
+// Config is the configuration of a component and its subcomponents for an
+// environment. e.g. config/prod.yaml next to the definition of my-stack:
+//   values:
+//     global:
+//       domain: example.com
+//   subcomponents:
+//     ingress-nginx:
+//       values:
+//         controller:
+//           replicaCount: 3
+// Config of a component overrides the config of its subcomponents' own
+// definitions, so a stack can tune the components it composes.
+type Config struct {
+	Values        map[string]interface{} `yaml:"values,omitempty"`        // merged into the Values of the component
+	Subcomponents map[string]Config      `yaml:"subcomponents,omitempty"` // config of the subcomponents, by name
+}
+
+// LoadConfig reads the config of the component definition in dir for
+// environments. The common config is applied first, followed by each
+// environment in order. Missing config files are skipped, so components only
+// need config for the environments they differ in.
+func LoadConfig(dir string, environments ...string) (Config, error) {
+	var config Config
+	for _, environment := range append([]string{CommonEnvironment}, environments...) {
+		path := filepath.Join(dir, ConfigDir, environment+".yaml")
+		content, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf(`loading config %s: %w`, path, err)
+		}
+		var environmentConfig Config
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&environmentConfig); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf(`loading config %s: %w`, path, err)
+		}
+		config = config.Merge(environmentConfig)
+	}
+	return config, nil
+}
+
+// Merge deep merges override into c, with the values of override taking
+// precedence, using the semantics of yaml.Merge.
+func (c Config) Merge(override Config) Config {
+	merged := Config{}
+	if c.Values != nil || override.Values != nil {
+		merged.Values = yamlPlus.Merge(c.Values, override.Values)
+	}
+	for name, sub := range c.Subcomponents {
+		if merged.Subcomponents == nil {
+			merged.Subcomponents = map[string]Config{}
+		}
+		merged.Subcomponents[name] = sub
+	}
+	for name, sub := range override.Subcomponents {
+		if merged.Subcomponents == nil {
+			merged.Subcomponents = map[string]Config{}
+		}
+		merged.Subcomponents[name] = merged.Subcomponents[name].Merge(sub)
+	}
+	return merged
+}
+
+// loadConfig reads the config of a component loaded from a definition file.
+// Components which were not loaded via Load have no config.
+func (c Component) loadConfig(environments []string) (Config, error) {
+	if c.dir == "" {
+		return Config{}, nil
+	}
+	return LoadConfig(c.dir, environments...)
+}
@@ -0,0 +1,116 @@
+package component
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRender_config(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"component.yaml": `
+name: stack
+subcomponents:
+  - name: app
+    path: app
+    type: component
+`,
+		"config/common.yaml": `
+subcomponents:
+  app:
+    values:
+      replicas: 2
+    subcomponents:
+      web:
+        values:
+          image:
+            tag: common
+`,
+		"config/prod.yaml": `
+subcomponents:
+  app:
+    subcomponents:
+      web:
+        values:
+          image:
+            tag: prod
+`,
+		"app/component.yaml": `
+name: app
+subcomponents:
+  - name: web
+    path: manifests
+    values:
+      image:
+        repository: nginx
+        tag: latest
+`,
+		"app/config/common.yaml": `
+subcomponents:
+  web:
+    values:
+      image:
+        tag: app
+      port: 80
+`,
+		"app/config/prod.yaml":         "values:\n  debug: false\n",
+		"app/manifests/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+	})
+	root, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		environments []string
+		want         map[string]map[string]interface{}
+	}{
+		{
+			name: "common",
+			want: map[string]map[string]interface{}{
+				"stack":     {},
+				"stack/app": {"replicas": 2},
+				"stack/app/web": {
+					"image": map[string]interface{}{"repository": "nginx", "tag": "common"},
+					"port":  80,
+				},
+			},
+		},
+		{
+			name:         "prod",
+			environments: []string{"prod"},
+			want: map[string]map[string]interface{}{
+				"stack":     {},
+				"stack/app": {"replicas": 2, "debug": false},
+				"stack/app/web": {
+					"image": map[string]interface{}{"repository": "nginx", "tag": "prod"},
+					"port":  80,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := Render(root, RenderOptions{Environments: tt.environments})
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			got := map[string]map[string]interface{}{}
+			for _, r := range rendered {
+				got[r.Path] = r.Values
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Render() values = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_unknownField(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config/common.yaml": "valeus:\n  a: 1\n"})
+	if _, err := LoadConfig(dir); err == nil {
+		t.Errorf("LoadConfig() error = nil, want unknown field error")
+	}
+}
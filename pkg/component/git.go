@@ -0,0 +1,11 @@
+package component
+
+import (
+	"github.com/evanlouie/go/pkg/helm"
+)
+
+// GitCache is an on-disk cache of checkouts of the git repositories hosting
+// remote components, so repeated renders do not re-fetch them. It is the
+// cache of charts sourced from git (see helm.TemplateOptions.GitURL), so a
+// single cache may serve both; use helm.DefaultGitCache for the default one.
+type GitCache = helm.GitCache
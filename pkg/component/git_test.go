@@ -0,0 +1,113 @@
+package component
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// git runs a git command in dir.
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf(`running "git %s": %w: %s`, strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// gitRepo creates a git repository containing files, tagged v1.0.0.
+func gitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+		{"tag", "v1.0.0"},
+	} {
+		if err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRender_gitSource(t *testing.T) {
+	remote := gitRepo(t, map[string]string{
+		"stacks/observability/component.yaml": `
+name: observability
+subcomponents:
+  - name: dashboards
+    path: dashboards
+`,
+		"stacks/observability/dashboards/a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: dashboard-a\n",
+	})
+	cache := &GitCache{Dir: t.TempDir()}
+
+	root, err := Parse([]byte(`
+name: stack
+subcomponents:
+  - name: observability
+    source: ` + remote + `
+    ref: v1.0.0
+    path: stacks/observability
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	rendered, err := Render(root, RenderOptions{GitCache: cache})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	var paths []string
+	for _, r := range rendered {
+		paths = append(paths, r.Path)
+	}
+	if want := []string{"stack", "stack/observability", "stack/observability/dashboards"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Render() paths = %v, want %v", paths, want)
+	}
+	if manifests := Manifests(rendered); len(manifests) != 1 {
+		t.Errorf("Manifests() = %v, want 1 manifest", manifests)
+	}
+
+	// pinned refs are rendered from the cache without fetching
+	if err := os.RemoveAll(remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Render(root, RenderOptions{GitCache: cache}); err != nil {
+		t.Errorf("Render() from cache error = %v", err)
+	}
+	if err := cache.Remove(remote, "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Render(root, RenderOptions{GitCache: cache}); err == nil {
+		t.Errorf("Render() of removed repository succeeded, want error")
+	}
+}
+
+func TestRender_gitSourceCycle(t *testing.T) {
+	remote := gitRepo(t, map[string]string{"README.md": "# cyclic\n"})
+	writeFiles(t, remote, map[string]string{"component.yaml": "name: cyclic\nsource: " + remote + "\n"})
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "cycle"},
+	} {
+		if err := git(remote, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := Component{Name: "stack", Source: remote}
+	_, err := Render(root, RenderOptions{})
+	if err == nil || !strings.Contains(err.Error(), "cyclic source") {
+		t.Errorf("Render() error = %v, want cyclic source error", err)
+	}
+}
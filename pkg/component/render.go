@@ -0,0 +1,238 @@
+package component
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/evanlouie/go/pkg/helm"
+	"github.com/evanlouie/go/pkg/manifest"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// RenderOptions configure Render.
+type RenderOptions struct {
+	// TemplateOptions are the base options of every helm component (e.g.
+	// Credentials, ChartCache or IsolatedConfig). The chart, release,
+	// namespace and values are set from the component.
+	TemplateOptions helm.TemplateOptions
+
+	// Environments are the environments whose config (see LoadConfig) is
+	// applied, in order, after the common config. e.g: ["prod"]
+	Environments []string
+
+	// GitCache caches the checkouts of components with a Source. When nil,
+	// repositories are fetched into a temporary directory on every Render.
+	GitCache *GitCache
+}
+
+// Rendered is the output of a single component of a rendered tree.
+type Rendered struct {
+	Path      string // names of the component and its ancestors joined with "/". e.g: "my-stack/ingress-nginx"
+	Component Component
+	Values    map[string]interface{} // effective values of the component: its Values merged with the config of its definition and ancestors
+	Version   string                 // chart version of helm components. the exact version a Version constraint resolved to when TemplateOptions.ResolveVersion is set
+	Manifests []map[string]interface{}
+}
+
+// Render renders c and its subcomponents depth-first, returning the output of
+// each component in order: a component is followed by its subcomponents.
+// Components with a Source are fetched from their git repository and
+// rendered recursively, so a stack may be composed of components of other
+// repositories.
+// The config of each component definition (see LoadConfig) is merged into the
+// values of its components, with the config of ancestors taking precedence.
+func Render(c Component, opts RenderOptions) ([]Rendered, error) {
+	opts, cleanup, err := opts.withGitCache()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	config, err := c.loadConfig(opts.Environments)
+	if err != nil {
+		return nil, fmt.Errorf(`rendering component %s: %w`, c.Name, err)
+	}
+	return render(c, c.Name, opts, nil, config)
+}
+
+// withGitCache returns opts with a GitCache in a temporary directory if none
+// is set, along with a function removing it.
+func (opts RenderOptions) withGitCache() (RenderOptions, func(), error) {
+	if opts.GitCache != nil {
+		return opts, func() {}, nil
+	}
+	tmpDir, err := os.MkdirTemp("", "component-git-")
+	if err != nil {
+		return opts, nil, fmt.Errorf(`creating temporary directory for git checkouts: %w`, err)
+	}
+	opts.GitCache = &GitCache{Dir: tmpDir}
+	return opts, func() { os.RemoveAll(tmpDir) }, nil
+}
+
+// Manifests returns the manifests of all rendered components in order.
+func Manifests(rendered []Rendered) []map[string]interface{} {
+	var manifests []map[string]interface{}
+	for _, r := range rendered {
+		manifests = append(manifests, r.Manifests...)
+	}
+	return manifests
+}
+
+// render renders c at path. ancestors are the remote sources and definition
+// files of the ancestors of c (see loadDefinition). config is the config of c inherited from its definition
+// and ancestors.
+func render(c Component, path string, opts RenderOptions, ancestors []string, config Config) ([]Rendered, error) {
+	values := yamlPlus.Merge(c.Values, config.Values)
+	var rendered []Rendered
+	switch c.ResolvedType() {
+	case TypeHelm:
+		configured := c
+		configured.Values = values
+		templateOpts := configured.templateOptions(opts.TemplateOptions)
+		if templateOpts.ResolveVersion {
+			// resolved first so the values template is rendered against the exact version
+			var err error
+			if templateOpts, err = helm.ResolveTemplateVersion(templateOpts); err != nil {
+				return nil, fmt.Errorf(`rendering component %s: %w`, path, err)
+			}
+		}
+		templateOpts.ValuesTemplate = helm.ReleaseValuesTemplate(templateOpts, nil)
+		templateOpts.ValuesTemplate.Environments = opts.Environments
+		manifests, err := helm.TemplateWithCRDs(templateOpts)
+		if err != nil {
+			return nil, fmt.Errorf(`rendering component %s: %w`, path, err)
+		}
+		rendered = append(rendered, Rendered{Path: path, Component: c, Values: values, Version: templateOpts.Version, Manifests: manifests})
+	case TypeStatic:
+		manifests, err := manifest.LoadDirectory(c.resolve(c.Path))
+		if err != nil {
+			return nil, fmt.Errorf(`rendering component %s: %w`, path, err)
+		}
+		rendered = append(rendered, Rendered{Path: path, Component: c, Values: values, Manifests: manifest.ToMaps(manifests)})
+	case TypeComponent:
+		if c.Path == "" && c.Source == "" {
+			rendered = append(rendered, Rendered{Path: path, Component: c, Values: values})
+			break
+		}
+		// the definition at Path is rendered in place of the component
+		definition, definitionAncestors, release, err := c.loadDefinition(opts.GitCache, ancestors)
+		if err != nil {
+			return nil, fmt.Errorf(`rendering component %s: %w`, path, err)
+		}
+		defer release()
+		ancestors = definitionAncestors
+		// the config of the component overrides the config of the definition
+		definitionConfig, err := definition.loadConfig(opts.Environments)
+		if err != nil {
+			return nil, fmt.Errorf(`rendering component %s: %w`, path, err)
+		}
+		nested, err := render(definition, path, opts, ancestors, definitionConfig.Merge(Config{Values: values, Subcomponents: config.Subcomponents}))
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, nested...)
+	default:
+		return nil, fmt.Errorf(`rendering component %s: unknown type "%s"`, path, c.Type)
+	}
+
+	for _, sub := range c.Subcomponents {
+		subRendered, err := render(sub, path+"/"+sub.Name, opts, ancestors, config.Subcomponents[sub.Name])
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, subRendered...)
+	}
+	return rendered, nil
+}
+
+// loadDefinition loads the definition at Path (of the Source repository) of a
+// TypeComponent, returning it along with ancestors extended by the definition
+// and a function releasing the checkout of the Source repository (see
+// GitCache.Checkout) once the definition has been rendered.
+// ancestors are the "<source>@<ref>" of the remote ancestors of c and the
+// definition files of all of its ancestors, to detect components which include
+// themselves (e.g. a definition with "path: .").
+func (c Component) loadDefinition(cache *GitCache, ancestors []string) (Component, []string, func(), error) {
+	definitionPath := c.resolve(c.Path)
+	ancestors = append([]string{}, ancestors...)
+	release := func() {}
+	if c.Source != "" {
+		source := c.Source + "@" + c.Ref
+		if contains(ancestors, source) {
+			return Component{}, nil, nil, fmt.Errorf(`cyclic source %s`, source)
+		}
+		ancestors = append(ancestors, source)
+		checkout, releaseCheckout, err := cache.Checkout(c.Source, c.Ref)
+		if err != nil {
+			return Component{}, nil, nil, err
+		}
+		definitionPath, release = filepath.Join(checkout, filepath.FromSlash(c.Path)), releaseCheckout
+	}
+	file := definitionFile(definitionPath)
+	if contains(ancestors, file) {
+		release()
+		return Component{}, nil, nil, fmt.Errorf(`cyclic path %s`, file)
+	}
+	ancestors = append(ancestors, file)
+	definition, err := Load(definitionPath)
+	if err != nil {
+		release()
+		return Component{}, nil, nil, err
+	}
+	return definition, ancestors, release, nil
+}
+
+// definitionFile returns the absolute path, with symlinks resolved, of the
+// definition file Load reads for path.
+func definitionFile(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, DefaultFileName)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// templateOptions returns the options to template the chart of a helm
+// component, based on base.
+func (c Component) templateOptions(base helm.TemplateOptions) helm.TemplateOptions {
+	opts := base
+	opts.Release = c.Release
+	if opts.Release == "" {
+		opts.Release = c.Name
+	}
+	opts.Chart = c.Chart
+	if c.Repo == "" && !helm.IsOCI(c.Chart) {
+		// local charts are relative to the component definition. otherwise the
+		// chart is a reference to a repository of the host (e.g. bitnami/nginx)
+		if _, err := os.Stat(c.resolve(c.Chart)); err == nil {
+			opts.Chart = c.resolve(c.Chart)
+		}
+	}
+	opts.Repo = c.Repo
+	opts.Version = c.Version
+	opts.Namespace = c.Namespace
+	opts.Values = append([]string{}, base.Values...)
+	for _, valuesFile := range c.ValuesFiles {
+		opts.Values = append(opts.Values, c.resolve(valuesFile))
+	}
+	opts.ValuesMap = append([]map[string]interface{}{}, base.ValuesMap...)
+	if len(c.Values) > 0 {
+		opts.ValuesMap = append(opts.ValuesMap, c.Values)
+	}
+	return opts
+}
+
+// contains determines if values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package component
+
+import (
+	"fmt"
+)
+
+// Walk visits c and its subcomponents depth-first in the order Render renders
+// them, without rendering them. fn receives each component along with its
+// path (see Rendered.Path). As with Render, the definition at the Path (of the
+// Source repository) of a TypeComponent is loaded and visited in its place.
+// Walking stops at the first error returned by fn. Only opts.GitCache is used.
+func Walk(c Component, opts RenderOptions, fn func(path string, c Component) error) error {
+	opts, cleanup, err := opts.withGitCache()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return walk(c, c.Name, opts, nil, fn)
+}
+
+// walk visits c at path. ancestors are the remote sources and definition
+// files of the ancestors of c (see loadDefinition).
+func walk(c Component, path string, opts RenderOptions, ancestors []string, fn func(path string, c Component) error) error {
+	if c.ResolvedType() == TypeComponent && (c.Path != "" || c.Source != "") {
+		definition, definitionAncestors, release, err := c.loadDefinition(opts.GitCache, ancestors)
+		if err != nil {
+			return fmt.Errorf(`walking component %s: %w`, path, err)
+		}
+		err = walk(definition, path, opts, definitionAncestors, fn)
+		release()
+		if err != nil {
+			return err
+		}
+		ancestors = definitionAncestors
+	} else if err := fn(path, c); err != nil {
+		return err
+	}
+
+	for _, sub := range c.Subcomponents {
+		if err := walk(sub, path+"/"+sub.Name, opts, ancestors, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
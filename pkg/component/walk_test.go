@@ -0,0 +1,117 @@
+package component
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"component.yaml": `
+name: stack
+subcomponents:
+  - name: nginx
+    repo: https://charts.example.com
+    chart: nginx
+  - name: monitoring
+    path: monitoring
+    type: component
+`,
+		"monitoring/component.yaml": `
+name: monitoring
+chart: kube-prometheus-stack
+repo: https://prometheus-community.github.io/helm-charts
+subcomponents:
+  - name: dashboards
+    path: dashboards
+`,
+	})
+	root, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var visited []string
+	err = Walk(root, RenderOptions{}, func(path string, c Component) error {
+		visited = append(visited, path+"="+string(c.ResolvedType()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	want := []string{
+		"stack=component",
+		"stack/nginx=helm",
+		"stack/monitoring=helm",
+		"stack/monitoring/dashboards=static",
+	}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk() visited = %v, want %v", visited, want)
+	}
+}
+
+func TestWalk_cyclicPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"component.yaml": `
+name: stack
+subcomponents:
+  - name: shared-a
+    path: shared
+    type: component
+  - name: shared-b
+    path: shared
+    type: component
+`,
+		"shared/component.yaml": "name: shared\n",
+		"self/component.yaml": `
+name: self
+subcomponents:
+  - name: again
+    path: .
+    type: component
+`,
+		"loop/a/component.yaml": `
+name: a
+subcomponents:
+  - name: b
+    path: ../b
+    type: component
+`,
+		"loop/b/component.yaml": `
+name: b
+subcomponents:
+  - name: a
+    path: ../a
+    type: component
+`,
+	})
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "included by siblings", path: dir},
+		{name: "includes itself", path: filepath.Join(dir, "self"), wantErr: true},
+		{name: "includes its includer", path: filepath.Join(dir, "loop", "a"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := Load(tt.path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			err = Walk(root, RenderOptions{}, func(string, Component) error { return nil })
+			if (err != nil) != tt.wantErr || (err != nil && !strings.Contains(err.Error(), "cyclic path")) {
+				t.Errorf("Walk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, err = Render(root, RenderOptions{})
+			if (err != nil) != tt.wantErr || (err != nil && !strings.Contains(err.Error(), "cyclic path")) {
+				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
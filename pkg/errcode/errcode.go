@@ -0,0 +1,127 @@
+// Package errcode assigns stable, machine-readable codes to the categories of
+// failures returned by the packages of this module, so automation can branch
+// on a failure without matching error messages:
+//   switch errcode.Of(err) {
+//   case errcode.HelmChartNotFound: ...
+//   case errcode.HelmRepoUnreachable: ... // retry
+//   }
+// Codes never change meaning once assigned; new categories get new codes.
+package errcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies a category of failure. e.g: "HELM001"
+type Code string
+
+// Codes of the helm package.
+const (
+	HelmChartNotFound    Code = "HELM001" // the chart does not exist in the repository
+	HelmVersionNotFound  Code = "HELM002" // the chart exists but not at the requested version
+	HelmRepoUnreachable  Code = "HELM003" // the repository or registry could not be reached
+	HelmAuthRequired     Code = "HELM004" // the repository or registry requires (other) credentials
+	HelmReleaseNotFound  Code = "HELM005" // the release is not installed
+	HelmSandboxLimit     Code = "HELM006" // helm was stopped for exceeding a limit of its sandbox
+	HelmLimitExceeded    Code = "HELM007" // the output of helm exceeds the output limits
+	HelmShutdown         Code = "HELM008" // the operation was rejected or cancelled by Shutdown
+	HelmValuesInvalid    Code = "HELM009" // the values do not conform to the values.schema.json of the chart
+	HelmDigestMismatch   Code = "HELM010" // a chart or values file does not match its expected digest
+	HelmArtifactNotFound Code = "HELM011" // the artifact is not in the artifact store
+)
+
+// Codes of the yaml package.
+const (
+	YAMLDecode         Code = "YAML001" // a document is not valid yaml
+	YAMLNonMapDocument Code = "YAML002" // a document is valid yaml but not a map
+)
+
+// Codes of the helm installer.
+const (
+	InstallUnsupportedPlatform Code = "INST001" // no helm release is available for the host
+	InstallDownload            Code = "INST002" // the helm release could not be downloaded
+	InstallChecksumMismatch    Code = "INST003" // the downloaded helm release does not match its published checksum
+)
+
+// Codes of the manifest package.
+const (
+	ManifestSchemaNotFound     Code = "MFST001" // no schema is known for the kind of a resource
+	ManifestClusterUnreachable Code = "MFST002" // the cluster could not be reached for a dry run
+)
+
+// Codes of the filelock package.
+const (
+	LockUnsupported Code = "LOCK001" // file locks are not supported on the platform
+)
+
+// Coder is implemented by errors carrying a Code.
+type Coder interface {
+	Code() Code
+}
+
+// Error is an error carrying a Code.
+type Error struct {
+	code Code
+	Err  error
+}
+
+// New creates an error with code and message, for sentinel errors checked
+// with errors.Is:
+//   var ErrChartNotFound = errcode.New(errcode.HelmChartNotFound, "chart not found")
+func New(code Code, message string) *Error {
+	return &Error{code: code, Err: errors.New(message)}
+}
+
+// Wrap returns err carrying code, with the same message. nil is returned if
+// err is nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{code: code, Err: err}
+}
+
+func (e *Error) Code() Code {
+	return e.code
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Of returns the code of the outermost error in the chain of err carrying a
+// code, or "" if none does.
+func Of(err error) Code {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if coder, ok := err.(Coder); ok && coder.Code() != "" {
+			return coder.Code()
+		}
+	}
+	return ""
+}
+
+// Format formats err prefixed with its code for logs. e.g:
+//   [HELM001] running "helm pull ...": exit status 1: Error: chart "nginx" not found
+func Format(err error) string {
+	if code := Of(err); code != "" {
+		return fmt.Sprintf("[%s] %v", code, err)
+	}
+	return err.Error()
+}
+
+// Report is the JSON representation of an error in machine-readable reports.
+// e.g: {"code": "HELM001", "message": "running \"helm pull ...\": ..."}
+type Report struct {
+	Code    Code   `json:"code,omitempty"` // empty if the error has no code
+	Message string `json:"message"`
+}
+
+// NewReport creates the Report of err.
+func NewReport(err error) Report {
+	return Report{Code: Of(err), Message: err.Error()}
+}
@@ -0,0 +1,63 @@
+package errcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errChartNotFound = New(HelmChartNotFound, "chart not found")
+
+// codedError is a typed error implementing Coder.
+type codedError struct{}
+
+func (codedError) Error() string { return "invalid values" }
+
+func (codedError) Code() Code { return HelmValuesInvalid }
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "sentinel", err: errChartNotFound, want: HelmChartNotFound},
+		{name: "wrapped sentinel", err: fmt.Errorf(`pulling nginx: %w`, errChartNotFound), want: HelmChartNotFound},
+		{name: "wrapped", err: Wrap(YAMLNonMapDocument, errors.New("not a map")), want: YAMLNonMapDocument},
+		{name: "outermost code", err: Wrap(InstallDownload, fmt.Errorf(`downloading: %w`, Wrap(InstallChecksumMismatch, errors.New("mismatch")))), want: InstallDownload},
+		{name: "typed error", err: fmt.Errorf(`validating: %w`, codedError{}), want: HelmValuesInvalid},
+		{name: "no code", err: errors.New("failed")},
+		{name: "nil", err: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Of(tt.err); got != tt.want {
+				t.Errorf("Of() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	err := fmt.Errorf(`pulling nginx: %w`, errChartNotFound)
+	if !errors.Is(err, errChartNotFound) {
+		t.Errorf("errors.Is() = false, want sentinels to be matched")
+	}
+	if got, want := Format(err), "[HELM001] pulling nginx: chart not found"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	if got, want := Format(errors.New("failed")), "failed"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	if Wrap(HelmShutdown, nil) != nil {
+		t.Errorf("Wrap(nil) != nil")
+	}
+	report, err := json.Marshal(NewReport(err))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(report), `{"code":"HELM001","message":"pulling nginx: chart not found"}`; got != want {
+		t.Errorf("NewReport() = %s, want %s", got, want)
+	}
+}
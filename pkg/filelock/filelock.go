@@ -0,0 +1,63 @@
+// Package filelock provides advisory locks on files shared between processes
+// (flock on unix, LockFileEx on windows), so concurrent processes on one
+// machine can safely share caches and configuration. Locks also exclude other
+// goroutines of the same process acquiring the lock via a separate Acquire.
+package filelock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+// ErrUnsupported is returned by Acquire on platforms without file locks.
+var ErrUnsupported = errcode.New(errcode.LockUnsupported, "file locks are not supported on this platform")
+
+// Lock is a held lock of a file.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire blocks until it holds the exclusive lock of the file at path,
+// creating the file and its directory if missing. The file is not removed on
+// Release so it can be locked again by other processes.
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+// AcquireShared blocks until it holds a shared lock of the file at path:
+// any number of shared locks may be held at once, but not alongside an
+// exclusive lock.
+func AcquireShared(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+func acquire(path string, exclusive bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf(`creating directory of lock file %s: %w`, path, err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf(`opening lock file %s: %w`, path, err)
+	}
+	if err := lock(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf(`locking %s: %w`, path, err)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock. Releasing a released lock is a no-op.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	defer func() { l.f = nil }()
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return fmt.Errorf(`unlocking %s: %w`, l.f.Name(), err)
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package filelock
+
+import (
+	"os"
+)
+
+func lock(f *os.File, exclusive bool) error {
+	return ErrUnsupported
+}
+
+func unlock(f *os.File) error {
+	return ErrUnsupported
+}
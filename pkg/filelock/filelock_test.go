@@ -0,0 +1,66 @@
+package filelock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "cache.lock")
+	held, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		l, err := Acquire(path)
+		if err != nil {
+			t.Errorf("Acquire() error = %v", err)
+		}
+		acquired <- l
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("Acquire() returned while the lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := held.Release(); err != nil {
+		t.Errorf("Release() of a released lock error = %v, want nil", err)
+	}
+	select {
+	case l := <-acquired:
+		l.Release()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Acquire() did not return after the lock was released")
+	}
+}
+
+func TestAcquireShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	first, err := AcquireShared(path)
+	if err != nil {
+		t.Fatalf("AcquireShared() error = %v", err)
+	}
+	defer first.Release()
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := AcquireShared(path)
+		if err != nil {
+			t.Errorf("AcquireShared() error = %v", err)
+		}
+		second.Release()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("AcquireShared() blocked while only a shared lock was held")
+	}
+}
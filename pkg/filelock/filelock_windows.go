@@ -0,0 +1,42 @@
+//go:build windows
+// +build windows
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileExclusiveLock = 0x2
+	allBytes              = uintptr(^uint32(0)) // low and high words of the number of bytes locked
+)
+
+// lock locks the whole of f: all bytes up to the maximum offset.
+func lock(f *os.File, exclusive bool) error {
+	var flags uintptr
+	if exclusive {
+		flags = lockfileExclusiveLock
+	}
+	var overlapped syscall.Overlapped
+	if r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, allBytes, allBytes, uintptr(unsafe.Pointer(&overlapped))); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func unlock(f *os.File) error {
+	var overlapped syscall.Overlapped
+	if r, _, err := procUnlockFileEx.Call(f.Fd(), 0, allBytes, allBytes, uintptr(unsafe.Pointer(&overlapped))); r == 0 {
+		return err
+	}
+	return nil
+}
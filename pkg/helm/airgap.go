@@ -0,0 +1,398 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/evanlouie/go/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// AirGapManifestFile is the name of the manifest of the bundles written by
+// ExportBundle, listing their contents.
+const AirGapManifestFile = "bundle.yaml"
+
+// Types of the files of an AirGapBundle.
+const (
+	AirGapChart     = "chart"     // the chart archive. e.g: "charts/nginx-1.2.3.tgz"
+	AirGapManifests = "manifests" // the rendered manifests as a single yaml stream: "manifests.yaml"
+	AirGapImageList = "images"    // the references of the images of the manifests, one per line: "images.txt"
+	AirGapImage     = "image"     // a saved image. e.g: "images/nginx_1.19.tar"
+)
+
+// AirGapBundle is the manifest of a bundle written by ExportBundle.
+type AirGapBundle struct {
+	Chart   string       `yaml:"chart"`            // name of the bundled chart
+	Version string       `yaml:"version"`          // version of the bundled chart
+	Source  string       `yaml:"source,omitempty"` // repository, OCI registry or git repository the chart was pulled from. empty for local charts
+	Created time.Time    `yaml:"created"`
+	Images  []string     `yaml:"images"` // references of the container images of the rendered manifests. see manifest.Images
+	Files   []AirGapFile `yaml:"files"`
+}
+
+// AirGapFile is a file of an AirGapBundle.
+type AirGapFile struct {
+	Path   string `yaml:"path"`            // slash separated path of the file in the bundle
+	Type   string `yaml:"type"`            // e.g: AirGapChart
+	Digest string `yaml:"digest"`          // "sha256:<hex>" of the file
+	Image  string `yaml:"image,omitempty"` // reference of the image saved in files of type AirGapImage
+}
+
+// File returns the path of the first file of type fileType in the bundle, or
+// an empty string if there is none.
+func (b AirGapBundle) File(fileType string) string {
+	for _, file := range b.Files {
+		if file.Type == fileType {
+			return file.Path
+		}
+	}
+	return ""
+}
+
+// ImageStore saves container images to and loads them from files so they can
+// be carried into an air-gapped environment.
+type ImageStore interface {
+	SaveImage(reference string, path string) error
+	LoadImage(path string) error
+}
+
+// DockerImageStore is an ImageStore running the docker CLI (or a compatible
+// CLI such as podman): images are pulled and saved with `docker save` and
+// loaded with `docker load`.
+type DockerImageStore struct {
+	Binary string // defaults to "docker"
+}
+
+// SaveImage pulls reference and saves it as a tarball to path.
+func (s DockerImageStore) SaveImage(reference string, path string) error {
+	if err := s.run("pull", "--quiet", reference); err != nil {
+		return err
+	}
+	return s.run("save", "--output", path, reference)
+}
+
+// LoadImage loads the image tarball at path.
+func (s DockerImageStore) LoadImage(path string) error {
+	return s.run("load", "--input", path)
+}
+
+func (s DockerImageStore) run(args ...string) error {
+	binary := s.Binary
+	if binary == "" {
+		binary = "docker"
+	}
+	// the process is killed when Shutdown is called
+	cmd := exec.CommandContext(shutdown.ctx, binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(`running "%s": %w: %s`, cmd, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ExportOptions configure ExportBundle.
+type ExportOptions struct {
+	TemplateOptions TemplateOptions // the chart, release and values of the render
+	Images          ImageStore      // when set, the images of the rendered manifests are saved into the bundle
+}
+
+// imageFileReplacer replaces the characters of image references which are not
+// safe in file names.
+var imageFileReplacer = strings.NewReplacer("/", "_", ":", "_", "@", "_")
+
+// ExportBundle packages everything needed to deploy the render of
+// opts.TemplateOptions offline into a gzipped tarball at dest: the chart
+// archive, the manifests rendered from it, the list of the images they
+// reference and, when opts.Images is set, the saved images. The AirGapBundle
+// manifest of the bundle is written as AirGapManifestFile and returned.
+//
+// Local charts (including charts checked out from git) are packaged with
+// `helm package`. The manifests are rendered from the bundled chart archive so
+// they match the chart which is deployed.
+func ExportBundle(opts ExportOptions, dest string) (AirGapBundle, error) {
+	templateOpts, err := ResolveTemplateVersion(opts.TemplateOptions)
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	bundle := AirGapBundle{Created: time.Now().UTC(), Images: []string{}}
+	if templateOpts.GitURL != "" {
+		bundle.Source = templateOpts.GitURL
+	} else if templateOpts.Repo != "" || IsOCI(templateOpts.Chart) {
+		bundle.Source = templateOpts.provenanceSource()
+	}
+	templateOpts, removeCheckout, err := templateOpts.withGitChart()
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	defer removeCheckout()
+
+	stage, err := mkdirTemp("airgap-")
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`creating temporary directory for bundle %s: %w`, dest, err)
+	}
+	defer removeTemp(stage)
+	chartsDir := filepath.Join(stage, "charts")
+	if err := os.MkdirAll(chartsDir, 0755); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`creating directory %s: %w`, chartsDir, err)
+	}
+	archivePath, err := templateOpts.bundleChart(chartsDir)
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+	}
+	chartPath, removeExtracted, err := extractChart(archive, archivePath)
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	metadata, err := chartProvenance(chartPath, "", "")
+	removeExtracted()
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	bundle.Chart, bundle.Version = metadata.Chart, metadata.Version
+
+	templateOpts.Repo, templateOpts.Chart, templateOpts.Version = "", archivePath, ""
+	templateOpts.DependencyUpdate = false // dependencies are packaged with the chart
+	maps, err := TemplateWithCRDs(templateOpts)
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	manifests := manifest.FromMaps(maps)
+	rendered, err := manifest.Encode(manifests)
+	if err != nil {
+		return AirGapBundle{}, err
+	}
+	if err := os.WriteFile(filepath.Join(stage, "manifests.yaml"), rendered, 0644); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`writing rendered manifests to bundle: %w`, err)
+	}
+	for _, image := range manifest.Images(manifests) {
+		bundle.Images = append(bundle.Images, image.Reference)
+	}
+	imageList := strings.Join(bundle.Images, "\n")
+	if imageList != "" {
+		imageList += "\n"
+	}
+	if err := os.WriteFile(filepath.Join(stage, "images.txt"), []byte(imageList), 0644); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`writing image list to bundle: %w`, err)
+	}
+
+	files := []AirGapFile{
+		{Path: "charts/" + filepath.Base(archivePath), Type: AirGapChart},
+		{Path: "manifests.yaml", Type: AirGapManifests},
+		{Path: "images.txt", Type: AirGapImageList},
+	}
+	if opts.Images != nil {
+		if err := os.MkdirAll(filepath.Join(stage, "images"), 0755); err != nil {
+			return AirGapBundle{}, fmt.Errorf(`creating directory for images: %w`, err)
+		}
+		for _, image := range bundle.Images {
+			file := AirGapFile{Path: "images/" + imageFileReplacer.Replace(image) + ".tar", Type: AirGapImage, Image: image}
+			if err := opts.Images.SaveImage(image, filepath.Join(stage, filepath.FromSlash(file.Path))); err != nil {
+				return AirGapBundle{}, fmt.Errorf(`saving image %s: %w`, image, err)
+			}
+			files = append(files, file)
+		}
+	}
+	for idx := range files {
+		if files[idx].Digest, err = fileDigest(filepath.Join(stage, filepath.FromSlash(files[idx].Path))); err != nil {
+			return AirGapBundle{}, err
+		}
+	}
+	bundle.Files = files
+
+	content, err := yaml.Marshal(bundle)
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`marshalling bundle manifest: %w`, err)
+	}
+	if err := os.WriteFile(filepath.Join(stage, AirGapManifestFile), content, 0644); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`writing bundle manifest: %w`, err)
+	}
+	paths := []string{AirGapManifestFile}
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	if err := writeTarball(dest, stage, paths); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`writing bundle %s: %w`, dest, err)
+	}
+	return bundle, nil
+}
+
+// bundleChart writes the chart archive of opts into dir, returning its path:
+// remote charts are pulled, local chart archives copied and local chart
+// directories packaged.
+func (opts TemplateOptions) bundleChart(dir string) (string, error) {
+	if opts.Repo != "" || IsOCI(opts.Chart) {
+		pullOpts := opts.pullOptions()
+		archivePath, err := pullArchiveFile(pullOpts, dir)
+		if err != nil {
+			return "", err
+		}
+		archive, err := os.ReadFile(archivePath)
+		if err != nil {
+			return "", fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+		}
+		if _, err := pullOpts.verifyDigest(archive); err != nil {
+			return "", err
+		}
+		return archivePath, nil
+	}
+	if isChartArchive(opts.Chart) {
+		archive, err := os.ReadFile(longPath(opts.Chart))
+		if err != nil {
+			return "", fmt.Errorf(`reading chart archive %s: %w`, opts.Chart, err)
+		}
+		archivePath := filepath.Join(dir, filepath.Base(opts.Chart))
+		if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+			return "", fmt.Errorf(`copying chart archive %s: %w`, opts.Chart, err)
+		}
+		return archivePath, nil
+	}
+	if opts.DependencyUpdate {
+		isolated, err := openIsolatedConfig(opts.IsolatedConfig)
+		if err != nil {
+			return "", err
+		}
+		err = dependencyUpdate(isolated, opts.Chart)
+		isolated.Close()
+		if err != nil {
+			return "", fmt.Errorf(`updating dependencies of helm chart %s: %w`, opts.Chart, err)
+		}
+	}
+	return Package(opts.Chart, dir, "", "")
+}
+
+// fileDigest returns the "sha256:<hex>" digest of the file at path.
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf(`reading %s: %w`, path, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf(`reading %s: %w`, path, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeTarball writes the files of dir at the slash separated paths to a
+// gzipped tarball at dest. The tarball is written next to dest and renamed
+// into place so a partially written bundle is never observed.
+func writeTarball(dest string, dir string, paths []string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	gzw := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gzw)
+	err = func() error {
+		for _, name := range paths {
+			file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				return err
+			}
+			info, err := file.Stat()
+			if err == nil {
+				err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: info.Size(), Typeflag: tar.TypeReg, ModTime: info.ModTime()})
+			}
+			if err == nil {
+				_, err = io.Copy(tw, file)
+			}
+			file.Close()
+			if err != nil {
+				return fmt.Errorf(`adding %s: %w`, name, err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gzw.Close()
+	}()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// ImportBundle extracts the bundle written by ExportBundle at path into dest
+// and verifies the digest of each of its files, returning its manifest. When
+// images is set, the saved images of the bundle are loaded with it (e.g. into
+// the docker daemon, to be pushed to a registry of the air-gapped
+// environment). Paths of the manifest are relative to dest. Entries resolving
+// outside of dest are rejected.
+func ImportBundle(path string, dest string, images ImageStore) (AirGapBundle, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`reading bundle %s: %w`, path, err)
+	}
+	err = extractArchiveFrom(file, longPath(dest))
+	file.Close()
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`extracting bundle %s: %w`, path, err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, AirGapManifestFile))
+	if err != nil {
+		return AirGapBundle{}, fmt.Errorf(`reading manifest of bundle %s: %w`, path, err)
+	}
+	var bundle AirGapBundle
+	if err := yaml.Unmarshal(content, &bundle); err != nil {
+		return AirGapBundle{}, fmt.Errorf(`parsing manifest of bundle %s: %w`, path, err)
+	}
+	for _, file := range bundle.Files {
+		name := cleanRelativePath(file.Path)
+		if name == "" {
+			return AirGapBundle{}, fmt.Errorf(`file %s of bundle %s resolves outside of %s`, file.Path, path, dest)
+		}
+		digest, err := fileDigest(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			return AirGapBundle{}, fmt.Errorf(`verifying bundle %s: %w`, path, err)
+		}
+		if digest != file.Digest {
+			return AirGapBundle{}, fmt.Errorf(`digest of %s of bundle %s does not match: expected %s, got %s`, file.Path, path, file.Digest, digest)
+		}
+	}
+	if images != nil {
+		for _, file := range bundle.Files {
+			if file.Type != AirGapImage {
+				continue
+			}
+			if err := images.LoadImage(filepath.Join(dest, filepath.FromSlash(file.Path))); err != nil {
+				return AirGapBundle{}, fmt.Errorf(`loading image %s of bundle %s: %w`, file.Image, path, err)
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// cleanRelativePath cleans the slash separated relative path name, returning an
+// empty string if it resolves outside of its root.
+func cleanRelativePath(name string) string {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+	return name
+}
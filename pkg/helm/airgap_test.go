@@ -0,0 +1,109 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+// fakeImageStore saves the reference of images as their content.
+type fakeImageStore struct {
+	loaded []string
+}
+
+func (s *fakeImageStore) SaveImage(reference string, path string) error {
+	return os.WriteFile(path, []byte(reference), 0644)
+}
+
+func (s *fakeImageStore) LoadImage(path string) error {
+	content, err := os.ReadFile(path)
+	s.loaded = append(s.loaded, string(content))
+	return err
+}
+
+func TestExportBundle(t *testing.T) {
+	archivePath := helmtest.NewChartArchive(t, helmtest.Chart{Name: "web", Version: "1.2.3"})
+	// the fake helm pulls the archive into --destination and templates a
+	// Deployment of the name of the chart directory it is passed
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="/nonexistent/repositories.yaml"' ;;
+pull) while [ $# -gt 0 ]; do [ "$1" = "--destination" ] && dest="$2"; shift; done; cp '`+archivePath+`' "$dest/web-1.2.3.tgz" ;;
+template) for arg; do chart="$arg"; done; name=$(sed -n 's/^name: //p' "$chart/Chart.yaml"); cat <<YAML
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: $name
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: ghcr.io/my-org/web:1.2.3
+        - name: proxy
+          image: envoyproxy/envoy:v1.20.0
+YAML
+;;
+esac`)
+	dir := t.TempDir()
+	bundlePath := filepath.Join(dir, "bundle.tgz")
+	images := &fakeImageStore{}
+
+	exported, err := ExportBundle(ExportOptions{
+		TemplateOptions: TemplateOptions{Release: "web", Repo: "https://charts.example.com", Chart: "web", Version: "1.2.3"},
+		Images:          images,
+	}, bundlePath)
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+	if exported.Chart != "web" || exported.Version != "1.2.3" || exported.Source != "https://charts.example.com" {
+		t.Errorf("ExportBundle() = %s@%s from %s, want web@1.2.3 from https://charts.example.com", exported.Chart, exported.Version, exported.Source)
+	}
+	wantImages := []string{"envoyproxy/envoy:v1.20.0", "ghcr.io/my-org/web:1.2.3"}
+	if !reflect.DeepEqual(exported.Images, wantImages) {
+		t.Errorf("ExportBundle() images = %v, want %v", exported.Images, wantImages)
+	}
+	var paths []string
+	for _, file := range exported.Files {
+		paths = append(paths, file.Path)
+	}
+	wantPaths := []string{"charts/web-1.2.3.tgz", "manifests.yaml", "images.txt", "images/envoyproxy_envoy_v1.20.0.tar", "images/ghcr.io_my-org_web_1.2.3.tar"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("ExportBundle() files = %v, want %v", paths, wantPaths)
+	}
+
+	importDir := t.TempDir()
+	imported, err := ImportBundle(bundlePath, importDir, images)
+	if err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+	if !reflect.DeepEqual(imported.Files, exported.Files) {
+		t.Errorf("ImportBundle() files = %+v, want %+v", imported.Files, exported.Files)
+	}
+	if !reflect.DeepEqual(images.loaded, wantImages) {
+		t.Errorf("ImportBundle() loaded images %v, want %v", images.loaded, wantImages)
+	}
+	rendered, err := os.ReadFile(filepath.Join(importDir, imported.File(AirGapManifests)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rendered), "name: web") {
+		t.Errorf("ImportBundle() manifests = %s, want the Deployment rendered from the bundled chart", rendered)
+	}
+
+	// a bundle whose files were modified is rejected
+	if err := os.WriteFile(filepath.Join(importDir, "manifests.yaml"), []byte("kind: Tampered\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tampered := filepath.Join(dir, "tampered.tgz")
+	if err := writeTarball(tampered, importDir, append([]string{AirGapManifestFile}, paths...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportBundle(tampered, t.TempDir(), nil); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("ImportBundle() error = %v, want a digest mismatch", err)
+	}
+}
@@ -0,0 +1,61 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isChartArchive determines if chart is the path of a local chart archive
+// (e.g. a vendored "nginx-1.2.3.tgz") rather than a chart directory or a
+// reference to a chart in a repository.
+func isChartArchive(chart string) bool {
+	if IsOCI(chart) || !(strings.HasSuffix(chart, ".tgz") || strings.HasSuffix(chart, ".tar.gz")) {
+		return false
+	}
+	info, err := os.Stat(longPath(chart))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// extractChart extracts the chart archive of chart into a temporary
+// directory, returning the path of the extracted chart along with a function
+// removing it. Entries resolving outside of the directory are rejected.
+func extractChart(archive []byte, chart string) (string, func(), error) {
+	tmpDir, err := mkdirTemp("fabrikate")
+	if err != nil {
+		return "", nil, fmt.Errorf(`creating temporary directory to extract helm chart %s: %w`, chart, err)
+	}
+	cleanup := func() { removeTemp(tmpDir) }
+	if err := extractArchive(archive, longPath(tmpDir)); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf(`extracting chart archive of %s: %w`, chart, err)
+	}
+	// charts are packaged in a single directory named after the chart
+	chartFiles, err := filepath.Glob(filepath.Join(tmpDir, "*", "Chart.yaml"))
+	if err != nil || len(chartFiles) != 1 {
+		cleanup()
+		return "", nil, fmt.Errorf(`expected 1 chart in archive of %s, found %d`, chart, len(chartFiles))
+	}
+	return filepath.Dir(chartFiles[0]), cleanup, nil
+}
+
+// withChartArchive returns opts with its local chart archive extracted and
+// set as a local Chart, along with a function removing the extracted chart,
+// so the crds directory of the chart can be read and its dependencies
+// updated like those of a chart directory.
+func (opts TemplateOptions) withChartArchive() (TemplateOptions, func(), error) {
+	if opts.Repo != "" || !isChartArchive(opts.Chart) {
+		return opts, func() {}, nil
+	}
+	archive, err := os.ReadFile(longPath(opts.Chart))
+	if err != nil {
+		return opts, nil, fmt.Errorf(`reading chart archive %s: %w`, opts.Chart, err)
+	}
+	chartPath, cleanup, err := extractChart(archive, opts.Chart)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.Chart, opts.Version = chartPath, ""
+	return opts, cleanup, nil
+}
@@ -0,0 +1,72 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestTemplateWithCRDs_chartArchive(t *testing.T) {
+	archivePath := helmtest.NewChartArchive(t, helmtest.Chart{
+		Name: "nginx",
+		CRDs: map[string]string{"crd.yaml": "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\n"},
+	})
+	// helm < v3.1.0 cannot --include-crds, so the crds directory of the
+	// extracted chart is read. the fake helm templates the name of the chart
+	// directory it is passed as last argument
+	useFakeHelm(t, `case "$1" in
+version) echo 'version.BuildInfo{Version:"v3.0.0", GitCommit:"abc123", GitTreeState:"clean", GoVersion:"go1.13.4"}' ;;
+template) for arg; do chart="$arg"; done; echo "kind: ConfigMap"; grep '^name:' "$chart/Chart.yaml" ;;
+esac`)
+
+	maps, err := TemplateWithCRDs(TemplateOptions{Release: "nginx", Chart: archivePath})
+	if err != nil {
+		t.Fatalf("TemplateWithCRDs() error = %v", err)
+	}
+	var kinds []string
+	for _, m := range maps {
+		kinds = append(kinds, m["kind"].(string))
+	}
+	if got := strings.Join(kinds, ","); got != "CustomResourceDefinition,ConfigMap" {
+		t.Errorf("TemplateWithCRDs() kinds = %s, want CustomResourceDefinition,ConfigMap", got)
+	}
+	if name := maps[len(maps)-1]["name"]; name != "nginx" {
+		t.Errorf("TemplateWithCRDs() templated %v, want the extracted chart nginx", name)
+	}
+}
+
+func TestTemplate_chartArchiveTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	content := []byte("pwned")
+	if err := tw.WriteHeader(&tar.Header{Name: "nginx/../../evil.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "nginx-0.1.0.tgz")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+esac`)
+
+	if _, err := Template(TemplateOptions{Release: "nginx", Chart: archivePath}); err == nil || !strings.Contains(err.Error(), "resolves outside of") {
+		t.Errorf("Template() error = %v, want the archive to be rejected", err)
+	}
+}
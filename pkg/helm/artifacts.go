@@ -0,0 +1,275 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	"github.com/evanlouie/go/pkg/manifest"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrArtifactNotFound is wrapped by errors of ArtifactStore for digests which
+// are not stored.
+var ErrArtifactNotFound = errcode.New(errcode.HelmArtifactNotFound, "artifact not found")
+
+// Artifact is the metadata record of a render stored in an ArtifactStore.
+type Artifact struct {
+	Digest       string    `json:"digest"` // sha256 of the rendered yaml stream, hex encoded
+	Repo         string    `json:"repo,omitempty"`
+	Chart        string    `json:"chart"`
+	Version      string    `json:"version,omitempty"`
+	ValuesDigest string    `json:"valuesDigest"` // see ValuesDigest
+	Created      time.Time `json:"created"`
+	Manifests    []string  `json:"manifests"` // ManifestDigest of each rendered manifest, in order
+}
+
+// ArtifactStore is a content-addressed store of render outputs. Each render is
+// written under the sha256 of its output with an Artifact record describing
+// what produced it, so renders are deduplicated and a manifest found in a
+// cluster or a pull request can be traced back to the chart, version and
+// values it was rendered from (see FindByManifest). The layout of Dir is:
+//   <Dir>/objects/<digest>.yaml   the rendered yaml stream
+//   <Dir>/artifacts/<digest>.json the Artifact record
+type ArtifactStore struct {
+	Dir string
+}
+
+func (s *ArtifactStore) objectPath(digest string) string {
+	return filepath.Join(s.Dir, "objects", digest+".yaml")
+}
+
+func (s *ArtifactStore) artifactPath(digest string) string {
+	return filepath.Join(s.Dir, "artifacts", digest+".json")
+}
+
+// ManifestDigest returns the sha256 of the yaml representation of m, hex
+// encoded. Keys are marshalled in order so equal manifests have equal digests.
+func ManifestDigest(m manifest.Manifest) (string, error) {
+	content, err := yaml.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf(`marshalling %s %s: %w`, m.GVK(), m.Name(), err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ValuesDigest returns the sha256 of the values opts renders with: the
+// contents of the Values files (downloading URLs), the Set flags and the
+// ValuesMap before ValuesResolvers are applied, so secrets injected by
+// resolvers are not part of the digest. Renders with equal values have equal digests.
+func ValuesDigest(opts TemplateOptions) (string, error) {
+	var parts []string
+	for _, valuesPath := range opts.Values {
+		content, err := opts.readValues(valuesPath)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "values", string(content))
+	}
+	for _, set := range opts.Set {
+		parts = append(parts, "set", set)
+	}
+	for _, valueMap := range opts.ValuesMap {
+		content, err := yaml.Marshal(valueMap)
+		if err != nil {
+			return "", fmt.Errorf(`marshalling values %+v: %w`, valueMap, err)
+		}
+		parts = append(parts, "map", string(content))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Put stores manifests rendered with opts. The output is addressed by its
+// content, so storing an identical render again returns the existing Artifact
+// with its original creation time.
+func (s *ArtifactStore) Put(opts TemplateOptions, manifests []manifest.Manifest) (Artifact, error) {
+	content, err := manifest.Encode(manifests)
+	if err != nil {
+		return Artifact{}, err
+	}
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	if existing, err := s.Get(digest); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, ErrArtifactNotFound) {
+		return Artifact{}, err
+	}
+
+	valuesDigest, err := ValuesDigest(opts)
+	if err != nil {
+		return Artifact{}, err
+	}
+	artifact := Artifact{
+		Digest:       digest,
+		Repo:         opts.Repo,
+		Chart:        opts.Chart,
+		Version:      opts.Version,
+		ValuesDigest: valuesDigest,
+		Created:      time.Now().UTC(),
+		Manifests:    []string{},
+	}
+	for _, m := range manifests {
+		manifestDigest, err := ManifestDigest(m)
+		if err != nil {
+			return Artifact{}, err
+		}
+		artifact.Manifests = append(artifact.Manifests, manifestDigest)
+	}
+	record, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		return Artifact{}, fmt.Errorf(`encoding artifact %s: %w`, digest, err)
+	}
+
+	// the object is written before the record so a listed artifact always has
+	// its output
+	if err := writeFileAtomic(s.objectPath(digest), content); err != nil {
+		return Artifact{}, fmt.Errorf(`storing artifact %s: %w`, digest, err)
+	}
+	if err := writeFileAtomic(s.artifactPath(digest), record); err != nil {
+		return Artifact{}, fmt.Errorf(`storing artifact %s: %w`, digest, err)
+	}
+	return artifact, nil
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames
+// it into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get returns the Artifact record of digest.
+func (s *ArtifactStore) Get(digest string) (Artifact, error) {
+	content, err := os.ReadFile(s.artifactPath(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return Artifact{}, fmt.Errorf(`reading artifact %s: %w`, digest, ErrArtifactNotFound)
+	} else if err != nil {
+		return Artifact{}, fmt.Errorf(`reading artifact %s: %w`, digest, err)
+	}
+	var artifact Artifact
+	if err := json.Unmarshal(content, &artifact); err != nil {
+		return Artifact{}, fmt.Errorf(`decoding artifact %s: %w`, digest, err)
+	}
+	return artifact, nil
+}
+
+// Manifests returns the rendered manifests of digest.
+func (s *ArtifactStore) Manifests(digest string) ([]manifest.Manifest, error) {
+	content, err := os.ReadFile(s.objectPath(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`reading artifact %s: %w`, digest, ErrArtifactNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf(`reading artifact %s: %w`, digest, err)
+	}
+	maps, err := yamlPlus.DecodeMaps(content)
+	if err != nil {
+		return nil, fmt.Errorf(`decoding artifact %s: %w`, digest, err)
+	}
+	return manifest.FromMaps(maps), nil
+}
+
+// List returns the records of all stored artifacts, newest first.
+func (s *ArtifactStore) List() ([]Artifact, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, "artifacts"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf(`listing artifacts in %s: %w`, s.Dir, err)
+	}
+	var artifacts []Artifact
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		artifact, err := s.Get(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	sort.SliceStable(artifacts, func(i, j int) bool {
+		return artifacts[i].Created.After(artifacts[j].Created)
+	})
+	return artifacts, nil
+}
+
+// FindByManifest answers "which render produced this manifest?": it returns
+// the artifacts whose output contains m, newest first.
+func (s *ArtifactStore) FindByManifest(m manifest.Manifest) ([]Artifact, error) {
+	digest, err := ManifestDigest(m)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var found []Artifact
+	for _, artifact := range artifacts {
+		for _, manifestDigest := range artifact.Manifests {
+			if manifestDigest == digest {
+				found = append(found, artifact)
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// Remove removes the artifact of digest from the store.
+func (s *ArtifactStore) Remove(digest string) error {
+	// the record is removed first so a partially removed artifact is not listed
+	for _, path := range []string{s.artifactPath(digest), s.objectPath(digest)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf(`removing artifact %s: %w`, digest, err)
+		}
+	}
+	return nil
+}
+
+// GarbageCollect removes the artifacts created before olderThan, except the
+// keep newest artifacts which are always retained. The digests of the removed
+// artifacts are returned.
+func (s *ArtifactStore) GarbageCollect(olderThan time.Time, keep int) ([]string, error) {
+	artifacts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for idx, artifact := range artifacts {
+		if idx < keep || !artifact.Created.Before(olderThan) {
+			continue
+		}
+		if err := s.Remove(artifact.Digest); err != nil {
+			return removed, err
+		}
+		removed = append(removed, artifact.Digest)
+	}
+	return removed, nil
+}
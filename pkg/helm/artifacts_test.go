@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+func TestArtifactStore(t *testing.T) {
+	store := &ArtifactStore{Dir: t.TempDir()}
+	configMap := func(name string) manifest.Manifest {
+		return manifest.Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": name}}
+	}
+	v1 := TemplateOptions{Repo: "https://charts.example.com", Chart: "web", Version: "1.0.0", Set: []string{"replicas=1"}}
+	v2 := v1
+	v2.Version, v2.Set = "2.0.0", []string{"replicas=2"}
+
+	first, err := store.Put(v1, []manifest.Manifest{configMap("shared"), configMap("old")})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	second, err := store.Put(v2, []manifest.Manifest{configMap("shared"), configMap("new")})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if first.Digest == second.Digest || first.ValuesDigest == second.ValuesDigest {
+		t.Errorf("Put() digests of different renders are equal: %+v, %+v", first, second)
+	}
+	again, err := store.Put(v2, []manifest.Manifest{configMap("shared"), configMap("old")})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if !reflect.DeepEqual(again, first) {
+		t.Errorf("Put() identical output = %+v, want existing %+v", again, first)
+	}
+
+	manifests, err := store.Manifests(second.Digest)
+	if err != nil {
+		t.Fatalf("Manifests() error = %v", err)
+	}
+	if len(manifests) != 2 || manifests[1].Name() != "new" {
+		t.Errorf("Manifests() = %v, want shared and new", manifests)
+	}
+
+	tests := []struct {
+		name     string
+		manifest manifest.Manifest
+		want     []string
+	}{
+		{"single", configMap("old"), []string{"web@1.0.0"}},
+		{"shared", configMap("shared"), []string{"web@2.0.0", "web@1.0.0"}},
+		{"none", configMap("other"), nil},
+	}
+	// order renders deterministically by backdating the first
+	first.Created = first.Created.Add(-48 * time.Hour)
+	record, _ := json.Marshal(first)
+	if err := os.WriteFile(store.artifactPath(first.Digest), record, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := store.FindByManifest(tt.manifest)
+			if err != nil {
+				t.Fatalf("FindByManifest() error = %v", err)
+			}
+			var got []string
+			for _, artifact := range found {
+				got = append(got, artifact.Chart+"@"+artifact.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindByManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	removed, err := store.GarbageCollect(time.Now().Add(-24*time.Hour), 0)
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{first.Digest}) {
+		t.Errorf("GarbageCollect() = %v, want %v", removed, []string{first.Digest})
+	}
+	if removed, _ := store.GarbageCollect(time.Now().Add(time.Hour), 1); len(removed) != 0 {
+		t.Errorf("GarbageCollect() keep 1 removed %v", removed)
+	}
+	if _, err := store.Get(first.Digest); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Get() removed error = %v, want ErrArtifactNotFound", err)
+	}
+}
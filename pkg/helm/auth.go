@@ -0,0 +1,106 @@
+package helm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// repoAuth holds the options used to authenticate and connect to a chart
+// repository.
+type repoAuth struct {
+	username              string
+	password              string
+	passCredentials       bool
+	caFile                string
+	certFile              string
+	keyFile               string
+	insecureSkipTLSVerify bool
+}
+
+// isSet determines if any authentication or TLS options have been provided.
+func (a repoAuth) isSet() bool {
+	return a != repoAuth{}
+}
+
+// args builds the helm flags used to authenticate and connect to a chart
+// repository.
+func (a repoAuth) args() []string {
+	var args []string
+	if a.username != "" {
+		args = append(args, "--username", a.username)
+	}
+	if a.password != "" {
+		args = append(args, "--password", a.password)
+	}
+	if a.passCredentials {
+		args = append(args, "--pass-credentials")
+	}
+	if a.caFile != "" {
+		args = append(args, "--ca-file", a.caFile)
+	}
+	if a.certFile != "" {
+		args = append(args, "--cert-file", a.certFile)
+	}
+	if a.keyFile != "" {
+		args = append(args, "--key-file", a.keyFile)
+	}
+	if a.insecureSkipTLSVerify {
+		args = append(args, "--insecure-skip-tls-verify")
+	}
+	return args
+}
+
+// httpClient returns a client connecting to a chart repository with the TLS
+// options of a, the same as helm does with args. Returns nil, for
+// http.DefaultClient, when no TLS options are set.
+func (a repoAuth) httpClient() (*http.Client, error) {
+	if a.caFile == "" && a.certFile == "" && a.keyFile == "" && !a.insecureSkipTLSVerify {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: a.insecureSkipTLSVerify}
+	if a.caFile != "" {
+		ca, err := os.ReadFile(a.caFile)
+		if err != nil {
+			return nil, fmt.Errorf(`reading CA bundle %s: %w`, a.caFile, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf(`reading CA bundle %s: no PEM certificates found`, a.caFile)
+		}
+	}
+	if a.certFile != "" || a.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(a.certFile, a.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf(`loading client certificate %s and key %s: %w`, a.certFile, a.keyFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
+
+// redactCommand returns the string form of cmd with the values of any
+// credential flags redacted so that it is safe to include in errors and logs.
+func redactCommand(cmd *exec.Cmd) string {
+	return strings.Join(redactArgs(cmd.Args), " ")
+}
+
+// redactArgs returns a copy of args with the values of --password replaced.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for idx := range redacted {
+		if redacted[idx] == "--password" && idx+1 < len(redacted) {
+			redacted[idx+1] = "REDACTED"
+		} else if strings.HasPrefix(redacted[idx], "--password=") {
+			redacted[idx] = "--password=REDACTED"
+		}
+	}
+	return redacted
+}
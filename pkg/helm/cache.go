@@ -0,0 +1,195 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/cache"
+	"github.com/evanlouie/go/pkg/filelock"
+)
+
+// ErrNotCacheable is wrapped by errors of ChartCache.Pull for charts which do
+// not specify an exact version.
+var ErrNotCacheable = errors.New("chart version is not cacheable")
+
+// ChartCache is an on-disk cache of pulled charts so repeated renders of the
+// same chart version do not re-download it.
+// Charts are stored extracted under <Dir>/<key>/<chart> where key is the
+// sha256 of the repository URL, chart and version. Only exact versions are
+// cached; pulls of the latest version or version ranges always bypass the
+// cache as what they resolve to changes over time.
+// When Shared is set, chart archives missing from Dir are read from it before
+// being pulled and pulled archives are written to it, so CI runners can share
+// charts via a remote cache (e.g. Redis or S3, see package cache).
+type ChartCache struct {
+	Dir    string
+	Shared cache.Cache // optional cache of chart archives shared between ChartCaches
+}
+
+// DefaultChartCache returns a ChartCache in the user cache directory (e.g.
+// ~/.cache/evanlouie/helm/charts on Linux).
+func DefaultChartCache() (*ChartCache, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf(`determining user cache directory: %w`, err)
+	}
+	return &ChartCache{Dir: filepath.Join(cacheDir, "evanlouie", "helm", "charts")}, nil
+}
+
+// cacheKey returns the content address of a chart version in a repository.
+func cacheKey(repoURL string, chart string, version string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{repoURL, chart, version}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// isCacheable determines if a chart version can be cached. Only exact
+// semantic versions (e.g. "1.2.3", not "" or "^1.2.0") are cacheable.
+func isCacheable(version string) bool {
+	_, err := parseSemVer(version)
+	return err == nil
+}
+
+// Pull returns the path of the chart described by opts in the cache, pulling
+// it into the cache first if it is not already present. opts.Into is ignored.
+// An error wrapping ErrNotCacheable is returned if the chart version is not
+// an exact version, unless opts.ResolveVersion resolves it to one.
+func (c *ChartCache) Pull(opts PullOptions) (string, error) {
+	opts, err := opts.withResolvedVersion()
+	if err != nil {
+		return "", err
+	}
+	chart, version := opts.Chart, opts.Version
+	if IsOCI(opts.RepoURL) || IsOCI(opts.Chart) {
+		ref, ociVersion, err := ociChartRef(opts.RepoURL, opts.Chart, opts.Version)
+		if err != nil {
+			return "", err
+		}
+		chart, version = ref, ociVersion
+	}
+	chartName := chart
+	if IsOCI(chart) {
+		chartName = ociChartName(chart)
+	}
+
+	if !isCacheable(version) {
+		return "", fmt.Errorf(`caching helm chart %s: %w: "%s"`, chart, ErrNotCacheable, version)
+	}
+
+	entryDir := filepath.Join(c.Dir, cacheKey(opts.RepoURL, opts.Chart, opts.Version))
+	chartPath := filepath.Join(entryDir, chartName)
+	if _, err := os.Stat(filepath.Join(chartPath, "Chart.yaml")); err == nil {
+		return chartPath, nil
+	}
+
+	// pull into a temporary directory in the cache and rename it into place so
+	// concurrent pulls never observe a partially extracted chart
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", fmt.Errorf(`creating chart cache directory %s: %w`, c.Dir, err)
+	}
+	// concurrent processes pulling the same chart version wait for the first
+	// rather than each downloading it
+	entryLock, err := filelock.Acquire(entryDir + ".lock")
+	if err != nil {
+		return "", fmt.Errorf(`locking chart cache entry of %s: %w`, chart, err)
+	}
+	defer entryLock.Release()
+	if _, err := os.Stat(filepath.Join(chartPath, "Chart.yaml")); err == nil {
+		return chartPath, nil
+	}
+	tmpDir, err := os.MkdirTemp(c.Dir, ".pull-")
+	if err != nil {
+		return "", fmt.Errorf(`creating temporary directory in chart cache %s: %w`, c.Dir, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	opts.Into = tmpDir
+	if err := c.pull(opts); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		// another process may have cached the same chart concurrently
+		if _, statErr := os.Stat(filepath.Join(chartPath, "Chart.yaml")); statErr == nil {
+			return chartPath, nil
+		}
+		return "", fmt.Errorf(`moving helm chart %s into chart cache %s: %w`, chart, c.Dir, err)
+	}
+
+	return chartPath, nil
+}
+
+// digestFile is the file of a cache entry holding the sha256 digest of the
+// archive the chart was extracted from.
+const digestFile = ".sha256"
+
+// pull pulls the chart archive of opts, via the shared cache when set, and
+// extracts it into opts.Into along with its digest.
+func (c *ChartCache) pull(opts PullOptions) error {
+	var archive []byte
+	var err error
+	if c.Shared == nil {
+		if archive, err = pullArchive(opts); err != nil {
+			return err
+		}
+	} else {
+		key := cacheKey(opts.RepoURL, opts.Chart, opts.Version)
+		archive, err = c.Shared.Get(key)
+		if errors.Is(err, cache.ErrMiss) {
+			if archive, err = pullArchive(opts); err != nil {
+				return err
+			}
+			if err := c.Shared.Set(key, archive, 0); err != nil {
+				return fmt.Errorf(`caching helm chart %s in shared cache: %w`, opts.Chart, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf(`reading helm chart %s from shared cache: %w`, opts.Chart, err)
+		}
+	}
+	digest, err := opts.verifyDigest(archive)
+	if err != nil {
+		return err
+	}
+	if err := extractArchive(archive, longPath(opts.Into)); err != nil {
+		return fmt.Errorf(`extracting chart archive of %s: %w`, opts.Chart, err)
+	}
+	if err := os.WriteFile(filepath.Join(opts.Into, digestFile), []byte(digest), 0644); err != nil {
+		return fmt.Errorf(`recording digest of helm chart %s: %w`, opts.Chart, err)
+	}
+	return nil
+}
+
+// Digest returns the sha256 digest (hex encoded) of the archive a cached chart
+// version was extracted from. Charts cached before digests were recorded
+// return an empty digest.
+func (c *ChartCache) Digest(repoURL string, chart string, version string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(c.Dir, cacheKey(repoURL, chart, version), digestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf(`reading digest of helm chart %s@%s from chart cache: %w`, chart, version, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Remove removes a chart version from the cache.
+func (c *ChartCache) Remove(repoURL string, chart string, version string) error {
+	entryDir := filepath.Join(c.Dir, cacheKey(repoURL, chart, version))
+	if err := os.RemoveAll(entryDir); err != nil {
+		return fmt.Errorf(`removing %s from chart cache: %w`, entryDir, err)
+	}
+	if c.Shared != nil {
+		return c.Shared.Delete(cacheKey(repoURL, chart, version))
+	}
+	return nil
+}
+
+// Purge removes all charts from the cache.
+func (c *ChartCache) Purge() error {
+	if err := os.RemoveAll(c.Dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(`purging chart cache %s: %w`, c.Dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package helm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/cache"
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestChartCache_Pull(t *testing.T) {
+	cache := &ChartCache{Dir: t.TempDir()}
+	repoURL := "https://charts.example.com"
+
+	// pre-populate the cache so helm is not needed
+	entryDir := filepath.Join(cache.Dir, cacheKey(repoURL, "nginx", "1.2.3"))
+	if _, err := helmtest.WriteChart(entryDir, helmtest.Chart{Name: "nginx", Version: "1.2.3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cache.Pull(PullOptions{RepoURL: repoURL, Chart: "nginx", Version: "1.2.3"})
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if want := filepath.Join(entryDir, "nginx"); got != want {
+		t.Errorf("Pull() = %v, want %v", got, want)
+	}
+
+	for _, version := range []string{"", "^1.2.0", "~1.2"} {
+		if _, err := cache.Pull(PullOptions{RepoURL: repoURL, Chart: "nginx", Version: version}); !errors.Is(err, ErrNotCacheable) {
+			t.Errorf("Pull() version %q error = %v, want ErrNotCacheable", version, err)
+		}
+	}
+
+	if err := cache.Remove(repoURL, "nginx", "1.2.3"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := cache.Purge(); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+}
+
+func TestChartCache_Pull_shared(t *testing.T) {
+	// helm fails, so charts can only come from the shared cache
+	useFakeHelm(t, `echo "Error: failed to download" >&2; exit 1`)
+	repoURL := "https://charts.example.com"
+	archive, err := os.ReadFile(helmtest.NewChartArchive(t, helmtest.Chart{Name: "nginx", Version: "1.2.3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := &cache.Memory{}
+	if err := shared.Set(cacheKey(repoURL, "nginx", "1.2.3"), archive, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	chartCache := &ChartCache{Dir: t.TempDir(), Shared: shared}
+	got, err := chartCache.Pull(PullOptions{RepoURL: repoURL, Chart: "nginx", Version: "1.2.3"})
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(got, "Chart.yaml")); err != nil {
+		t.Errorf("Pull() did not extract the shared archive: %v", err)
+	}
+	if _, err := chartCache.Pull(PullOptions{RepoURL: repoURL, Chart: "nginx", Version: "2.0.0"}); !errors.Is(err, ErrChartNotFound) {
+		t.Errorf("Pull() uncached error = %v, want ErrChartNotFound", err)
+	}
+
+	if err := chartCache.Remove(repoURL, "nginx", "1.2.3"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := shared.Get(cacheKey(repoURL, "nginx", "1.2.3")); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("Remove() did not remove the chart from the shared cache: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package helm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Feature is an optional feature of the helm client which is only available
+// in some versions of helm.
+type Feature string
+
+const (
+	FeatureIncludeCRDs     Feature = "--include-crds"
+	FeaturePostRenderer    Feature = "--post-renderer"
+	FeaturePassCredentials Feature = "--pass-credentials"
+	FeatureOCI             Feature = "OCI registries"
+)
+
+// featureMinVersions are the first helm versions supporting each Feature.
+var featureMinVersions = map[Feature]string{
+	FeatureIncludeCRDs:     "3.1.0",
+	FeaturePostRenderer:    "3.1.0",
+	FeaturePassCredentials: "3.6.1",
+	FeatureOCI:             "3.8.0",
+}
+
+// Plugin is a helm plugin installed on the host helm client.
+type Plugin struct {
+	Name        string
+	Version     string
+	Description string
+}
+
+// CapabilitySet is the set of features supported by a helm client.
+type CapabilitySet struct {
+	Version  BuildInfo
+	Features map[Feature]bool
+	Plugins  []Plugin
+}
+
+// Capabilities probes the helm client on the PATH for its version and
+// installed plugins and returns the features it supports.
+func Capabilities() (CapabilitySet, error) {
+	v, err := Version()
+	if err != nil {
+		return CapabilitySet{}, err
+	}
+	capabilities := newCapabilitySet(v)
+	capabilities.Plugins, err = PluginList()
+	if err != nil {
+		return capabilities, err
+	}
+	return capabilities, nil
+}
+
+// newCapabilitySet computes the features supported by helm version v. OCI
+// registries are also supported prior to Helm v3.8.0 when the
+// HELM_EXPERIMENTAL_OCI environment variable is set.
+func newCapabilitySet(v BuildInfo) CapabilitySet {
+	capabilities := CapabilitySet{Version: v, Features: map[Feature]bool{}}
+	if _, _, _, err := v.SemVer(); err != nil {
+		return capabilities
+	}
+	for feature, minVersion := range featureMinVersions {
+		parsedMin, err := parseSemVer(minVersion)
+		if err != nil {
+			continue
+		}
+		capabilities.Features[feature] = v.AtLeast(parsedMin.major, parsedMin.minor, parsedMin.patch)
+	}
+	if v.AtLeast(3, 0, 0) && os.Getenv("HELM_EXPERIMENTAL_OCI") != "" {
+		capabilities.Features[FeatureOCI] = true
+	}
+	return capabilities
+}
+
+// Supports determines if feature is supported.
+func (c CapabilitySet) Supports(feature Feature) bool {
+	return c.Features[feature]
+}
+
+// HasPlugin determines if a plugin with the given name is installed.
+func (c CapabilitySet) HasPlugin(name string) bool {
+	for _, plugin := range c.Plugins {
+		if plugin.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Require returns an error listing all features which are not supported.
+func (c CapabilitySet) Require(features ...Feature) error {
+	var unsupported []string
+	for _, feature := range features {
+		if !c.Supports(feature) {
+			unsupported = append(unsupported, fmt.Sprintf("%s (requires helm >= v%s)", feature, featureMinVersions[feature]))
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	sort.Strings(unsupported)
+	return fmt.Errorf(`helm %s does not support %s`, c.Version.Version, strings.Join(unsupported, ", "))
+}
+
+// requiredFeatures returns the helm features needed to template with opts.
+func (opts TemplateOptions) requiredFeatures() []Feature {
+	var features []Feature
+	if opts.IncludeCRDs {
+		features = append(features, FeatureIncludeCRDs)
+	}
+	if opts.PostRenderer != "" {
+		features = append(features, FeaturePostRenderer)
+	}
+	if opts.PassCredentials {
+		features = append(features, FeaturePassCredentials)
+	}
+	if IsOCI(opts.Repo) || IsOCI(opts.Chart) {
+		features = append(features, FeatureOCI)
+	}
+	return features
+}
+
+// requiredFeatures returns the helm features needed to pull with opts.
+func (opts PullOptions) requiredFeatures() []Feature {
+	var features []Feature
+	if opts.PassCredentials {
+		features = append(features, FeaturePassCredentials)
+	}
+	if IsOCI(opts.RepoURL) || IsOCI(opts.Chart) {
+		features = append(features, FeatureOCI)
+	}
+	return features
+}
+
+// requireFeatures fails fast if the helm client does not support features.
+// If the helm version cannot be determined, no error is returned and helm is
+// left to report any unsupported flags.
+func requireFeatures(features []Feature) error {
+	if len(features) == 0 {
+		return nil
+	}
+	v, err := Version()
+	if err != nil {
+		return nil
+	}
+	return newCapabilitySet(v).Require(features...)
+}
+
+// PluginList runs `helm plugin list` and parses the installed plugins.
+func PluginList() ([]Plugin, error) {
+	cmd := helmCommand("plugin", "list")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, newCommandError(cmd, stderr.String(), err)
+	}
+	return parsePluginList(stdout.String()), nil
+}
+
+// PluginInstall runs `helm plugin install <source> [--version <version>]`
+// unless a plugin named name is already installed, e.g:
+//   helm.PluginInstall("diff", helm.HelmDiffPlugin, "v3.1.3")
+// source is a URL or path of the plugin and version is the tag to install.
+// The installed plugins are checked again while holding the host
+// configuration lock so concurrent installs do not fail with "plugin already
+// exists".
+func PluginInstall(name string, source string, version string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	plugins, err := PluginList()
+	if err != nil {
+		return err
+	}
+	for _, plugin := range plugins {
+		if plugin.Name == name {
+			return nil
+		}
+	}
+	installArgs := []string{"plugin", "install", source}
+	if version != "" {
+		installArgs = append(installArgs, "--version", version)
+	}
+	installCmd := helmCommand(installArgs...)
+	var stdout, stderr bytes.Buffer
+	installCmd.Stdout = &stdout
+	installCmd.Stderr = &stderr
+	if err := installCmd.Run(); err != nil {
+		return newCommandError(installCmd, stderr.String(), err)
+	}
+	return nil
+}
+
+// parsePluginList parses the tab separated table outputted by
+// `helm plugin list`:
+//   NAME    VERSION DESCRIPTION
+//   diff    3.1.3   Preview helm upgrade changes as a diff
+func parsePluginList(output string) []Plugin {
+	var plugins []Plugin
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		columns := strings.SplitN(scanner.Text(), "\t", 3)
+		for idx := range columns {
+			columns[idx] = strings.TrimSpace(columns[idx])
+		}
+		if columns[0] == "" || columns[0] == "NAME" {
+			continue
+		}
+		plugin := Plugin{Name: columns[0]}
+		if len(columns) > 1 {
+			plugin.Version = columns[1]
+		}
+		if len(columns) > 2 {
+			plugin.Description = columns[2]
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins
+}
@@ -0,0 +1,49 @@
+package helm
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCapabilitySet_Require(t *testing.T) {
+	if experimental, ok := os.LookupEnv("HELM_EXPERIMENTAL_OCI"); ok {
+		os.Unsetenv("HELM_EXPERIMENTAL_OCI")
+		defer os.Setenv("HELM_EXPERIMENTAL_OCI", experimental)
+	}
+	tests := []struct {
+		name     string
+		version  string
+		features []Feature
+		wantErr  bool
+	}{
+		{"no features", "v3.0.0", nil, false},
+		{"include crds supported", "v3.1.0", []Feature{FeatureIncludeCRDs}, false},
+		{"include crds unsupported", "v3.0.3", []Feature{FeatureIncludeCRDs}, true},
+		{"oci supported", "v3.8.0", []Feature{FeatureOCI, FeaturePassCredentials}, false},
+		{"oci unsupported", "v3.2.4", []Feature{FeatureOCI}, true},
+		{"prerelease", "v3.8.0-rc.1", []Feature{FeatureOCI}, false},
+		{"unparsable version", "unknown", []Feature{FeatureIncludeCRDs}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newCapabilitySet(BuildInfo{Version: tt.version}).Require(tt.features...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Require() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parsePluginList(t *testing.T) {
+	output := "NAME   \tVERSION\tDESCRIPTION\n" +
+		"diff   \t3.1.3  \tPreview helm upgrade changes as a diff\n" +
+		"secrets\t3.12.0 \tThis plugin provides secrets values encryption for Helm charts secure storing\n"
+	want := []Plugin{
+		{Name: "diff", Version: "3.1.3", Description: "Preview helm upgrade changes as a diff"},
+		{Name: "secrets", Version: "3.12.0", Description: "This plugin provides secrets values encryption for Helm charts secure storing"},
+	}
+	if got := parsePluginList(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePluginList() = %v, want %v", got, want)
+	}
+}
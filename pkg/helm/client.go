@@ -0,0 +1,52 @@
+package helm
+
+import (
+	"sync"
+)
+
+// Client configures how the helm binary is executed by this package. It
+// applies to every helm invocation (e.g. Template, Pull and Version).
+type Client struct {
+	HelmBinary string   // path to the helm binary. defaults to "helm", resolved via PATH
+	Env        []string // additional KEY=VALUE environment variables (e.g. HELM_CONFIG_HOME or KUBECONFIG). take precedence over inherited variables and are passed even when SanitizeEnv is enabled
+	Dir        string   // working directory of helm. relative chart and values paths are resolved against it. defaults to the working directory of the current process
+}
+
+var (
+	clientLock sync.RWMutex
+	client     Client
+)
+
+// SetClient configures the helm binary, extra environment and working
+// directory used for all subsequent helm invocations. Use this to pin a
+// specific helm version or to point helm at a non-default configuration
+// (e.g. HELM_CONFIG_HOME) without changing the environment of the current
+// process.
+func SetClient(c Client) {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+	client = Client{
+		HelmBinary: c.HelmBinary,
+		Env:        append([]string{}, c.Env...),
+		Dir:        c.Dir,
+	}
+}
+
+// CurrentClient returns the Client configured via SetClient.
+func CurrentClient() Client {
+	clientLock.RLock()
+	defer clientLock.RUnlock()
+	return Client{
+		HelmBinary: client.HelmBinary,
+		Env:        append([]string{}, client.Env...),
+		Dir:        client.Dir,
+	}
+}
+
+// binary returns the helm binary to execute.
+func (c Client) binary() string {
+	if c.HelmBinary == "" {
+		return "helm"
+	}
+	return c.HelmBinary
+}
@@ -0,0 +1,51 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeHelmVersion is the `helm version` output of fake helm binaries.
+const fakeHelmVersion = `version.BuildInfo{Version:"v3.7.1", GitCommit:"abc123", GitTreeState:"clean", GoVersion:"go1.16.9"}`
+
+// useFakeHelm writes a shell script to a temporary directory and configures
+// the Client to use it as the helm binary for the rest of the test. body
+// receives the helm arguments as "$@".
+func useFakeHelm(t *testing.T, body string) (binary string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake helm binary is a shell script")
+	}
+	binary = filepath.Join(t.TempDir(), "helm")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	previous := CurrentClient()
+	t.Cleanup(func() { SetClient(previous) })
+	SetClient(Client{HelmBinary: binary})
+	return binary
+}
+
+func TestSetClient(t *testing.T) {
+	binary := useFakeHelm(t, `echo "version.BuildInfo{Version:\"v3.7.1\", GitCommit:\"$FAKE_COMMIT\", GitTreeState:\"clean\", GoVersion:\"go1.16.9\"}"`)
+	dir := t.TempDir()
+	SetClient(Client{HelmBinary: binary, Env: []string{"FAKE_COMMIT=abc123"}, Dir: dir})
+
+	cmd := helmCommand("version")
+	if cmd.Path != binary {
+		t.Errorf("helmCommand().Path = %s, want %s", cmd.Path, binary)
+	}
+	if cmd.Dir != dir {
+		t.Errorf("helmCommand().Dir = %s, want %s", cmd.Dir, dir)
+	}
+
+	v, err := Version()
+	if err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if want := (BuildInfo{Version: "v3.7.1", GitCommit: "abc123", GitTreeState: "clean", GoVersion: "go1.16.9"}); v != want {
+		t.Errorf("Version() = %+v, want %+v", v, want)
+	}
+}
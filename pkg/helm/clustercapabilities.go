@@ -0,0 +1,126 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubectlBinary is the kubectl used to query clusters.
+var kubectlBinary = "kubectl"
+
+// ClusterCapabilities are the capabilities of a Kubernetes cluster charts can
+// inspect via .Capabilities: its version and the API versions it serves.
+// Snapshots written with WriteFile can be loaded into TemplateOptions via
+// CapabilitiesFile so offline renders (e.g. in CI without cluster access)
+// match a specific real cluster.
+type ClusterCapabilities struct {
+	KubeVersion string    `yaml:"kubeVersion"` // e.g: "v1.21.2"
+	APIVersions []string  `yaml:"apiVersions"` // group/versions served by the cluster in lexical order. e.g: ["apps/v1", "monitoring.coreos.com/v1", "v1"]
+	Captured    time.Time `yaml:"captured"`
+}
+
+// SnapshotCapabilities queries the version and API versions of the cluster of
+// the current context of kubeconfig via kubectl. An empty kubeconfig uses the
+// default configuration of kubectl ($KUBECONFIG or ~/.kube/config).
+func SnapshotCapabilities(kubeconfig string) (ClusterCapabilities, error) {
+	var args []string
+	if kubeconfig != "" {
+		args = append(args, "--kubeconfig", kubeconfig)
+	}
+
+	versionOutput, err := kubectl(append([]string{"version", "--output", "json"}, args...)...)
+	if err != nil {
+		return ClusterCapabilities{}, err
+	}
+	var version struct {
+		ServerVersion *struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal(versionOutput, &version); err != nil {
+		return ClusterCapabilities{}, fmt.Errorf(`parsing output of "kubectl version": %w`, err)
+	}
+	if version.ServerVersion == nil || version.ServerVersion.GitVersion == "" {
+		return ClusterCapabilities{}, fmt.Errorf(`"kubectl version" did not report a server version`)
+	}
+
+	apiVersionsOutput, err := kubectl(append([]string{"api-versions"}, args...)...)
+	if err != nil {
+		return ClusterCapabilities{}, err
+	}
+	apiVersions := strings.Fields(string(apiVersionsOutput))
+	sort.Strings(apiVersions)
+
+	return ClusterCapabilities{
+		KubeVersion: version.ServerVersion.GitVersion,
+		APIVersions: apiVersions,
+		Captured:    time.Now().UTC(),
+	}, nil
+}
+
+// kubectl runs kubectl with args, returning its stdout.
+func kubectl(args ...string) ([]byte, error) {
+	// the process is killed when Shutdown is called
+	cmd := exec.CommandContext(shutdown.ctx, kubectlBinary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(`running "%s": %w: %s`, strings.Join(cmd.Args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// WriteFile writes the snapshot to path as yaml.
+func (c ClusterCapabilities) WriteFile(path string) error {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf(`marshalling cluster capabilities: %w`, err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf(`writing cluster capabilities to %s: %w`, path, err)
+	}
+	return nil
+}
+
+// LoadCapabilities reads a snapshot written by ClusterCapabilities.WriteFile.
+func LoadCapabilities(path string) (ClusterCapabilities, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ClusterCapabilities{}, fmt.Errorf(`reading cluster capabilities %s: %w`, path, err)
+	}
+	var c ClusterCapabilities
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return ClusterCapabilities{}, fmt.Errorf(`parsing cluster capabilities %s: %w`, path, err)
+	}
+	return c, nil
+}
+
+// withCapabilitiesFile returns opts with the KubeVersion and APIVersions of
+// its CapabilitiesFile. KubeVersion and APIVersions set explicitly take
+// precedence over the snapshot.
+func (opts TemplateOptions) withCapabilitiesFile() (TemplateOptions, error) {
+	if opts.CapabilitiesFile == "" {
+		return opts, nil
+	}
+	c, err := LoadCapabilities(opts.CapabilitiesFile)
+	if err != nil {
+		return opts, err
+	}
+	if opts.KubeVersion == "" {
+		opts.KubeVersion = c.KubeVersion
+	}
+	if len(opts.APIVersions) == 0 {
+		opts.APIVersions = c.APIVersions
+	}
+	opts.CapabilitiesFile = ""
+	return opts, nil
+}
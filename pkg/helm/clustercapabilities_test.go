@@ -0,0 +1,71 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSnapshotCapabilities(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake kubectl binary is a shell script")
+	}
+	dir := t.TempDir()
+	fakeKubectl := filepath.Join(dir, "kubectl")
+	script := `#!/bin/sh
+[ "$2" = "--kubeconfig" ] || [ "$4" = "--kubeconfig" ] || { echo "missing --kubeconfig" >&2; exit 1; }
+case "$1" in
+version) echo '{"clientVersion": {"gitVersion": "v1.22.0"}, "serverVersion": {"gitVersion": "v1.21.2"}}' ;;
+api-versions) printf 'v1\napps/v1\nmonitoring.coreos.com/v1\n' ;;
+esac
+`
+	if err := os.WriteFile(fakeKubectl, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	previous := kubectlBinary
+	kubectlBinary = fakeKubectl
+	defer func() { kubectlBinary = previous }()
+
+	snapshot, err := SnapshotCapabilities(filepath.Join(dir, "kubeconfig"))
+	if err != nil {
+		t.Fatalf("SnapshotCapabilities() error = %v", err)
+	}
+	want := ClusterCapabilities{KubeVersion: "v1.21.2", APIVersions: []string{"apps/v1", "monitoring.coreos.com/v1", "v1"}}
+	if snapshot.KubeVersion != want.KubeVersion || !reflect.DeepEqual(snapshot.APIVersions, want.APIVersions) {
+		t.Errorf("SnapshotCapabilities() = %+v, want %+v", snapshot, want)
+	}
+
+	path := filepath.Join(dir, "capabilities.yaml")
+	if err := snapshot.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		opts TemplateOptions
+		want []string
+	}{
+		{
+			name: "snapshot",
+			opts: TemplateOptions{Chart: "./chart", CapabilitiesFile: path},
+			want: []string{"template", "--kube-version", "v1.21.2", "--api-versions", "apps/v1", "--api-versions", "monitoring.coreos.com/v1", "--api-versions", "v1", "./chart"},
+		},
+		{
+			name: "explicit-precedence",
+			opts: TemplateOptions{Chart: "./chart", CapabilitiesFile: path, KubeVersion: "v1.20.0", APIVersions: []string{"v1"}},
+			want: []string{"template", "--kube-version", "v1.20.0", "--api-versions", "v1", "./chart"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TemplateCommand(tt.opts)
+			if err != nil {
+				t.Fatalf("TemplateCommand() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TemplateCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
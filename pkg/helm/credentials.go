@@ -0,0 +1,101 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credentials are the username and password (or token) used to authenticate
+// with a chart repository or OCI registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialProvider resolves the credentials for a chart repository or OCI
+// registry at the time they are needed, so credentials do not need to be
+// embedded in plaintext in option structs.
+// Implementations may read from the environment, files, Vault or cloud secret
+// managers. url is the repository URL or OCI registry reference being
+// accessed.
+type CredentialProvider interface {
+	Credentials(url string) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function into a CredentialProvider.
+type CredentialProviderFunc func(url string) (Credentials, error)
+
+// Credentials calls f(url).
+func (f CredentialProviderFunc) Credentials(url string) (Credentials, error) {
+	return f(url)
+}
+
+// EnvCredentials reads credentials from environment variables.
+type EnvCredentials struct {
+	UsernameVar string // name of the environment variable holding the username
+	PasswordVar string // name of the environment variable holding the password
+}
+
+// Credentials reads the environment variables of e. An error is returned if
+// either variable is not set.
+func (e EnvCredentials) Credentials(url string) (Credentials, error) {
+	var creds Credentials
+	for _, target := range []struct {
+		name  string
+		value *string
+	}{{e.UsernameVar, &creds.Username}, {e.PasswordVar, &creds.Password}} {
+		value, ok := os.LookupEnv(target.name)
+		if !ok {
+			return Credentials{}, fmt.Errorf(`reading credentials for %s: environment variable %s is not set`, url, target.name)
+		}
+		*target.value = value
+	}
+	return creds, nil
+}
+
+// FileCredentials reads credentials from files, such as secrets mounted into a
+// container. Trailing newlines are removed from the file contents.
+type FileCredentials struct {
+	UsernameFile string // path of the file holding the username
+	PasswordFile string // path of the file holding the password
+}
+
+// Credentials reads the files of f.
+func (f FileCredentials) Credentials(url string) (Credentials, error) {
+	var creds Credentials
+	for _, target := range []struct {
+		path  string
+		value *string
+	}{{f.UsernameFile, &creds.Username}, {f.PasswordFile, &creds.Password}} {
+		content, err := os.ReadFile(target.path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf(`reading credentials for %s: %w`, url, err)
+		}
+		*target.value = strings.TrimRight(string(content), "\r\n")
+	}
+	return creds, nil
+}
+
+// resolveCredentials sets username and password from provider if provider is
+// set and neither username nor password have been explicitly provided.
+func resolveCredentials(provider CredentialProvider, url string, username *string, password *string) error {
+	if provider == nil || *username != "" || *password != "" {
+		return nil
+	}
+	creds, err := provider.Credentials(url)
+	if err != nil {
+		return fmt.Errorf(`resolving credentials for %s: %w`, url, err)
+	}
+	*username, *password = creds.Username, creds.Password
+	return nil
+}
+
+// credentialURL returns the URL credentials are resolved for when accessing
+// chart in repo: the repo URL, or the chart reference for OCI charts.
+func credentialURL(repo string, chart string) string {
+	if repo == "" && IsOCI(chart) {
+		return chart
+	}
+	return repo
+}
@@ -0,0 +1,48 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_resolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"username": "file-user\n", "password": "file-pass\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	static := CredentialProviderFunc(func(url string) (Credentials, error) {
+		return Credentials{Username: "func-user", Password: "func-pass"}, nil
+	})
+
+	tests := []struct {
+		name         string
+		provider     CredentialProvider
+		username     string
+		password     string
+		wantUsername string
+		wantPassword string
+		wantErr      bool
+	}{
+		{"no provider", nil, "", "", "", "", false},
+		{"func", static, "", "", "func-user", "func-pass", false},
+		{"explicit credentials take precedence", static, "user", "", "user", "", false},
+		{"file", FileCredentials{UsernameFile: filepath.Join(dir, "username"), PasswordFile: filepath.Join(dir, "password")}, "", "", "file-user", "file-pass", false},
+		{"missing file", FileCredentials{UsernameFile: filepath.Join(dir, "missing"), PasswordFile: filepath.Join(dir, "password")}, "", "", "", "", true},
+		{"missing env", EnvCredentials{UsernameVar: "HELM_TEST_UNSET_USERNAME", PasswordVar: "HELM_TEST_UNSET_PASSWORD"}, "", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, password := tt.username, tt.password
+			err := resolveCredentials(tt.provider, "https://charts.example.com", &username, &password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if username != tt.wantUsername || password != tt.wantPassword {
+				t.Errorf("resolveCredentials() = %s:%s, want %s:%s", username, password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
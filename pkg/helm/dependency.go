@@ -0,0 +1,54 @@
+package helm
+
+import (
+	"bytes"
+)
+
+// DependencyUpdate runs `helm dependency update` on the chart at chartPath,
+// resolving the dependencies listed in its Chart.yaml, writing a Chart.lock
+// and downloading the dependencies into the "charts" directory of the chart.
+// Repositories of the host helm client are refreshed as part of the update.
+func DependencyUpdate(chartPath string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	return runDependency(nil, "update", chartPath)
+}
+
+// DependencyBuild runs `helm dependency build` on the chart at chartPath,
+// downloading the dependencies pinned in its Chart.lock into the "charts"
+// directory of the chart.
+func DependencyBuild(chartPath string) error {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return runDependency(nil, "build", chartPath)
+}
+
+// dependencyUpdate is DependencyUpdate run in isolated. The host helm client
+// is not locked when isolated as its configuration is not used.
+func dependencyUpdate(isolated *isolatedConfig, chartPath string) error {
+	if isolated == nil {
+		return DependencyUpdate(chartPath)
+	}
+	return runDependency(isolated, "update", chartPath)
+}
+
+// runDependency runs `helm dependency <subcommand> <chartPath>` in isolated.
+func runDependency(isolated *isolatedConfig, subcommand string, chartPath string) error {
+	cmd := isolated.command("dependency", subcommand, chartPath)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return newCommandError(cmd, stderr.String(), err)
+	}
+
+	return nil
+}
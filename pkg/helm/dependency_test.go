@@ -0,0 +1,59 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestDependency(t *testing.T) {
+	// the fake helm resolves the dependencies of the chart into a Chart.lock on
+	// update and only downloads the locked dependencies on build
+	useFakeHelm(t, `case "$2" in
+update) echo 'dependencies: []' > "$3/Chart.lock" ;;
+build) [ -f "$3/Chart.lock" ] || { echo 'Error: the lock file (Chart.lock) is out of sync with the dependencies file (Chart.yaml). Please update the dependencies' >&2; exit 1; } ;;
+esac
+mkdir -p "$3/charts" && : > "$3/charts/common-1.0.0.tgz"`)
+
+	tests := []struct {
+		name      string
+		chart     helmtest.Chart
+		locked    bool
+		run       func(chartPath string) error
+		wantFiles []string
+		wantErr   string
+	}{
+		{"update", helmtest.Chart{}, false, DependencyUpdate, []string{"Chart.lock", "charts/common-1.0.0.tgz"}, ""},
+		{"update path with spaces", helmtest.Chart{Name: "my chart"}, false, DependencyUpdate, []string{"Chart.lock", "charts/common-1.0.0.tgz"}, ""},
+		{"build", helmtest.Chart{}, true, DependencyBuild, []string{"charts/common-1.0.0.tgz"}, ""},
+		{"build without lock", helmtest.Chart{}, false, DependencyBuild, nil, "out of sync"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartPath := helmtest.NewChart(t, tt.chart)
+			if tt.locked {
+				if err := os.WriteFile(filepath.Join(chartPath, "Chart.lock"), []byte("dependencies: []\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := tt.run(chartPath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			for _, file := range tt.wantFiles {
+				if _, err := os.Stat(filepath.Join(chartPath, file)); err != nil {
+					t.Errorf("%s not written: %v", file, err)
+				}
+			}
+		})
+	}
+}
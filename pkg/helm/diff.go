@@ -0,0 +1,180 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+// Diff renders the charts described by before and after (e.g. the same chart
+// at two versions, or with two sets of values) via TemplateManifests and
+// compares the renders resource by resource. The unified diff of the yaml of
+// each change is available via manifest.Change.UnifiedDiff, e.g. to review
+// what an upgrade will change:
+//   before := helm.TemplateOptions{Repo: repo, Chart: "nginx", Version: "1.0.0"}
+//   after := before
+//   after.Version = "2.0.0"
+//   diff, err := helm.Diff(before, after)
+//   ...
+//   fmt.Print(diff.Summary())
+//   unified, err := diff.UnifiedDiff()
+func Diff(before TemplateOptions, after TemplateOptions) (manifest.Diff, error) {
+	beforeManifests, err := TemplateManifests(before)
+	if err != nil {
+		return manifest.Diff{}, fmt.Errorf(`rendering helm chart %s for diff: %w`, before.Chart, err)
+	}
+	afterManifests, err := TemplateManifests(after)
+	if err != nil {
+		return manifest.Diff{}, fmt.Errorf(`rendering helm chart %s for diff: %w`, after.Chart, err)
+	}
+	return manifest.DiffManifests(beforeManifests, afterManifests)
+}
+
+// HelmDiffPlugin is the source DiffUpgrade installs the helm-diff plugin from
+// when it is not installed.
+const HelmDiffPlugin = "https://github.com/databus23/helm-diff"
+
+// DiffUpgrade previews the changes `helm upgrade` of release to the chart of
+// opts would make to the cluster via the helm-diff plugin, installing the
+// plugin from HelmDiffPlugin if missing. Releases which are not installed yet
+// are diffed as if they were empty. e.g. to review a deploy:
+//   diff, err := helm.DiffUpgrade("ingress", helm.TemplateOptions{Repo: repo, Chart: "ingress-nginx", Version: "4.0.6", Namespace: "ingress"})
+//   ...
+//   fmt.Print(diff.Summary())
+// helm-diff only reports the resources which change so the Before and After
+// of each manifest.Change are not set. Charts in repositories requiring
+// credentials or TLS options must be added to the host helm client.
+func DiffUpgrade(release string, opts TemplateOptions) (manifest.Diff, error) {
+	if err := PluginInstall("diff", HelmDiffPlugin, ""); err != nil {
+		return manifest.Diff{}, fmt.Errorf(`installing helm-diff plugin: %w`, err)
+	}
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.Repo, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return manifest.Diff{}, err
+	}
+	repo, chart, version, err := resolveChart(opts.Repo, opts.Chart, opts.Version, opts.repoAuth(), true)
+	if err != nil {
+		return manifest.Diff{}, err
+	}
+	if repo != "" && opts.repoAuth().isSet() {
+		return manifest.Diff{}, fmt.Errorf(`diffing release %s: helm-diff does not support credentials or TLS options for repository %s; add it to the host helm client`, release, repo)
+	}
+
+	diffArgs := []string{"diff", "upgrade", release, chart, "--output", "json", "--allow-unreleased"}
+	if repo != "" {
+		diffArgs = append(diffArgs, "--repo", repo)
+	}
+	if version != "" {
+		diffArgs = append(diffArgs, "--version", version)
+	}
+	if opts.Devel {
+		diffArgs = append(diffArgs, "--devel")
+	}
+	if opts.Namespace != "" {
+		diffArgs = append(diffArgs, "--namespace", opts.Namespace)
+	}
+	for _, set := range opts.Set {
+		diffArgs = append(diffArgs, "--set", set)
+	}
+	valuesMaps, err := resolveValues(opts.ValuesMap, opts.ValuesResolvers)
+	if err != nil {
+		return manifest.Diff{}, err
+	}
+	values := opts.Values
+	if len(remoteValues(opts.Values)) > 0 || len(opts.valuesTemplates()) > 0 || len(valuesMaps) > 0 {
+		valuesDir, err := mkdirTemp("fabrikate")
+		if err != nil {
+			return manifest.Diff{}, fmt.Errorf(`creating temporary directory for values of release %s: %w`, release, err)
+		}
+		defer removeTemp(valuesDir)
+		if values, err = opts.downloadValues(valuesDir); err != nil {
+			return manifest.Diff{}, err
+		}
+		// in-memory values are written after the downloaded values, which use the same file names
+		mapsDir := filepath.Join(valuesDir, "maps")
+		if err := os.Mkdir(mapsDir, 0700); err != nil {
+			return manifest.Diff{}, fmt.Errorf(`creating directory %s: %w`, mapsDir, err)
+		}
+		valuesPaths, err := writeValuesFiles(mapsDir, valuesMaps)
+		if err != nil {
+			return manifest.Diff{}, err
+		}
+		values = append(values, valuesPaths...)
+	}
+	for _, yamlPath := range values {
+		diffArgs = append(diffArgs, "--values", yamlPath)
+	}
+
+	diffCmd := helmCommand(diffArgs...)
+	var stdout, stderr bytes.Buffer
+	diffCmd.Stdout = &stdout
+	diffCmd.Stderr = &stderr
+	if err := diffCmd.Run(); err != nil {
+		return manifest.Diff{}, newCommandError(diffCmd, stderr.String(), err)
+	}
+	diff, err := parseDiffUpgrade(stdout.Bytes())
+	if err != nil {
+		return manifest.Diff{}, fmt.Errorf(`parsing output of "%s": %w`, redactCommand(diffCmd), err)
+	}
+	return diff, nil
+}
+
+// diffUpgradeChange is a single entry of the output of
+// `helm diff upgrade --output json`.
+type diffUpgradeChange struct {
+	API       string `json:"api"` // e.g: "apps/v1"
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Change    string `json:"change"` // ADD, MODIFY, REMOVE or OWNERSHIP
+}
+
+// parseDiffUpgrade parses the output of `helm diff upgrade --output json`:
+//   [{"api": "apps/v1", "kind": "Deployment", "namespace": "web", "name": "api", "change": "MODIFY"}]
+// Changes of the ownership of a resource (e.g. a resource adopted from
+// another release) are Changed. Each list is sorted by resource.
+func parseDiffUpgrade(output []byte) (manifest.Diff, error) {
+	var diff manifest.Diff
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		// older versions of helm-diff output nothing when there are no changes
+		return diff, nil
+	}
+	var entries []diffUpgradeChange
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return diff, err
+	}
+	for _, entry := range entries {
+		change := manifest.Change{Resource: manifest.Key{
+			GVK:       manifest.ParseGVK(entry.API, entry.Kind),
+			Namespace: entry.Namespace,
+			Name:      entry.Name,
+		}}
+		switch entry.Change {
+		case "ADD":
+			change.Type = manifest.Added
+			diff.Added = append(diff.Added, change)
+		case "MODIFY", "OWNERSHIP":
+			change.Type = manifest.Changed
+			diff.Changed = append(diff.Changed, change)
+		case "REMOVE":
+			change.Type = manifest.Removed
+			diff.Removed = append(diff.Removed, change)
+		default:
+			return diff, fmt.Errorf(`unknown change "%s" of %s %s`, entry.Change, entry.Kind, entry.Name)
+		}
+	}
+	for _, changes := range [][]manifest.Change{diff.Added, diff.Changed, diff.Removed} {
+		sort.Slice(changes, func(i, j int) bool {
+			a, b := changes[i].Resource, changes[j].Resource
+			return strings.Join([]string{a.GVK.Group, a.GVK.Kind, a.Namespace, a.Name, a.GVK.Version}, "\x00") <
+				strings.Join([]string{b.GVK.Group, b.GVK.Kind, b.Namespace, b.Name, b.GVK.Version}, "\x00")
+		})
+	}
+	return diff, nil
+}
@@ -0,0 +1,131 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+func TestDiff(t *testing.T) {
+	// the fake chart renders a Deployment with the replicas given by --set and
+	// a ConfigMap named after the chart version
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template)
+  replicas=1 version=""
+  while [ $# -gt 0 ]; do
+    case "$1" in
+    --set) replicas="${2#replicas=}"; shift ;;
+    --version) version="$2"; shift ;;
+    esac
+    shift
+  done
+  printf 'apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: %s\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config-%s\n' "$replicas" "$version"
+  ;;
+esac`)
+	chart := t.TempDir()
+
+	before := TemplateOptions{Chart: chart, Release: "web", Version: "1.0.0"}
+	after := before
+	after.Version = "2.0.0"
+	after.Set = []string{"replicas=2"}
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	want := "1 added, 1 changed, 1 removed\n" +
+		"+ v1, Kind=ConfigMap config-2.0.0\n" +
+		"~ apps/v1, Kind=Deployment web\n" +
+		"- v1, Kind=ConfigMap config-1.0.0\n"
+	if got := diff.Summary(); got != want {
+		t.Errorf("Diff().Summary() = %s, want %s", got, want)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Type != manifest.Changed {
+		t.Fatalf("Diff().Changed = %v, want 1 change", diff.Changed)
+	}
+	unified, err := diff.Changed[0].UnifiedDiff()
+	if err != nil {
+		t.Fatalf("Change.UnifiedDiff() error = %v", err)
+	}
+	if !strings.Contains(unified, "-    replicas: 1\n+    replicas: 2\n") {
+		t.Errorf("Change.UnifiedDiff() = %s, want replicas change", unified)
+	}
+}
+
+func TestDiffUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+plugin)
+  case "$2" in
+  list) printf 'NAME\tVERSION\tDESCRIPTION\n'; [ -f "$FAKE_DIR/installed" ] && printf 'diff\t3.1.3\tPreview helm upgrade changes as a diff\n'; true ;;
+  install) echo "$3" > "$FAKE_DIR/installed" ;;
+  esac ;;
+diff)
+  echo "$@" > "$FAKE_DIR/args"
+  echo '[{"api": "apps/v1", "kind": "Deployment", "namespace": "web", "name": "api", "change": "MODIFY"},'
+  echo ' {"api": "v1", "kind": "Service", "namespace": "web", "name": "api", "change": "ADD"},'
+  echo ' {"api": "v1", "kind": "ConfigMap", "namespace": "web", "name": "old", "change": "REMOVE"},'
+  echo ' {"api": "v1", "kind": "ConfigMap", "namespace": "web", "name": "adopted", "change": "OWNERSHIP"}]' ;;
+esac`)
+	client := CurrentClient()
+	client.Env = []string{"FAKE_DIR=" + dir}
+	SetClient(client)
+	chart := t.TempDir()
+
+	diff, err := DiffUpgrade("api", TemplateOptions{Chart: chart, Namespace: "web", Set: []string{"replicas=2"}})
+	if err != nil {
+		t.Fatalf("DiffUpgrade() error = %v", err)
+	}
+	installed, err := os.ReadFile(filepath.Join(dir, "installed"))
+	if err != nil || strings.TrimSpace(string(installed)) != HelmDiffPlugin {
+		t.Errorf("DiffUpgrade() installed plugin %q (%v), want %s", installed, err, HelmDiffPlugin)
+	}
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "diff upgrade api " + chart + " --output json --allow-unreleased --namespace web --set replicas=2\n"; string(args) != want {
+		t.Errorf("DiffUpgrade() ran helm %q, want %q", args, want)
+	}
+	want := "1 added, 2 changed, 1 removed\n" +
+		"+ v1, Kind=Service web/api\n" +
+		"~ v1, Kind=ConfigMap web/adopted\n" +
+		"~ apps/v1, Kind=Deployment web/api\n" +
+		"- v1, Kind=ConfigMap web/old\n"
+	if got := diff.Summary(); got != want {
+		t.Errorf("DiffUpgrade().Summary() = %q, want %q", got, want)
+	}
+	if diff.Changed[1].Type != manifest.Changed || diff.Changed[1].Before != nil {
+		t.Errorf("DiffUpgrade().Changed[1] = %+v, want a Changed resource without manifests", diff.Changed[1])
+	}
+}
+
+func TestParseDiffUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no changes", output: "[]", want: "0 added, 0 changed, 0 removed\n"},
+		{name: "empty output", output: "\n", want: "0 added, 0 changed, 0 removed\n"},
+		{name: "unknown change", output: `[{"api": "v1", "kind": "Service", "name": "api", "change": "REPLACE"}]`, wantErr: true},
+		{name: "invalid", output: "Error: release not found", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := parseDiffUpgrade([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDiffUpgrade() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && diff.Summary() != tt.want {
+				t.Errorf("parseDiffUpgrade().Summary() = %q, want %q", diff.Summary(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultEnvAllowlist are the environment variables passed to helm when
+// sanitized environments are enabled via SanitizeEnv. Entries ending in "*"
+// match all variables with the preceding prefix.
+// Variables required to locate binaries, helm configuration, kubeconfig,
+// proxies and certificate bundles are kept; everything else (e.g. cloud
+// provider credentials) is dropped.
+var DefaultEnvAllowlist = []string{
+	"PATH",
+	"HOME",
+	"USERPROFILE", // windows equivalent of HOME
+	"SYSTEMROOT",  // required by windows networking
+	"TMPDIR",
+	"TEMP",
+	"TMP",
+	"HELM_*",
+	"XDG_CACHE_HOME",
+	"XDG_CONFIG_HOME",
+	"XDG_DATA_HOME",
+	"KUBECONFIG",
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+	"http_proxy",
+	"https_proxy",
+	"no_proxy",
+	"SSL_CERT_FILE",
+	"SSL_CERT_DIR",
+}
+
+var (
+	envLock      sync.RWMutex
+	sanitizeEnv  bool
+	envAllowlist []string
+)
+
+// SanitizeEnv configures whether helm is run with a minimal, explicitly
+// constructed environment instead of inheriting the environment of the
+// current process. When enabled, only variables matching DefaultEnvAllowlist
+// or the additional allow entries are passed to helm; limiting what chart
+// hooks and plugins can access and making renders reproducible across hosts
+// with differing environments.
+func SanitizeEnv(enabled bool, allow ...string) {
+	envLock.Lock()
+	defer envLock.Unlock()
+	sanitizeEnv = enabled
+	envAllowlist = append(append([]string{}, DefaultEnvAllowlist...), allow...)
+}
+
+// helmCommand creates an *exec.Cmd running helm with args using the Client
+// configured via SetClient and the environment configured via SanitizeEnv.
+func helmCommand(args ...string) *exec.Cmd {
+	c := CurrentClient()
+	// the process is killed when Shutdown is called
+	cmd := exec.CommandContext(shutdown.ctx, c.binary(), args...)
+	cmd.Dir = c.Dir
+	envLock.RLock()
+	defer envLock.RUnlock()
+	if sanitizeEnv {
+		cmd.Env = filterEnv(os.Environ(), envAllowlist)
+	}
+	if len(c.Env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		// later entries take precedence over earlier entries of the same key
+		cmd.Env = append(cmd.Env, c.Env...)
+	}
+	return cmd
+}
+
+// probes memoizes the stdout of helm commands whose output only depends on the
+// helm binary and its environment (`helm version`, `helm env`), which would
+// otherwise be run several times per render.
+var probes = struct {
+	sync.Mutex
+	outputs map[string]string
+}{outputs: map[string]string{}}
+
+// runProbe runs helm with args as helmCommand does, returning its stdout and
+// stderr. Outputs of successful runs without stderr are memoized per helm
+// binary, working directory and environment; the size and modification time of
+// the binary are part of the key so upgrading helm in place is picked up.
+func runProbe(args ...string) (*exec.Cmd, string, string, error) {
+	cmd := helmCommand(args...)
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	key := strings.Join(append([]string{strings.Join(args, " "), cmd.Path, cmd.Dir}, env...), "\x00")
+	if info, err := os.Stat(cmd.Path); err == nil {
+		key += fmt.Sprintf("\x00%d\x00%d", info.Size(), info.ModTime().UnixNano())
+	}
+	probes.Lock()
+	output, ok := probes.outputs[key]
+	probes.Unlock()
+	if ok {
+		return cmd, output, "", nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return cmd, stdout.String(), stderr.String(), newCommandError(cmd, stderr.String(), err)
+	}
+	if stderr.Len() == 0 {
+		probes.Lock()
+		probes.outputs[key] = stdout.String()
+		probes.Unlock()
+	}
+	return cmd, stdout.String(), stderr.String(), nil
+}
+
+// filterEnv returns the KEY=VALUE pairs of env whose keys match allowlist.
+func filterEnv(env []string, allowlist []string) []string {
+	filtered := []string{}
+	for _, pair := range env {
+		key := strings.SplitN(pair, "=", 2)[0]
+		for _, allowed := range allowlist {
+			if key == allowed || (strings.HasSuffix(allowed, "*") && strings.HasPrefix(key, strings.TrimSuffix(allowed, "*"))) {
+				filtered = append(filtered, pair)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// HelmEnv is the environment of the helm client as reported by `helm env`:
+// the locations of its configuration, cache and data, so they can be shared
+// with (or kept apart from) the host helm installation.
+type HelmEnv struct {
+	Bin              string // HELM_BIN
+	CacheHome        string // HELM_CACHE_HOME
+	ConfigHome       string // HELM_CONFIG_HOME
+	DataHome         string // HELM_DATA_HOME
+	Plugins          string // HELM_PLUGINS
+	RegistryConfig   string // HELM_REGISTRY_CONFIG
+	RepositoryCache  string // HELM_REPOSITORY_CACHE
+	RepositoryConfig string // HELM_REPOSITORY_CONFIG
+	Namespace        string // HELM_NAMESPACE
+	KubeContext      string // HELM_KUBECONTEXT
+	Debug            bool   // HELM_DEBUG
+
+	Vars map[string]string // all variables reported, including those above
+}
+
+// Env runs `helm env` with the Client configured via SetClient and the
+// environment configured via SanitizeEnv, returning the reported variables.
+// The output is memoized per helm binary and environment.
+func Env() (HelmEnv, error) {
+	_, stdout, _, err := runProbe("env")
+	if err != nil {
+		return HelmEnv{}, err
+	}
+	return parseHelmEnv(stdout), nil
+}
+
+// parseHelmEnv parses the KEY="VALUE" lines of the output of `helm env`.
+func parseHelmEnv(output string) HelmEnv {
+	env := HelmEnv{Vars: map[string]string{}}
+	for _, line := range strings.Split(output, "\n") {
+		pair := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			continue
+		}
+		value := pair[1]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		env.Vars[pair[0]] = value
+	}
+	env.Bin = env.Vars["HELM_BIN"]
+	env.CacheHome = env.Vars["HELM_CACHE_HOME"]
+	env.ConfigHome = env.Vars["HELM_CONFIG_HOME"]
+	env.DataHome = env.Vars["HELM_DATA_HOME"]
+	env.Plugins = env.Vars["HELM_PLUGINS"]
+	env.RegistryConfig = env.Vars["HELM_REGISTRY_CONFIG"]
+	env.RepositoryCache = env.Vars["HELM_REPOSITORY_CACHE"]
+	env.RepositoryConfig = env.Vars["HELM_REPOSITORY_CONFIG"]
+	env.Namespace = env.Vars["HELM_NAMESPACE"]
+	env.KubeContext = env.Vars["HELM_KUBECONTEXT"]
+	env.Debug, _ = strconv.ParseBool(env.Vars["HELM_DEBUG"])
+	return env
+}
@@ -0,0 +1,123 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_filterEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"HELM_CONFIG_HOME=/tmp/helm",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"PATHOLOGICAL=1",
+		"EXTRA=a=b",
+	}
+	tests := []struct {
+		name      string
+		allowlist []string
+		want      []string
+	}{
+		{"default", DefaultEnvAllowlist, []string{"PATH=/usr/bin", "HELM_CONFIG_HOME=/tmp/helm"}},
+		{"extra", append(DefaultEnvAllowlist, "EXTRA"), []string{"PATH=/usr/bin", "HELM_CONFIG_HOME=/tmp/helm", "EXTRA=a=b"}},
+		{"prefix", []string{"PATH*"}, []string{"PATH=/usr/bin", "PATHOLOGICAL=1"}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterEnv(env, tt.allowlist); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	useFakeHelm(t, `case "$1" in env) cat <<'OUT'
+HELM_BIN="helm"
+HELM_CACHE_HOME="/home/user/.cache/helm"
+HELM_CONFIG_HOME="/home/user/.config/helm"
+HELM_DATA_HOME="/home/user/.local/share/helm"
+HELM_DEBUG="true"
+HELM_NAMESPACE="default"
+HELM_REGISTRY_CONFIG="/home/user/.config/helm/registry/config.json"
+HELM_REPOSITORY_CACHE="/home/user/.cache/helm/repository"
+HELM_REPOSITORY_CONFIG="/home/user/.config/helm/repositories.yaml"
+HELM_KUBECONTEXT=""
+OUT
+;; *) exit 1 ;; esac`)
+	env, err := Env()
+	if err != nil {
+		t.Fatalf("Env() error = %v", err)
+	}
+	if env.CacheHome != "/home/user/.cache/helm" || env.RepositoryCache != "/home/user/.cache/helm/repository" || env.RegistryConfig != "/home/user/.config/helm/registry/config.json" {
+		t.Errorf("Env() = %+v, want the reported cache and registry paths", env)
+	}
+	if !env.Debug || env.Namespace != "default" || env.KubeContext != "" {
+		t.Errorf("Env() = %+v, want debug in namespace default", env)
+	}
+	if value, ok := env.Vars["HELM_KUBECONTEXT"]; !ok || value != "" {
+		t.Errorf("Env().Vars[HELM_KUBECONTEXT] = %q, %v, want empty and reported", value, ok)
+	}
+	if path, err := RepoConfigPath(); err != nil || path != env.RepositoryConfig {
+		t.Errorf("RepoConfigPath() = %s, %v, want %s", path, err, env.RepositoryConfig)
+	}
+
+	useFakeHelm(t, `echo "Error: unknown command" >&2; exit 1`)
+	if _, err := Env(); err == nil {
+		t.Errorf("Env() error = nil, want error")
+	}
+}
+
+func Test_runProbe(t *testing.T) {
+	calls := filepath.Join(t.TempDir(), "calls")
+	body := `echo "$1" >> '` + calls + `'
+case "$1" in
+version) echo '` + fakeHelmVersion + `' ;;
+env) echo "HELM_REPOSITORY_CONFIG=\"$FAKE_HOME/repositories.yaml\"" ;;
+esac`
+	binary := useFakeHelm(t, body)
+	countCalls := func() int {
+		t.Helper()
+		content, err := os.ReadFile(calls)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return strings.Count(string(content), "\n")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := Version(); err != nil {
+			t.Fatalf("Version() error = %v", err)
+		}
+		if _, err := RepoConfigPath(); err != nil {
+			t.Fatalf("RepoConfigPath() error = %v", err)
+		}
+	}
+	if got := countCalls(); got != 2 {
+		t.Errorf("helm ran %d times, want version and env once each", got)
+	}
+
+	// a different environment is probed again
+	SetClient(Client{HelmBinary: binary, Env: []string{"FAKE_HOME=/home/user"}})
+	if path, err := RepoConfigPath(); err != nil || path != "/home/user/repositories.yaml" {
+		t.Errorf("RepoConfigPath() = %s, %v, want /home/user/repositories.yaml", path, err)
+	}
+	if got := countCalls(); got != 3 {
+		t.Errorf("helm ran %d times, want env probed again for the new environment", got)
+	}
+
+	// as is a helm binary upgraded in place
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"+body+"\n# upgraded\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Version(); err != nil {
+		t.Fatalf("Version() error = %v", err)
+	}
+	if got := countCalls(); got != 4 {
+		t.Errorf("helm ran %d times, want version probed again for the upgraded binary", got)
+	}
+}
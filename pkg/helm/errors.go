@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+// Errors classifying the failure of a helm command, detected from its stderr.
+// Errors returned by this package for failed helm commands can be checked
+// with errors.Is. e.g:
+//   if errors.Is(err, helm.ErrChartNotFound) { ... }
+var (
+	ErrChartNotFound   = errcode.New(errcode.HelmChartNotFound, "chart not found")
+	ErrVersionNotFound = errcode.New(errcode.HelmVersionNotFound, "chart version not found")
+	ErrRepoUnreachable = errcode.New(errcode.HelmRepoUnreachable, "repository unreachable")
+	ErrAuthRequired    = errcode.New(errcode.HelmAuthRequired, "authentication required")
+	ErrReleaseNotFound = errcode.New(errcode.HelmReleaseNotFound, "release not found")
+)
+
+// stderrPatterns map patterns in the stderr of helm to the error they
+// classify. Patterns are checked in order so more specific patterns come
+// first (e.g. a missing version also reports the chart as "not found").
+var stderrPatterns = []struct {
+	rgx *regexp.Regexp
+	err error
+}{
+	{regexp.MustCompile(`(?i)(release: not found|release "[^"]*" not found)`), ErrReleaseNotFound},
+	{regexp.MustCompile(`(?i)(401 Unauthorized|403 Forbidden|unauthorized|authentication required|basic credential not found|failed to authorize|denied: )`), ErrAuthRequired},
+	{regexp.MustCompile(`(?i)(version "[^"]*" not found|at version "[^"]*"|no chart version found|invalid_reference: invalid tag|manifest unknown)`), ErrVersionNotFound},
+	{regexp.MustCompile(`(?i)(no such host|connection refused|i/o timeout|network is unreachable|TLS handshake timeout|x509: |is not a valid chart repository or cannot be reached|could not find protocol handler)`), ErrRepoUnreachable},
+	{regexp.MustCompile(`(?i)(chart "[^"]*" not found|no chart name found|failed to download|oci://\S*: not found|repository name \([^)]*\) not found|path "[^"]*" not found)`), ErrChartNotFound},
+}
+
+// classifyStderr returns the error classifying the stderr of a failed helm
+// command, or nil if it cannot be classified.
+func classifyStderr(stderr string) error {
+	for _, pattern := range stderrPatterns {
+		if pattern.rgx.MatchString(stderr) {
+			return pattern.err
+		}
+	}
+	return nil
+}
+
+// CommandError is returned when a helm command fails.
+type CommandError struct {
+	Command string // the command with any credentials redacted
+	Stderr  string // the stderr of the command
+	Kind    error  // one of ErrChartNotFound, ErrVersionNotFound, ErrRepoUnreachable, ErrAuthRequired or ErrReleaseNotFound, or ErrShutdown if the command was killed by Shutdown. nil if the failure could not be classified
+	Err     error  // the error running the command (e.g. an *exec.ExitError)
+}
+
+// newCommandError creates a CommandError for cmd which failed with err.
+func newCommandError(cmd *exec.Cmd, stderr string, err error) *CommandError {
+	kind := classifyStderr(stderr)
+	if shuttingDown() {
+		kind = ErrShutdown
+	}
+	return &CommandError{
+		Command: redactCommand(cmd),
+		Stderr:  stderr,
+		Kind:    kind,
+		Err:     err,
+	}
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf(`running "%s": %v: %v`, e.Command, e.Err, e.Stderr)
+}
+
+// Code returns the code of the classification of the failure, or "" if it
+// could not be classified.
+func (e *CommandError) Code() errcode.Code {
+	return errcode.Of(e.Kind)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the classification of the failure so callers
+// can use errors.Is(err, ErrChartNotFound) and similar.
+func (e *CommandError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
@@ -0,0 +1,43 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+func TestCommandError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   error
+		code   errcode.Code
+	}{
+		{"chart not found", `Error: chart "nginxx" not found in https://charts.bitnami.com/bitnami repository`, ErrChartNotFound, errcode.HelmChartNotFound},
+		{"local chart not found", `Error: path "./charts/missing" not found`, ErrChartNotFound, errcode.HelmChartNotFound},
+		{"version not found", `Error: chart "nginx" version "99.0.0" not found in https://charts.bitnami.com/bitnami repository`, ErrVersionNotFound, errcode.HelmVersionNotFound},
+		{"repo unreachable", `Error: looks like "https://charts.example.invalid" is not a valid chart repository or cannot be reached: Get "https://charts.example.invalid/index.yaml": dial tcp: lookup charts.example.invalid: no such host`, ErrRepoUnreachable, errcode.HelmRepoUnreachable},
+		{"auth required", `Error: failed to fetch https://charts.example.com/index.yaml : 401 Unauthorized`, ErrAuthRequired, errcode.HelmAuthRequired},
+		{"release not found", `Error: release: not found`, ErrReleaseNotFound, errcode.HelmReleaseNotFound},
+		{"oci chart not found", `Error: oci://registry.example.com:5000/charts/nginxx:1.0.0: not found`, ErrChartNotFound, errcode.HelmChartNotFound},
+		{"missing template function", `Error: parse error at (nginx/templates/_helpers.tpl:3): function "lookupp" not defined or not found`, nil, ""},
+		{"missing kubernetes resource", `Error: UPGRADE FAILED: secrets "nginx-tls" not found`, nil, ""},
+		{"unclassified", `Error: template: nginx/templates/deployment.yaml:3:4: executing "nginx/templates/deployment.yaml" at <.Values.foo>: nil pointer`, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(newCommandError(exec.Command("helm", "template"), tt.stderr, errors.New("exit status 1")))
+			for _, kind := range []error{ErrChartNotFound, ErrVersionNotFound, ErrRepoUnreachable, ErrAuthRequired, ErrReleaseNotFound} {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(%v) = %v, want %v", kind, got, kind == tt.want)
+				}
+			}
+			if got := errcode.Of(fmt.Errorf(`templating: %w`, err)); got != tt.code {
+				t.Errorf("errcode.Of() = %q, want %q", got, tt.code)
+			}
+		})
+	}
+}
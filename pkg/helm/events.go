@@ -0,0 +1,62 @@
+package helm
+
+import (
+	"time"
+)
+
+// EventType is the type of a progress Event emitted while templating.
+type EventType string
+
+const (
+	ChartResolved    EventType = "ChartResolved"    // the chart reference passed to helm has been resolved (e.g. to an existing host repository or OCI reference)
+	PullStarted      EventType = "PullStarted"      // the chart is being pulled to read CRDs from its filesystem
+	PullFinished     EventType = "PullFinished"     // the chart has been pulled. Err is set if the pull failed
+	TemplateStarted  EventType = "TemplateStarted"  // `helm template` is starting
+	TemplateFinished EventType = "TemplateFinished" // `helm template` has finished. Err is set if templating failed
+	TemplateWarning  EventType = "TemplateWarning"  // `helm template` wrote a benign warning to stderr. Message is set to the warning
+	ValidationFailed EventType = "ValidationFailed" // the options are not supported by the helm client. Err describes the failure
+	LimitExceeded    EventType = "LimitExceeded"    // the output exceeds the OutputLimits of the options. Err describes the limit
+)
+
+// Event is a progress event emitted via TemplateOptions.Events.
+type Event struct {
+	Type     EventType
+	Release  string
+	Chart    string
+	Version  string
+	Time     time.Time     // when the event occurred
+	Duration time.Duration // duration of the operation for *Finished events
+	Err      error         // error of the operation for *Finished and ValidationFailed events
+	Message  string        // message of TemplateWarning events
+}
+
+// EventChannel returns a callback for TemplateOptions.Events which sends all
+// events to ch.
+// ch should be buffered or actively received from as templating blocks until
+// each event is sent.
+func EventChannel(ch chan<- Event) func(Event) {
+	return func(event Event) {
+		ch <- event
+	}
+}
+
+// emit sends event to opts.Events if set, populating the release, chart,
+// version and time of the event when not already set.
+func (opts TemplateOptions) emit(event Event) {
+	if opts.Events == nil {
+		return
+	}
+	if event.Release == "" {
+		event.Release = opts.Release
+	}
+	if event.Chart == "" {
+		event.Chart = opts.Chart
+	}
+	if event.Version == "" {
+		event.Version = opts.Version
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	opts.Events(event)
+}
@@ -0,0 +1,35 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplate_events(t *testing.T) {
+	events := make(chan Event, 10)
+	opts := TemplateOptions{
+		Release: "my-release",
+		Chart:   "testdata/template/does-not-exist",
+		Events:  EventChannel(events),
+	}
+	_, templateErr := Template(opts)
+	close(events)
+
+	var got []EventType
+	for event := range events {
+		got = append(got, event.Type)
+		if event.Release != opts.Release || event.Chart != opts.Chart {
+			t.Errorf("event %s = %+v, want release %s and chart %s", event.Type, event, opts.Release, opts.Chart)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("event %s has no time", event.Type)
+		}
+		if event.Type == TemplateFinished && event.Err != templateErr {
+			t.Errorf("event %s error = %v, want %v", event.Type, event.Err, templateErr)
+		}
+	}
+	want := []EventType{TemplateStarted, ChartResolved, TemplateFinished}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Template() events = %v, want %v", got, want)
+	}
+}
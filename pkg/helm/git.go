@@ -0,0 +1,235 @@
+package helm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/filelock"
+)
+
+// GitCache is an on-disk cache of checkouts of git repositories hosting charts
+// (see TemplateOptions.GitURL) or remote components, so repeated renders do
+// not re-fetch them.
+// Checkouts are stored under <Dir>/<key> where key is the sha256 of the
+// repository URL and ref. A checkout of a branch is reused as of the first
+// fetch; pin charts and components to a tag or commit, or Remove the checkout
+// to pick up new commits. Checkouts without a ref (the default branch) are
+// never cached: each is fetched into its own temporary directory.
+type GitCache struct {
+	Dir string
+}
+
+// DefaultGitCache returns a GitCache in the user cache directory (e.g.
+// ~/.cache/evanlouie/helm/git on Linux).
+func DefaultGitCache() (*GitCache, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf(`determining user cache directory: %w`, err)
+	}
+	return &GitCache{Dir: filepath.Join(cacheDir, "evanlouie", "helm", "git")}, nil
+}
+
+// gitCacheKey returns the content address of a ref of a repository.
+func gitCacheKey(url string, ref string) string {
+	sum := sha256.Sum256([]byte(url + "\x00" + ref))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checkout returns the path of a checkout of ref (a branch, tag or commit) of
+// the git repository at url, fetching it into the cache first if it is not
+// already present. An empty ref fetches the default branch into a new
+// temporary directory on every call, so a checkout is never replaced while a
+// concurrent render reads it. release removes that directory and must be
+// called once the checkout is no longer used; it is a no-op for cached refs.
+// url and ref commonly come from remote component definitions and must not
+// start with "-", so they cannot be passed to git as options.
+func (c *GitCache) Checkout(url string, ref string) (dir string, release func(), err error) {
+	if err := validateGitArgs(url, ref); err != nil {
+		return "", nil, err
+	}
+	if ref == "" {
+		tmpDir, err := mkdirTemp("helm-git-")
+		if err != nil {
+			return "", nil, fmt.Errorf(`creating temporary directory for git checkout of %s: %w`, url, err)
+		}
+		if err := gitCheckout(tmpDir, url, ref); err != nil {
+			removeTemp(tmpDir)
+			return "", nil, err
+		}
+		return tmpDir, func() { removeTemp(tmpDir) }, nil
+	}
+	entryDir := filepath.Join(c.Dir, gitCacheKey(url, ref))
+	if _, err := os.Stat(filepath.Join(entryDir, ".git")); err == nil {
+		return entryDir, func() {}, nil
+	}
+
+	// fetch into a temporary directory in the cache and rename it into place so
+	// concurrent renders never observe a partial checkout
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", nil, fmt.Errorf(`creating git cache directory %s: %w`, c.Dir, err)
+	}
+	// concurrent processes checking out the same ref wait for the first rather
+	// than each fetching it
+	entryLock, err := filelock.Acquire(entryDir + ".lock")
+	if err != nil {
+		return "", nil, fmt.Errorf(`locking git cache entry of %s: %w`, url, err)
+	}
+	defer entryLock.Release()
+	if _, err := os.Stat(filepath.Join(entryDir, ".git")); err == nil {
+		return entryDir, func() {}, nil
+	}
+	tmpDir, err := os.MkdirTemp(c.Dir, ".checkout-")
+	if err != nil {
+		return "", nil, fmt.Errorf(`creating temporary directory in git cache %s: %w`, c.Dir, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := gitCheckout(tmpDir, url, ref); err != nil {
+		return "", nil, err
+	}
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		return "", nil, fmt.Errorf(`moving checkout of %s into git cache %s: %w`, url, c.Dir, err)
+	}
+	return entryDir, func() {}, nil
+}
+
+// validateGitArgs rejects a url or ref which git would parse as an option
+// (e.g. "--upload-pack=<command>").
+func validateGitArgs(url string, ref string) error {
+	if strings.HasPrefix(url, "-") {
+		return fmt.Errorf(`invalid git repository URL "%s": must not start with "-"`, url)
+	}
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf(`invalid git ref "%s" of %s: must not start with "-"`, ref, url)
+	}
+	return nil
+}
+
+// Remove removes the checkout of a ref of a repository from the cache.
+func (c *GitCache) Remove(url string, ref string) error {
+	entryDir := filepath.Join(c.Dir, gitCacheKey(url, ref))
+	if err := os.RemoveAll(entryDir); err != nil {
+		return fmt.Errorf(`removing %s from git cache: %w`, entryDir, err)
+	}
+	return nil
+}
+
+// Purge removes all checkouts from the cache.
+func (c *GitCache) Purge() error {
+	if err := os.RemoveAll(c.Dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(`purging git cache %s: %w`, c.Dir, err)
+	}
+	return nil
+}
+
+// gitCheckout checks out ref of the repository at url into dir. Only ref is
+// fetched when the remote allows it; otherwise (e.g. an abbreviated commit)
+// all branches and tags are fetched to resolve ref.
+func gitCheckout(dir string, url string, ref string) error {
+	if err := validateGitArgs(url, ref); err != nil {
+		return err
+	}
+	if err := git(dir, "init", "--quiet"); err != nil {
+		return err
+	}
+	target := ref
+	if target == "" {
+		target = "HEAD"
+	}
+	// "--" ends the options so url and ref are only ever positional arguments
+	if err := git(dir, "fetch", "--quiet", "--depth", "1", "--", url, target); err == nil {
+		return git(dir, "checkout", "--quiet", "--detach", "FETCH_HEAD")
+	}
+	if err := git(dir, "fetch", "--quiet", "--tags", "--", url, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return err
+	}
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		if err := git(dir, "checkout", "--quiet", "--detach", candidate); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf(`checking out git repository %s: ref "%s" not found`, url, ref)
+}
+
+// git runs a git command in dir. Prompts for credentials are disabled so
+// private repositories without configured credentials fail instead of hanging.
+func git(dir string, args ...string) error {
+	_, err := gitOutput(dir, args...)
+	return err
+}
+
+// gitOutput runs a git command in dir as git does, returning its trimmed
+// stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	// the process is killed when Shutdown is called
+	cmd := exec.CommandContext(shutdown.ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(`running "git %s": %w: %s`, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// withGitChart returns opts with the chart of its GitURL checked out and set
+// as a local Chart, along with a function removing the checkout if it is not
+// cached in opts.GitCache.
+func (opts TemplateOptions) withGitChart() (TemplateOptions, func(), error) {
+	if opts.GitURL == "" {
+		return opts, func() {}, nil
+	}
+	if opts.Repo != "" {
+		return opts, nil, fmt.Errorf(`templating helm chart of %s: GitURL and Repo are mutually exclusive`, opts.GitURL)
+	}
+	gitCache, removeCache := opts.GitCache, func() {}
+	if gitCache == nil {
+		tmpDir, err := mkdirTemp("helm-git-")
+		if err != nil {
+			return opts, nil, fmt.Errorf(`creating temporary directory for git checkout of %s: %w`, opts.GitURL, err)
+		}
+		gitCache, removeCache = &GitCache{Dir: tmpDir}, func() { removeTemp(tmpDir) }
+	}
+	checkout, release, err := gitCache.Checkout(opts.GitURL, opts.GitRef)
+	if err != nil {
+		removeCache()
+		return opts, nil, err
+	}
+	cleanup := func() {
+		release()
+		removeCache()
+	}
+	chartPath := filepath.Join(checkout, filepath.FromSlash(opts.GitPath))
+	if rel, err := filepath.Rel(checkout, chartPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		cleanup()
+		return opts, nil, fmt.Errorf(`GitPath %s is outside of git repository %s`, opts.GitPath, opts.GitURL)
+	}
+	if _, err := os.Stat(filepath.Join(chartPath, "Chart.yaml")); err != nil {
+		cleanup()
+		return opts, nil, fmt.Errorf(`no helm chart found at "%s" of git repository %s@%s: %w`, opts.GitPath, opts.GitURL, opts.GitRef, err)
+	}
+	if opts.Provenance != nil {
+		commit, err := gitOutput(checkout, "rev-parse", "HEAD")
+		if err != nil {
+			cleanup()
+			return opts, nil, err
+		}
+		chart, err := chartProvenance(chartPath, opts.GitURL, "sha1:"+commit)
+		if err != nil {
+			cleanup()
+			return opts, nil, err
+		}
+		opts.Provenance.record(chart)
+	}
+	opts.Chart, opts.Version = chartPath, ""
+	opts.GitURL, opts.GitRef, opts.GitPath = "", "", ""
+	return opts, cleanup, nil
+}
@@ -0,0 +1,181 @@
+package helm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestTemplate_gitChart(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := t.TempDir()
+	if _, err := helmtest.WriteChart(filepath.Join(remote, "charts"), helmtest.Chart{Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+		{"tag", "v1.0.0"},
+	} {
+		if err := git(remote, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the fake helm prints the name of the chart it is passed as last argument
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template) for arg; do chart="$arg"; done; grep '^name:' "$chart/Chart.yaml" ;;
+esac`)
+	gitCache := &GitCache{Dir: t.TempDir()}
+
+	tests := []struct {
+		name    string
+		opts    TemplateOptions
+		wantErr string
+	}{
+		{"cached", TemplateOptions{GitURL: remote, GitRef: "v1.0.0", GitPath: "charts/web", GitCache: gitCache}, ""},
+		{"uncached", TemplateOptions{GitURL: remote, GitPath: "charts/web"}, ""},
+		{"missing-chart", TemplateOptions{GitURL: remote, GitRef: "v1.0.0", GitPath: "charts/api", GitCache: gitCache}, "no helm chart found"},
+		{"outside-repository", TemplateOptions{GitURL: remote, GitRef: "v1.0.0", GitPath: "../web", GitCache: gitCache}, "outside of git repository"},
+		{"with-repo", TemplateOptions{GitURL: remote, Repo: "https://charts.example.com", Chart: "web"}, "mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := Template(tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Template() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Template() error = %v", err)
+			}
+			if strings.TrimSpace(output) != "name: web" {
+				t.Errorf("Template() = %q, want the chart web", output)
+			}
+		})
+	}
+
+	// the checkout of a tag is reused once the remote is gone
+	if err := os.RemoveAll(remote); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Template(TemplateOptions{GitURL: remote, GitRef: "v1.0.0", GitPath: "charts/web", GitCache: gitCache}); err != nil {
+		t.Errorf("Template() from git cache error = %v", err)
+	}
+}
+
+func TestTemplateCommand_gitChart(t *testing.T) {
+	got, err := TemplateCommand(TemplateOptions{Release: "web", GitURL: "https://github.com/my-org/charts.git", GitRef: "v1.0.0", GitPath: "charts/web"})
+	if err != nil {
+		t.Fatalf("TemplateCommand() error = %v", err)
+	}
+	if want := []string{"template", "web", "<https://github.com/my-org/charts.git@v1.0.0>/charts/web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TemplateCommand() = %v, want %v", got, want)
+	}
+}
+
+func TestGitCache_Checkout_optionArgs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "executed")
+	gitCache := &GitCache{Dir: filepath.Join(dir, "cache")}
+
+	tests := []struct {
+		name string
+		url  string
+		ref  string
+	}{
+		{"url", "--upload-pack=touch " + marker, ""},
+		{"ref", "https://github.com/my-org/charts.git", "--upload-pack=touch " + marker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := gitCache.Checkout(tt.url, tt.ref)
+			if err == nil || !strings.Contains(err.Error(), `must not start with "-"`) {
+				t.Errorf("Checkout() error = %v, want the option rejected", err)
+			}
+			if err := gitCheckout(t.TempDir(), tt.url, tt.ref); err == nil {
+				t.Errorf("gitCheckout() error = nil, want the option rejected")
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Errorf("git executed the option")
+			}
+		})
+	}
+}
+
+func TestGitCache_Checkout_defaultBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := t.TempDir()
+	commit := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(remote, "file"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content},
+		} {
+			if err := git(remote, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := git(remote, "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	commit("v1")
+	gitCache := &GitCache{Dir: t.TempDir()}
+	read := func(dir string) string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(dir, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	// a render still reading the first checkout of the default branch is not
+	// affected by a later checkout picking up a new commit
+	first, releaseFirst, err := gitCache.Checkout(remote, "")
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	commit("v2")
+	second, releaseSecond, err := gitCache.Checkout(remote, "")
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if got := read(second); got != "v2" {
+		t.Errorf("Checkout() of default branch = %s, want v2", got)
+	}
+	if got := read(first); got != "v1" {
+		t.Errorf("first Checkout() of default branch = %s, want v1 until released", got)
+	}
+
+	releaseFirst()
+	releaseSecond()
+	for _, dir := range []string{first, second} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("released checkout %s stat error = %v, want not exist", dir, err)
+		}
+	}
+	// checkouts of the default branch are not stored in the cache
+	if entries, err := os.ReadDir(gitCache.Dir); err == nil && len(entries) != 0 {
+		t.Errorf("git cache has %d entries, want none", len(entries))
+	}
+}
@@ -0,0 +1,195 @@
+// Package helmtest provides utilities for writing synthetic helm charts in
+// tests so fixtures can be created programmatically instead of committing
+// testdata trees.
+package helmtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTemplate is the template written when a Chart has no Templates. It
+// renders a single ConfigMap named after the release.
+const DefaultTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-{{ .Chart.Name }}
+data:
+  value: {{ .Values.value | default "default" | quote }}
+`
+
+// Chart is a declarative description of a synthetic helm chart.
+type Chart struct {
+	Name        string                 // defaults to "test-chart"
+	Version     string                 // defaults to "0.1.0"
+	AppVersion  string                 // optional
+	Annotations map[string]string      // written to the annotations of Chart.yaml. e.g: {"artifacthub.io/license": "Apache-2.0"}
+	Values      map[string]interface{} // written to values.yaml
+	Templates   map[string]string      // file name (relative to templates/) to content. defaults to DefaultTemplate as configmap.yaml
+	CRDs        map[string]string      // file name (relative to crds/) to content
+	Subcharts   []Chart                // written unpacked to charts/<name>
+}
+
+func (c Chart) withDefaults() Chart {
+	if c.Name == "" {
+		c.Name = "test-chart"
+	}
+	if c.Version == "" {
+		c.Version = "0.1.0"
+	}
+	if c.Templates == nil {
+		c.Templates = map[string]string{"configmap.yaml": DefaultTemplate}
+	}
+	return c
+}
+
+// WriteChart writes chart to dir/<chart name> and returns the path of the
+// written chart directory.
+func WriteChart(dir string, chart Chart) (string, error) {
+	chart = chart.withDefaults()
+	chartPath := filepath.Join(dir, chart.Name)
+
+	metadata := map[string]interface{}{
+		"apiVersion": "v2",
+		"name":       chart.Name,
+		"version":    chart.Version,
+		"type":       "application",
+	}
+	if chart.AppVersion != "" {
+		metadata["appVersion"] = chart.AppVersion
+	}
+	if len(chart.Annotations) > 0 {
+		metadata["annotations"] = chart.Annotations
+	}
+	values := chart.Values
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	files := map[string][]byte{}
+	for name, content := range map[string]interface{}{"Chart.yaml": metadata, "values.yaml": values} {
+		marshalled, err := yaml.Marshal(content)
+		if err != nil {
+			return "", fmt.Errorf(`marshalling %s of chart %s: %w`, name, chart.Name, err)
+		}
+		files[name] = marshalled
+	}
+	for name, content := range chart.Templates {
+		files[filepath.Join("templates", name)] = []byte(content)
+	}
+	for name, content := range chart.CRDs {
+		files[filepath.Join("crds", name)] = []byte(content)
+	}
+	for name, content := range files {
+		path := filepath.Join(chartPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf(`creating directory for %s: %w`, path, err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return "", fmt.Errorf(`writing %s: %w`, path, err)
+		}
+	}
+
+	for _, subchart := range chart.Subcharts {
+		if _, err := WriteChart(filepath.Join(chartPath, "charts"), subchart); err != nil {
+			return "", fmt.Errorf(`writing subchart of %s: %w`, chart.Name, err)
+		}
+	}
+
+	return chartPath, nil
+}
+
+// PackageChart writes chart as a packaged chart archive to
+// dir/<name>-<version>.tgz, the same layout produced by `helm package`, and
+// returns the path of the archive.
+func PackageChart(dir string, chart Chart) (string, error) {
+	chart = chart.withDefaults()
+	tmpDir, err := os.MkdirTemp("", "helmtest")
+	if err != nil {
+		return "", fmt.Errorf(`creating temporary directory to package chart %s: %w`, chart.Name, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	chartPath, err := WriteChart(tmpDir, chart)
+	if err != nil {
+		return "", err
+	}
+
+	// collect files in lexical order so archives are reproducible
+	var files []string
+	err = filepath.WalkDir(chartPath, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf(`walking chart %s: %w`, chartPath, err)
+	}
+	sort.Strings(files)
+
+	var archive bytes.Buffer
+	gzw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gzw)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf(`reading %s: %w`, file, err)
+		}
+		relPath, err := filepath.Rel(tmpDir, file)
+		if err != nil {
+			return "", err
+		}
+		header := &tar.Header{Name: filepath.ToSlash(relPath), Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", fmt.Errorf(`writing archive header for %s: %w`, relPath, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return "", fmt.Errorf(`writing %s to archive: %w`, relPath, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf(`closing tar writer: %w`, err)
+	}
+	if err := gzw.Close(); err != nil {
+		return "", fmt.Errorf(`closing gzip writer: %w`, err)
+	}
+
+	archivePath := filepath.Join(dir, fmt.Sprintf("%s-%s.tgz", chart.Name, chart.Version))
+	if err := os.WriteFile(archivePath, archive.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf(`writing chart archive %s: %w`, archivePath, err)
+	}
+	return archivePath, nil
+}
+
+// NewChart writes chart to a temporary directory removed at the end of the
+// test and returns the path of the chart directory. The test fails if the
+// chart cannot be written.
+func NewChart(t testing.TB, chart Chart) string {
+	t.Helper()
+	chartPath, err := WriteChart(t.TempDir(), chart)
+	if err != nil {
+		t.Fatalf("writing test chart: %v", err)
+	}
+	return chartPath
+}
+
+// NewChartArchive packages chart into a temporary directory removed at the
+// end of the test and returns the path of the archive. The test fails if the
+// chart cannot be packaged.
+func NewChartArchive(t testing.TB, chart Chart) string {
+	t.Helper()
+	archivePath, err := PackageChart(t.TempDir(), chart)
+	if err != nil {
+		t.Fatalf("packaging test chart: %v", err)
+	}
+	return archivePath
+}
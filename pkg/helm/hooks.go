@@ -0,0 +1,63 @@
+package helm
+
+import (
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// HookAnnotation is the annotation helm uses to mark a resource as a hook.
+const HookAnnotation = "helm.sh/hook"
+
+// TestHooks are the helm hook types used for `helm test` resources.
+var TestHooks = []string{"test", "test-success", "test-failure"}
+
+// Hooks returns the helm hook types (e.g. "pre-install", "test") a manifest is
+// annotated with. Returns nil if the manifest is not a hook.
+func Hooks(manifest map[string]interface{}) []string {
+	annotation, ok := yamlPlus.GetString(manifest, "metadata", "annotations", HookAnnotation)
+	if !ok {
+		return nil
+	}
+	var hooks []string
+	for _, hook := range strings.Split(annotation, ",") {
+		if hook = strings.TrimSpace(hook); hook != "" {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// RemoveHooks removes helm hook resources from manifests.
+// If hookTypes are provided, only hooks of those types are removed (e.g.
+// RemoveHooks(manifests, TestHooks...) removes only test hooks); otherwise all
+// hooks are removed.
+func RemoveHooks(manifests []map[string]interface{}, hookTypes ...string) []map[string]interface{} {
+	var kept []map[string]interface{}
+	for _, manifest := range manifests {
+		if !isFilteredHook(Hooks(manifest), hookTypes) {
+			kept = append(kept, manifest)
+		}
+	}
+	return kept
+}
+
+// isFilteredHook determines if a resource annotated with hooks should be
+// removed given the hook types to filter. All hooks are filtered when no hook
+// types are provided.
+func isFilteredHook(hooks []string, hookTypes []string) bool {
+	if len(hooks) == 0 {
+		return false
+	}
+	if len(hookTypes) == 0 {
+		return true
+	}
+	for _, hook := range hooks {
+		for _, hookType := range hookTypes {
+			if hook == hookType {
+				return true
+			}
+		}
+	}
+	return false
+}
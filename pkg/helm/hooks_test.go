@@ -0,0 +1,42 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemoveHooks(t *testing.T) {
+	hook := func(name string, hooks string) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"annotations": map[string]interface{}{HookAnnotation: hooks},
+			},
+		}
+	}
+	plain := map[string]interface{}{"metadata": map[string]interface{}{"name": "plain"}}
+	test := hook("test", "test")
+	install := hook("install", "pre-install, pre-upgrade")
+	legacyTest := hook("legacy-test", "test-success")
+	manifests := []map[string]interface{}{plain, test, install, legacyTest}
+
+	type args struct {
+		hookTypes []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []map[string]interface{}
+	}{
+		{"all hooks", args{}, []map[string]interface{}{plain}},
+		{"test hooks", args{TestHooks}, []map[string]interface{}{plain, install}},
+		{"one of multiple hooks", args{[]string{"pre-upgrade"}}, []map[string]interface{}{plain, test, legacyTest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemoveHooks(manifests, tt.args.hookTypes...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RemoveHooks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evanlouie/go/pkg/cache"
+	"gopkg.in/yaml.v3"
+)
+
+// IndexFile is the parsed index.yaml of a chart repository.
+type IndexFile struct {
+	APIVersion string                    `yaml:"apiVersion"`
+	Generated  time.Time                 `yaml:"generated"`
+	Entries    map[string][]ChartVersion `yaml:"entries"`
+}
+
+// ChartVersion is a single version of a chart listed in a repository index.
+type ChartVersion struct {
+	Name        string            `yaml:"name"`
+	Version     string            `yaml:"version"`
+	AppVersion  string            `yaml:"appVersion,omitempty"`
+	APIVersion  string            `yaml:"apiVersion,omitempty"`
+	Description string            `yaml:"description,omitempty"`
+	Home        string            `yaml:"home,omitempty"`
+	Sources     []string          `yaml:"sources,omitempty"`
+	Keywords    []string          `yaml:"keywords,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	Deprecated  bool              `yaml:"deprecated,omitempty"`
+	Created     time.Time         `yaml:"created,omitempty"`
+	Digest      string            `yaml:"digest,omitempty"`
+	URLs        []string          `yaml:"urls"`
+}
+
+// ChartWarning is a warning about the maintenance status of a chart found
+// while resolving it from a repository index.
+type ChartWarning struct {
+	Chart   string
+	Version string
+	Reason  string // one of ChartDeprecated or ChartStale
+	Message string
+}
+
+const (
+	// ChartDeprecated is the ChartWarning reason for charts marked
+	// `deprecated: true`.
+	ChartDeprecated = "deprecated"
+	// ChartStale is the ChartWarning reason for charts which have not
+	// published a new version within the stale threshold.
+	ChartStale = "stale"
+)
+
+// DefaultStaleAfter is the duration without a new release after which a chart
+// is considered stale.
+const DefaultStaleAfter = 365 * 24 * time.Hour
+
+// ResolvedChart is a chart version resolved from a repository index along
+// with any warnings about its maintenance status.
+type ResolvedChart struct {
+	ChartVersion
+	Warnings []ChartWarning
+}
+
+// FetchIndex downloads and parses the index.yaml of the chart repository at
+// repoURL.
+func FetchIndex(repoURL string) (*IndexFile, error) {
+	body, err := downloadIndex(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(body)
+}
+
+// FetchIndexWithCache is the same as FetchIndex but reads the index from c if
+// it was downloaded less than maxAge ago, otherwise downloading it and storing
+// it in c. Indexes of busy repositories are large and change frequently, so
+// maxAge bounds how stale a resolved "latest" version may be.
+func FetchIndexWithCache(repoURL string, c cache.Cache, maxAge time.Duration) (*IndexFile, error) {
+	key := "index\x00" + strings.TrimSuffix(repoURL, "/")
+	// cached indexes are prefixed with the RFC 3339 time they were downloaded at
+	cached, err := c.Get(key)
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		return nil, fmt.Errorf(`reading repository index of %s from cache: %w`, repoURL, err)
+	}
+	if err == nil {
+		if idx := bytes.IndexByte(cached, '\n'); idx > 0 {
+			downloaded, err := time.Parse(time.RFC3339, string(cached[:idx]))
+			if err == nil && time.Since(downloaded) < maxAge {
+				return ParseIndex(cached[idx+1:])
+			}
+		}
+	}
+
+	body, err := downloadIndex(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ParseIndex(body)
+	if err != nil {
+		return nil, err
+	}
+	entry := append([]byte(time.Now().UTC().Format(time.RFC3339)+"\n"), body...)
+	if err := c.Set(key, entry, maxAge); err != nil {
+		return nil, fmt.Errorf(`caching repository index of %s: %w`, repoURL, err)
+	}
+	return index, nil
+}
+
+// downloadIndex downloads the index.yaml of the chart repository at repoURL.
+func downloadIndex(repoURL string) ([]byte, error) {
+	return IndexClient{}.download(repoURL)
+}
+
+// IndexClient fetches the index.yaml of chart repositories, authenticating
+// with basic auth when Username or Password are set. The zero value uses
+// http.DefaultClient without auth, as FetchIndex does.
+type IndexClient struct {
+	Client   *http.Client // defaults to http.DefaultClient
+	Username string
+	Password string
+	Devel    bool // resolve versions including pre-releases, as `helm --devel`
+}
+
+// Index downloads and parses the index.yaml of the chart repository at
+// repoURL.
+func (c IndexClient) Index(repoURL string) (*IndexFile, error) {
+	body, err := c.download(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(body)
+}
+
+// ResolveVersion returns the newest version of chart in the repository at
+// repoURL matching constraint. See IndexFile.Resolve and IndexFile.ResolveDevel.
+func (c IndexClient) ResolveVersion(repoURL string, chart string, constraint string) (ChartVersion, error) {
+	index, err := c.Index(repoURL)
+	if err != nil {
+		return ChartVersion{}, err
+	}
+	entry, err := index.resolve(chart, constraint, c.Devel)
+	if err != nil {
+		return ChartVersion{}, fmt.Errorf(`resolving chart %s@%s from %s: %w`, chart, constraint, repoURL, err)
+	}
+	return entry, nil
+}
+
+func (c IndexClient) download(repoURL string) ([]byte, error) {
+	indexURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	req, err := http.NewRequest(http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf(`creating request for repository index %s: %w`, indexURL, err)
+	}
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf(`downloading repository index %s: %w`, indexURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(`downloading repository index %s: unexpected status %s`, indexURL, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf(`reading repository index %s: %w`, indexURL, err)
+	}
+	return body, nil
+}
+
+// ResolveVersion returns the newest version of chart in the repository at
+// repoURL matching the semantic version constraint (e.g. "~1.2.x", "^2" or
+// ">=1.2.0 <1.5.0"). Resolving a constraint before pulling pins the exact
+// version rendered, so the chart can be cached and the render recorded. See
+// PullOptions.ResolveVersion and TemplateOptions.ResolveVersion.
+func ResolveVersion(repoURL string, chart string, constraint string) (ChartVersion, error) {
+	return IndexClient{}.ResolveVersion(repoURL, chart, constraint)
+}
+
+// isVersionConstraint determines if version of chart in the repository at
+// repoURL is a constraint which can be resolved against the repository index.
+func isVersionConstraint(repoURL string, chart string, version string) bool {
+	return repoURL != "" && !IsOCI(repoURL) && !IsOCI(chart) && version != "" && !isCacheable(version)
+}
+
+// resolveVersionConstraint resolves version to the newest matching version of
+// chart in the chart repository at repoURL if it is a constraint rather than
+// an exact version. Pre-release versions match any constraint when devel is
+// set. Empty versions, exact versions and charts of OCI registries (which
+// have no index) are returned unchanged.
+// The index is downloaded with the credentials and TLS options of auth, the
+// same as helm is run with. When auth is not set and searchHost is, those the
+// repository was added to the host helm client with are used instead.
+func resolveVersionConstraint(repoURL string, chart string, version string, auth repoAuth, searchHost bool, devel bool) (string, error) {
+	if !isVersionConstraint(repoURL, chart, version) {
+		return version, nil
+	}
+	if !auth.isSet() && searchHost {
+		hostAuth, err := hostRepositoryAuth(repoURL)
+		if err != nil {
+			return "", err
+		}
+		auth = hostAuth
+	}
+	httpClient, err := auth.httpClient()
+	if err != nil {
+		return "", fmt.Errorf(`resolving chart %s@%s from %s: %w`, chart, version, repoURL, err)
+	}
+	client := IndexClient{Client: httpClient, Username: auth.username, Password: auth.password, Devel: devel}
+	entry, err := client.ResolveVersion(repoURL, chart, version)
+	if err != nil {
+		return "", err
+	}
+	return entry.Version, nil
+}
+
+// ParseIndex parses the contents of a repository index.yaml. The versions of
+// each chart are sorted newest first.
+func ParseIndex(content []byte) (*IndexFile, error) {
+	var index IndexFile
+	if err := yaml.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf(`parsing repository index: %w`, err)
+	}
+	index.SortEntries()
+	return &index, nil
+}
+
+// SortEntries sorts the versions of every chart in the index by semantic
+// version, newest first. Versions which are not valid semantic versions are
+// sorted last.
+func (idx *IndexFile) SortEntries() {
+	for _, versions := range idx.Entries {
+		sort.SliceStable(versions, func(i, j int) bool {
+			a, aErr := parseSemVer(versions[i].Version)
+			b, bErr := parseSemVer(versions[j].Version)
+			if aErr != nil || bErr != nil {
+				return aErr == nil
+			}
+			return a.compare(b) > 0
+		})
+	}
+}
+
+// Get returns the entry for version of chart in the index. An empty version
+// returns the latest stable (non pre-release) version.
+func (idx *IndexFile) Get(chart string, version string) (ChartVersion, error) {
+	versions, ok := idx.Entries[chart]
+	if !ok || len(versions) == 0 {
+		return ChartVersion{}, fmt.Errorf(`chart %s not found in repository index`, chart)
+	}
+	for _, entry := range versions {
+		if version == "" {
+			if v, err := parseSemVer(entry.Version); err == nil && v.prerelease == "" {
+				return entry, nil
+			}
+		} else if entry.Version == version || strings.TrimPrefix(entry.Version, "v") == strings.TrimPrefix(version, "v") {
+			return entry, nil
+		}
+	}
+	if version == "" {
+		return ChartVersion{}, fmt.Errorf(`no stable version of chart %s found in repository index`, chart)
+	}
+	return ChartVersion{}, fmt.Errorf(`version %s of chart %s not found in repository index`, version, chart)
+}
+
+// Resolve returns the newest version of chart matching the semantic version
+// constraint (e.g. "~1.2.x", "^2", ">=1.2.0 <1.5.0" or "1.2 - 1.4 || 2.x").
+// Pre-release versions only match constraints specifying a pre-release and
+// versions which are not semantic versions never match. An empty constraint
+// returns the latest stable version, as Get does.
+func (idx *IndexFile) Resolve(chart string, constraint string) (ChartVersion, error) {
+	return idx.resolve(chart, constraint, false)
+}
+
+// ResolveDevel returns the newest version of chart matching constraint
+// including pre-release versions, as `helm --devel` does: "^1.2.0" matches
+// 1.3.0-beta.1 but not 2.0.0-rc.1. An empty constraint returns the newest
+// version, stable or not.
+func (idx *IndexFile) ResolveDevel(chart string, constraint string) (ChartVersion, error) {
+	return idx.resolve(chart, constraint, true)
+}
+
+func (idx *IndexFile) resolve(chart string, constraint string, devel bool) (ChartVersion, error) {
+	if strings.TrimSpace(constraint) == "" {
+		if !devel {
+			return idx.Get(chart, "")
+		}
+		constraint = "*"
+	}
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return ChartVersion{}, err
+	}
+	versions, ok := idx.Entries[chart]
+	if !ok || len(versions) == 0 {
+		return ChartVersion{}, fmt.Errorf(`chart %s not found in repository index`, chart)
+	}
+	var newest ChartVersion
+	var newestVersion semVer
+	for _, entry := range versions {
+		v, err := parseSemVer(entry.Version)
+		if err != nil || !c.matches(v, devel) {
+			continue
+		}
+		if newest.Version == "" || v.compare(newestVersion) > 0 {
+			newest, newestVersion = entry, v
+		}
+	}
+	if newest.Version == "" {
+		return ChartVersion{}, fmt.Errorf(`no version of chart %s matching "%s" found in repository index`, chart, constraint)
+	}
+	return newest, nil
+}
+
+// Warnings returns maintenance warnings for version of chart: whether the
+// chart is marked deprecated and whether the chart has not published a new
+// version within staleAfter (the newest version is used to determine when the
+// chart was last updated). A staleAfter of 0 uses DefaultStaleAfter.
+func (idx *IndexFile) Warnings(chart string, version string, staleAfter time.Duration) []ChartWarning {
+	if staleAfter == 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	var warnings []ChartWarning
+	// deprecation is declared in the newest version of a chart but applies to all of them
+	entry, err := idx.Get(chart, version)
+	if versions := idx.Entries[chart]; err == nil && (entry.Deprecated || versions[0].Deprecated) {
+		warnings = append(warnings, ChartWarning{
+			Chart:   chart,
+			Version: entry.Version,
+			Reason:  ChartDeprecated,
+			Message: fmt.Sprintf("chart %s@%s is marked as deprecated", chart, entry.Version),
+		})
+	}
+	var lastUpdated time.Time
+	for _, entry := range idx.Entries[chart] {
+		if entry.Created.After(lastUpdated) {
+			lastUpdated = entry.Created
+		}
+	}
+	if !lastUpdated.IsZero() && time.Since(lastUpdated) > staleAfter {
+		warnings = append(warnings, ChartWarning{
+			Chart:   chart,
+			Version: version,
+			Reason:  ChartStale,
+			Message: fmt.Sprintf("chart %s has not published a new version since %s", chart, lastUpdated.Format("2006-01-02")),
+		})
+	}
+	return warnings
+}
+
+// ResolveChart looks up version of chart (the latest stable version if empty)
+// in the index of the repository at repoURL and returns it along with
+// warnings if the chart is deprecated or has not been updated within
+// DefaultStaleAfter.
+func ResolveChart(repoURL string, chart string, version string) (ResolvedChart, error) {
+	index, err := FetchIndex(repoURL)
+	if err != nil {
+		return ResolvedChart{}, err
+	}
+	entry, err := index.Get(chart, version)
+	if err != nil {
+		return ResolvedChart{}, fmt.Errorf(`resolving chart %s@%s from %s: %w`, chart, version, repoURL, err)
+	}
+	return ResolvedChart{
+		ChartVersion: entry,
+		Warnings:     index.Warnings(chart, entry.Version, 0),
+	}, nil
+}
@@ -0,0 +1,175 @@
+package helm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/evanlouie/go/pkg/cache"
+)
+
+func TestResolveChart(t *testing.T) {
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  maintained:
+    - name: maintained
+      version: 1.10.0
+      created: %[1]s
+      urls: [maintained-1.10.0.tgz]
+    - name: maintained
+      version: 2.0.0-rc.1
+      created: %[1]s
+      urls: [maintained-2.0.0-rc.1.tgz]
+    - name: maintained
+      version: 1.9.0
+      created: %[1]s
+      urls: [maintained-1.9.0.tgz]
+  abandoned:
+    - name: abandoned
+      version: 0.1.0
+      deprecated: true
+      created: 2015-01-01T00:00:00Z
+      urls: [abandoned-0.1.0.tgz]
+`, recent)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, index)
+	}))
+	defer server.Close()
+
+	type args struct {
+		chart   string
+		version string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantVersion  string
+		wantWarnings []string
+		wantErr      bool
+	}{
+		{"latest stable", args{"maintained", ""}, "1.10.0", nil, false},
+		{"specific version", args{"maintained", "1.9.0"}, "1.9.0", nil, false},
+		{"deprecated and stale", args{"abandoned", ""}, "0.1.0", []string{ChartDeprecated, ChartStale}, false},
+		{"missing version", args{"maintained", "3.0.0"}, "", nil, true},
+		{"missing chart", args{"missing", ""}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveChart(server.URL+"/charts/", tt.args.chart, tt.args.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolveChart() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Version != tt.wantVersion {
+				t.Errorf("ResolveChart() version = %v, want %v", got.Version, tt.wantVersion)
+			}
+			var gotWarnings []string
+			for _, warning := range got.Warnings {
+				gotWarnings = append(gotWarnings, warning.Reason)
+			}
+			if !reflect.DeepEqual(gotWarnings, tt.wantWarnings) {
+				t.Errorf("ResolveChart() warnings = %v, want %v", got.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestFetchIndexWithCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, "apiVersion: v1\nentries:\n  nginx:\n    - name: nginx\n      version: 1.%d.0\n", requests)
+	}))
+	defer server.Close()
+	indexCache := &cache.Memory{}
+
+	tests := []struct {
+		name         string
+		maxAge       time.Duration
+		wantVersion  string
+		wantRequests int
+	}{
+		{"miss", time.Hour, "1.1.0", 1},
+		{"hit", time.Hour, "1.1.0", 1},
+		{"stale", 0, "1.2.0", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := FetchIndexWithCache(server.URL, indexCache, tt.maxAge)
+			if err != nil {
+				t.Fatalf("FetchIndexWithCache() error = %v", err)
+			}
+			if got := index.Entries["nginx"][0].Version; got != tt.wantVersion {
+				t.Errorf("FetchIndexWithCache() version = %v, want %v", got, tt.wantVersion)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("FetchIndexWithCache() requests = %d, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  web:
+    - {name: web, version: 2.0.0-rc.1, urls: [web-2.0.0-rc.1.tgz]}
+    - {name: web, version: 1.3.0, urls: [web-1.3.0.tgz]}
+    - {name: web, version: 1.2.10, urls: [web-1.2.10.tgz]}
+    - {name: web, version: 1.2.9, urls: [web-1.2.9.tgz]}
+    - {name: web, version: latest, urls: [web-latest.tgz]}
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, index)
+	}))
+	defer server.Close()
+	client := IndexClient{Username: "user", Password: "pass"}
+
+	tests := []struct {
+		name       string
+		chart      string
+		constraint string
+		devel      bool
+		want       string
+		wantErr    bool
+	}{
+		{"patch", "web", "~1.2.x", false, "1.2.10", false},
+		{"caret", "web", "^1", false, "1.3.0", false},
+		{"latest-stable", "web", "", false, "1.3.0", false},
+		{"prerelease", "web", ">=2.0.0-0", false, "2.0.0-rc.1", false},
+		{"devel-caret", "web", "^1", true, "1.3.0", false},
+		{"devel-range", "web", ">=1.0.0", true, "2.0.0-rc.1", false},
+		{"devel-latest", "web", "", true, "2.0.0-rc.1", false},
+		{"no-match", "web", "~1.4", false, "", true},
+		{"missing-chart", "api", "1.x", false, "", true},
+		{"invalid-constraint", "web", "~one", false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := client
+			client.Devel = tt.devel
+			got, err := client.ResolveVersion(server.URL, tt.chart, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Version != tt.want {
+				t.Errorf("ResolveVersion() = %v, want %v", got.Version, tt.want)
+			}
+		})
+	}
+	if _, err := ResolveVersion(server.URL, "web", "1.x"); err == nil {
+		t.Errorf("ResolveVersion() without credentials error = nil, want unauthorized")
+	}
+}
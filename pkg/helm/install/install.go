@@ -0,0 +1,27 @@
+// Package install provides a helm 3 binary to programs which cannot rely on
+// one being present on the host.
+package install
+
+import (
+	"github.com/evanlouie/go/pkg/helm/internal/installer"
+)
+
+// Install the latest Helm release to a temporary file on the host. Returns the
+// path to the installed binary.
+// It is the callers responsibility to ensure that the file is cleaned up.
+func Install() (string, error) {
+	return installer.Install()
+}
+
+// InstallTo installs the latest Helm release into dir, which may be shared
+// between processes, unless it is already installed there. Returns the path to
+// the installed binary.
+func InstallTo(dir string) (string, error) {
+	return installer.InstallTo(dir)
+}
+
+// GetHelm gets the path to a Helm 3 binary first searching for it on the user
+// $PATH or installing it to a temporary file if it is not found.
+func GetHelm() (string, error) {
+	return installer.GetHelm()
+}
@@ -6,15 +6,21 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 
+	"github.com/evanlouie/go/pkg/errcode"
+	"github.com/evanlouie/go/pkg/filelock"
 	"github.com/evanlouie/go/pkg/helm"
 	"github.com/google/go-github/v33/github"
 )
@@ -75,6 +81,55 @@ func decompressZippedBin(body []byte) ([]byte, error) {
 	return nil, fmt.Errorf(`no file named "helm.exe" found in zip file`)
 }
 
+// download gets the body of url, failing for responses other than 200 OK.
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.InstallDownload, fmt.Errorf(`downloading %s: %w`, url, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errcode.Wrap(errcode.InstallDownload, fmt.Errorf(`downloading %s: %s`, url, resp.Status))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.InstallDownload, fmt.Errorf(`reading body of download response from %s: %w`, url, err))
+	}
+	return body, nil
+}
+
+// sha256Rgx matches a hex encoded sha256 digest.
+var sha256Rgx = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// verifyChecksum verifies archive against the digest listed for the file name
+// in checksumFile, which holds "<sha256 hex>  <file name>" lines as written by
+// sha256sum. Files which are malformed or do not list name fail verification.
+func verifyChecksum(archive []byte, checksumFile []byte, name string) error {
+	var expected string
+	for _, line := range strings.Split(string(checksumFile), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !sha256Rgx.MatchString(fields[0]) {
+			return errcode.Wrap(errcode.InstallChecksumMismatch, fmt.Errorf(`verifying %s: malformed checksum line "%s"`, name, line))
+		}
+		// sha256sum prefixes the names of files read in binary mode with "*"
+		if strings.TrimPrefix(fields[1], "*") == name {
+			expected = fields[0]
+			break
+		}
+	}
+	if expected == "" {
+		return errcode.Wrap(errcode.InstallChecksumMismatch, fmt.Errorf(`verifying %s: no checksum listed for it`, name))
+	}
+	sum := sha256.Sum256(archive)
+	if digest := hex.EncodeToString(sum[:]); !strings.EqualFold(expected, digest) {
+		return errcode.Wrap(errcode.InstallChecksumMismatch, fmt.Errorf(`checksum of %s does not match: expected sha256:%s, got sha256:%s`, name, expected, digest))
+	}
+	return nil
+}
+
 // downloadLatest downloads the helm latest binary from the latest release from
 // github for the OS corresponding to runtime.GOOS and return it as a byte
 // slice.
@@ -83,7 +138,7 @@ func downloadLatest() ([]byte, error) {
 	client := github.NewClient(nil)
 	release, _, err := client.Repositories.GetLatestRelease(context.Background(), "helm", "Helm")
 	if err != nil {
-		return nil, fmt.Errorf(`getting latest release from github for helm/helm: %w`, err)
+		return nil, errcode.Wrap(errcode.InstallDownload, fmt.Errorf(`getting latest release from github for helm/helm: %w`, err))
 	}
 	if len(strings.TrimSpace(*release.Body)) == 0 {
 		return nil, fmt.Errorf(`getting latest release from github for helm/helm: empty release body was found for release %s`, *release.Name)
@@ -99,19 +154,22 @@ func downloadLatest() ([]byte, error) {
 	case "windows":
 		compressExt = "zip"
 	default:
-		return nil, fmt.Errorf(`downloading helm binary: unsupported host %s`, runtime.GOOS)
+		return nil, errcode.Wrap(errcode.InstallUnsupportedPlatform, fmt.Errorf(`downloading helm binary: unsupported host %s`, runtime.GOOS))
 	}
 
 	// download the os specific release
 	downloadURL := fmt.Sprintf(`https://get.helm.sh/helm-%s-%s-amd64.%s`, *release.TagName, runtime.GOOS, compressExt)
-	resp, err := http.Get(downloadURL)
+	bodyBytes, err := download(downloadURL)
 	if err != nil {
-		return nil, fmt.Errorf(`downloading helm from %s: %w`, downloadURL, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := io.ReadAll(resp.Body)
+	// get.helm.sh publishes "<sha256 hex>  <file name>" next to each release
+	checksumFile, err := download(downloadURL + ".sha256sum")
 	if err != nil {
-		return nil, fmt.Errorf(`reading body of helm download response from %s: %w`, downloadURL, err)
+		return nil, err
+	}
+	if err := verifyChecksum(bodyBytes, checksumFile, path.Base(downloadURL)); err != nil {
+		return nil, err
 	}
 
 	// decompress the file and get the helm bin bytes
@@ -165,6 +223,52 @@ func Install() (string, error) {
 	return f.Name(), nil
 }
 
+// InstallTo installs the latest Helm release as <dir>/helm (helm.exe on
+// windows) unless it is already installed there, returning the path to the
+// binary. Processes installing into the same directory (e.g. one shared by
+// CI jobs) wait for the first install rather than overwriting each other.
+func InstallTo(dir string) (string, error) {
+	name := "helm"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	binary := filepath.Join(dir, name)
+	installLock, err := filelock.Acquire(filepath.Join(dir, ".install.lock"))
+	if err != nil {
+		return "", fmt.Errorf(`locking helm install directory %s: %w`, dir, err)
+	}
+	defer installLock.Release()
+	if info, err := os.Stat(binary); err == nil && !info.IsDir() {
+		return binary, nil
+	}
+
+	downloadedBytes, err := downloadLatest()
+	if err != nil {
+		return "", fmt.Errorf(`downloaded latest helm release: %w`, err)
+	}
+	// write to a temporary file renamed into place so the binary is never
+	// observed partially written
+	f, err := os.CreateTemp(dir, ".helm-")
+	if err != nil {
+		return "", fmt.Errorf(`creating temporary file in %s to hold downloaded helm binary: %w`, dir, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(downloadedBytes); err != nil {
+		f.Close()
+		return "", fmt.Errorf(`writing downloaded helm binary to temporary file %s: %w`, f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf(`writing downloaded helm binary to temporary file %s: %w`, f.Name(), err)
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return "", fmt.Errorf(`setting permission %s to downloaded Helm binary %s`, os.FileMode(0755), f.Name())
+	}
+	if err := os.Rename(f.Name(), binary); err != nil {
+		return "", fmt.Errorf(`moving downloaded helm binary to %s: %w`, binary, err)
+	}
+	return binary, nil
+}
+
 // GetHelm gets the path to a Helm 3 binary first searching for it on the user
 // $PATH or installing it to a temporary file if it is not found.
 func GetHelm() (string, error) {
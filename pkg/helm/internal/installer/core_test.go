@@ -0,0 +1,49 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	archive := []byte("helm archive")
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	other := strings.Repeat("0", 64)
+	const name = "helm-v3.7.1-linux-amd64.tar.gz"
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  string
+	}{
+		{name: "match", checksum: digest + "  " + name + "\n"},
+		{name: "match uppercase binary mode", checksum: strings.ToUpper(digest) + " *" + name},
+		{name: "match among other names", checksum: other + "  helm-v3.7.1-darwin-amd64.tar.gz\n" + digest + "  " + name + "\n"},
+		{name: "mismatch", checksum: other + "  " + name, wantErr: "does not match"},
+		{name: "malformed digest", checksum: "not-a-digest  " + name, wantErr: "malformed checksum line"},
+		{name: "malformed line", checksum: digest, wantErr: "malformed checksum line"},
+		{name: "empty", checksum: "", wantErr: "no checksum listed"},
+		{name: "other names only", checksum: digest + "  helm-v3.7.1-windows-amd64.zip\n", wantErr: "no checksum listed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChecksum(archive, []byte(tt.checksum), name)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verifyChecksum() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("verifyChecksum() error = %v, want %s", err, tt.wantErr)
+			}
+			if code := errcode.Of(err); code != errcode.InstallChecksumMismatch {
+				t.Errorf("errcode.Of() = %s, want %s", code, errcode.InstallChecksumMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// isolatedConfig is a throwaway helm configuration used for a single
+// operation when IsolatedConfig is set, so the repositories and cache of the
+// host helm client are neither read nor modified.
+// A nil *isolatedConfig uses the host helm client.
+type isolatedConfig struct {
+	dir string
+	env []string
+}
+
+// newIsolatedConfig creates a temporary directory holding the helm
+// configuration home, repository config and repository cache of an operation.
+func newIsolatedConfig() (*isolatedConfig, error) {
+	dir, err := mkdirTemp("fabrikate-helm")
+	if err != nil {
+		return nil, fmt.Errorf(`creating temporary directory for isolated helm config: %w`, err)
+	}
+	return &isolatedConfig{
+		dir: dir,
+		env: []string{
+			"HELM_CONFIG_HOME=" + filepath.Join(dir, "config"),
+			"HELM_REPOSITORY_CONFIG=" + filepath.Join(dir, "config", "repositories.yaml"),
+			"HELM_REPOSITORY_CACHE=" + filepath.Join(dir, "cache", "repository"),
+		},
+	}, nil
+}
+
+// openIsolatedConfig returns a new isolatedConfig if enabled, nil otherwise.
+func openIsolatedConfig(enabled bool) (*isolatedConfig, error) {
+	if !enabled {
+		return nil, nil
+	}
+	return newIsolatedConfig()
+}
+
+// command creates an *exec.Cmd running helm with args in the isolated
+// configuration.
+func (c *isolatedConfig) command(args ...string) *exec.Cmd {
+	cmd := helmCommand(args...)
+	if c == nil {
+		return cmd
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// later entries take precedence over earlier entries of the same key
+	cmd.Env = append(cmd.Env, c.env...)
+	return cmd
+}
+
+// searchHost determines if the repositories of the host helm client should be
+// searched for a matching repository URL.
+func (c *isolatedConfig) searchHost() bool {
+	return c == nil
+}
+
+// Close removes the isolated configuration.
+func (c *isolatedConfig) Close() error {
+	if c == nil {
+		return nil
+	}
+	return removeTemp(c.dir)
+}
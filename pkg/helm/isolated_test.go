@@ -0,0 +1,49 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPullWithOptions_isolatedConfig(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "helm.log")
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+*) echo "$* config=$HELM_REPOSITORY_CONFIG cache=$HELM_REPOSITORY_CACHE" >> "`+log+`" ;;
+esac`)
+
+	err := PullWithOptions(PullOptions{
+		RepoURL:        "https://charts.example.com",
+		Chart:          "my-chart",
+		Version:        "1.0.0",
+		Into:           dir,
+		IsolatedConfig: true,
+	})
+	if err != nil {
+		t.Fatalf("PullWithOptions() error = %v", err)
+	}
+
+	content, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "pull my-chart ") {
+		t.Fatalf("expected only `helm pull` to run without searching host repositories, got %q", lines)
+	}
+	if !strings.Contains(lines[0], "--repo https://charts.example.com") {
+		t.Errorf("expected chart to be pulled via --repo, got %s", lines[0])
+	}
+	fields := strings.Fields(lines[0])
+	config := strings.TrimPrefix(fields[len(fields)-2], "config=")
+	cache := strings.TrimPrefix(fields[len(fields)-1], "cache=")
+	if config == "" || cache == "" || filepath.Dir(filepath.Dir(config)) != filepath.Dir(filepath.Dir(cache)) {
+		t.Fatalf("expected isolated repository config and cache, got config=%s cache=%s", config, cache)
+	}
+	if _, err := os.Stat(filepath.Dir(filepath.Dir(config))); !os.IsNotExist(err) {
+		t.Errorf("expected isolated config to be removed after pulling, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// ErrLimitExceeded is wrapped by the errors returned when rendered output
+// exceeds the OutputLimits of TemplateOptions.
+var ErrLimitExceeded = errcode.New(errcode.HelmLimitExceeded, "output limit exceeded")
+
+// OutputLimits guard against pathological chart output (e.g. a template
+// looping over a large value) which could overwhelm downstream tooling such
+// as GitOps controllers or the Kubernetes API server.
+type OutputLimits struct {
+	MaxDocuments     int  // maximum number of non-empty documents in the output. 0 for no limit
+	MaxDocumentBytes int  // maximum size in bytes of a single document. 0 for no limit. the Kubernetes API server rejects objects over ~1.5MiB
+	WarnOnly         bool // emit LimitExceeded events instead of failing when a limit is exceeded
+}
+
+// check returns an error wrapping ErrLimitExceeded for every limit output
+// exceeds.
+func (limits OutputLimits) check(output string) []error {
+	if limits.MaxDocuments <= 0 && limits.MaxDocumentBytes <= 0 {
+		return nil
+	}
+
+	var errs []error
+	documents := 0
+	for _, document := range yamlPlus.SplitDocuments([]byte(output)) {
+		if isBlankDocument(output[document.Offset:document.End]) {
+			continue
+		}
+		documents++
+		if size := document.End - document.Offset; limits.MaxDocumentBytes > 0 && size > limits.MaxDocumentBytes {
+			errs = append(errs, fmt.Errorf(`%w: document at lines %d-%d is %d bytes, exceeding the maximum of %d bytes`, ErrLimitExceeded, document.StartLine, document.EndLine, size, limits.MaxDocumentBytes))
+		}
+	}
+	if limits.MaxDocuments > 0 && documents > limits.MaxDocuments {
+		errs = append(errs, fmt.Errorf(`%w: output contains %d documents, exceeding the maximum of %d documents`, ErrLimitExceeded, documents, limits.MaxDocuments))
+	}
+	return errs
+}
+
+// enforce checks output against limits, emitting a LimitExceeded event for
+// every limit exceeded. Returns the first error unless limits.WarnOnly is set.
+func (limits OutputLimits) enforce(opts TemplateOptions, output string) error {
+	errs := limits.check(output)
+	for _, err := range errs {
+		opts.emit(Event{Type: LimitExceeded, Err: err})
+	}
+	if len(errs) > 0 && !limits.WarnOnly {
+		return errs[0]
+	}
+	return nil
+}
+
+// isBlankDocument determines if a raw yaml document contains only markers,
+// whitespace and comments. e.g: the "# Source: ..." header helm outputs for a
+// template which rendered nothing.
+func isBlankDocument(document string) bool {
+	for _, line := range strings.Split(documentBody(document), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
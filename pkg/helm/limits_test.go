@@ -0,0 +1,42 @@
+package helm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutputLimits_check(t *testing.T) {
+	output := `---
+a: 1
+---
+b: 2
+---
+# only a comment
+---
+c: ` + "'0123456789012345678901234567890123456789'" + `
+`
+	tests := []struct {
+		name     string
+		limits   OutputLimits
+		wantErrs int
+	}{
+		{"no limits", OutputLimits{}, 0},
+		{"within limits", OutputLimits{MaxDocuments: 3, MaxDocumentBytes: 100}, 0},
+		{"too many documents", OutputLimits{MaxDocuments: 2}, 1},
+		{"document too large", OutputLimits{MaxDocumentBytes: 20}, 1},
+		{"both", OutputLimits{MaxDocuments: 1, MaxDocumentBytes: 20}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.limits.check(output)
+			if len(errs) != tt.wantErrs {
+				t.Errorf("check() = %v, want %d errors", errs, tt.wantErrs)
+			}
+			for _, err := range errs {
+				if !errors.Is(err, ErrLimitExceeded) {
+					t.Errorf("check() error = %v, want ErrLimitExceeded", err)
+				}
+			}
+		})
+	}
+}
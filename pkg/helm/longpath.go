@@ -0,0 +1,73 @@
+package helm
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// longPath converts path to an extended-length path (\\?\C:\...) on Windows
+// so deeply nested chart files can be extracted and read beyond MAX_PATH (260
+// characters). Relative paths are resolved against the Dir of the Client, as
+// helm resolves them, rather than the working directory of the current
+// process. path is returned unchanged on other platforms.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" || path == "" {
+		return path
+	}
+	abs, err := absolutePath(path, CurrentClient().Dir)
+	if err != nil {
+		return path
+	}
+	return extendedLengthPath(abs)
+}
+
+// absolutePath returns path made absolute against dir, or against the working
+// directory of the current process if dir is empty.
+func absolutePath(path, dir string) (string, error) {
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Abs(path)
+}
+
+// extendedLengthPath converts the absolute Windows path to its extended-length
+// form. UNC paths (\\server\share\...) are converted to \\?\UNC\server\share\...
+// Relative paths and paths already in extended-length or device form are
+// returned unchanged.
+func extendedLengthPath(path string) string {
+	path = strings.ReplaceAll(path, "/", `\`)
+	switch {
+	case strings.HasPrefix(path, `\\?\`), strings.HasPrefix(path, `\\.\`):
+		return path
+	case strings.HasPrefix(path, `\\`):
+		return `\\?\UNC\` + cleanWindowsPath(strings.TrimPrefix(path, `\\`))
+	case len(path) >= 3 && path[1] == ':' && path[2] == '\\':
+		return `\\?\` + cleanWindowsPath(path)
+	default:
+		return path
+	}
+}
+
+// cleanWindowsPath removes empty, "." and ".." elements from a backslash
+// separated path as extended-length paths are not normalized by Windows.
+func cleanWindowsPath(path string) string {
+	var elements []string
+	for _, element := range strings.Split(path, `\`) {
+		switch element {
+		case "", ".":
+		case "..":
+			// never traverse above the volume (e.g. "C:") or UNC share
+			if len(elements) > 1 {
+				elements = elements[:len(elements)-1]
+			}
+		default:
+			elements = append(elements, element)
+		}
+	}
+	cleaned := strings.Join(elements, `\`)
+	if len(elements) == 1 && strings.HasSuffix(cleaned, ":") {
+		cleaned += `\` // volume root
+	}
+	return cleaned
+}
@@ -0,0 +1,62 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_extendedLengthPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"drive", `C:\Users\me\charts`, `\\?\C:\Users\me\charts`},
+		{"drive root", `C:\`, `\\?\C:\`},
+		{"forward slashes", `C:/Users/me/charts`, `\\?\C:\Users\me\charts`},
+		{"dot elements", `C:\Users\.\me\..\you\\charts\`, `\\?\C:\Users\you\charts`},
+		{"unc", `\\server\share\charts`, `\\?\UNC\server\share\charts`},
+		{"already extended", `\\?\C:\Users\me`, `\\?\C:\Users\me`},
+		{"device", `\\.\pipe\helm`, `\\.\pipe\helm`},
+		{"relative", `charts\nginx`, `charts\nginx`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extendedLengthPath(tt.path); got != tt.want {
+				t.Errorf("extendedLengthPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_absolutePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "charts", "nginx")
+	tests := []struct {
+		name string
+		path string
+		dir  string
+		want string
+	}{
+		{"relative to dir", filepath.Join("charts", "nginx"), dir, abs},
+		{"relative to working directory", filepath.Join("charts", "nginx"), "", filepath.Join(wd, "charts", "nginx")},
+		{"absolute", abs, wd, abs},
+		{"dot elements", filepath.Join("charts", ".", "web", "..", "nginx"), dir, abs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := absolutePath(tt.path, tt.dir)
+			if err != nil {
+				t.Fatalf("absolutePath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("absolutePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	"gopkg.in/yaml.v3"
+)
+
+// ChartSpec identifies a version of a chart in a repository.
+type ChartSpec struct {
+	Name    string
+	Version string // empty for the latest stable version
+}
+
+// Mirror downloads the selected chart versions from the repository at repoURL
+// into dest along with a regenerated index.yaml referencing the downloaded
+// archives, so dest can be served as a mirror of the upstream repository.
+// Entries of an existing index.yaml in dest are preserved so mirrors can be
+// updated incrementally.
+// If dest is an oci:// registry reference, the charts are pushed to the
+// registry instead and no index is written.
+// Returns the index of the mirrored charts.
+func Mirror(repoURL string, charts []ChartSpec, dest string) (*IndexFile, error) {
+	upstream, err := FetchIndex(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveDir := dest
+	if IsOCI(dest) {
+		tmpDir, err := mkdirTemp("fabrikate")
+		if err != nil {
+			return nil, fmt.Errorf(`creating temporary directory to mirror charts from %s: %w`, repoURL, err)
+		}
+		defer removeTemp(tmpDir)
+		archiveDir = tmpDir
+	} else if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf(`creating mirror directory %s: %w`, dest, err)
+	}
+
+	mirrored := &IndexFile{APIVersion: "v1", Entries: map[string][]ChartVersion{}}
+	var archives []string
+	for _, spec := range charts {
+		entry, err := upstream.Get(spec.Name, spec.Version)
+		if err != nil {
+			return nil, fmt.Errorf(`resolving chart %s@%s from %s: %w`, spec.Name, spec.Version, repoURL, err)
+		}
+		archive, err := downloadChartArchive(repoURL, entry, archiveDir)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, archive)
+
+		// reference the mirrored archive relative to the index
+		entry.URLs = []string{filepath.Base(archive)}
+		mirrored.Entries[entry.Name] = append(mirrored.Entries[entry.Name], entry)
+	}
+
+	if IsOCI(dest) {
+		for _, archive := range archives {
+			if err := Push(archive, dest); err != nil {
+				return nil, err
+			}
+		}
+		mirrored.SortEntries()
+		return mirrored, nil
+	}
+
+	// merge with the existing mirror index
+	indexPath := filepath.Join(dest, "index.yaml")
+	if content, err := os.ReadFile(indexPath); err == nil {
+		existing, err := ParseIndex(content)
+		if err != nil {
+			return nil, fmt.Errorf(`parsing existing mirror index %s: %w`, indexPath, err)
+		}
+		mirrored.Merge(existing)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`reading existing mirror index %s: %w`, indexPath, err)
+	}
+	if err := mirrored.WriteFile(indexPath); err != nil {
+		return nil, err
+	}
+
+	return mirrored, nil
+}
+
+// Merge adds the chart versions of other which are not already in the index.
+func (idx *IndexFile) Merge(other *IndexFile) {
+	if idx.Entries == nil {
+		idx.Entries = map[string][]ChartVersion{}
+	}
+	for name, versions := range other.Entries {
+		for _, entry := range versions {
+			exists := false
+			for _, existing := range idx.Entries[name] {
+				if existing.Version == entry.Version {
+					exists = true
+					break
+				}
+			}
+			if !exists {
+				idx.Entries[name] = append(idx.Entries[name], entry)
+			}
+		}
+	}
+	idx.SortEntries()
+}
+
+// WriteFile writes the index to path as yaml, updating its generated time.
+func (idx *IndexFile) WriteFile(path string) error {
+	if idx.APIVersion == "" {
+		idx.APIVersion = "v1"
+	}
+	idx.Generated = time.Now()
+	content, err := yaml.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf(`marshalling repository index: %w`, err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf(`writing repository index %s: %w`, path, err)
+	}
+	return nil
+}
+
+// mirrorClient downloads the chart archives mirrored by Mirror.
+var mirrorClient = &http.Client{Timeout: 5 * time.Minute}
+
+// downloadChartArchive downloads the archive of a chart version listed in the
+// index of the repository at repoURL into dir, verifying its digest when the
+// index provides one. Returns the path to the downloaded archive.
+func downloadChartArchive(repoURL string, entry ChartVersion, dir string) (string, error) {
+	if len(entry.URLs) == 0 {
+		return "", fmt.Errorf(`chart %s@%s has no download URLs in the repository index`, entry.Name, entry.Version)
+	}
+	// the archive is named after the chart version in the untrusted upstream
+	// index, so it must not be able to escape dir
+	for _, field := range []string{entry.Name, entry.Version} {
+		if field == "" || field == "." || field == ".." || strings.ContainsAny(field, `/\`) {
+			return "", fmt.Errorf(`invalid chart %s@%s in the repository index of %s: name and version must not be empty or contain path separators`, entry.Name, entry.Version, repoURL)
+		}
+	}
+	archiveURL, err := resolveChartURL(repoURL, entry.URLs[0])
+	if err != nil {
+		return "", err
+	}
+	resp, err := mirrorClient.Get(archiveURL)
+	if err != nil {
+		return "", fmt.Errorf(`downloading chart %s@%s from %s: %w`, entry.Name, entry.Version, archiveURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(`downloading chart %s@%s from %s: unexpected status %s`, entry.Name, entry.Version, archiveURL, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf(`reading chart %s@%s from %s: %w`, entry.Name, entry.Version, archiveURL, err)
+	}
+
+	if entry.Digest != "" {
+		sum := sha256.Sum256(body)
+		if digest := hex.EncodeToString(sum[:]); !strings.EqualFold(digest, entry.Digest) {
+			return "", errcode.Wrap(errcode.HelmDigestMismatch, fmt.Errorf(`digest of chart %s@%s downloaded from %s is %s, expected %s`, entry.Name, entry.Version, archiveURL, digest, entry.Digest))
+		}
+	}
+
+	archivePath := filepath.Join(dir, fmt.Sprintf("%s-%s.tgz", entry.Name, entry.Version))
+	if err := os.WriteFile(archivePath, body, 0644); err != nil {
+		return "", fmt.Errorf(`writing chart archive %s: %w`, archivePath, err)
+	}
+	return archivePath, nil
+}
+
+// resolveChartURL resolves a (possibly relative) chart URL from a repository
+// index against the repository URL.
+func resolveChartURL(repoURL string, chartURL string) (string, error) {
+	base, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf(`parsing repository URL %s: %w`, repoURL, err)
+	}
+	ref, err := url.Parse(chartURL)
+	if err != nil {
+		return "", fmt.Errorf(`parsing chart URL %s: %w`, chartURL, err)
+	}
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+	// relative URLs are relative to the directory holding index.yaml
+	base.Path = path.Join(base.Path, ref.Path)
+	return base.String(), nil
+}
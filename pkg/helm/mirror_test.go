@@ -0,0 +1,127 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+func TestMirror(t *testing.T) {
+	archive := []byte("chart archive")
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  web:
+    - name: web
+      version: 1.0.0
+      digest: %[1]s
+      urls: [web-1.0.0.tgz]
+  upper:
+    - name: upper
+      version: 1.0.0
+      digest: %[2]s
+      urls: [upper-1.0.0.tgz]
+  tampered:
+    - name: tampered
+      version: 1.0.0
+      digest: %[1]s
+      urls: [tampered-1.0.0.tgz]
+  traversal:
+    - name: ../../traversal
+      version: 1.0.0
+      urls: [traversal-1.0.0.tgz]
+  traversal-version:
+    - name: traversal-version
+      version: ../1.0.0
+      urls: [traversal-version-1.0.0.tgz]
+`, digest, strings.ToUpper(digest))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			fmt.Fprint(w, index)
+		case "/charts/web-1.0.0.tgz", "/charts/upper-1.0.0.tgz", "/charts/traversal-1.0.0.tgz", "/charts/traversal-version-1.0.0.tgz":
+			w.Write(archive)
+		case "/charts/tampered-1.0.0.tgz":
+			w.Write([]byte("tampered archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		chart    ChartSpec
+		wantFile string
+		wantErr  string
+		wantCode errcode.Code
+	}{
+		{"relative-url", ChartSpec{Name: "web"}, "web-1.0.0.tgz", "", ""},
+		{"uppercase-digest", ChartSpec{Name: "upper"}, "upper-1.0.0.tgz", "", ""},
+		{"digest-mismatch", ChartSpec{Name: "tampered"}, "", "expected " + digest, errcode.HelmDigestMismatch},
+		{"name-traversal", ChartSpec{Name: "traversal"}, "", "must not be empty or contain path separators", ""},
+		{"version-traversal", ChartSpec{Name: "traversal-version", Version: "../1.0.0"}, "", "must not be empty or contain path separators", ""},
+		{"missing-chart", ChartSpec{Name: "missing"}, "", "resolving chart missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "mirror", "charts")
+			got, err := Mirror(server.URL+"/charts/", []ChartSpec{tt.chart}, dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Mirror() error = %v, want %s", err, tt.wantErr)
+				}
+				if tt.wantCode != "" && errcode.Of(err) != tt.wantCode {
+					t.Errorf("Mirror() error code = %s, want %s", errcode.Of(err), tt.wantCode)
+				}
+				// nothing is written outside of dest
+				entries, err := os.ReadDir(root)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != 1 || entries[0].Name() != "mirror" {
+					t.Errorf("Mirror() wrote %v outside of %s", entries, dest)
+				}
+				if _, err := os.Stat(filepath.Join(dest, "index.yaml")); err == nil {
+					t.Errorf("Mirror() wrote the index of a failed mirror")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Mirror() error = %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(dest, tt.wantFile))
+			if err != nil {
+				t.Fatalf("reading mirrored archive: %v", err)
+			}
+			if string(content) != string(archive) {
+				t.Errorf("mirrored archive = %q, want %q", content, archive)
+			}
+			written, err := os.ReadFile(filepath.Join(dest, "index.yaml"))
+			if err != nil {
+				t.Fatalf("reading mirror index: %v", err)
+			}
+			index, err := ParseIndex(written)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries := index.Entries[tt.chart.Name]
+			if len(entries) != 1 || len(entries[0].URLs) != 1 || entries[0].URLs[0] != tt.wantFile {
+				t.Errorf("mirror index entries of %s = %+v, want the single URL %s", tt.chart.Name, entries, tt.wantFile)
+			}
+			if len(got.Entries) != 1 {
+				t.Errorf("Mirror() = %+v, want only %s", got.Entries, tt.chart.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package helm
+
+import (
+	"fmt"
+
+	"github.com/evanlouie/go/pkg/manifest"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// InjectNamespaceOptions configure InjectNamespace.
+type InjectNamespaceOptions struct {
+	Overwrite       bool // replace namespaces already set in the manifests. by default only manifests without a namespace are modified
+	CreateNamespace bool // append a v1 Namespace of the namespace unless one is already in the manifests
+}
+
+// InjectNamespace sets metadata.namespace of the namespaced manifests to
+// namespace, mirroring how `helm install --namespace` places resources which
+// do not specify a namespace. Cluster-scoped resources (e.g. ClusterRoles and
+// CustomResourceDefinitions) are left untouched; see
+// manifest.RegisterClusterScoped to register additional cluster-scoped kinds.
+// Custom resources of cluster-scoped CRDs within manifests are recognized as
+// well.
+// Manifests are modified in place; the returned slice additionally contains
+// the Namespace when opts.CreateNamespace is set.
+func InjectNamespace(manifests []map[string]interface{}, namespace string, opts InjectNamespaceOptions) ([]map[string]interface{}, error) {
+	renderedCRDs := map[manifest.GroupKind]bool{}
+	for _, kind := range manifest.ClusterScopedCRDKinds(manifest.FromMaps(manifests)) {
+		renderedCRDs[kind] = true
+	}
+
+	hasNamespace := false
+	for _, m := range manifests {
+		if m == nil {
+			continue
+		}
+		gvk := manifest.Manifest(m).GVK()
+		if name, _ := yamlPlus.GetString(m, "metadata", "name"); gvk.GroupKind() == (manifest.GroupKind{Kind: "Namespace"}) && name == namespace {
+			hasNamespace = true
+		}
+		if manifest.IsClusterScoped(gvk.GroupKind()) || renderedCRDs[gvk.GroupKind()] {
+			continue
+		}
+
+		// inject the metadata map if it is not present
+		if m["metadata"] == nil {
+			m["metadata"] = map[string]interface{}{}
+		}
+		metadata, ok := m["metadata"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"metadata" of manifest is not a map[string]interface{}: %+v`, m)
+		}
+		if existing, _ := metadata["namespace"].(string); existing == "" || opts.Overwrite {
+			metadata["namespace"] = namespace
+		}
+	}
+
+	if opts.CreateNamespace && !hasNamespace {
+		manifests = append(manifests, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name": namespace,
+			},
+		})
+	}
+
+	return manifests, nil
+}
@@ -0,0 +1,161 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInjectNamespace(t *testing.T) {
+	type args struct {
+		manifests []map[string]interface{}
+		namespace string
+		opts      InjectNamespaceOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			args: args{namespace: "foo"},
+			want: nil,
+		},
+		{
+			name: "empty-map",
+			args: args{
+				manifests: []map[string]interface{}{{}},
+				namespace: "foo",
+			},
+			want: []map[string]interface{}{
+				{"metadata": map[string]interface{}{"namespace": "foo"}},
+			},
+		},
+		{
+			name: "namespaced-and-cluster-scoped",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "nginx"}},
+					{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": map[string]interface{}{"name": "nginx"}},
+					{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": map[string]interface{}{"name": "foos.example.com"}},
+				},
+				namespace: "foo",
+			},
+			want: []map[string]interface{}{
+				{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "nginx", "namespace": "foo"}},
+				{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": map[string]interface{}{"name": "nginx"}},
+				{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": map[string]interface{}{"name": "foos.example.com"}},
+			},
+		},
+		{
+			name: "custom-resources-of-rendered-crds",
+			args: args{
+				manifests: []map[string]interface{}{
+					{
+						"apiVersion": "apiextensions.k8s.io/v1",
+						"kind":       "CustomResourceDefinition",
+						"metadata":   map[string]interface{}{"name": "clusterfoos.example.com"},
+						"spec": map[string]interface{}{
+							"group": "example.com",
+							"scope": "Cluster",
+							"names": map[string]interface{}{"kind": "ClusterFoo"},
+						},
+					},
+					{"apiVersion": "example.com/v1", "kind": "ClusterFoo", "metadata": map[string]interface{}{"name": "foo"}},
+					{"apiVersion": "example.com/v1", "kind": "Foo", "metadata": map[string]interface{}{"name": "foo"}},
+				},
+				namespace: "foo",
+			},
+			want: []map[string]interface{}{
+				{
+					"apiVersion": "apiextensions.k8s.io/v1",
+					"kind":       "CustomResourceDefinition",
+					"metadata":   map[string]interface{}{"name": "clusterfoos.example.com"},
+					"spec": map[string]interface{}{
+						"group": "example.com",
+						"scope": "Cluster",
+						"names": map[string]interface{}{"kind": "ClusterFoo"},
+					},
+				},
+				{"apiVersion": "example.com/v1", "kind": "ClusterFoo", "metadata": map[string]interface{}{"name": "foo"}},
+				{"apiVersion": "example.com/v1", "kind": "Foo", "metadata": map[string]interface{}{"name": "foo", "namespace": "foo"}},
+			},
+		},
+		{
+			name: "existing-namespace",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx", "namespace": "bar"}},
+				},
+				namespace: "foo",
+			},
+			want: []map[string]interface{}{
+				{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx", "namespace": "bar"}},
+			},
+		},
+		{
+			name: "overwrite-existing-namespace",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx", "namespace": "bar"}},
+				},
+				namespace: "foo",
+				opts:      InjectNamespaceOptions{Overwrite: true},
+			},
+			want: []map[string]interface{}{
+				{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx", "namespace": "foo"}},
+			},
+		},
+		{
+			name: "create-namespace",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx"}},
+				},
+				namespace: "foo",
+				opts:      InjectNamespaceOptions{CreateNamespace: true},
+			},
+			want: []map[string]interface{}{
+				{"kind": "Service", "metadata": map[string]interface{}{"name": "nginx", "namespace": "foo"}},
+				{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "foo"}},
+			},
+		},
+		{
+			name: "create-namespace-already-rendered",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "foo"}},
+				},
+				namespace: "foo",
+				opts:      InjectNamespaceOptions{CreateNamespace: true},
+			},
+			want: []map[string]interface{}{
+				{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "foo"}},
+			},
+		},
+		{
+			name: "with-invalid-metadata-type",
+			args: args{
+				manifests: []map[string]interface{}{
+					{"metadata": []map[string]interface{}{{"name": "nginx-deployment"}}},
+				},
+				namespace: "foo",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InjectNamespace(tt.args.manifests, tt.args.namespace, tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("InjectNamespace() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InjectNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// packagedRgx captures the archive path from the output of `helm package`.
+var packagedRgx = regexp.MustCompile(`(?m)Successfully packaged chart and saved it to: (.+)$`)
+
+// Package runs `helm package` on the chart at chartPath, writing the chart
+// archive to destination (the current working directory if empty) and returns
+// the path of the archive.
+// The version and appVersion of the Chart.yaml are overridden if provided.
+func Package(chartPath string, destination string, version string, appVersion string) (string, error) {
+	packageArgs := []string{"package", chartPath}
+	if destination != "" {
+		packageArgs = append(packageArgs, "--destination", destination)
+	}
+	if version != "" {
+		packageArgs = append(packageArgs, "--version", version)
+	}
+	if appVersion != "" {
+		packageArgs = append(packageArgs, "--app-version", appVersion)
+	}
+
+	packageCmd := helmCommand(packageArgs...)
+	var stdout, stderr bytes.Buffer
+	packageCmd.Stdout = &stdout
+	packageCmd.Stderr = &stderr
+	if err := packageCmd.Run(); err != nil {
+		return "", newCommandError(packageCmd, stderr.String(), err)
+	}
+
+	match := packagedRgx.FindStringSubmatch(stdout.String())
+	if match == nil {
+		return "", fmt.Errorf(`parsing archive path from output of "%s": %s`, packageCmd, stdout.String())
+	}
+	return strings.TrimSpace(match[1]), nil
+}
+
+// Push runs `helm push` to upload the chart archive at archivePath to remote.
+// remote is an OCI registry reference (e.g. oci://ghcr.io/my-org/charts) which
+// must be logged in to via RegistryLogin if it requires authentication.
+func Push(archivePath string, remote string) error {
+	if !IsOCI(remote) {
+		return fmt.Errorf(`pushing chart archive %s: remote %s is not an %s registry reference`, archivePath, remote, ociScheme)
+	}
+	if err := requireFeatures([]Feature{FeatureOCI}); err != nil {
+		return fmt.Errorf(`pushing chart archive %s to %s: %w`, archivePath, remote, err)
+	}
+
+	pushCmd := helmCommand("push", archivePath, remote)
+	var stdout, stderr bytes.Buffer
+	pushCmd.Stdout = &stdout
+	pushCmd.Stderr = &stderr
+	if err := pushCmd.Run(); err != nil {
+		return newCommandError(pushCmd, stderr.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackage(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		destination string
+		version     string
+		preamble    string // printed by `helm package` before the archive path
+		eol         string // ends the archive path line, which is not printed if empty
+		stderr      string // fails the package if set
+		want        string
+		wantErr     string
+	}{
+		{"working directory", "", "", "", "\n", "", filepath.Join(cwd, "nginx-0.1.0.tgz"), ""},
+		{"destination and version", dir, "1.2.3", "", "\n", "", filepath.Join(dir, "nginx-1.2.3.tgz"), ""},
+		{"destination with spaces", filepath.Join(dir, "my charts"), "", "", "\n", "", filepath.Join(dir, "my charts", "nginx-0.1.0.tgz"), ""},
+		{"dependency output", dir, "", "Saving 1 charts\nDownloading common from repo https://charts.bitnami.com/bitnami\nDeleting outdated charts\n", "\n", "", filepath.Join(dir, "nginx-0.1.0.tgz"), ""},
+		{"carriage return", dir, "", "", "\r\n", "", filepath.Join(dir, "nginx-0.1.0.tgz"), ""},
+		{"no archive path", dir, "", "Walking chart directory\n", "", "", "", "parsing archive path"},
+		{"helm error", dir, "", "", "", "Error: validation: chart.metadata.version is required", "", "chart.metadata.version is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the fake helm reports the archive path helm would write to
+			useFakeHelm(t, `chart=$2 destination=$(pwd) version=0.1.0
+shift 2
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--destination) destination=$2; shift ;;
+	--version) version=$2; shift ;;
+	esac
+	shift
+done
+printf '%s' '`+tt.preamble+`'
+[ -n '`+tt.eol+`' ] && printf 'Successfully packaged chart and saved it to: %s/%s-%s.tgz%s' "$destination" "$(basename "$chart")" "$version" '`+tt.eol+`'
+[ -z '`+tt.stderr+`' ] || { echo '`+tt.stderr+`' >&2; exit 1; }`)
+			got, err := Package(filepath.Join("testdata", "nginx"), tt.destination, tt.version, "")
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Package() error = %v, want %q", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Package() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,252 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// licenseAnnotations are the Chart.yaml annotations declaring the license of
+// a chart, in order of precedence. artifacthub.io/license is the SPDX
+// identifier displayed by Artifact Hub.
+var licenseAnnotations = []string{"artifacthub.io/license", "licenses"}
+
+// ChartProvenance records a third-party chart which was pulled during a
+// render.
+type ChartProvenance struct {
+	Source       string            `json:"source"` // repository URL, OCI registry or git repository the chart was pulled from
+	Chart        string            `json:"chart"`
+	Version      string            `json:"version"` // exact version of the chart, as declared by its Chart.yaml
+	Digest       string            `json:"digest"`  // "sha256:<hex>" of the chart archive or "sha1:<commit>" of charts checked out from git. empty for charts cached before digests were recorded
+	License      string            `json:"license,omitempty"`
+	Dependencies []ChartDependency `json:"dependencies,omitempty"` // dependencies of the Chart.yaml packaged with the chart
+	Annotations  map[string]string `json:"annotations,omitempty"`  // artifacthub.io annotations of the Chart.yaml. e.g: {"artifacthub.io/signKey": "..."}
+	Maintainers  []ChartMaintainer `json:"maintainers,omitempty"`
+}
+
+// Provenance records the charts pulled by the renders of TemplateOptions
+// sharing it, so compliance teams can audit exactly which third-party content
+// landed in an output. Each chart is recorded once. It is safe for concurrent
+// use.
+type Provenance struct {
+	// BuilderID identifies the system producing the output in the written
+	// statement. e.g: "https://github.com/my-org/my-stack/.github/workflows/render.yaml"
+	BuilderID string
+
+	mu     sync.Mutex
+	charts []ChartProvenance
+}
+
+// record adds chart unless an equal chart is already recorded.
+func (p *Provenance) record(chart ChartProvenance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, recorded := range p.charts {
+		if recorded.Source == chart.Source && recorded.Chart == chart.Chart && recorded.Version == chart.Version && recorded.Digest == chart.Digest {
+			return
+		}
+	}
+	p.charts = append(p.charts, chart)
+}
+
+// Charts returns the recorded charts, sorted by source, chart and version.
+func (p *Provenance) Charts() []ChartProvenance {
+	p.mu.Lock()
+	charts := append([]ChartProvenance(nil), p.charts...)
+	p.mu.Unlock()
+	sort.Slice(charts, func(i, j int) bool {
+		if charts[i].Source != charts[j].Source {
+			return charts[i].Source < charts[j].Source
+		}
+		if charts[i].Chart != charts[j].Chart {
+			return charts[i].Chart < charts[j].Chart
+		}
+		return charts[i].Version < charts[j].Version
+	})
+	return charts
+}
+
+// Statement returns the recorded charts as an in-toto statement with a SLSA
+// provenance (v0.2) predicate: each chart is a material identified by its
+// source and digest, and the output is the subject identified by its sha256.
+// The full ChartProvenance records are included as the build config. e.g:
+//   {
+//     "_type": "https://in-toto.io/Statement/v0.1",
+//     "predicateType": "https://slsa.dev/provenance/v0.2",
+//     "subject": [{"name": "manifests.yaml", "digest": {"sha256": "..."}}],
+//     "predicate": {
+//       "builder": {"id": "..."},
+//       "buildType": "https://github.com/evanlouie/go/pkg/helm/template@v1",
+//       "buildConfig": {"charts": [{"source": "https://kubernetes.github.io/ingress-nginx", "chart": "ingress-nginx", ...}]},
+//       "materials": [{"uri": "https://kubernetes.github.io/ingress-nginx/ingress-nginx@4.0.6", "digest": {"sha256": "..."}}]
+//     }
+//   }
+func (p *Provenance) Statement(name string, output []byte) ([]byte, error) {
+	type digestSet map[string]string
+	type material struct {
+		URI    string    `json:"uri"`
+		Digest digestSet `json:"digest,omitempty"`
+	}
+	charts := p.Charts()
+	materials := []material{}
+	for _, chart := range charts {
+		m := material{URI: strings.TrimSuffix(chart.Source, "/") + "/" + chart.Chart + "@" + chart.Version}
+		if chart.Digest != "" {
+			algorithm, digest := splitDigest(chart.Digest)
+			m.Digest = digestSet{algorithm: digest}
+		}
+		materials = append(materials, m)
+	}
+	sum := sha256.Sum256(output)
+	statement := map[string]interface{}{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"subject": []map[string]interface{}{{
+			"name":   name,
+			"digest": digestSet{"sha256": hex.EncodeToString(sum[:])},
+		}},
+		"predicate": map[string]interface{}{
+			"builder":     map[string]string{"id": p.BuilderID},
+			"buildType":   "https://github.com/evanlouie/go/pkg/helm/template@v1",
+			"buildConfig": map[string]interface{}{"charts": charts},
+			"metadata": map[string]interface{}{
+				"buildFinishedOn": time.Now().UTC().Format(time.RFC3339),
+				"completeness":    map[string]bool{"materials": true},
+			},
+			"materials": materials,
+		},
+	}
+	content, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf(`encoding provenance of %s: %w`, name, err)
+	}
+	return content, nil
+}
+
+// WriteFile writes the Statement of output, named after the base of
+// outputPath, to path.
+func (p *Provenance) WriteFile(path string, outputPath string, output []byte) error {
+	content, err := p.Statement(filepath.Base(outputPath), output)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf(`writing provenance to %s: %w`, path, err)
+	}
+	return nil
+}
+
+// splitDigest splits a "<algorithm>:<hex>" digest, defaulting to sha256.
+func splitDigest(digest string) (string, string) {
+	if idx := strings.Index(digest, ":"); idx >= 0 {
+		return digest[:idx], digest[idx+1:]
+	}
+	return "sha256", digest
+}
+
+// chartProvenance returns the provenance of the chart in chartPath, pulled
+// from source with digest, from the metadata of its Chart.yaml.
+func chartProvenance(chartPath string, source string, digest string) (ChartProvenance, error) {
+	content, err := os.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return ChartProvenance{}, fmt.Errorf(`reading metadata of helm chart %s: %w`, chartPath, err)
+	}
+	var metadata ChartMetadata
+	if err := yaml.Unmarshal(content, &metadata); err != nil {
+		return ChartProvenance{}, fmt.Errorf(`parsing metadata of helm chart %s: %w`, chartPath, err)
+	}
+	provenance := ChartProvenance{
+		Source:       source,
+		Chart:        metadata.Name,
+		Version:      metadata.Version,
+		Digest:       digest,
+		Dependencies: metadata.Dependencies,
+		Maintainers:  metadata.Maintainers,
+	}
+	for _, annotation := range licenseAnnotations {
+		if license := metadata.Annotations[annotation]; license != "" {
+			provenance.License = license
+			break
+		}
+	}
+	for key, value := range metadata.Annotations {
+		if strings.HasPrefix(key, "artifacthub.io/") {
+			if provenance.Annotations == nil {
+				provenance.Annotations = map[string]string{}
+			}
+			provenance.Annotations[key] = value
+		}
+	}
+	return provenance, nil
+}
+
+// withProvenance records the remote chart of opts in opts.Provenance,
+// returning opts templating the recorded chart. Charts which are not already
+// pulled (e.g. into a ChartCache) are pulled into a temporary directory first
+// so the digest recorded is that of the chart templated, along with a
+// function removing it.
+func (opts TemplateOptions) withProvenance() (TemplateOptions, func(), error) {
+	if opts.Provenance == nil || (opts.Repo == "" && !IsOCI(opts.Chart)) {
+		return opts, func() {}, nil
+	}
+	pullOpts := opts.pullOptions()
+	archive, err := pullArchive(pullOpts)
+	if err != nil {
+		return opts, nil, err
+	}
+	digest, err := pullOpts.verifyDigest(archive)
+	if err != nil {
+		return opts, nil, err
+	}
+	chartPath, cleanup, err := extractChart(archive, opts.Chart)
+	if err != nil {
+		return opts, nil, err
+	}
+	chart, err := chartProvenance(chartPath, opts.provenanceSource(), "sha256:"+digest)
+	if err != nil {
+		cleanup()
+		return opts, nil, err
+	}
+	opts.Provenance.record(chart)
+	opts.Repo, opts.Chart, opts.Version = "", chartPath, ""
+	return opts, cleanup, nil
+}
+
+// recordCachedChart records the remote chart of opts, pulled into chartPath
+// of opts.ChartCache, in opts.Provenance.
+func (opts TemplateOptions) recordCachedChart(chartPath string) error {
+	if opts.Provenance == nil {
+		return nil
+	}
+	digest, err := opts.ChartCache.Digest(opts.Repo, opts.Chart, opts.Version)
+	if err != nil {
+		return err
+	}
+	if digest != "" {
+		digest = "sha256:" + digest
+	}
+	chart, err := chartProvenance(chartPath, opts.provenanceSource(), digest)
+	if err != nil {
+		return err
+	}
+	opts.Provenance.record(chart)
+	return nil
+}
+
+// provenanceSource returns the repository URL or OCI registry of the remote
+// chart of opts.
+func (opts TemplateOptions) provenanceSource() string {
+	if IsOCI(opts.Chart) {
+		return strings.TrimSuffix(opts.Chart, "/"+ociChartName(opts.Chart))
+	}
+	return opts.Repo
+}
@@ -0,0 +1,125 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestTemplate_provenance(t *testing.T) {
+	archivePath := helmtest.NewChartArchive(t, helmtest.Chart{
+		Name:        "nginx",
+		Version:     "1.2.3",
+		Annotations: map[string]string{"artifacthub.io/license": "Apache-2.0", "example.com/team": "web"},
+	})
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(archive)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	// the fake helm pulls the archive into --destination and templates the
+	// name of the local chart it is passed as last argument
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="/nonexistent/repositories.yaml"' ;;
+pull) while [ $# -gt 0 ]; do [ "$1" = "--destination" ] && dest="$2"; shift; done; cp '`+archivePath+`' "$dest/" ;;
+template) for arg; do chart="$arg"; done; grep '^name:' "$chart/Chart.yaml" ;;
+esac`)
+	want := []ChartProvenance{{
+		Source:      "https://charts.example.com",
+		Chart:       "nginx",
+		Version:     "1.2.3",
+		Digest:      digest,
+		License:     "Apache-2.0",
+		Annotations: map[string]string{"artifacthub.io/license": "Apache-2.0"},
+	}}
+
+	tests := []struct {
+		name string
+		opts TemplateOptions
+	}{
+		{"pulled", TemplateOptions{Repo: "https://charts.example.com", Chart: "nginx", Version: "1.2.3"}},
+		{"chart-cache", TemplateOptions{Repo: "https://charts.example.com", Chart: "nginx", Version: "1.2.3", ChartCache: &ChartCache{Dir: t.TempDir()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provenance := &Provenance{BuilderID: "https://ci.example.com"}
+			tt.opts.Provenance = provenance
+			// the chart is recorded once across renders
+			for i := 0; i < 2; i++ {
+				output, err := Template(tt.opts)
+				if err != nil {
+					t.Fatalf("Template() error = %v", err)
+				}
+				if strings.TrimSpace(output) != "name: nginx" {
+					t.Errorf("Template() = %q, want the recorded chart to be templated", output)
+				}
+			}
+			if got := provenance.Charts(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Charts() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestProvenance_Statement(t *testing.T) {
+	provenance := &Provenance{BuilderID: "https://ci.example.com"}
+	provenance.record(ChartProvenance{Source: "https://charts.example.com/", Chart: "nginx", Version: "1.2.3", Digest: "sha256:abc"})
+	provenance.record(ChartProvenance{Source: "https://github.com/my-org/charts.git", Chart: "web", Version: "0.1.0", Digest: "sha1:def"})
+	provenance.record(ChartProvenance{Source: "oci://registry.example.com/charts", Chart: "redis", Version: "2.0.0"})
+
+	content, err := provenance.Statement("manifests.yaml", []byte("output"))
+	if err != nil {
+		t.Fatalf("Statement() error = %v", err)
+	}
+	var statement struct {
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+		Predicate struct {
+			Builder   struct{ ID string } `json:"builder"`
+			Materials []struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+			} `json:"materials"`
+		} `json:"predicate"`
+	}
+	if err := json.Unmarshal(content, &statement); err != nil {
+		t.Fatalf("Statement() is not json: %v", err)
+	}
+	sum := sha256.Sum256([]byte("output"))
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != "manifests.yaml" || statement.Subject[0].Digest["sha256"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Statement() subject = %+v, want the digest of the output", statement.Subject)
+	}
+	if statement.Predicate.Builder.ID != "https://ci.example.com" {
+		t.Errorf("Statement() builder = %s, want https://ci.example.com", statement.Predicate.Builder.ID)
+	}
+	var materials []string
+	for _, material := range statement.Predicate.Materials {
+		materials = append(materials, material.URI+" "+strings.Join(keysAndValues(material.Digest), "="))
+	}
+	wantMaterials := []string{
+		"https://charts.example.com/nginx@1.2.3 sha256=abc",
+		"https://github.com/my-org/charts.git/web@0.1.0 sha1=def",
+		"oci://registry.example.com/charts/redis@2.0.0 ",
+	}
+	if !reflect.DeepEqual(materials, wantMaterials) {
+		t.Errorf("Statement() materials = %q, want %q", materials, wantMaterials)
+	}
+}
+
+func keysAndValues(m map[string]string) []string {
+	var kv []string
+	for key, value := range m {
+		kv = append(kv, key, value)
+	}
+	return kv
+}
@@ -1,53 +1,380 @@
 package helm
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/errcode"
 )
 
+// PullOptions encapsulate the options for `helm pull`.
+// helm pull \
+//   --untar --untardir <Into> \
+//   --repo <RepoURL> \
+//   --version <Version> \
+//   --verify --keyring <Keyring> \
+//   --username <Username> --password <Password> --pass-credentials \
+//   --ca-file <CAFile> --cert-file <CertFile> --key-file <KeyFile> \
+//   --insecure-skip-tls-verify \
+//   <Chart>
+type PullOptions struct {
+	RepoURL         string // --repo. may be an oci:// registry URL
+	Chart           string // [CHART]
+	Version         string // --version
+	Devel           bool   // --devel. use pre-release versions: the newest version when Version is empty and pre-releases matching a Version constraint
+	Into            string // --untardir. the chart is extracted to <Into>/<Chart>
+	Digest          string // expected sha256 digest of the chart archive (optionally prefixed with "sha256:"). the pull fails if the downloaded archive does not match
+	Verify          bool   // --verify. verify the signature of the chart against its provenance file (.prov) before using it
+	Keyring         string // --keyring. keyring of the public keys Verify checks signatures with. defaults to ~/.gnupg/pubring.gpg
+	Username        string // --username. chart repository username
+	Password        string // --password. chart repository password
+	PassCredentials bool   // --pass-credentials. pass credentials to all domains (e.g. when chart archives are hosted on a different domain than the index)
+
+	Credentials CredentialProvider // resolved when the command runs to set Username and Password if neither is set
+
+	ResolveVersion bool // resolve a Version constraint (e.g. "~1.2.x") to the newest matching version in the repository index before pulling, so the chart can be cached (see ChartCache). helm resolves constraints itself otherwise
+
+	IsolatedConfig bool // run helm with a temporary config home, repository config and repository cache so the host helm client is neither used nor modified
+
+	CAFile                string // --ca-file. verify certificates of HTTPS-enabled servers using this CA bundle
+	CertFile              string // --cert-file. identify HTTPS client using this SSL certificate file
+	KeyFile               string // --key-file. identify HTTPS client using this SSL key file
+	InsecureSkipTLSVerify bool   // --insecure-skip-tls-verify. skip tls certificate checks for the chart download
+}
+
+func (opts PullOptions) repoAuth() repoAuth {
+	return repoAuth{
+		username:              opts.Username,
+		password:              opts.Password,
+		passCredentials:       opts.PassCredentials,
+		caFile:                opts.CAFile,
+		certFile:              opts.CertFile,
+		keyFile:               opts.KeyFile,
+		insecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
+	}
+}
+
+// withResolvedVersion returns opts with a Version constraint resolved to an
+// exact version when ResolveVersion is set.
+func (opts PullOptions) withResolvedVersion() (PullOptions, error) {
+	if !opts.ResolveVersion {
+		return opts, nil
+	}
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.RepoURL, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return opts, err
+	}
+	version, err := resolveVersionConstraint(opts.RepoURL, opts.Chart, opts.Version, opts.repoAuth(), !opts.IsolatedConfig, opts.Devel)
+	if err != nil {
+		return opts, err
+	}
+	opts.Version, opts.ResolveVersion = version, false
+	return opts, nil
+}
+
 // Pull will do a `helm pull` for the target chart and extract the chart to
 // `into`.
 // If an existing repository is found in in the host helm client with same
 // repository URL, the chart will be pulled from that repository instead of
 // using the "--repo" option.
+// Charts hosted in OCI registries can be pulled by providing an oci:// repoURL
+// or an oci:// chart reference (e.g. oci://ghcr.io/my-org/charts/my-chart).
 // Note that the directory structure will look like: <into>/<chart>/Chart.yaml
+//
+// Deprecated: use PullWithOptions, which supports credentials, TLS options,
+// digest and signature verification and pre-release versions, or
+// PullArchiveWithOptions to pull the chart archive without extracting it.
 func Pull(repoURL string, chart string, version string, into string) error {
-	// check if existing repo with same URL in host client
-	existingRepo, err := FindRepoNameByURL(repoURL)
+	return PullWithOptions(PullOptions{
+		RepoURL: repoURL,
+		Chart:   chart,
+		Version: version,
+		Into:    into,
+	})
+}
+
+// PullWithOptions will do a `helm pull` for the chart specified by opts and
+// extract it to opts.Into.
+// When credentials or TLS options are provided, the chart is always pulled via
+// the "--repo" option so private repositories do not need to be added to the
+// host helm client beforehand.
+// If opts.Digest is set, the chart archive is verified before extraction; see
+// PullWithDigest.
+func PullWithOptions(opts PullOptions) error {
+	if opts.Digest != "" {
+		_, err := PullWithDigest(opts)
+		return err
+	}
+
+	return pull(opts,
+		"--untar",                         // untar
+		"--untardir", longPath(opts.Into), // untar into the target directory instead of cwd. extended-length on windows for deeply nested charts
+	)
+}
+
+// PullWithDigest is the same as PullWithOptions but computes the sha256 digest
+// of the downloaded chart archive before extracting it, failing if it does not
+// match opts.Digest (when set). The computed digest is returned (hex encoded,
+// as found in the "digest" of repository indexes) so callers can record it in
+// a lockfile and pin future pulls.
+func PullWithDigest(opts PullOptions) (string, error) {
+	archive, err := pullArchive(opts)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := opts.verifyDigest(archive)
+	if err != nil {
+		return digest, err
+	}
+
+	if err := extractArchive(archive, longPath(opts.Into)); err != nil {
+		return digest, fmt.Errorf(`extracting chart archive of %s: %w`, opts.Chart, err)
+	}
+	return digest, nil
+}
+
+// ChartArchive is a chart archive (.tgz) pulled by PullArchive.
+type ChartArchive struct {
+	Path   string // e.g: "vendor/nginx-1.2.3.tgz"
+	Size   int64  // in bytes
+	Digest string // sha256 of the archive, hex encoded as found in the "digest" of repository indexes
+}
+
+// PullArchive will do a `helm pull` for the target chart and write the chart
+// archive (.tgz) to dest without extracting it, e.g. to vendor charts or
+// mirror them into another repository.
+func PullArchive(repoURL string, chart string, version string, dest string) (ChartArchive, error) {
+	return PullArchiveWithOptions(PullOptions{
+		RepoURL: repoURL,
+		Chart:   chart,
+		Version: version,
+		Into:    dest,
+	})
+}
+
+// PullArchiveWithOptions is the same as PullArchive for the chart specified by
+// opts, writing the chart archive to the directory opts.Into. If opts.Digest
+// is set, the archive is verified before it is moved into opts.Into so a
+// mismatching archive is never written.
+func PullArchiveWithOptions(opts PullOptions) (ChartArchive, error) {
+	if err := os.MkdirAll(longPath(opts.Into), 0755); err != nil {
+		return ChartArchive{}, fmt.Errorf(`creating directory %s: %w`, opts.Into, err)
+	}
+	// pull into a temporary directory next to the destination so the archive
+	// can be verified and renamed into place
+	tmpDir, err := os.MkdirTemp(opts.Into, ".pull-")
+	if err != nil {
+		return ChartArchive{}, fmt.Errorf(`creating temporary directory to pull helm chart %s: %w`, opts.Chart, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	archivePath, err := pullArchiveFile(opts, tmpDir)
+	if err != nil {
+		return ChartArchive{}, err
+	}
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		return ChartArchive{}, fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+	}
+	digest, err := opts.verifyDigest(archive)
+	if err != nil {
+		return ChartArchive{}, err
+	}
+	dest := filepath.Join(opts.Into, filepath.Base(archivePath))
+	if err := os.Rename(archivePath, dest); err != nil {
+		return ChartArchive{}, fmt.Errorf(`moving chart archive of %s to %s: %w`, opts.Chart, dest, err)
+	}
+	return ChartArchive{Path: dest, Size: int64(len(archive)), Digest: digest}, nil
+}
+
+// verifyDigest returns the sha256 digest of archive (hex encoded), failing if
+// it does not match opts.Digest (when set).
+func (opts PullOptions) verifyDigest(archive []byte) (string, error) {
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	if expected := strings.TrimPrefix(opts.Digest, "sha256:"); expected != "" && !strings.EqualFold(expected, digest) {
+		return digest, errcode.Wrap(errcode.HelmDigestMismatch, fmt.Errorf(`digest of helm chart %s@%s does not match: expected sha256:%s, got sha256:%s`, opts.Chart, opts.Version, expected, digest))
+	}
+	return digest, nil
+}
+
+// pullArchive pulls the chart archive (.tgz) specified by opts without
+// extracting it, returning its contents.
+func pullArchive(opts PullOptions) ([]byte, error) {
+	tmpDir, err := mkdirTemp("fabrikate")
+	if err != nil {
+		return nil, fmt.Errorf(`creating temporary directory to pull helm chart %s: %w`, opts.Chart, err)
+	}
+	defer removeTemp(tmpDir)
+	archivePath, err := pullArchiveFile(opts, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+	}
+	return archive, nil
+}
+
+// pullArchiveFile pulls the chart archive (.tgz) specified by opts into the
+// empty directory dir, returning its path.
+func pullArchiveFile(opts PullOptions, dir string) (string, error) {
+	if err := pull(opts, "--destination", dir); err != nil {
+		return "", err
+	}
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return "", fmt.Errorf(`searching for chart archive of %s in %s: %w`, opts.Chart, dir, err)
+	}
+	if len(archives) != 1 {
+		return "", fmt.Errorf(`expected 1 chart archive of %s to be pulled, found %d`, opts.Chart, len(archives))
+	}
+	return archives[0], nil
+}
+
+// pull runs `helm pull` for the chart specified by opts, passing
+// destinationArgs to control where and how the chart is written.
+func pull(opts PullOptions, destinationArgs ...string) error {
+	// every pull (PullWithOptions, PullWithDigest, PullArchiveWithOptions and
+	// the chart cache) is tracked by Shutdown here
+	end, err := beginOperation()
+	if err != nil {
+		return fmt.Errorf(`pulling helm chart %s: %w`, opts.Chart, err)
+	}
+	defer end()
+	opts, err = opts.withResolvedVersion()
 	if err != nil {
 		return err
 	}
-	if existingRepo != "" {
-		chart = path.Join(existingRepo, chart) // set chart to the form of <repo_name>/<path_to_chart>
-		repoURL = ""                           // zero out so --repo is not used
+	if err := requireFeatures(opts.requiredFeatures()); err != nil {
+		return fmt.Errorf(`pulling helm chart %s: %w`, opts.Chart, err)
 	}
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.RepoURL, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return err
+	}
+	isolated, err := openIsolatedConfig(opts.IsolatedConfig)
+	if err != nil {
+		return err
+	}
+	defer isolated.Close()
 
-	// arguments don't include --repo by default
-	pullArgs := []string{
-		"pull", chart,
-		"--untar",          // untar
-		"--untardir", into, // untar into the target directory instead of cwd
+	chart, version, repoURL := opts.Chart, opts.Version, opts.RepoURL
+	if IsOCI(repoURL) || IsOCI(chart) {
+		// OCI registries cannot be added as helm repos; reference the chart directly
+		ref, ociVersion, err := ociChartRef(repoURL, chart, version)
+		if err != nil {
+			return err
+		}
+		chart, version, repoURL = ref, ociVersion, ""
+	} else if !opts.repoAuth().isSet() && isolated.searchHost() {
+		// check if existing repo with same URL in host client
+		existingRepo, err := FindRepoNameByURL(repoURL)
+		if err != nil {
+			return err
+		}
+		if existingRepo != "" {
+			chart = path.Join(existingRepo, chart) // set chart to the form of <repo_name>/<path_to_chart>
+			repoURL = ""                           // zero out so --repo is not used
+		}
 	}
 
+	// arguments don't include --repo by default
+	pullArgs := append([]string{"pull", chart}, destinationArgs...)
+
 	// provide a --version if specified
 	if version != "" {
 		pullArgs = append(pullArgs, "--version", version)
 	}
+	if opts.Devel {
+		pullArgs = append(pullArgs, "--devel")
+	}
+	if opts.Verify {
+		pullArgs = append(pullArgs, "--verify")
+	}
+	if opts.Keyring != "" {
+		pullArgs = append(pullArgs, "--keyring", opts.Keyring)
+	}
 
 	// use the --repo option to pull directly from URL if repo not on host Helm
 	if repoURL != "" {
 		pullArgs = append(pullArgs, "--repo", repoURL)
 	}
 
-	cmd := exec.Command("helm", pullArgs...)
+	// credentials and TLS options for private repositories
+	pullArgs = append(pullArgs, opts.repoAuth().args()...)
+
+	cmd := isolated.command(pullArgs...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%w: %v", err, stderr.String())
+		return newCommandError(cmd, stderr.String(), err)
 	}
 
 	return nil
 }
+
+// extractArchive extracts the regular files and directories of the gzipped
+// tarball archive into dest. Entries resolving outside of dest (e.g. via ".."
+// or absolute paths) are rejected and other entry types (e.g. symlinks) are
+// skipped.
+func extractArchive(archive []byte, dest string) error {
+	return extractArchiveFrom(bytes.NewReader(archive), dest)
+}
+
+// extractArchiveFrom is extractArchive for an archive streamed from r, e.g.
+// archives too large to be read into memory.
+func extractArchiveFrom(r io.Reader, dest string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf(`creating gzip reader: %w`, err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf(`parsing file in archive: %w`, err)
+		}
+
+		name := path.Clean(strings.ReplaceAll(header.Name, "\\", "/"))
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || filepath.VolumeName(name) != "" {
+			return fmt.Errorf(`archive entry %s resolves outside of %s`, header.Name, dest)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf(`creating directory %s: %w`, target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf(`creating directory for %s: %w`, target, err)
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return fmt.Errorf(`writing %s: %w`, target, err)
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf(`writing archive entry %s to %s: %w`, header.Name, target, err)
+			}
+		}
+	}
+}
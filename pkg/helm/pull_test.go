@@ -0,0 +1,133 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func Test_extractArchive(t *testing.T) {
+	archivePath := helmtest.NewChartArchive(t, helmtest.Chart{Name: "nginx"})
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := extractArchive(archive, dest); err != nil {
+		t.Fatalf("extractArchive() error = %v", err)
+	}
+	for _, file := range []string{"Chart.yaml", "values.yaml", "templates/configmap.yaml"} {
+		if _, err := os.Stat(filepath.Join(dest, "nginx", filepath.FromSlash(file))); err != nil {
+			t.Errorf("extractArchive() did not extract %s: %v", file, err)
+		}
+	}
+
+	for _, name := range []string{"../escape.yaml", "nginx/../../escape.yaml", "/etc/escape.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			gzw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gzw)
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write([]byte("x"))
+			tw.Close()
+			gzw.Close()
+			if err := extractArchive(buf.Bytes(), t.TempDir()); err == nil {
+				t.Errorf("extractArchive() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPullArchiveWithOptions(t *testing.T) {
+	archivePath := helmtest.NewChartArchive(t, helmtest.Chart{Name: "nginx", Version: "1.2.3"})
+	archive, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	// the fake helm pulls the archive into --destination
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="/nonexistent/repositories.yaml"' ;;
+pull) while [ $# -gt 0 ]; do [ "$1" = "--destination" ] && dest="$2"; shift; done; cp '`+archivePath+`' "$dest/nginx-1.2.3.tgz" ;;
+esac`)
+
+	tests := []struct {
+		name    string
+		digest  string
+		wantErr bool
+	}{
+		{"unverified", "", false},
+		{"verified", "sha256:" + digest, false},
+		{"mismatch", "sha256:" + strings.Repeat("0", 64), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "vendor")
+			got, err := PullArchiveWithOptions(PullOptions{RepoURL: "https://charts.example.com", Chart: "nginx", Version: "1.2.3", Into: dest, Digest: tt.digest})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PullArchiveWithOptions() error = nil, want a digest mismatch")
+				}
+				if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+					t.Errorf("PullArchiveWithOptions() wrote %d entries for a mismatching archive", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PullArchiveWithOptions() error = %v", err)
+			}
+			want := ChartArchive{Path: filepath.Join(dest, "nginx-1.2.3.tgz"), Size: int64(len(archive)), Digest: digest}
+			if got != want {
+				t.Errorf("PullArchiveWithOptions() = %+v, want %+v", got, want)
+			}
+			if _, err := os.Stat(got.Path); err != nil {
+				t.Errorf("PullArchiveWithOptions() did not write the archive: %v", err)
+			}
+		})
+	}
+}
+
+func TestPullWithOptions_args(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="/nonexistent/repositories.yaml"' ;;
+pull) echo "$@" > '`+argsFile+`' ;;
+esac`)
+	into := t.TempDir()
+
+	tests := []struct {
+		name string
+		opts PullOptions
+		want string
+	}{
+		{"minimal", PullOptions{RepoURL: "https://charts.example.com", Chart: "nginx", Into: into}, "pull nginx --untar --untardir " + into + " --repo https://charts.example.com"},
+		{"verify", PullOptions{RepoURL: "https://charts.example.com", Chart: "nginx", Version: "1.2.3", Devel: true, Verify: true, Keyring: "/keys/pubring.gpg", Into: into}, "pull nginx --untar --untardir " + into + " --version 1.2.3 --devel --verify --keyring /keys/pubring.gpg --repo https://charts.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := PullWithOptions(tt.opts); err != nil {
+				t.Fatalf("PullWithOptions() error = %v", err)
+			}
+			got, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(got)) != tt.want {
+				t.Errorf("PullWithOptions() ran helm %s, want %s", strings.TrimSpace(string(got)), tt.want)
+			}
+		})
+	}
+}
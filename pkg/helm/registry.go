@@ -0,0 +1,110 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ociScheme is the URL scheme used by helm to reference charts stored in OCI
+// registries (e.g. oci://ghcr.io/my-org/charts/my-chart)
+const ociScheme = "oci://"
+
+// IsOCI determines if the provided repository URL or chart reference points to
+// an OCI registry.
+func IsOCI(ref string) bool {
+	return strings.HasPrefix(strings.ToLower(ref), ociScheme)
+}
+
+// ociChartRef computes the full OCI reference and version for a chart hosted in
+// an OCI registry.
+// The chart can be provided as a full reference (oci://host/path/chart) or as
+// a chart name relative to an oci:// repo. A tag in the reference
+// (oci://host/path/chart:1.2.3) is split out and returned as the version as
+// helm only accepts the version via --version.
+func ociChartRef(repo string, chart string, version string) (ref string, resolvedVersion string, err error) {
+	ref = chart
+	if !IsOCI(chart) {
+		ref = strings.TrimSuffix(repo, "/") + "/" + strings.TrimPrefix(chart, "/")
+	}
+
+	// split the tag out of the final path segment
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		tag := ref[idx+1:]
+		ref = ref[:idx]
+		if version != "" && version != tag {
+			return "", "", fmt.Errorf(`OCI chart reference %s has tag %s which conflicts with version %s`, chart, tag, version)
+		}
+		version = tag
+	}
+
+	return ref, version, nil
+}
+
+// ociChartName returns the name of the chart referenced by an OCI reference.
+// This is the name of the directory the chart is extracted to by `helm pull`.
+func ociChartName(ref string) string {
+	name := path.Base(ref)
+	if idx := strings.LastIndex(name, ":"); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// RegistryLogin logs the host Helm client into the OCI registry at `host`
+// (e.g. ghcr.io or myregistry.azurecr.io) so that charts can be pulled from
+// private registries.
+func RegistryLogin(host string, username string, password string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	loginCmd := helmCommand("registry", "login", strings.TrimPrefix(host, ociScheme), "--username", username, "--password-stdin")
+	var stdout, stderr bytes.Buffer
+	loginCmd.Stdin = strings.NewReader(password)
+	loginCmd.Stdout = &stdout
+	loginCmd.Stderr = &stderr
+	if err := loginCmd.Run(); err != nil {
+		return newCommandError(loginCmd, stderr.String(), err)
+	}
+
+	return nil
+}
+
+// RegistryLoginWithProvider is the same as RegistryLogin but resolves the
+// username and password for host from provider.
+func RegistryLoginWithProvider(host string, provider CredentialProvider) error {
+	creds, err := provider.Credentials(host)
+	if err != nil {
+		return fmt.Errorf(`resolving credentials for %s: %w`, host, err)
+	}
+	return RegistryLogin(host, creds.Username, creds.Password)
+}
+
+// RegistryLogout logs the host Helm client out of the OCI registry at `host`.
+func RegistryLogout(host string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	logoutCmd := helmCommand("registry", "logout", strings.TrimPrefix(host, ociScheme))
+	var stdout, stderr bytes.Buffer
+	logoutCmd.Stdout = &stdout
+	logoutCmd.Stderr = &stderr
+	if err := logoutCmd.Run(); err != nil {
+		return newCommandError(logoutCmd, stderr.String(), err)
+	}
+
+	return nil
+}
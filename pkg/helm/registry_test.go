@@ -0,0 +1,60 @@
+package helm
+
+import "testing"
+
+func Test_ociChartRef(t *testing.T) {
+	type args struct {
+		repo    string
+		chart   string
+		version string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantRef     string
+		wantVersion string
+		wantErr     bool
+	}{
+		{
+			name:        "repo and chart name",
+			args:        args{repo: "oci://ghcr.io/my-org/charts/", chart: "my-chart", version: "1.2.3"},
+			wantRef:     "oci://ghcr.io/my-org/charts/my-chart",
+			wantVersion: "1.2.3",
+			wantErr:     false,
+		},
+		{
+			name:        "full reference with tag",
+			args:        args{chart: "oci://localhost:5000/charts/my-chart:0.1.0"},
+			wantRef:     "oci://localhost:5000/charts/my-chart",
+			wantVersion: "0.1.0",
+			wantErr:     false,
+		},
+		{
+			name:        "full reference without tag",
+			args:        args{chart: "oci://localhost:5000/my-chart"},
+			wantRef:     "oci://localhost:5000/my-chart",
+			wantVersion: "",
+			wantErr:     false,
+		},
+		{
+			name:    "conflicting tag and version",
+			args:    args{chart: "oci://ghcr.io/my-chart:0.1.0", version: "0.2.0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRef, gotVersion, err := ociChartRef(tt.args.repo, tt.args.chart, tt.args.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ociChartRef() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotRef != tt.wantRef {
+				t.Errorf("ociChartRef() ref = %v, want %v", gotRef, tt.wantRef)
+			}
+			if gotVersion != tt.wantVersion {
+				t.Errorf("ociChartRef() version = %v, want %v", gotVersion, tt.wantVersion)
+			}
+		})
+	}
+}
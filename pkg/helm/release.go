@@ -0,0 +1,145 @@
+package helm
+
+import (
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+const (
+	ManagedByLabel             = "app.kubernetes.io/managed-by" // label set to "Helm" on resources of a release
+	InstanceLabel              = "app.kubernetes.io/instance"   // label conventionally set to the release name by charts
+	ReleaseNameAnnotation      = "meta.helm.sh/release-name"    // annotation helm uses to determine the release owning a resource
+	ReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+
+	defaultReleaseName      = "release-name" // the release name used by `helm template` when none is provided
+	defaultReleaseNamespace = "default"
+)
+
+// AddReleaseMetadata adds the labels and annotations `helm install` adds to
+// the resources of a release so manifests applied by other means (e.g.
+// kubectl or a GitOps controller) can later be adopted by `helm upgrade`:
+//   - app.kubernetes.io/managed-by: Helm
+//   - meta.helm.sh/release-name: <release>
+//   - meta.helm.sh/release-namespace: <namespace>
+// app.kubernetes.io/instance: <release> is also added if not already set by
+// the chart. Hooks are skipped as helm does not adopt them.
+// An empty release or namespace defaults to the values used by helm
+// ("release-name" and "default"). manifests are modified in place.
+func AddReleaseMetadata(manifests []map[string]interface{}, release string, namespace string) []map[string]interface{} {
+	if release == "" {
+		release = defaultReleaseName
+	}
+	if namespace == "" {
+		namespace = defaultReleaseNamespace
+	}
+	for _, manifest := range manifests {
+		if len(Hooks(manifest)) > 0 {
+			continue
+		}
+		setOwnership(manifest, release, namespace)
+		labels := metadataMap(manifest, "labels")
+		if _, ok := labels[InstanceLabel]; !ok {
+			labels[InstanceLabel] = release
+		}
+	}
+	return manifests
+}
+
+// AddMetadata sets labels and annotations on the metadata of every manifest,
+// including hooks. e.g: to record the component, pipeline run or render time
+// which produced the manifests. Values already set by the chart are replaced.
+// manifests are modified in place.
+func AddMetadata(manifests []map[string]interface{}, labels map[string]string, annotations map[string]string) []map[string]interface{} {
+	for _, manifest := range manifests {
+		if manifest == nil {
+			continue
+		}
+		if len(labels) > 0 {
+			manifestLabels := metadataMap(manifest, "labels")
+			for key, value := range labels {
+				manifestLabels[key] = value
+			}
+		}
+		if len(annotations) > 0 {
+			manifestAnnotations := metadataMap(manifest, "annotations")
+			for key, value := range annotations {
+				manifestAnnotations[key] = value
+			}
+		}
+	}
+	return manifests
+}
+
+// AdoptionPatches returns a patch for each non-hook resource of manifests
+// which, when applied to the existing resource in a cluster (e.g. via
+// `kubectl patch --type merge` or `kubectl apply --server-side`), allows it to
+// be adopted by the helm release of release and namespace. This eases
+// migrating resources previously managed via kubectl to helm.
+// An empty release or namespace defaults to the values used by helm
+// ("release-name" and "default").
+func AdoptionPatches(manifests []map[string]interface{}, release string, namespace string) []map[string]interface{} {
+	var patches []map[string]interface{}
+	for _, manifest := range manifests {
+		if len(Hooks(manifest)) > 0 {
+			continue
+		}
+		patches = append(patches, AdoptionPatch(manifest, release, namespace))
+	}
+	return patches
+}
+
+// AdoptionPatch returns a merge patch adding the label and annotations helm
+// requires to adopt the existing resource identified by manifest into the
+// release of release and namespace:
+//   - app.kubernetes.io/managed-by: Helm
+//   - meta.helm.sh/release-name: <release>
+//   - meta.helm.sh/release-namespace: <namespace>
+// The patch includes the apiVersion, kind, name and namespace of manifest to
+// identify the resource. manifest is not modified.
+func AdoptionPatch(manifest map[string]interface{}, release string, namespace string) map[string]interface{} {
+	if release == "" {
+		release = defaultReleaseName
+	}
+	if namespace == "" {
+		namespace = defaultReleaseNamespace
+	}
+	patch := map[string]interface{}{}
+	for _, field := range []string{"apiVersion", "kind"} {
+		if value, ok := manifest[field]; ok {
+			patch[field] = value
+		}
+	}
+	metadata := map[string]interface{}{}
+	for _, field := range []string{"name", "namespace"} {
+		if value, ok := yamlPlus.Get(manifest, "metadata", field); ok {
+			metadata[field] = value
+		}
+	}
+	patch["metadata"] = metadata
+	setOwnership(patch, release, namespace)
+	return patch
+}
+
+// setOwnership sets the label and annotations helm uses to determine the
+// release owning a resource.
+func setOwnership(manifest map[string]interface{}, release string, namespace string) {
+	metadataMap(manifest, "labels")[ManagedByLabel] = "Helm"
+	annotations := metadataMap(manifest, "annotations")
+	annotations[ReleaseNameAnnotation] = release
+	annotations[ReleaseNamespaceAnnotation] = namespace
+}
+
+// metadataMap returns the map at metadata.<field> of manifest, creating it
+// (and metadata) if it does not exist.
+func metadataMap(manifest map[string]interface{}, field string) map[string]interface{} {
+	metadata, ok := manifest["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		manifest["metadata"] = metadata
+	}
+	m, ok := metadata[field].(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		metadata[field] = m
+	}
+	return m
+}
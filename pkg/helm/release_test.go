@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddReleaseMetadata(t *testing.T) {
+	manifests := []map[string]interface{}{
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "no-labels"}},
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":   "chart-instance",
+			"labels": map[string]interface{}{InstanceLabel: "from-chart", "app": "nginx"},
+		}},
+		{"kind": "Pod", "metadata": map[string]interface{}{
+			"name":        "test",
+			"annotations": map[string]interface{}{HookAnnotation: "test"},
+		}},
+	}
+	want := []map[string]interface{}{
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":        "no-labels",
+			"labels":      map[string]interface{}{ManagedByLabel: "Helm", InstanceLabel: "my-release"},
+			"annotations": map[string]interface{}{ReleaseNameAnnotation: "my-release", ReleaseNamespaceAnnotation: "default"},
+		}},
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":        "chart-instance",
+			"labels":      map[string]interface{}{ManagedByLabel: "Helm", InstanceLabel: "from-chart", "app": "nginx"},
+			"annotations": map[string]interface{}{ReleaseNameAnnotation: "my-release", ReleaseNamespaceAnnotation: "default"},
+		}},
+		{"kind": "Pod", "metadata": map[string]interface{}{
+			"name":        "test",
+			"annotations": map[string]interface{}{HookAnnotation: "test"},
+		}},
+	}
+	if got := AddReleaseMetadata(manifests, "my-release", ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("AddReleaseMetadata() = %v, want %v", got, want)
+	}
+}
+
+func TestAddMetadata(t *testing.T) {
+	manifests := []map[string]interface{}{
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "no-labels"}},
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":   "chart-labels",
+			"labels": map[string]interface{}{"app": "nginx", "app.kubernetes.io/part-of": "from-chart"},
+		}},
+		{"kind": "ConfigMap"},
+	}
+	want := []map[string]interface{}{
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":        "no-labels",
+			"labels":      map[string]interface{}{"app.kubernetes.io/part-of": "platform"},
+			"annotations": map[string]interface{}{"example.com/rendered-at": "2021-06-01T00:00:00Z"},
+		}},
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"name":        "chart-labels",
+			"labels":      map[string]interface{}{"app": "nginx", "app.kubernetes.io/part-of": "platform"},
+			"annotations": map[string]interface{}{"example.com/rendered-at": "2021-06-01T00:00:00Z"},
+		}},
+		{"kind": "ConfigMap", "metadata": map[string]interface{}{
+			"labels":      map[string]interface{}{"app.kubernetes.io/part-of": "platform"},
+			"annotations": map[string]interface{}{"example.com/rendered-at": "2021-06-01T00:00:00Z"},
+		}},
+	}
+	got := AddMetadata(manifests,
+		map[string]string{"app.kubernetes.io/part-of": "platform"},
+		map[string]string{"example.com/rendered-at": "2021-06-01T00:00:00Z"},
+	)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddMetadata() = %v, want %v", got, want)
+	}
+}
+
+func TestAdoptionPatch(t *testing.T) {
+	manifest := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web", "labels": map[string]interface{}{"app": "nginx"}},
+		"spec":       map[string]interface{}{"replicas": 3},
+	}
+	want := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "nginx",
+			"namespace":   "web",
+			"labels":      map[string]interface{}{ManagedByLabel: "Helm"},
+			"annotations": map[string]interface{}{ReleaseNameAnnotation: "my-release", ReleaseNamespaceAnnotation: "web"},
+		},
+	}
+	if got := AdoptionPatch(manifest, "my-release", "web"); !reflect.DeepEqual(got, want) {
+		t.Errorf("AdoptionPatch() = %v, want %v", got, want)
+	}
+	if _, ok := manifest["metadata"].(map[string]interface{})["annotations"]; ok {
+		t.Errorf("AdoptionPatch() modified the manifest")
+	}
+}
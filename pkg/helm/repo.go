@@ -3,15 +3,20 @@ package helm
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // RepoListEntry is a single entry from the output of
 // `helm repo list --output json`
 type RepoListEntry struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
 }
 
 // RepoList lists all repositories currently in the host Helm client
@@ -19,12 +24,16 @@ func RepoList() (list []RepoListEntry, err error) {
 	lock.RLock()
 	defer lock.RUnlock()
 
-	listCmd := exec.Command("helm", "repo", "list", "--output", "json")
+	listCmd := helmCommand("repo", "list", "--output", "json")
 	var stdout, stderr bytes.Buffer
 	listCmd.Stdout = &stdout
 	listCmd.Stderr = &stderr
 	if err := listCmd.Run(); err != nil {
-		return list, fmt.Errorf(`running "%s": %w: %v`, listCmd, err, stderr.String())
+		// helm exits non-zero when there are no repositories on the host
+		if strings.Contains(stderr.String(), "no repositories to show") {
+			return list, nil
+		}
+		return list, newCommandError(listCmd, stderr.String(), err)
 	}
 
 	if err := json.Unmarshal(stdout.Bytes(), &list); err != nil {
@@ -34,18 +43,109 @@ func RepoList() (list []RepoListEntry, err error) {
 	return list, err
 }
 
+// RepoAddOptions encapsulate the options for `helm repo add`.
+// helm repo add \
+//   --username <Username> \
+//   --password-stdin <<< <Password> \
+//   --ca-file <CAFile> --cert-file <CertFile> --key-file <KeyFile> \
+//   --insecure-skip-tls-verify \
+//   --force-update \
+//   <Name> <URL>
+type RepoAddOptions struct {
+	Name        string // [NAME]
+	URL         string // [URL]
+	Username    string // --username
+	Password    string // --password-stdin. passed via stdin so it does not show up in the process list
+	CAFile      string // --ca-file
+	ForceUpdate bool   // --force-update. replace (overwrite) the repo if it already exists
+
+	CertFile              string // --cert-file. identify HTTPS client using this SSL certificate file
+	KeyFile               string // --key-file. identify HTTPS client using this SSL key file
+	InsecureSkipTLSVerify bool   // --insecure-skip-tls-verify. skip tls certificate checks for the repository
+
+	Credentials CredentialProvider // resolved when the command runs to set Username and Password if neither is set
+}
+
 // RepoAdd adds a helm repository of `name` pointing to `url` to the host Helm
 // client
 func RepoAdd(name string, url string) error {
+	return RepoAddWithOptions(RepoAddOptions{Name: name, URL: url})
+}
+
+// RepoAddWithOptions adds a helm repository to the host Helm client using the
+// provided options. Useful for adding private repositories requiring
+// credentials or a custom certificate authority.
+func RepoAddWithOptions(opts RepoAddOptions) error {
+	if err := resolveCredentials(opts.Credentials, opts.URL, &opts.Username, &opts.Password); err != nil {
+		return err
+	}
+
 	lock.Lock()
 	defer lock.Unlock()
 
-	addCmd := exec.Command("helm", "repo", "add", name, url)
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	addArgs := []string{"repo", "add"}
+	if opts.Username != "" {
+		addArgs = append(addArgs, "--username", opts.Username)
+	}
+	if opts.Password != "" {
+		addArgs = append(addArgs, "--password-stdin")
+	}
+	if opts.CAFile != "" {
+		addArgs = append(addArgs, "--ca-file", opts.CAFile)
+	}
+	if opts.CertFile != "" {
+		addArgs = append(addArgs, "--cert-file", opts.CertFile)
+	}
+	if opts.KeyFile != "" {
+		addArgs = append(addArgs, "--key-file", opts.KeyFile)
+	}
+	if opts.InsecureSkipTLSVerify {
+		addArgs = append(addArgs, "--insecure-skip-tls-verify")
+	}
+	if opts.ForceUpdate {
+		addArgs = append(addArgs, "--force-update")
+	}
+	addArgs = append(addArgs, opts.Name, opts.URL)
+
+	addCmd := helmCommand(addArgs...)
 	var stdout, stderr bytes.Buffer
+	if opts.Password != "" {
+		addCmd.Stdin = strings.NewReader(opts.Password)
+	}
 	addCmd.Stdout = &stdout
 	addCmd.Stderr = &stderr
 	if err := addCmd.Run(); err != nil {
-		return fmt.Errorf(`running "%s": %w: %v`, addCmd, err, stderr.String())
+		return newCommandError(addCmd, stderr.String(), err)
+	}
+
+	return nil
+}
+
+// RepoUpdate updates the local chart index of the helm repositories of
+// `names` on the host Helm client. All repositories are updated if no names
+// are provided.
+func RepoUpdate(names ...string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	updateCmd := helmCommand(append([]string{"repo", "update"}, names...)...)
+	var stdout, stderr bytes.Buffer
+	updateCmd.Stdout = &stdout
+	updateCmd.Stderr = &stderr
+	if err := updateCmd.Run(); err != nil {
+		return newCommandError(updateCmd, stderr.String(), err)
 	}
 
 	return nil
@@ -57,12 +157,18 @@ func RepoRemove(name string) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	removeCmd := exec.Command("helm", "repo", "remove", name)
+	configLock, err := lockHostConfig()
+	if err != nil {
+		return err
+	}
+	defer configLock.Release()
+
+	removeCmd := helmCommand("repo", "remove", name)
 	var stdout, stderr bytes.Buffer
 	removeCmd.Stdout = &stdout
 	removeCmd.Stderr = &stderr
 	if err := removeCmd.Run(); err != nil {
-		return fmt.Errorf(`running "%s": %v: %v`, removeCmd, err, stderr.String())
+		return newCommandError(removeCmd, stderr.String(), err)
 	}
 
 	return nil
@@ -71,17 +177,124 @@ func RepoRemove(name string) error {
 // FindRepoNameByURL attempts to search for an existing helm repository on the
 // the host matching the provided URL.
 // Will return the the name of the repo if found or empty string if not.
-// Errors when unable to parse the host repository list.
+// URLs are compared normalized: the scheme and host are case-insensitive and
+// trailing slashes are ignored, so "https://Charts.example.com/" matches
+// "https://charts.example.com". Repository aliases as used by the
+// dependencies of a Chart.yaml ("@<name>" or "alias:<name>") match the
+// repository of that name.
+// The repositories are read from the repositories.yaml of the host helm client
+// (see RepoConfigPath). Errors when unable to parse it.
 func FindRepoNameByURL(URL string) (string, error) {
-	repositories, err := RepoList()
+	repositories, err := hostRepositories()
 	if err != nil {
 		return "", fmt.Errorf(`getting helm repo list: %w`, err)
 	}
+	alias := strings.TrimPrefix(strings.TrimPrefix(URL, "@"), "alias:")
+	if alias != URL {
+		for _, entry := range repositories {
+			if entry.Name == alias {
+				return entry.Name, nil
+			}
+		}
+		return "", nil
+	}
+	normalized := normalizeRepoURL(URL)
 	for _, entry := range repositories {
-		if entry.URL == URL {
+		if normalizeRepoURL(entry.URL) == normalized {
 			return entry.Name, nil
 		}
 	}
 
 	return "", nil
 }
+
+// normalizeRepoURL returns repoURL with its scheme and host lowercased and
+// trailing slashes removed.
+func normalizeRepoURL(repoURL string) string {
+	repoURL = strings.TrimRight(strings.TrimSpace(repoURL), "/")
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return repoURL
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	return u.String()
+}
+
+// RepoConfigPath returns the path of the repositories.yaml of the host helm
+// client ($HELM_REPOSITORY_CONFIG) as reported by `helm env`.
+func RepoConfigPath() (string, error) {
+	env, err := Env()
+	if err != nil {
+		return "", err
+	}
+	if env.RepositoryConfig == "" {
+		return "", fmt.Errorf(`"helm env" did not report HELM_REPOSITORY_CONFIG`)
+	}
+	return env.RepositoryConfig, nil
+}
+
+// hostRepository is an entry of the repositories.yaml of the host helm
+// client.
+type hostRepository struct {
+	RepoListEntry         `yaml:",inline"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+	PassCredentialsAll    bool   `yaml:"pass_credentials_all"`
+	CAFile                string `yaml:"caFile"`
+	CertFile              string `yaml:"certFile"`
+	KeyFile               string `yaml:"keyFile"`
+	InsecureSkipTLSVerify bool   `yaml:"insecure_skip_tls_verify"`
+}
+
+func (r hostRepository) repoAuth() repoAuth {
+	return repoAuth{
+		username:              r.Username,
+		password:              r.Password,
+		passCredentials:       r.PassCredentialsAll,
+		caFile:                r.CAFile,
+		certFile:              r.CertFile,
+		keyFile:               r.KeyFile,
+		insecureSkipTLSVerify: r.InsecureSkipTLSVerify,
+	}
+}
+
+// hostRepositoryAuth returns the credentials and TLS options the repository
+// at repoURL was added to the host helm client with. Returns no options if
+// the repository has not been added.
+func hostRepositoryAuth(repoURL string) (repoAuth, error) {
+	repositories, err := hostRepositories()
+	if err != nil {
+		return repoAuth{}, fmt.Errorf(`getting helm repo list: %w`, err)
+	}
+	normalized := normalizeRepoURL(repoURL)
+	for _, entry := range repositories {
+		if normalizeRepoURL(entry.URL) == normalized {
+			return entry.repoAuth(), nil
+		}
+	}
+	return repoAuth{}, nil
+}
+
+// hostRepositories reads the repositories of the host helm client from its
+// repositories.yaml. A missing file has no repositories.
+func hostRepositories() ([]hostRepository, error) {
+	path, err := RepoConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	lock.RLock()
+	defer lock.RUnlock()
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf(`reading helm repositories %s: %w`, path, err)
+	}
+	var file struct {
+		Repositories []hostRepository `yaml:"repositories"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf(`parsing helm repositories %s: %w`, path, err)
+	}
+	return file.Repositories, nil
+}
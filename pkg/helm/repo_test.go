@@ -0,0 +1,57 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRepoNameByURL(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "repositories.yaml")
+	if err := os.WriteFile(config, []byte(`apiVersion: ""
+repositories:
+  - name: example
+    url: https://charts.example.com
+  - name: bitnami
+    url: https://Charts.Bitnami.com/bitnami/
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useFakeHelm(t, `case "$1" in
+env) echo 'HELM_BIN="helm"'; echo 'HELM_REPOSITORY_CONFIG="`+config+`"' ;;
+esac`)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://charts.example.com", "example"},
+		{"https://charts.example.com/", "example"},
+		{"HTTPS://CHARTS.EXAMPLE.COM", "example"},
+		{"https://charts.bitnami.com/bitnami", "bitnami"},
+		{"https://charts.bitnami.com/Bitnami", ""},
+		{"https://charts.example.org", ""},
+		{"@example", "example"},
+		{"alias:bitnami", "bitnami"},
+		{"@stable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := FindRepoNameByURL(tt.url)
+			if err != nil {
+				t.Fatalf("FindRepoNameByURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FindRepoNameByURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// hosts without repositories have no repositories.yaml
+	if err := os.Remove(config); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindRepoNameByURL("https://charts.example.com"); err != nil || got != "" {
+		t.Errorf("FindRepoNameByURL() without repositories = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,133 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GenerateIndex builds a repository index.yaml for the packaged charts (.tgz)
+// found recursively in dir and writes it to dir/index.yaml, mirroring
+// `helm repo index --merge`.
+// Chart URLs are <baseURL>/<path relative to dir>; relative paths are used if
+// baseURL is empty. Entries of an existing dir/index.yaml take precedence over
+// newly generated entries for the same chart version so digests and creation
+// times of published charts do not change.
+// The creation time of an entry is the modification time of its archive.
+func GenerateIndex(dir string, baseURL string) (*IndexFile, error) {
+	generated := &IndexFile{APIVersion: "v1", Entries: map[string][]ChartVersion{}}
+	err := filepath.WalkDir(dir, func(archivePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".tgz") {
+			return nil
+		}
+		chart, err := indexChartArchive(archivePath)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf(`reading file info of %s: %w`, archivePath, err)
+		}
+		chart.Created = info.ModTime()
+
+		relPath, err := filepath.Rel(dir, archivePath)
+		if err != nil {
+			return fmt.Errorf(`computing path of %s relative to %s: %w`, archivePath, dir, err)
+		}
+		chartURL := filepath.ToSlash(relPath)
+		if baseURL != "" {
+			chartURL = strings.TrimSuffix(baseURL, "/") + "/" + chartURL
+		}
+		chart.URLs = []string{chartURL}
+		generated.Entries[chart.Name] = append(generated.Entries[chart.Name], chart)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`indexing chart archives in %s: %w`, dir, err)
+	}
+
+	index := generated
+	indexPath := filepath.Join(dir, "index.yaml")
+	if content, err := os.ReadFile(indexPath); err == nil {
+		existing, err := ParseIndex(content)
+		if err != nil {
+			return nil, fmt.Errorf(`parsing existing repository index %s: %w`, indexPath, err)
+		}
+		existing.Merge(generated)
+		index = existing
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(`reading existing repository index %s: %w`, indexPath, err)
+	}
+	index.SortEntries()
+
+	if err := index.WriteFile(indexPath); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// indexChartArchive reads the Chart.yaml of a packaged chart and computes the
+// sha256 digest of the archive, returning them as an index entry without URLs.
+func indexChartArchive(archivePath string) (ChartVersion, error) {
+	var chart ChartVersion
+	content, err := os.ReadFile(archivePath)
+	if err != nil {
+		return chart, fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+	}
+	metadata, err := readArchiveFile(content, "Chart.yaml")
+	if err != nil {
+		return chart, fmt.Errorf(`reading Chart.yaml from chart archive %s: %w`, archivePath, err)
+	}
+	if err := yaml.Unmarshal(metadata, &chart); err != nil {
+		return chart, fmt.Errorf(`parsing Chart.yaml of chart archive %s: %w`, archivePath, err)
+	}
+	if chart.Name == "" || chart.Version == "" {
+		return chart, fmt.Errorf(`Chart.yaml of chart archive %s is missing a name or version`, archivePath)
+	}
+	sum := sha256.Sum256(content)
+	chart.Digest = hex.EncodeToString(sum[:])
+	chart.URLs = nil
+
+	return chart, nil
+}
+
+// readArchiveFile reads the file at name relative to the chart root directory
+// (e.g. "Chart.yaml" for "<chart>/Chart.yaml") of a packaged chart.
+func readArchiveFile(archive []byte, name string) ([]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf(`creating gzip reader: %w`, err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil, fmt.Errorf(`no file %s found in chart archive`, name)
+		case err != nil:
+			return nil, fmt.Errorf(`parsing file in chart archive: %w`, err)
+		}
+		// entries are of the form <chart>/<name>
+		segments := strings.SplitN(path.Clean(header.Name), "/", 2)
+		if len(segments) == 2 && segments[1] == name && header.Typeflag == tar.TypeReg {
+			return io.ReadAll(tr)
+		}
+	}
+}
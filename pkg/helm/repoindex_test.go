@@ -0,0 +1,58 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+)
+
+func TestGenerateIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, chart := range []helmtest.Chart{
+		{Name: "foo", Version: "1.0.0", AppVersion: "2.0.0"},
+		{Name: "foo", Version: "1.1.0"},
+		{Name: "bar", Version: "0.1.0"},
+	} {
+		if _, err := helmtest.PackageChart(dir, chart); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := GenerateIndex(dir, "https://charts.example.com/")
+	if err != nil {
+		t.Fatalf("GenerateIndex() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.yaml")); err != nil {
+		t.Errorf("GenerateIndex() did not write index.yaml: %v", err)
+	}
+
+	tests := []struct {
+		chart      string
+		version    string
+		appVersion string
+		url        string
+	}{
+		{"foo", "", "", "https://charts.example.com/foo-1.1.0.tgz"},
+		{"foo", "1.0.0", "2.0.0", "https://charts.example.com/foo-1.0.0.tgz"},
+		{"bar", "0.1.0", "", "https://charts.example.com/bar-0.1.0.tgz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chart+"@"+tt.version, func(t *testing.T) {
+			got, err := index.Get(tt.chart, tt.version)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.AppVersion != tt.appVersion {
+				t.Errorf("AppVersion = %v, want %v", got.AppVersion, tt.appVersion)
+			}
+			if len(got.URLs) != 1 || got.URLs[0] != tt.url {
+				t.Errorf("URLs = %v, want [%v]", got.URLs, tt.url)
+			}
+			if got.Digest == "" {
+				t.Errorf("Digest is empty")
+			}
+		})
+	}
+}
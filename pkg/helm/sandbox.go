@@ -0,0 +1,161 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/evanlouie/go/pkg/errcode"
+)
+
+// ErrSandboxLimit is wrapped by the errors returned when helm is stopped for
+// exceeding a limit of its Sandbox.
+var ErrSandboxLimit = errcode.New(errcode.HelmSandboxLimit, "sandbox limit exceeded")
+
+// Sandbox limits the resources of `helm template` so hostile chart templates
+// (e.g. a template recursing until memory is exhausted) cannot take down a
+// shared CI runner. Limits left at 0 are not enforced.
+// Timeout and ReadOnlyDir are enforced on all platforms. CPUTime, MemoryBytes
+// and FileBytes are rlimits applied to the helm process after it is started
+// but before it executes helm (so before helm reads the chart or starts any
+// children), and are only supported on linux: on other platforms templating
+// fails rather than running the chart without them.
+type Sandbox struct {
+	Timeout     time.Duration // wall-clock time after which helm is killed
+	CPUTime     time.Duration // RLIMIT_CPU. CPU time after which helm is killed. rounded up to seconds
+	MemoryBytes uint64        // RLIMIT_AS. maximum address space of helm; allow for the virtual memory reserved by the Go runtime (at least 512MiB)
+	FileBytes   uint64        // RLIMIT_FSIZE. maximum size of any file written by helm (e.g. by a post-renderer)
+
+	// ReadOnlyDir runs helm in an empty, read-only temporary working
+	// directory instead of the working directory of the Client. Relative
+	// paths of the chart, values files and post-renderer are made absolute.
+	ReadOnlyDir bool
+}
+
+// rlimits determines if any of the rlimits of s are set.
+func (s *Sandbox) rlimits() bool {
+	return s.CPUTime > 0 || s.MemoryBytes > 0 || s.FileBytes > 0
+}
+
+// withReadOnlyDir returns opts with its relative paths made absolute and the
+// read-only directory helm should be run in, along with a function removing
+// it. opts is returned unchanged if ReadOnlyDir is not set.
+func (s *Sandbox) withReadOnlyDir(opts TemplateOptions, valuesPaths []string) (TemplateOptions, []string, string, func(), error) {
+	if s == nil || !s.ReadOnlyDir {
+		return opts, valuesPaths, "", func() {}, nil
+	}
+	base := CurrentClient().Dir
+	if base == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return opts, nil, "", nil, fmt.Errorf(`getting working directory: %w`, err)
+		}
+		base = wd
+	}
+	absolute := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(base, path)
+	}
+	if opts.Repo == "" && !IsOCI(opts.Chart) {
+		// otherwise the chart is a reference to a repository of the host (e.g. bitnami/nginx)
+		if _, err := os.Stat(absolute(opts.Chart)); err == nil {
+			opts.Chart = absolute(opts.Chart)
+		}
+	}
+	opts.Values = append([]string(nil), opts.Values...)
+	for idx := range opts.Values {
+		opts.Values[idx] = absolute(opts.Values[idx])
+	}
+	valuesPaths = append([]string(nil), valuesPaths...)
+	for idx := range valuesPaths {
+		valuesPaths[idx] = absolute(valuesPaths[idx])
+	}
+	if strings.ContainsRune(opts.PostRenderer, filepath.Separator) || strings.ContainsRune(opts.PostRenderer, '/') {
+		opts.PostRenderer = absolute(opts.PostRenderer)
+	}
+
+	dir, err := mkdirTemp("fabrikate-sandbox")
+	if err != nil {
+		return opts, nil, "", nil, fmt.Errorf(`creating sandbox directory: %w`, err)
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		removeTemp(dir)
+		return opts, nil, "", nil, fmt.Errorf(`making sandbox directory %s read-only: %w`, dir, err)
+	}
+	return opts, valuesPaths, dir, func() {
+		os.Chmod(dir, 0700)
+		removeTemp(dir)
+	}, nil
+}
+
+// run runs cmd, whose stderr is written to stderr, within the limits of s. A
+// nil *Sandbox runs cmd without limits. Errors wrap ErrSandboxLimit if helm
+// was stopped for exceeding a limit.
+func (s *Sandbox) run(cmd *exec.Cmd, stderr *bytes.Buffer) error {
+	if s == nil {
+		return cmd.Run()
+	}
+	if s.rlimits() && !rlimitsSupported {
+		return fmt.Errorf(`sandboxing "%s": CPU, memory and file size limits are not supported on this platform`, redactCommand(cmd))
+	}
+	isolateProcess(cmd)
+	if !s.rlimits() {
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+	} else {
+		release, err := startGated(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyRlimits(cmd.Process.Pid, s); err != nil {
+			release(false)
+			cmd.Wait()
+			return fmt.Errorf(`sandboxing "%s": %w`, redactCommand(cmd), err)
+		}
+		if err := release(true); err != nil {
+			killProcess(cmd)
+			cmd.Wait()
+			return fmt.Errorf(`sandboxing "%s": starting helm: %w`, redactCommand(cmd), err)
+		}
+	}
+	// helm is run in its own process group which is not killed by the context
+	// of helmCommand
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-shutdown.ctx.Done():
+			killProcess(cmd)
+		case <-stopped:
+		}
+	}()
+	var timedOut int32
+	if s.Timeout > 0 {
+		timer := time.AfterFunc(s.Timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			killProcess(cmd)
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+	switch {
+	case err == nil:
+		return nil
+	case shuttingDown():
+		// killed by Shutdown rather than a limit
+		return err
+	case atomic.LoadInt32(&timedOut) == 1:
+		return fmt.Errorf(`%w: "%s" did not finish within %s`, ErrSandboxLimit, redactCommand(cmd), s.Timeout)
+	case s.rlimits() && (exceededRlimit(cmd.ProcessState) || (s.MemoryBytes > 0 && strings.Contains(stderr.String(), "out of memory"))):
+		return fmt.Errorf(`%w: "%s" was stopped by a CPU, memory or file size limit: %v`, ErrSandboxLimit, redactCommand(cmd), err)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+package helm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const rlimitsSupported = true
+
+// isolateProcess runs cmd in a process group of its own so killProcess also
+// kills its children (e.g. a post-renderer), which would otherwise keep the
+// output of helm open.
+func isolateProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcess kills the process group of cmd.
+func killProcess(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// startGated starts cmd held before it executes its program, until release
+// is called with true, so rlimits applied to the started process are in place
+// before the program runs. cmd is run by /bin/sh, which waits for a line on a
+// pipe and then execs the program in its place, keeping its pid, process
+// group and rlimits. When release is called with false (e.g. applying the
+// rlimits failed) the shell exits without running the program.
+func startGated(cmd *exec.Cmd) (release func(run bool) error, err error) {
+	gate, held, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf(`creating pipe: %w`, err)
+	}
+	fd := 3 + len(cmd.ExtraFiles)
+	path, args := cmd.Path, cmd.Args
+	cmd.Path = "/bin/sh"
+	cmd.Args = append([]string{"sh", "-c", fmt.Sprintf(`read -r _ <&%d && exec "$0" "$@" %d<&-`, fd, fd), path}, args[1:]...)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, gate)
+	err = cmd.Start()
+	// restored so errors name the program rather than the shell
+	cmd.Path, cmd.Args = path, args
+	cmd.ExtraFiles = cmd.ExtraFiles[:len(cmd.ExtraFiles)-1]
+	gate.Close()
+	if err != nil {
+		held.Close()
+		return nil, err
+	}
+	return func(run bool) error {
+		defer held.Close()
+		if !run {
+			return nil
+		}
+		_, err := held.Write([]byte("\n"))
+		return err
+	}, nil
+}
+
+// applyRlimits sets the rlimits of s on the process pid via prlimit(2). Soft
+// and hard limits are equal so the kernel kills the process when it exceeds
+// its CPU time rather than signalling SIGXCPU, which the Go runtime of helm
+// ignores.
+func applyRlimits(pid int, s *Sandbox) error {
+	limits := []struct {
+		resource int
+		name     string
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, "CPU time", uint64((s.CPUTime + 999999999) / 1000000000)},
+		{syscall.RLIMIT_AS, "memory", s.MemoryBytes},
+		{syscall.RLIMIT_FSIZE, "file size", s.FileBytes},
+	}
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+		rlimit := struct{ cur, max uint64 }{limit.value, limit.value}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(limit.resource), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf(`setting %s limit of process %d: %w`, limit.name, pid, errno)
+		}
+	}
+	return nil
+}
+
+// exceededRlimit determines if the process of state was killed by the kernel
+// for exceeding an rlimit.
+func exceededRlimit(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return false
+	}
+	switch status.Signal() {
+	case syscall.SIGKILL, syscall.SIGXCPU, syscall.SIGXFSZ:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package helm
+
+import (
+	"os"
+	"os/exec"
+)
+
+const rlimitsSupported = false
+
+func isolateProcess(cmd *exec.Cmd) {}
+
+func killProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func startGated(cmd *exec.Cmd) (func(run bool) error, error) {
+	return func(bool) error { return nil }, cmd.Start()
+}
+
+func applyRlimits(pid int, s *Sandbox) error {
+	return nil
+}
+
+func exceededRlimit(state *os.ProcessState) bool {
+	return false
+}
@@ -0,0 +1,122 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplate_sandbox(t *testing.T) {
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template) [ -n "$FAKE_ULIMIT" ] && echo "# cpu: $(ulimit -t)"
+  [ -n "$FAKE_STARTED" ] && touch "$FAKE_STARTED"
+  [ -n "$FAKE_SLEEP" ] && sleep "$FAKE_SLEEP"
+  [ -n "$FAKE_SPIN" ] && while :; do :; done
+  echo "# dir: $(pwd)"; echo "# chart: $3"; echo 'kind: ConfigMap' ;;
+esac`)
+	dir := t.TempDir()
+	chart := filepath.Join(dir, "chart")
+	if err := os.Mkdir(chart, 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := CurrentClient()
+	client.Dir = dir
+
+	t.Run("read-only dir", func(t *testing.T) {
+		SetClient(client)
+		output, err := Template(TemplateOptions{Release: "web", Chart: "chart", Sandbox: &Sandbox{ReadOnlyDir: true}})
+		if err != nil {
+			t.Fatalf("Template() error = %v", err)
+		}
+		if !strings.Contains(output, "# chart: "+chart+"\n") {
+			t.Errorf("Template() output = %q, want the chart as absolute path %s", output, chart)
+		}
+		if strings.Contains(output, "# dir: "+dir+"\n") {
+			t.Errorf("Template() output = %q, want helm run outside of %s", output, dir)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		sleeping := client
+		sleeping.Env = []string{"FAKE_SLEEP=5"}
+		SetClient(sleeping)
+		start := time.Now()
+		_, err := Template(TemplateOptions{Release: "web", Chart: chart, Sandbox: &Sandbox{Timeout: 100 * time.Millisecond}})
+		if !errors.Is(err, ErrSandboxLimit) {
+			t.Errorf("Template() error = %v, want ErrSandboxLimit", err)
+		}
+		if elapsed := time.Since(start); elapsed > 4*time.Second {
+			t.Errorf("Template() took %s, want helm killed after the timeout", elapsed)
+		}
+	})
+
+	t.Run("cpu time", func(t *testing.T) {
+		spinning := client
+		spinning.Env = []string{"FAKE_SPIN=1"}
+		SetClient(spinning)
+		_, err := Template(TemplateOptions{Release: "web", Chart: chart, Sandbox: &Sandbox{CPUTime: time.Second, Timeout: 20 * time.Second}})
+		if runtime.GOOS != "linux" {
+			if err == nil || errors.Is(err, ErrSandboxLimit) {
+				t.Errorf("Template() error = %v, want unsupported rlimits", err)
+			}
+			return
+		}
+		if !errors.Is(err, ErrSandboxLimit) || !strings.Contains(err.Error(), "CPU, memory or file size limit") {
+			t.Errorf("Template() error = %v, want ErrSandboxLimit of the CPU limit", err)
+		}
+	})
+
+	t.Run("rlimits before exec", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("rlimits are only supported on linux")
+		}
+		limited := client
+		limited.Env = []string{"FAKE_ULIMIT=1"}
+		SetClient(limited)
+		output, err := Template(TemplateOptions{Release: "web", Chart: chart, Sandbox: &Sandbox{CPUTime: 2 * time.Second}})
+		if err != nil {
+			t.Fatalf("Template() error = %v", err)
+		}
+		if !strings.Contains(output, "# cpu: 2\n") {
+			t.Errorf("Template() output = %q, want the CPU limit in place when helm starts", output)
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("rlimits are only supported on linux")
+		}
+		resetShutdown(t)
+		started := filepath.Join(t.TempDir(), "started")
+		sleeping := client
+		sleeping.Env = []string{"FAKE_STARTED=" + started, "FAKE_SLEEP=10"}
+		SetClient(sleeping)
+		errs := make(chan error, 1)
+		go func() {
+			_, err := Template(TemplateOptions{Release: "web", Chart: chart, Sandbox: &Sandbox{CPUTime: 20 * time.Second}})
+			errs <- err
+		}()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if _, err := os.Stat(started); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("fake helm template did not start")
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown() error = %v", err)
+		}
+		if err := <-errs; !errors.Is(err, ErrShutdown) || errors.Is(err, ErrSandboxLimit) {
+			t.Errorf("Template() error = %v, want ErrShutdown rather than ErrSandboxLimit", err)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// RemovedDocument is a document removed from rendered output by Sanitize.
+type RemovedDocument struct {
+	Index     int    // 0-indexed position of the document in the original output
+	StartLine int    // 1-indexed line the document starts on
+	EndLine   int    // 1-indexed line the document ends on
+	Reason    string // why the document was removed
+	Content   string // the raw content of the document
+}
+
+// Sanitize cleans up the raw multi-document yaml output of `helm template` so
+// every remaining document is a yaml map (i.e. a Kubernetes resource):
+//   - empty documents (e.g. templates which rendered nothing) are dropped
+//   - documents which are not maps (e.g. stray scalars or lists) are dropped
+//   - document separators are normalized to a single "---" line
+// The sanitized output is returned along with a report of every removed
+// document. An error is returned if any document is not valid yaml.
+func Sanitize(raw string) (string, []RemovedDocument, error) {
+	documents, errs := yamlPlus.DecodeCollect([]byte(raw))
+	if len(errs) > 0 {
+		return "", nil, fmt.Errorf(`sanitizing rendered output: %w`, errs[0])
+	}
+
+	var kept []string
+	var removed []RemovedDocument
+	for idx, document := range documents {
+		content := documentBody(raw[document.Offset:document.End])
+		var reason string
+		switch document.Value.(type) {
+		case nil:
+			reason = "empty document"
+		case map[string]interface{}:
+			kept = append(kept, content)
+			continue
+		default:
+			reason = fmt.Sprintf("document is a %T, not a map", document.Value)
+		}
+		removed = append(removed, RemovedDocument{
+			Index:     idx,
+			StartLine: document.StartLine,
+			EndLine:   document.EndLine,
+			Reason:    reason,
+			Content:   content,
+		})
+	}
+
+	return strings.Join(kept, "\n---\n"), removed, nil
+}
+
+// documentBody strips the document start ("---") and end ("...") markers and
+// surrounding blank lines from a raw yaml document.
+func documentBody(document string) string {
+	lines := strings.Split(document, "\n")
+	if len(lines) > 0 {
+		first := strings.TrimRight(lines[0], "\r")
+		if first == "---" || strings.HasPrefix(first, "--- ") || strings.HasPrefix(first, "---\t") {
+			// content may follow the marker on the same line. e.g: "--- {foo: bar}"
+			lines[0] = strings.TrimLeft(strings.TrimPrefix(first, "---"), " \t")
+		}
+	}
+	body := strings.Join(lines, "\n")
+	body = strings.TrimRight(body, " \t\r\n")
+	body = strings.TrimSuffix(body, "\n...")
+	if body == "..." {
+		body = ""
+	}
+	return strings.TrimLeft(body, "\r\n")
+}
@@ -0,0 +1,223 @@
+package helm
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+// SBOMFormat is the document format of an SBOM.
+type SBOMFormat string
+
+const (
+	SBOMCycloneDX SBOMFormat = "cyclonedx" // CycloneDX 1.4 JSON
+	SBOMSPDX      SBOMFormat = "spdx"      // SPDX 2.2 JSON
+)
+
+// SBOMOptions configure SBOM.
+type SBOMOptions struct {
+	Format    SBOMFormat
+	Name      string              // name of the render the SBOM describes. e.g: "my-stack"
+	Charts    []ChartProvenance   // charts pulled by the render. see Provenance.Charts
+	Manifests []manifest.Manifest // rendered manifests whose container images are listed. see manifest.Images
+}
+
+// SBOM generates a software bill of materials of a render: a CycloneDX or
+// SPDX document listing the charts it was rendered from, with their digest
+// and declared license, and the container images its workloads reference.
+// Charts and images are identified by package URLs (pkg:helm and pkg:docker,
+// or pkg:oci for images pinned to a digest).
+func SBOM(opts SBOMOptions) ([]byte, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	images := manifest.Images(opts.Manifests)
+	created := time.Now().UTC().Format(time.RFC3339)
+
+	var document interface{}
+	switch opts.Format {
+	case SBOMCycloneDX:
+		document = cycloneDX(opts.Name, uuid, created, opts.Charts, images)
+	case SBOMSPDX:
+		document = spdx(opts.Name, uuid, created, opts.Charts, images)
+	default:
+		return nil, fmt.Errorf(`unknown SBOM format "%s": expected %s or %s`, opts.Format, SBOMCycloneDX, SBOMSPDX)
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf(`encoding %s SBOM of %s: %w`, opts.Format, opts.Name, err)
+	}
+	return content, nil
+}
+
+// cycloneDX returns the CycloneDX document of charts and images.
+func cycloneDX(name string, uuid string, created string, charts []ChartProvenance, images []manifest.Image) map[string]interface{} {
+	components := []map[string]interface{}{}
+	for _, chart := range charts {
+		component := map[string]interface{}{
+			"type":    "application",
+			"name":    chart.Chart,
+			"version": chart.Version,
+			"purl":    chartPURL(chart),
+		}
+		if algorithm, digest := splitDigest(chart.Digest); algorithm == "sha256" && digest != "" {
+			component["hashes"] = []map[string]string{{"alg": "SHA-256", "content": digest}}
+		}
+		if chart.License != "" {
+			component["licenses"] = []map[string]string{{"expression": chart.License}}
+		}
+		if chart.Source != "" {
+			component["externalReferences"] = []map[string]string{{"type": "distribution", "url": chart.Source}}
+		}
+		components = append(components, component)
+	}
+	for _, image := range images {
+		component := map[string]interface{}{
+			"type": "container",
+			"name": image.Repository,
+			"purl": imagePURL(image),
+		}
+		if version := image.Tag; version != "" {
+			component["version"] = version
+		} else if image.Digest != "" {
+			component["version"] = image.Digest
+		}
+		if algorithm, digest := splitDigest(image.Digest); algorithm == "sha256" && digest != "" {
+			component["hashes"] = []map[string]string{{"alg": "SHA-256", "content": digest}}
+		}
+		components = append(components, component)
+	}
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.4",
+		"serialNumber": "urn:uuid:" + uuid,
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": created,
+			"component": map[string]string{"type": "application", "name": name},
+		},
+		"components": components,
+	}
+}
+
+// spdx returns the SPDX document of charts and images.
+func spdx(name string, uuid string, created string, charts []ChartProvenance, images []manifest.Image) map[string]interface{} {
+	packages := []map[string]interface{}{}
+	var described []string
+	add := func(pkg map[string]interface{}, purl string) {
+		id := fmt.Sprintf("SPDXRef-Package-%d", len(packages))
+		pkg["SPDXID"] = id
+		pkg["filesAnalyzed"] = false
+		pkg["licenseConcluded"] = "NOASSERTION"
+		pkg["copyrightText"] = "NOASSERTION"
+		pkg["externalRefs"] = []map[string]string{{
+			"referenceCategory": "PACKAGE-MANAGER",
+			"referenceType":     "purl",
+			"referenceLocator":  purl,
+		}}
+		packages = append(packages, pkg)
+		described = append(described, id)
+	}
+	for _, chart := range charts {
+		pkg := map[string]interface{}{
+			"name":             chart.Chart,
+			"versionInfo":      chart.Version,
+			"downloadLocation": noAssertion(chart.Source),
+			"licenseDeclared":  noAssertion(chart.License),
+		}
+		if algorithm, digest := splitDigest(chart.Digest); digest != "" {
+			pkg["checksums"] = []map[string]string{{"algorithm": strings.ToUpper(algorithm), "checksumValue": digest}}
+		}
+		add(pkg, chartPURL(chart))
+	}
+	for _, image := range images {
+		pkg := map[string]interface{}{
+			"name":             image.Repository,
+			"downloadLocation": "NOASSERTION",
+			"licenseDeclared":  "NOASSERTION",
+		}
+		if image.Tag != "" {
+			pkg["versionInfo"] = image.Tag
+		}
+		if algorithm, digest := splitDigest(image.Digest); digest != "" {
+			pkg["checksums"] = []map[string]string{{"algorithm": strings.ToUpper(algorithm), "checksumValue": digest}}
+		}
+		add(pkg, imagePURL(image))
+	}
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.2",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": "https://spdx.org/spdxdocs/" + url.PathEscape(name) + "-" + uuid,
+		"creationInfo": map[string]interface{}{
+			"created":  created,
+			"creators": []string{"Tool: github.com/evanlouie/go/pkg/helm"},
+		},
+		"documentDescribes": described,
+		"packages":          packages,
+	}
+}
+
+// noAssertion returns value, or NOASSERTION for SPDX fields without one.
+func noAssertion(value string) string {
+	if value == "" {
+		return "NOASSERTION"
+	}
+	return value
+}
+
+// chartPURL returns the package URL of chart. e.g:
+// "pkg:helm/ingress-nginx@4.0.6?repository_url=https%3A%2F%2Fkubernetes.github.io%2Fingress-nginx"
+func chartPURL(chart ChartProvenance) string {
+	purl := "pkg:helm/" + url.PathEscape(chart.Chart) + "@" + url.PathEscape(chart.Version)
+	if chart.Source != "" {
+		purl += "?" + url.Values{"repository_url": {chart.Source}}.Encode()
+	}
+	return purl
+}
+
+// imagePURL returns the package URL of image: pkg:oci for images pinned to a
+// digest, pkg:docker otherwise. e.g:
+// "pkg:oci/web@sha256%3Aabc?repository_url=ghcr.io%2Fmy-org%2Fweb&tag=1.2.3" or
+// "pkg:docker/my-org/web@1.2.3?repository_url=ghcr.io"
+func imagePURL(image manifest.Image) string {
+	if image.Digest != "" {
+		qualifiers := url.Values{"repository_url": {image.Repository}}
+		if image.Tag != "" {
+			qualifiers.Set("tag", image.Tag)
+		}
+		name := image.Repository[strings.LastIndex(image.Repository, "/")+1:]
+		return "pkg:oci/" + url.PathEscape(name) + "@" + url.PathEscape(image.Digest) + "?" + qualifiers.Encode()
+	}
+	repository, registry := image.Repository, ""
+	// the first segment of a repository is a registry if it is a hostname
+	if idx := strings.Index(repository, "/"); idx >= 0 && strings.ContainsAny(repository[:idx], ".:") {
+		registry, repository = repository[:idx], repository[idx+1:]
+	}
+	purl := "pkg:docker/" + repository
+	if image.Tag != "" {
+		purl += "@" + url.PathEscape(image.Tag)
+	}
+	if registry != "" {
+		purl += "?" + url.Values{"repository_url": {registry}}.Encode()
+	}
+	return purl
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf(`generating uuid: %w`, err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
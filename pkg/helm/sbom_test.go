@@ -0,0 +1,76 @@
+package helm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/evanlouie/go/pkg/manifest"
+)
+
+func TestSBOM(t *testing.T) {
+	charts := []ChartProvenance{{Source: "https://charts.example.com", Chart: "web", Version: "1.2.3", Digest: "sha256:abc", License: "Apache-2.0"}}
+	manifests := []manifest.Manifest{{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "ghcr.io/my-org/web:1.2.3"},
+						map[string]interface{}{"name": "proxy", "image": "envoyproxy/envoy@sha256:def"},
+					},
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		format SBOMFormat
+		field  string // of the components of the document
+		key    string // of the purls of components
+		want   []string
+	}{
+		{SBOMCycloneDX, "components", "purl", []string{
+			"pkg:helm/web@1.2.3?repository_url=https%3A%2F%2Fcharts.example.com",
+			"pkg:oci/envoy@sha256:def?repository_url=envoyproxy%2Fenvoy",
+			"pkg:docker/my-org/web@1.2.3?repository_url=ghcr.io",
+		}},
+		{SBOMSPDX, "packages", "externalRefs", []string{
+			"pkg:helm/web@1.2.3?repository_url=https%3A%2F%2Fcharts.example.com",
+			"pkg:oci/envoy@sha256:def?repository_url=envoyproxy%2Fenvoy",
+			"pkg:docker/my-org/web@1.2.3?repository_url=ghcr.io",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			content, err := SBOM(SBOMOptions{Format: tt.format, Name: "my-stack", Charts: charts, Manifests: manifests})
+			if err != nil {
+				t.Fatalf("SBOM() error = %v", err)
+			}
+			var document map[string]interface{}
+			if err := json.Unmarshal(content, &document); err != nil {
+				t.Fatalf("SBOM() is not json: %v", err)
+			}
+			components, _ := document[tt.field].([]interface{})
+			var purls []string
+			for _, entry := range components {
+				component := entry.(map[string]interface{})
+				switch purl := component[tt.key].(type) {
+				case string:
+					purls = append(purls, purl)
+				case []interface{}:
+					purls = append(purls, purl[0].(map[string]interface{})["referenceLocator"].(string))
+				}
+			}
+			if !reflect.DeepEqual(purls, tt.want) {
+				t.Errorf("SBOM() purls = %q, want %q", purls, tt.want)
+			}
+		})
+	}
+
+	if _, err := SBOM(SBOMOptions{Format: "swid"}); err == nil {
+		t.Error("SBOM() error = nil, want an unknown format")
+	}
+}
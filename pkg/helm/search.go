@@ -0,0 +1,70 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// SearchRepoOptions encapsulate the options for `helm search repo`.
+// helm search repo \
+//   --devel \
+//   --versions \
+//   --version <Version> \
+//   --regexp \
+//   --output json \
+//   <keyword>
+type SearchRepoOptions struct {
+	Devel    bool   // --devel. include development versions (alpha, beta and release candidates). ignored if Version is set
+	Versions bool   // --versions. list all versions of each chart instead of only the latest
+	Version  string // --version. semantic version constraint. e.g: "^1.2.0"
+	Regexp   bool   // --regexp. the keyword is a regular expression
+}
+
+// SearchRepoEntry is a single entry from the output of
+// `helm search repo --output json`
+type SearchRepoEntry struct {
+	Name        string `json:"name"` // <repo name>/<chart>
+	Version     string `json:"version"`
+	AppVersion  string `json:"app_version"`
+	Description string `json:"description"`
+}
+
+// SearchRepo searches the repositories of the host helm client for charts
+// matching keyword. All charts are listed if keyword is empty.
+// Repository indexes are not updated; see RepoUpdate.
+func SearchRepo(keyword string, opts SearchRepoOptions) ([]SearchRepoEntry, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	searchArgs := []string{"search", "repo", "--output", "json"}
+	if opts.Devel {
+		searchArgs = append(searchArgs, "--devel")
+	}
+	if opts.Versions {
+		searchArgs = append(searchArgs, "--versions")
+	}
+	if opts.Version != "" {
+		searchArgs = append(searchArgs, "--version", opts.Version)
+	}
+	if opts.Regexp {
+		searchArgs = append(searchArgs, "--regexp")
+	}
+	if keyword != "" {
+		searchArgs = append(searchArgs, keyword)
+	}
+
+	searchCmd := helmCommand(searchArgs...)
+	var stdout, stderr bytes.Buffer
+	searchCmd.Stdout = &stdout
+	searchCmd.Stderr = &stderr
+	if err := searchCmd.Run(); err != nil {
+		return nil, newCommandError(searchCmd, stderr.String(), err)
+	}
+
+	var entries []SearchRepoEntry
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		return nil, fmt.Errorf(`parsing output of "%s": %w`, searchCmd, err)
+	}
+	return entries, nil
+}
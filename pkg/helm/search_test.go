@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchRepo(t *testing.T) {
+	nginx := SearchRepoEntry{Name: "bitnami/nginx", Version: "9.5.4", AppVersion: "1.21.3", Description: "Chart for the nginx server"}
+	tests := []struct {
+		name    string
+		output  string
+		stderr  string // fails the search if set
+		want    []SearchRepoEntry
+		wantErr string
+	}{
+		{
+			"entries",
+			`[{"name":"bitnami/nginx","version":"9.5.4","app_version":"1.21.3","description":"Chart for the nginx server"},{"name":"bitnami/nginx","version":"9.5.3","app_version":"1.21.2","description":"Chart for the nginx server"}]`,
+			"",
+			[]SearchRepoEntry{nginx, {Name: "bitnami/nginx", Version: "9.5.3", AppVersion: "1.21.2", Description: "Chart for the nginx server"}},
+			"",
+		},
+		{"no app version", `[{"name":"bitnami/common","version":"1.10.0","app_version":"","description":"A Library Helm Chart"}]`, "", []SearchRepoEntry{{Name: "bitnami/common", Version: "1.10.0", Description: "A Library Helm Chart"}}, ""},
+		{"unknown fields", `[{"name":"bitnami/nginx","version":"9.5.4","app_version":"1.21.3","description":"Chart for the nginx server","deprecated":false}]`, "", []SearchRepoEntry{nginx}, ""},
+		{"no results", "[]\n", "", []SearchRepoEntry{}, ""},
+		{"table output", "NAME\tCHART VERSION\tAPP VERSION\tDESCRIPTION\nbitnami/nginx\t9.5.4\t1.21.3\tChart for the nginx server\n", "", nil, "parsing output"},
+		{"no output", "", "", nil, "parsing output"},
+		{"helm error", "", "Error: no repositories configured", nil, "no repositories configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeHelm(t, `printf '`+tt.output+`'
+[ -z '`+tt.stderr+`' ] || { echo '`+tt.stderr+`' >&2; exit 1; }`)
+			got, err := SearchRepo("nginx", SearchRepoOptions{})
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("SearchRepo() error = %v, want %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchRepo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+}
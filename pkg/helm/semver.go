@@ -0,0 +1,376 @@
+package helm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semVerRgx matches semantic versions with an optional leading "v" and
+// optional minor/patch components (e.g. "v1", "1.2", "1.2.3-rc.1+build.5").
+var semVerRgx = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// semVer is a parsed semantic version.
+type semVer struct {
+	major, minor, patch int
+	prerelease          string
+	metadata            string
+}
+
+// parseSemVer parses a semantic version string. Missing minor and patch
+// components are treated as 0.
+func parseSemVer(version string) (semVer, error) {
+	var v semVer
+	matches := semVerRgx.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return v, fmt.Errorf(`invalid semantic version "%s"`, version)
+	}
+	for idx, target := range []*int{&v.major, &v.minor, &v.patch} {
+		if matches[idx+1] == "" {
+			continue
+		}
+		value, err := strconv.Atoi(matches[idx+1])
+		if err != nil {
+			return v, fmt.Errorf(`parsing version string %s as int: %w`, matches[idx+1], err)
+		}
+		*target = value
+	}
+	v.prerelease = matches[4]
+	v.metadata = matches[5]
+	return v, nil
+}
+
+func (v semVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	if v.metadata != "" {
+		s += "+" + v.metadata
+	}
+	return s
+}
+
+// compare returns -1, 0 or 1 if v is less than, equal to or greater than
+// other following semver precedence rules; build metadata is ignored.
+func (v semVer) compare(other semVer) int {
+	for _, pair := range [][2]int{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(v.prerelease, other.prerelease)
+}
+
+// comparePrerelease compares pre-release identifiers. A version without a
+// pre-release has higher precedence than one with a pre-release.
+func comparePrerelease(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for idx := 0; idx < len(aParts) && idx < len(bParts); idx++ {
+		aNum, aErr := strconv.Atoi(aParts[idx])
+		bNum, bErr := strconv.Atoi(bParts[idx])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1 // numeric identifiers have lower precedence
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[idx], bParts[idx]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// constraint is a parsed semantic version constraint as accepted by helm for
+// --version (e.g. "~1.2.x", "^2", ">=1.2.0 <1.5.0", "1.2 - 1.4 || 2.x"). It
+// matches a version if all comparisons of any one of its alternatives match.
+type constraint []alternative
+
+// alternative is a set of comparisons separated by "||" from the other
+// alternatives of a constraint.
+type alternative struct {
+	comparisons []comparison
+	prerelease  bool // a version of the alternative specifies a pre-release (e.g. ">=1.2.0-0"), so pre-release versions may match
+}
+
+// comparison compares a version with a bound. op is one of "=", "!=", ">",
+// ">=", "<" or "<=".
+type comparison struct {
+	op    string
+	bound semVer
+}
+
+// partialRgx matches a version in a constraint whose missing or wildcard (x,
+// X or *) components match any value. e.g. "1", "1.2.x", "v1.2.3-rc.1".
+var partialRgx = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// comparisonRgx matches a single comparison of a constraint.
+var comparisonRgx = regexp.MustCompile(`^(=|!=|>=|<=|>|<|~>|~|\^)?\s*(\S+)$`)
+
+// partialVersion is a version of a constraint with specified (0 - 3)
+// leading components. e.g. "1.2.x" specifies 2.
+type partialVersion struct {
+	semVer
+	specified int
+}
+
+// parsePartial parses a version of a constraint.
+func parsePartial(version string) (partialVersion, error) {
+	matches := partialRgx.FindStringSubmatch(version)
+	if matches == nil {
+		return partialVersion{}, fmt.Errorf(`invalid version "%s"`, version)
+	}
+	var p partialVersion
+	for idx, target := range []*int{&p.major, &p.minor, &p.patch} {
+		component := matches[idx+1]
+		if component == "" || strings.ContainsAny(component, "xX*") {
+			break
+		}
+		value, err := strconv.Atoi(component)
+		if err != nil {
+			return partialVersion{}, fmt.Errorf(`parsing version string %s as int: %w`, component, err)
+		}
+		*target = value
+		p.specified++
+	}
+	if p.specified == 3 {
+		p.prerelease = matches[4]
+	}
+	return p, nil
+}
+
+// next returns the smallest version greater than all versions matched by p.
+// e.g. "1.2.x" -> "1.3.0". ok is false if p matches all versions.
+func (p partialVersion) next() (semVer, bool) {
+	switch p.specified {
+	case 0:
+		return semVer{}, false
+	case 1:
+		return semVer{major: p.major + 1}, true
+	case 2:
+		return semVer{major: p.major, minor: p.minor + 1}, true
+	default:
+		return semVer{major: p.major, minor: p.minor, patch: p.patch + 1}, true
+	}
+}
+
+// exclusiveUpper returns the bound of an upper limit derived from a partial
+// version (e.g. "<2.0.0" of "^1.2"), which also excludes the pre-releases of
+// the limit: 2.0.0-rc.1 is not a 1.x version although it is less than 2.0.0.
+func exclusiveUpper(limit semVer) semVer {
+	limit.prerelease = "0" // the lowest pre-release
+	return limit
+}
+
+// parseConstraint parses a semantic version constraint. Alternatives are
+// separated by "||" and the comparisons of an alternative by spaces or commas.
+// Supported comparisons are:
+//   - "1.2.3", "=1.2.3": exactly 1.2.3. "1.2" and "1.2.x" match any 1.2 patch
+//   - "!=1.2.3", ">1.2.3", ">=1.2.3", "<1.2.3", "<=1.2.3"
+//   - "~1.2.3": patch updates (>=1.2.3 <1.3.0). "~1" allows minor updates
+//   - "^1.2.3": updates not changing the leftmost non-zero component
+//     (>=1.2.3 <2.0.0, ^0.2.3 is >=0.2.3 <0.3.0)
+//   - "1.2 - 1.4.5": inclusive ranges
+//   - "*", "x" or an empty constraint: any version
+// As with helm, pre-release versions are only matched by alternatives with a
+// version specifying a pre-release (e.g. ">=1.2.0-0"), unless matched with
+// devel (see constraint.matches).
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		// rejoin operators separated from their versions (e.g. ">= 1.2")
+		var terms []string
+		for idx := 0; idx < len(fields); idx++ {
+			if strings.Trim(fields[idx], "=!<>~^") == "" && idx+1 < len(fields) {
+				terms = append(terms, fields[idx]+fields[idx+1])
+				idx++
+				continue
+			}
+			terms = append(terms, fields[idx])
+		}
+
+		alt := alternative{comparisons: []comparison{}}
+		for idx := 0; idx < len(terms); idx++ {
+			// hyphen range: <lower> - <upper>
+			if idx+2 < len(terms) && terms[idx+1] == "-" {
+				lower, err := parsePartial(terms[idx])
+				if err != nil {
+					return nil, fmt.Errorf(`invalid constraint "%s": %w`, s, err)
+				}
+				upper, err := parsePartial(terms[idx+2])
+				if err != nil {
+					return nil, fmt.Errorf(`invalid constraint "%s": %w`, s, err)
+				}
+				alt.comparisons = append(alt.comparisons, comparison{">=", lower.semVer})
+				if upper.specified == 3 {
+					alt.comparisons = append(alt.comparisons, comparison{"<=", upper.semVer})
+				} else if next, ok := upper.next(); ok {
+					alt.comparisons = append(alt.comparisons, comparison{"<", exclusiveUpper(next)})
+				}
+				alt.prerelease = alt.prerelease || lower.prerelease != "" || upper.prerelease != ""
+				idx += 2
+				continue
+			}
+			termComparisons, prerelease, err := parseComparison(terms[idx])
+			if err != nil {
+				return nil, fmt.Errorf(`invalid constraint "%s": %w`, s, err)
+			}
+			alt.comparisons = append(alt.comparisons, termComparisons...)
+			alt.prerelease = alt.prerelease || prerelease
+		}
+		c = append(c, alt)
+	}
+	return c, nil
+}
+
+// parseComparison parses a single comparison of a constraint into the
+// primitive comparisons it is equivalent to. prerelease is true if the version
+// of the comparison specifies a pre-release.
+func parseComparison(term string) (comparisons []comparison, prerelease bool, err error) {
+	matches := comparisonRgx.FindStringSubmatch(term)
+	if matches == nil {
+		return nil, false, fmt.Errorf(`invalid comparison "%s"`, term)
+	}
+	p, err := parsePartial(matches[2])
+	if err != nil {
+		return nil, false, err
+	}
+	comparisons, err = p.comparisons(matches[1], term)
+	return comparisons, p.prerelease != "", err
+}
+
+// comparisons returns the primitive comparisons equivalent to comparing with
+// p using op.
+func (p partialVersion) comparisons(op string, term string) ([]comparison, error) {
+	next, bounded := p.next()
+
+	switch op {
+	case "", "=":
+		if p.specified == 3 {
+			return []comparison{{"=", p.semVer}}, nil
+		} else if !bounded {
+			return nil, nil
+		}
+		return []comparison{{">=", p.semVer}, {"<", exclusiveUpper(next)}}, nil
+	case "!=":
+		if p.specified == 3 {
+			return []comparison{{"!=", p.semVer}}, nil
+		}
+		return nil, fmt.Errorf(`comparison "%s" requires a full version`, term)
+	case ">":
+		if p.specified == 3 {
+			return []comparison{{">", p.semVer}}, nil
+		} else if !bounded {
+			return []comparison{{"<", exclusiveUpper(semVer{})}}, nil // nothing is greater than any version
+		}
+		return []comparison{{">=", next}}, nil
+	case ">=":
+		return []comparison{{">=", p.semVer}}, nil
+	case "<":
+		if p.specified < 3 {
+			return []comparison{{"<", exclusiveUpper(p.semVer)}}, nil // "<2" excludes 2.0.0-rc.1
+		}
+		return []comparison{{"<", p.semVer}}, nil
+	case "<=":
+		switch {
+		case p.specified == 3:
+			return []comparison{{"<=", p.semVer}}, nil
+		case !bounded:
+			return nil, nil
+		default:
+			return []comparison{{"<", exclusiveUpper(next)}}, nil
+		}
+	case "~", "~>":
+		switch p.specified {
+		case 0:
+			return nil, nil
+		case 1:
+			return []comparison{{">=", p.semVer}, {"<", exclusiveUpper(semVer{major: p.major + 1})}}, nil
+		default:
+			return []comparison{{">=", p.semVer}, {"<", exclusiveUpper(semVer{major: p.major, minor: p.minor + 1})}}, nil
+		}
+	default: // "^"
+		var upper semVer
+		switch {
+		case p.specified == 0:
+			return nil, nil
+		case p.major > 0 || p.specified == 1:
+			upper = semVer{major: p.major + 1}
+		case p.minor > 0 || p.specified == 2:
+			upper = semVer{minor: p.minor + 1}
+		default:
+			upper = semVer{patch: p.patch + 1}
+		}
+		return []comparison{{">=", p.semVer}, {"<", exclusiveUpper(upper)}}, nil
+	}
+}
+
+// matches determines if version satisfies the constraint. Pre-release
+// versions only satisfy alternatives specifying a pre-release unless devel is
+// set, as with `helm --devel`.
+func (c constraint) matches(version semVer, devel bool) bool {
+	for _, alt := range c {
+		if version.prerelease != "" && !devel && !alt.prerelease {
+			continue
+		}
+		if matchesAll(alt.comparisons, version) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll determines if version satisfies all comparisons.
+func matchesAll(comparisons []comparison, version semVer) bool {
+	for _, cmp := range comparisons {
+		result := version.compare(cmp.bound)
+		var ok bool
+		switch cmp.op {
+		case "=":
+			ok = result == 0
+		case "!=":
+			ok = result != 0
+		case ">":
+			ok = result > 0
+		case ">=":
+			ok = result >= 0
+		case "<":
+			ok = result < 0
+		case "<=":
+			ok = result <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
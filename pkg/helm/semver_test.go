@@ -0,0 +1,59 @@
+package helm
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+		devel      bool
+		wantErr    bool
+	}{
+		{constraint: "1.2.3", matches: []string{"1.2.3", "v1.2.3"}, rejects: []string{"1.2.4"}},
+		{constraint: "1.2.x", matches: []string{"1.2.0", "1.2.9"}, rejects: []string{"1.3.0", "1.1.9"}},
+		{constraint: "~1.2.x", matches: []string{"1.2.0", "1.2.9"}, rejects: []string{"1.3.0"}},
+		{constraint: "~1.2.3", matches: []string{"1.2.3", "1.2.10"}, rejects: []string{"1.2.2", "1.3.0"}},
+		{constraint: "~1", matches: []string{"1.0.0", "1.9.0"}, rejects: []string{"2.0.0"}},
+		{constraint: "^1.2.3", matches: []string{"1.2.3", "1.9.0"}, rejects: []string{"2.0.0", "1.2.2"}},
+		{constraint: "^0.2.3", matches: []string{"0.2.3", "0.2.9"}, rejects: []string{"0.3.0"}},
+		{constraint: "^0.0.3", matches: []string{"0.0.3"}, rejects: []string{"0.0.4"}},
+		{constraint: ">=1.2.0 <1.5.0", matches: []string{"1.2.0", "1.4.9"}, rejects: []string{"1.5.0", "1.1.0"}},
+		{constraint: ">= 1.2, < 1.5", matches: []string{"1.2.0"}, rejects: []string{"1.5.0"}},
+		{constraint: ">1.2", matches: []string{"1.3.0"}, rejects: []string{"1.2.9"}},
+		{constraint: "<=1.2", matches: []string{"1.2.9"}, rejects: []string{"1.3.0"}},
+		{constraint: "!=1.2.3", matches: []string{"1.2.4"}, rejects: []string{"1.2.3"}},
+		{constraint: "1.2 - 1.4.5", matches: []string{"1.2.0", "1.4.5"}, rejects: []string{"1.4.6", "1.1.0"}},
+		{constraint: "1.2 - 1.4", matches: []string{"1.4.9"}, rejects: []string{"1.5.0"}},
+		{constraint: "1.x || >=3.0.0", matches: []string{"1.5.0", "3.1.0"}, rejects: []string{"2.0.0"}},
+		{constraint: "*", matches: []string{"0.0.1", "10.0.0"}, rejects: []string{"1.0.0-rc.1"}},
+		{constraint: ">=1.0.0-0", matches: []string{"1.0.0-rc.1", "1.0.0"}, rejects: []string{"0.9.0"}},
+		{constraint: "^1.2.0", rejects: []string{"1.3.0-beta.1"}},
+		{constraint: "^1.2.0-beta.1", matches: []string{"1.2.0-beta.2", "1.4.0-rc.1", "1.9.0"}, rejects: []string{"1.2.0-alpha.1", "2.0.0-rc.1"}},
+		{constraint: ">=1.0.0-0 <2", matches: []string{"1.9.0-rc.1"}, rejects: []string{"2.0.0-rc.1"}},
+		{constraint: "^1.2.0", devel: true, matches: []string{"1.3.0-beta.1", "1.2.1"}, rejects: []string{"1.2.0-rc.1", "2.0.0-rc.1"}},
+		{constraint: "~1.2.x", devel: true, matches: []string{"1.2.5-alpha.1"}, rejects: []string{"1.3.0-alpha.1"}},
+		{constraint: "*", devel: true, matches: []string{"1.0.0-rc.1", "0.0.0-alpha"}},
+		{constraint: ">*", devel: true, rejects: []string{"0.0.0-alpha"}},
+		{constraint: "1.2.a", wantErr: true},
+		{constraint: "!=1.2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := parseConstraint(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConstraint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, version := range tt.matches {
+				if v, _ := parseSemVer(version); !c.matches(v, tt.devel) {
+					t.Errorf("parseConstraint(%q).matches(%s, %t) = false, want true", tt.constraint, version, tt.devel)
+				}
+			}
+			for _, version := range tt.rejects {
+				if v, _ := parseSemVer(version); c.matches(v, tt.devel) {
+					t.Errorf("parseConstraint(%q).matches(%s, %t) = true, want false", tt.constraint, version, tt.devel)
+				}
+			}
+		})
+	}
+}
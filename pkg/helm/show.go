@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// ShowOptions encapsulate the options for `helm show`.
+// helm show <values|chart|readme> \
+//   --repo <Repo> \
+//   --version <Version> \
+//   --username <Username> --password <Password> --pass-credentials \
+//   --ca-file <CAFile> --cert-file <CertFile> --key-file <KeyFile> \
+//   --insecure-skip-tls-verify \
+//   <Chart>
+type ShowOptions struct {
+	Chart   string // [CHART]. a chart reference or path to a local chart
+	Repo    string // --repo. may be an oci:// registry URL
+	Version string // --version
+
+	Username        string // --username. chart repository username
+	Password        string // --password. chart repository password
+	PassCredentials bool   // --pass-credentials. pass credentials to all domains
+
+	Credentials CredentialProvider // resolved when the command runs to set Username and Password if neither is set
+
+	CAFile                string // --ca-file. verify certificates of HTTPS-enabled servers using this CA bundle
+	CertFile              string // --cert-file. identify HTTPS client using this SSL certificate file
+	KeyFile               string // --key-file. identify HTTPS client using this SSL key file
+	InsecureSkipTLSVerify bool   // --insecure-skip-tls-verify. skip tls certificate checks for the chart download
+
+	IsolatedConfig bool // run helm with a temporary config home, repository config and repository cache so the host helm client is neither used nor modified. repositories of the host are not searched
+}
+
+func (opts ShowOptions) repoAuth() repoAuth {
+	return repoAuth{
+		username:              opts.Username,
+		password:              opts.Password,
+		passCredentials:       opts.PassCredentials,
+		caFile:                opts.CAFile,
+		certFile:              opts.CertFile,
+		keyFile:               opts.KeyFile,
+		insecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
+	}
+}
+
+// ChartMetadata is the metadata of a chart found in its Chart.yaml.
+type ChartMetadata struct {
+	APIVersion   string            `yaml:"apiVersion"`
+	Name         string            `yaml:"name"`
+	Version      string            `yaml:"version"`
+	KubeVersion  string            `yaml:"kubeVersion,omitempty"`
+	Description  string            `yaml:"description,omitempty"`
+	Type         string            `yaml:"type,omitempty"`
+	Keywords     []string          `yaml:"keywords,omitempty"`
+	Home         string            `yaml:"home,omitempty"`
+	Sources      []string          `yaml:"sources,omitempty"`
+	Dependencies []ChartDependency `yaml:"dependencies,omitempty"`
+	Maintainers  []ChartMaintainer `yaml:"maintainers,omitempty"`
+	Icon         string            `yaml:"icon,omitempty"`
+	AppVersion   string            `yaml:"appVersion,omitempty"`
+	Deprecated   bool              `yaml:"deprecated,omitempty"`
+	Annotations  map[string]string `yaml:"annotations,omitempty"`
+}
+
+// ChartDependency is a dependency listed in a Chart.yaml.
+type ChartDependency struct {
+	Name         string        `yaml:"name" json:"name"`
+	Version      string        `yaml:"version,omitempty" json:"version,omitempty"`
+	Repository   string        `yaml:"repository,omitempty" json:"repository,omitempty"`
+	Condition    string        `yaml:"condition,omitempty" json:"condition,omitempty"`
+	Tags         []string      `yaml:"tags,omitempty" json:"tags,omitempty"`
+	ImportValues []interface{} `yaml:"import-values,omitempty" json:"import-values,omitempty"`
+	Alias        string        `yaml:"alias,omitempty" json:"alias,omitempty"`
+}
+
+// ChartMaintainer is a maintainer listed in a Chart.yaml.
+type ChartMaintainer struct {
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	URL   string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// ShowValues runs `helm show values` and returns the parsed default values of
+// the chart. Charts without default values return an empty map.
+func ShowValues(opts ShowOptions) (map[string]interface{}, error) {
+	output, err := show("values", opts)
+	if err != nil {
+		return nil, err
+	}
+	maps, err := yamlPlus.DecodeMaps(output)
+	if err != nil {
+		return nil, fmt.Errorf(`parsing values of helm chart %s: %w`, opts.Chart, err)
+	}
+	values := map[string]interface{}{}
+	for _, m := range maps {
+		for key, value := range m {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// ShowChart runs `helm show chart` and returns the parsed Chart.yaml of the
+// chart.
+func ShowChart(opts ShowOptions) (ChartMetadata, error) {
+	var metadata ChartMetadata
+	output, err := show("chart", opts)
+	if err != nil {
+		return metadata, err
+	}
+	if err := yaml.Unmarshal(output, &metadata); err != nil {
+		return metadata, fmt.Errorf(`parsing Chart.yaml of helm chart %s: %w`, opts.Chart, err)
+	}
+	return metadata, nil
+}
+
+// ShowReadme runs `helm show readme` and returns the README of the chart.
+func ShowReadme(opts ShowOptions) (string, error) {
+	output, err := show("readme", opts)
+	return string(output), err
+}
+
+// show runs `helm show <subcommand>` for the chart of opts and returns stdout.
+func show(subcommand string, opts ShowOptions) ([]byte, error) {
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.Repo, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return nil, err
+	}
+	isolated, err := openIsolatedConfig(opts.IsolatedConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer isolated.Close()
+	showArgs := []string{"show", subcommand}
+	repo, chart, version, err := resolveChart(opts.Repo, opts.Chart, opts.Version, opts.repoAuth(), isolated.searchHost())
+	if err != nil {
+		return nil, err
+	}
+	if repo != "" {
+		showArgs = append(showArgs, "--repo", repo)
+	}
+	showArgs = append(showArgs, opts.repoAuth().args()...)
+	if version != "" {
+		showArgs = append(showArgs, "--version", version)
+	}
+	showArgs = append(showArgs, chart)
+
+	showCmd := isolated.command(showArgs...)
+	var stdout, stderr bytes.Buffer
+	showCmd.Stdout = &stdout
+	showCmd.Stderr = &stderr
+	if err := showCmd.Run(); err != nil {
+		return nil, newCommandError(showCmd, stderr.String(), err)
+	}
+
+	return stdout.Bytes(), nil
+}
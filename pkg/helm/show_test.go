@@ -0,0 +1,164 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeHelmShow is a fake helm printing the files of the local chart, the last
+// argument, like `helm show` does.
+const fakeHelmShow = `for chart; do :; done
+[ -f "$chart/Chart.yaml" ] || { echo "Error: Chart.yaml file is missing" >&2; exit 1; }
+case "$2" in
+values) cat "$chart/values.yaml" 2>/dev/null ;;
+chart) cat "$chart/Chart.yaml" ;;
+readme) cat "$chart/README.md" 2>/dev/null ;;
+esac
+exit 0`
+
+// writeShowChart writes files to a temporary chart directory.
+func writeShowChart(t *testing.T, files map[string]string) string {
+	t.Helper()
+	chartPath := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chartPath
+}
+
+func TestShowValues(t *testing.T) {
+	useFakeHelm(t, fakeHelmShow)
+	chart := "apiVersion: v2\nname: nginx\nversion: 1.2.3\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"values", map[string]string{"Chart.yaml": chart, "values.yaml": "replicaCount: 1\nimage:\n  tag: 1.21.0\n"}, map[string]interface{}{"replicaCount": 1, "image": map[string]interface{}{"tag": "1.21.0"}}, false},
+		{"multiple documents", map[string]string{"Chart.yaml": chart, "values.yaml": "replicaCount: 1\n---\nreplicaCount: 2\nservice: {}\n"}, map[string]interface{}{"replicaCount": 2, "service": map[string]interface{}{}}, false},
+		{"comments only", map[string]string{"Chart.yaml": chart, "values.yaml": "# replicaCount: 1\n"}, map[string]interface{}{}, false},
+		{"no values file", map[string]string{"Chart.yaml": chart}, map[string]interface{}{}, false},
+		{"malformed", map[string]string{"Chart.yaml": chart, "values.yaml": "replicaCount: [1\n"}, nil, true},
+		{"not a chart", map[string]string{"values.yaml": "replicaCount: 1\n"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShowValues(ShowOptions{Chart: writeShowChart(t, tt.files)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShowValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShowValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowChart(t *testing.T) {
+	useFakeHelm(t, fakeHelmShow)
+	tests := []struct {
+		name    string
+		chart   string
+		want    ChartMetadata
+		wantErr bool
+	}{
+		{
+			"chart",
+			"apiVersion: v2\nname: nginx\nversion: 1.2.3\nappVersion: 1.21.0\ndependencies:\n  - name: common\n    version: 1.x.x\n    repository: https://charts.bitnami.com/bitnami\n",
+			ChartMetadata{APIVersion: "v2", Name: "nginx", Version: "1.2.3", AppVersion: "1.21.0", Dependencies: []ChartDependency{{Name: "common", Version: "1.x.x", Repository: "https://charts.bitnami.com/bitnami"}}},
+			false,
+		},
+		{
+			"maintainers and annotations",
+			"apiVersion: v2\nname: nginx\nversion: 1.2.3\ndeprecated: true\nmaintainers:\n  - name: Bitnami\n    email: containers@bitnami.com\nannotations:\n  category: Infrastructure\n",
+			ChartMetadata{APIVersion: "v2", Name: "nginx", Version: "1.2.3", Deprecated: true, Maintainers: []ChartMaintainer{{Name: "Bitnami", Email: "containers@bitnami.com"}}, Annotations: map[string]string{"category": "Infrastructure"}},
+			false,
+		},
+		{"malformed", "name: [nginx\n", ChartMetadata{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShowChart(ShowOptions{Chart: writeShowChart(t, map[string]string{"Chart.yaml": tt.chart})})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShowChart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShowChart() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowReadme(t *testing.T) {
+	useFakeHelm(t, fakeHelmShow)
+	chart := "apiVersion: v2\nname: nginx\nversion: 1.2.3\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"readme", map[string]string{"Chart.yaml": chart, "README.md": "# nginx\n\nA web server.\n\n"}, "# nginx\n\nA web server.\n\n", false},
+		{"no readme", map[string]string{"Chart.yaml": chart}, "", false},
+		{"not a chart", map[string]string{"README.md": "# nginx\n"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShowReadme(ShowOptions{Chart: writeShowChart(t, tt.files)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShowReadme() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ShowReadme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShow_isolatedConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "repositories.yaml")
+	if err := os.WriteFile(config, []byte(`repositories:
+  - name: bitnami
+    url: https://charts.bitnami.com/bitnami
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the fake helm prints the repository the chart was shown from. charts of
+	// named repositories must be in the repository config helm is run with
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="`+config+`"' ;;
+show)
+	for chart; do :; done
+	case "$chart" in
+	*/*) grep -q "name: ${chart%%/*}$" "${HELM_REPOSITORY_CONFIG:-`+config+`}" || { echo "Error: repo ${chart%%/*} not found" >&2; exit 1; }
+		echo "# ${chart#*/} from ${chart%%/*}" ;;
+	*) echo "# $chart from $4" ;;
+	esac ;;
+esac`)
+
+	tests := []struct {
+		name     string
+		isolated bool
+		want     string
+	}{
+		{"host repository", false, "# nginx from bitnami\n"},
+		{"isolated", true, "# nginx from https://charts.bitnami.com/bitnami\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShowReadme(ShowOptions{Repo: "https://charts.bitnami.com/bitnami", Chart: "nginx", IsolatedConfig: tt.isolated})
+			if err != nil {
+				t.Fatalf("ShowReadme() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ShowReadme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	"github.com/evanlouie/go/pkg/logger"
+)
+
+// ErrShutdown is wrapped by the errors of operations rejected or cancelled by
+// Shutdown.
+var ErrShutdown = errcode.New(errcode.HelmShutdown, "helm is shutting down")
+
+// shutdown is the state of the package used by Shutdown.
+var shutdown = newShutdownState()
+
+type shutdownState struct {
+	mu       sync.Mutex
+	closed   bool
+	ctx      context.Context // cancelled by Shutdown, killing the helm, git, kubectl and docker subprocesses of the package
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+	temps    map[string]struct{} // workspace temporary directories not yet removed
+}
+
+func newShutdownState() *shutdownState {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &shutdownState{ctx: ctx, cancel: cancel, temps: map[string]struct{}{}}
+}
+
+// Shutdown stops the package for the process to exit, e.g. when the render
+// service or CLI receives SIGTERM in a container:
+//   - new templates and pulls are rejected with ErrShutdown
+//   - in-flight helm subprocesses, and the git, kubectl and docker subprocesses
+//     of the package, are killed
+//   - in-flight templates and pulls are waited for until ctx is done
+//   - workspace temporary directories (pulled charts, values files, isolated
+//     helm configurations) which have not been removed yet are removed
+//   - buffered logs are flushed
+// Shutdown cannot be undone. An error is returned if ctx is done before the
+// in-flight operations have returned; the temporary directories are removed
+// regardless.
+//   ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//   defer cancel()
+//   if err := helm.Shutdown(ctx); err != nil { ... }
+func Shutdown(ctx context.Context) error {
+	shutdown.mu.Lock()
+	shutdown.closed = true
+	shutdown.mu.Unlock()
+	shutdown.cancel()
+
+	waited := make(chan struct{})
+	go func() {
+		shutdown.inflight.Wait()
+		close(waited)
+	}()
+	var err error
+	select {
+	case <-waited:
+	case <-ctx.Done():
+		err = fmt.Errorf(`waiting for in-flight helm operations: %w`, ctx.Err())
+	}
+
+	shutdown.mu.Lock()
+	for dir := range shutdown.temps {
+		os.Chmod(dir, 0700) // sandbox directories are read-only
+		os.RemoveAll(dir)
+		delete(shutdown.temps, dir)
+	}
+	shutdown.mu.Unlock()
+	logger.Flush()
+	return err
+}
+
+// shuttingDown determines if Shutdown has been called.
+func shuttingDown() bool {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+	return shutdown.closed
+}
+
+// beginOperation registers an in-flight operation Shutdown waits for,
+// returning the function ending it. Errors wrap ErrShutdown once Shutdown has
+// been called.
+func beginOperation() (func(), error) {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+	if shutdown.closed {
+		return nil, ErrShutdown
+	}
+	shutdown.inflight.Add(1)
+	return shutdown.inflight.Done, nil
+}
+
+// mkdirTemp creates a workspace temporary directory as os.MkdirTemp does in
+// the default directory for temporary files. Directories not yet removed by
+// removeTemp are removed by Shutdown.
+func mkdirTemp(pattern string) (string, error) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+	shutdown.temps[dir] = struct{}{}
+	return dir, nil
+}
+
+// removeTemp removes a directory created by mkdirTemp.
+func removeTemp(dir string) error {
+	shutdown.mu.Lock()
+	delete(shutdown.temps, dir)
+	shutdown.mu.Unlock()
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,121 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// resetShutdown restores the package after a test calling Shutdown.
+func resetShutdown(t *testing.T) {
+	t.Cleanup(func() { shutdown = newShutdownState() })
+}
+
+func TestShutdown(t *testing.T) {
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template) touch "$FAKE_STARTED"; exec sleep 10 ;;
+esac`)
+	resetShutdown(t)
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	client := CurrentClient()
+	client.Env = []string{"FAKE_STARTED=" + started}
+	SetClient(client)
+
+	tmpDir, err := mkdirTemp("fabrikate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := Template(TemplateOptions{Release: "web", Chart: dir})
+		errs <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("fake helm template did not start")
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Shutdown() took %s, want in-flight helm killed", elapsed)
+	}
+	if err := <-errs; !errors.Is(err, ErrShutdown) {
+		t.Errorf("in-flight Template() error = %v, want ErrShutdown", err)
+	}
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Errorf("temporary directory %s not removed by Shutdown(): %v", tmpDir, err)
+	}
+	if _, err := Template(TemplateOptions{Release: "web", Chart: dir}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Template() after Shutdown() error = %v, want ErrShutdown", err)
+	}
+	if err := PullWithOptions(PullOptions{Chart: dir, Into: t.TempDir()}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("PullWithOptions() after Shutdown() error = %v, want ErrShutdown", err)
+	}
+	if _, err := PullWithDigest(PullOptions{Chart: dir, Into: t.TempDir()}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("PullWithDigest() after Shutdown() error = %v, want ErrShutdown", err)
+	}
+	if _, err := PullArchiveWithOptions(PullOptions{Chart: dir, Into: t.TempDir()}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("PullArchiveWithOptions() after Shutdown() error = %v, want ErrShutdown", err)
+	}
+	cache := &ChartCache{Dir: t.TempDir()}
+	if _, err := cache.Pull(PullOptions{Chart: dir, Version: "1.0.0"}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("ChartCache.Pull() after Shutdown() error = %v, want ErrShutdown", err)
+	}
+}
+
+func TestShutdown_pullArchive(t *testing.T) {
+	// the background sleep keeps the output of the killed helm open, so the pull
+	// only returns a second after Shutdown killed it
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="/nonexistent/repositories.yaml"' ;;
+pull) touch "$FAKE_STARTED"; sleep 1 & exec sleep 10 ;;
+esac`)
+	resetShutdown(t)
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	client := CurrentClient()
+	client.Env = []string{"FAKE_STARTED=" + started}
+	SetClient(client)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := PullArchiveWithOptions(PullOptions{RepoURL: "https://charts.example.com", Chart: "web", Version: "1.0.0", Into: t.TempDir()})
+		errs <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("fake helm pull did not start: %v", <-errs)
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("Shutdown() returned after %s, want it to wait for the in-flight PullArchiveWithOptions()", elapsed)
+	}
+	if err := <-errs; !errors.Is(err, ErrShutdown) {
+		t.Errorf("in-flight PullArchiveWithOptions() error = %v, want ErrShutdown", err)
+	}
+}
@@ -0,0 +1,125 @@
+package helm
+
+import (
+	"sort"
+)
+
+// KindSortOrder is an ordering of Kubernetes kinds.
+type KindSortOrder []string
+
+// InstallOrder is the order in which helm installs resources of a release.
+var InstallOrder = KindSortOrder{
+	"PriorityClass",
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// UninstallOrder is the order in which helm uninstalls resources of a
+// release.
+var UninstallOrder = KindSortOrder{
+	"APIService",
+	"Ingress",
+	"IngressClass",
+	"Service",
+	"CronJob",
+	"Job",
+	"StatefulSet",
+	"HorizontalPodAutoscaler",
+	"Deployment",
+	"ReplicaSet",
+	"ReplicationController",
+	"Pod",
+	"DaemonSet",
+	"RoleBindingList",
+	"RoleBinding",
+	"RoleList",
+	"Role",
+	"ClusterRoleBindingList",
+	"ClusterRoleBinding",
+	"ClusterRoleList",
+	"ClusterRole",
+	"CustomResourceDefinition",
+	"PersistentVolumeClaim",
+	"PersistentVolume",
+	"StorageClass",
+	"ConfigMap",
+	"SecretList",
+	"Secret",
+	"ServiceAccount",
+	"PodDisruptionBudget",
+	"PodSecurityPolicy",
+	"LimitRange",
+	"ResourceQuota",
+	"NetworkPolicy",
+	"Namespace",
+	"PriorityClass",
+}
+
+// SortByKind sorts manifests (e.g. the output of TemplateWithCRDs) in
+// InstallOrder, the same way helm orders the resources of a release, so the
+// output applies cleanly with `kubectl apply -f`. manifests are sorted in
+// place.
+func SortByKind(manifests []map[string]interface{}) []map[string]interface{} {
+	return SortByKindOrder(manifests, InstallOrder)
+}
+
+// SortByKindOrder sorts manifests by the position of their kind in order.
+// Kinds not in order are placed last, sorted alphabetically by kind. The
+// relative order of manifests of the same kind is kept. manifests are sorted
+// in place.
+func SortByKindOrder(manifests []map[string]interface{}, order KindSortOrder) []map[string]interface{} {
+	ordering := make(map[string]int, len(order))
+	for idx, kind := range order {
+		ordering[kind] = idx
+	}
+	sort.SliceStable(manifests, func(i, j int) bool {
+		kindI, _ := manifests[i]["kind"].(string)
+		kindJ, _ := manifests[j]["kind"].(string)
+		first, iKnown := ordering[kindI]
+		second, jKnown := ordering[kindJ]
+		switch {
+		case !iKnown && !jKnown:
+			return kindI < kindJ
+		case !iKnown:
+			return false
+		case !jKnown:
+			return true
+		default:
+			return first < second
+		}
+	})
+	return manifests
+}
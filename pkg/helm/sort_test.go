@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortByKindOrder(t *testing.T) {
+	resource := func(kind string, name string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "metadata": map[string]interface{}{"name": name}}
+	}
+	manifests := []map[string]interface{}{
+		resource("Deployment", "web"),
+		resource("Widget", "b"),
+		resource("Service", "web"),
+		resource("Gadget", "a"),
+		resource("ConfigMap", "first"),
+		resource("Namespace", "web"),
+		resource("ConfigMap", "second"),
+		resource("CustomResourceDefinition", "widgets.example.com"),
+	}
+
+	tests := []struct {
+		name  string
+		order KindSortOrder
+		want  []map[string]interface{}
+	}{
+		{
+			name:  "install",
+			order: InstallOrder,
+			want: []map[string]interface{}{
+				resource("Namespace", "web"),
+				resource("ConfigMap", "first"),
+				resource("ConfigMap", "second"),
+				resource("CustomResourceDefinition", "widgets.example.com"),
+				resource("Service", "web"),
+				resource("Deployment", "web"),
+				resource("Gadget", "a"),
+				resource("Widget", "b"),
+			},
+		},
+		{
+			name:  "uninstall",
+			order: UninstallOrder,
+			want: []map[string]interface{}{
+				resource("Service", "web"),
+				resource("Deployment", "web"),
+				resource("CustomResourceDefinition", "widgets.example.com"),
+				resource("ConfigMap", "first"),
+				resource("ConfigMap", "second"),
+				resource("Namespace", "web"),
+				resource("Gadget", "a"),
+				resource("Widget", "b"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]map[string]interface{}{}, manifests...)
+			if got := SortByKindOrder(input, tt.order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortByKindOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StateFile is a declarative list of releases in the style of a
+// helmfile.yaml, so helmfile users can migrate their state onto TemplateAll.
+// e.g:
+//   repositories:
+//     - name: ingress-nginx
+//       url: https://kubernetes.github.io/ingress-nginx
+//   releases:
+//     - name: ingress
+//       namespace: ingress
+//       chart: ingress-nginx/ingress-nginx
+//       version: 4.0.6
+//       labels:
+//         env: prod
+//       values:
+//         - values/ingress.yaml
+//         - controller:
+//             replicaCount: 2
+//       set:
+//         - name: controller.service.type
+//           value: LoadBalancer
+// Go templating of helmfile.yaml.gotmpl, environments and hooks are not
+// supported.
+type StateFile struct {
+	Repositories []StateRepository `yaml:"repositories"`
+	Releases     []StateRelease    `yaml:"releases"`
+
+	dir string // directory of the state file, which relative paths are resolved against
+}
+
+// StateRepository is a chart repository releases of a StateFile reference
+// charts of as <name>/<chart>.
+type StateRepository struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	OCI  bool   `yaml:"oci"` // URL is an OCI registry (without the oci:// scheme)
+}
+
+// StateRelease is a release of a StateFile.
+type StateRelease struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Chart     string            `yaml:"chart"` // <repository name>/<chart>, a path relative to the state file, or an oci:// reference
+	Version   string            `yaml:"version"`
+	Labels    map[string]string `yaml:"labels"`    // matched by the selectors of TemplateOptions in addition to the name, namespace and chart of the release
+	Values    []interface{}     `yaml:"values"`    // values files relative to the state file (or http(s):// URLs) and inline values. files ending in ValuesTemplateSuffix are templates of the release (see ValuesTemplateData)
+	Set       []StateSet        `yaml:"set"`       // --set flags
+	Installed *bool             `yaml:"installed"` // releases which are not installed are not rendered. defaults to true
+}
+
+// StateSet is a --set flag of a StateRelease.
+type StateSet struct {
+	Name  string      `yaml:"name"`
+	Value interface{} `yaml:"value"`
+}
+
+// LoadState reads a StateFile from path.
+func LoadState(path string) (StateFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return StateFile{}, fmt.Errorf(`reading state file %s: %w`, path, err)
+	}
+	var state StateFile
+	if err := yaml.Unmarshal(content, &state); err != nil {
+		return StateFile{}, fmt.Errorf(`parsing state file %s: %w`, path, err)
+	}
+	state.dir = filepath.Dir(path)
+	return state, nil
+}
+
+// labels returns the labels selectors match the release with: its Labels and
+// its name, namespace and chart.
+func (r StateRelease) labels() map[string]string {
+	labels := map[string]string{}
+	for key, value := range r.Labels {
+		labels[key] = value
+	}
+	labels["name"], labels["namespace"], labels["chart"] = r.Name, r.Namespace, r.Chart
+	return labels
+}
+
+// stateSelector is a parsed selector of TemplateOptions: all of its
+// conditions must match.
+type stateSelector []stateCondition
+
+type stateCondition struct {
+	key     string
+	value   string
+	negated bool
+}
+
+// parseStateSelector parses a comma separated list of key=value and
+// key!=value conditions. e.g: "env=prod,tier!=frontend"
+func parseStateSelector(selector string) (stateSelector, error) {
+	var parsed stateSelector
+	for _, raw := range strings.Split(selector, ",") {
+		raw = strings.TrimSpace(raw)
+		var condition stateCondition
+		if idx := strings.Index(raw, "!="); idx > 0 {
+			condition = stateCondition{key: raw[:idx], value: raw[idx+2:], negated: true}
+		} else if idx := strings.Index(raw, "="); idx > 0 {
+			condition = stateCondition{key: raw[:idx], value: raw[idx+1:]}
+		} else {
+			return nil, fmt.Errorf(`parsing selector "%s": expected key=value or key!=value, got "%s"`, selector, raw)
+		}
+		parsed = append(parsed, condition)
+	}
+	return parsed, nil
+}
+
+func (s stateSelector) matches(labels map[string]string) bool {
+	for _, condition := range s {
+		if (labels[condition.key] == condition.value) == condition.negated {
+			return false
+		}
+	}
+	return true
+}
+
+// TemplateOptions returns the options templating the releases of the state
+// matching any of selectors (all releases if none are provided), as helmfile
+// does for its --selector/-l flags: each selector is a comma separated list
+// of key=value or key!=value conditions which must all match the labels of a
+// release. e.g: ["env=prod", "name=ingress"] selects the releases labelled
+// env=prod and the release named ingress. Each options inherits base (e.g.
+// its ChartCache or the Env of its ValuesTemplate) and is suitable for
+// TemplateAll.
+func (s StateFile) TemplateOptions(base TemplateOptions, selectors ...string) ([]TemplateOptions, error) {
+	var parsed []stateSelector
+	for _, selector := range selectors {
+		p, err := parseStateSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	repositories := map[string]StateRepository{}
+	for _, repository := range s.Repositories {
+		repositories[repository.Name] = repository
+	}
+
+	var opts []TemplateOptions
+	for _, release := range s.Releases {
+		if release.Installed != nil && !*release.Installed {
+			continue
+		}
+		selected := len(parsed) == 0
+		for _, selector := range parsed {
+			if selector.matches(release.labels()) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+
+		opt := base
+		opt.Release, opt.Namespace, opt.Version = release.Name, release.Namespace, release.Version
+		opt.Repo, opt.Chart = "", release.Chart
+		var repository StateRepository
+		if idx := strings.Index(release.Chart, "/"); idx > 0 {
+			repository = repositories[release.Chart[:idx]]
+		}
+		if repository.URL != "" {
+			opt.Repo, opt.Chart = repository.URL, release.Chart[len(repository.Name)+1:]
+			if repository.OCI && !IsOCI(repository.URL) {
+				opt.Repo = "oci://" + repository.URL
+			}
+		} else if !IsOCI(release.Chart) && !filepath.IsAbs(release.Chart) {
+			if _, err := os.Stat(filepath.Join(s.dir, release.Chart)); err == nil {
+				opt.Chart = filepath.Join(s.dir, release.Chart)
+			}
+		}
+		opt.Values = append([]string(nil), base.Values...)
+		opt.ValuesMap = append([]map[string]interface{}(nil), base.ValuesMap...)
+		for _, values := range release.Values {
+			switch v := values.(type) {
+			case string:
+				if !filepath.IsAbs(v) && len(remoteValues([]string{v})) == 0 {
+					v = filepath.Join(s.dir, v)
+				}
+				opt.Values = append(opt.Values, v)
+			case map[string]interface{}:
+				opt.ValuesMap = append(opt.ValuesMap, v)
+			default:
+				return nil, fmt.Errorf(`values of release %s: expected a file path or a map, got %T`, release.Name, values)
+			}
+		}
+		opt.Set = append([]string(nil), base.Set...)
+		for _, set := range release.Set {
+			opt.Set = append(opt.Set, fmt.Sprintf("%s=%v", set.Name, set.Value))
+		}
+		opt.ValuesTemplate = ReleaseValuesTemplate(opt, release.Labels)
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// TemplateState templates the releases of the state file at path matching
+// selectors (see StateFile.TemplateOptions) via TemplateAll.
+func TemplateState(path string, base TemplateOptions, selectors []string, concurrency int) (TemplateResults, error) {
+	state, err := LoadState(path)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := state.TemplateOptions(base, selectors...)
+	if err != nil {
+		return nil, err
+	}
+	return TemplateAll(opts, concurrency)
+}
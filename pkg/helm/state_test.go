@@ -0,0 +1,127 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testState = `
+repositories:
+  - name: ingress-nginx
+    url: https://kubernetes.github.io/ingress-nginx
+  - name: ghcr
+    url: ghcr.io/my-org/charts
+    oci: true
+releases:
+  - name: ingress
+    namespace: ingress
+    chart: ingress-nginx/ingress-nginx
+    version: 4.0.6
+    labels:
+      env: prod
+    values:
+      - values/ingress.yaml
+      - https://example.com/values.yaml
+      - controller:
+          replicaCount: 2
+    set:
+      - name: controller.replicaCount
+        value: 3
+  - name: web
+    namespace: web
+    chart: ghcr/web
+    version: 1.0.0
+    labels:
+      env: staging
+  - name: local
+    chart: charts/local
+    labels:
+      env: prod
+      tier: frontend
+  - name: disabled
+    chart: charts/local
+    installed: false
+`
+
+func TestStateFile_TemplateOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "charts", "local"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "helmfile.yaml")
+	if err := os.WriteFile(path, []byte(testState), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+
+	t.Run("releases", func(t *testing.T) {
+		opts, err := state.TemplateOptions(TemplateOptions{Set: []string{"global=true"}})
+		if err != nil {
+			t.Fatalf("TemplateOptions() error = %v", err)
+		}
+		want := []TemplateOptions{
+			{
+				Release:   "ingress",
+				Namespace: "ingress",
+				Repo:      "https://kubernetes.github.io/ingress-nginx",
+				Chart:     "ingress-nginx",
+				Version:   "4.0.6",
+				Values:    []string{filepath.Join(dir, "values", "ingress.yaml"), "https://example.com/values.yaml"},
+				ValuesMap: []map[string]interface{}{{"controller": map[string]interface{}{"replicaCount": 2}}},
+				Set:       []string{"global=true", "controller.replicaCount=3"},
+				ValuesTemplate: &ValuesTemplateData{Release: ValuesTemplateRelease{
+					Name: "ingress", Namespace: "ingress", Chart: "ingress-nginx", Version: "4.0.6", Labels: map[string]string{"env": "prod"},
+				}},
+			},
+			{
+				Release: "web", Namespace: "web", Repo: "oci://ghcr.io/my-org/charts", Chart: "web", Version: "1.0.0", Set: []string{"global=true"},
+				ValuesTemplate: &ValuesTemplateData{Release: ValuesTemplateRelease{
+					Name: "web", Namespace: "web", Chart: "web", Version: "1.0.0", Labels: map[string]string{"env": "staging"},
+				}},
+			},
+			{
+				Release: "local", Chart: filepath.Join(dir, "charts", "local"), Set: []string{"global=true"},
+				ValuesTemplate: &ValuesTemplateData{Release: ValuesTemplateRelease{
+					Name: "local", Chart: filepath.Join(dir, "charts", "local"), Labels: map[string]string{"env": "prod", "tier": "frontend"},
+				}},
+			},
+		}
+		if !reflect.DeepEqual(opts, want) {
+			t.Errorf("TemplateOptions() = %+v, want %+v", opts, want)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		selectors []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "label", selectors: []string{"env=prod"}, want: []string{"ingress", "local"}},
+		{name: "conditions are and-ed", selectors: []string{"env=prod,tier!=frontend"}, want: []string{"ingress"}},
+		{name: "selectors are or-ed", selectors: []string{"name=web", "tier=frontend"}, want: []string{"web", "local"}},
+		{name: "namespace", selectors: []string{"namespace=web"}, want: []string{"web"}},
+		{name: "no match", selectors: []string{"env=dev"}},
+		{name: "invalid", selectors: []string{"env"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := state.TemplateOptions(TemplateOptions{}, tt.selectors...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TemplateOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var releases []string
+			for _, opt := range opts {
+				releases = append(releases, opt.Release)
+			}
+			if !reflect.DeepEqual(releases, tt.want) {
+				t.Errorf("TemplateOptions() releases = %v, want %v", releases, tt.want)
+			}
+		})
+	}
+}
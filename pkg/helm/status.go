@@ -0,0 +1,193 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Release statuses reported by helm.
+const (
+	StatusDeployed        = "deployed"
+	StatusUninstalled     = "uninstalled"
+	StatusSuperseded      = "superseded"
+	StatusFailed          = "failed"
+	StatusUninstalling    = "uninstalling"
+	StatusPendingInstall  = "pending-install"
+	StatusPendingUpgrade  = "pending-upgrade"
+	StatusPendingRollback = "pending-rollback"
+)
+
+// ReleaseOptions are the cluster flags of the commands inspecting releases.
+// helm <command> \
+//   --namespace <Namespace> \
+//   --kube-context <KubeContext> \
+//   --kubeconfig <Kubeconfig>
+type ReleaseOptions struct {
+	Namespace   string // --namespace. defaults to the namespace of the kubeconfig context
+	KubeContext string // --kube-context
+	Kubeconfig  string // --kubeconfig
+}
+
+func (opts ReleaseOptions) args() []string {
+	var args []string
+	if opts.Namespace != "" {
+		args = append(args, "--namespace", opts.Namespace)
+	}
+	if opts.KubeContext != "" {
+		args = append(args, "--kube-context", opts.KubeContext)
+	}
+	if opts.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", opts.Kubeconfig)
+	}
+	return args
+}
+
+// ListOptions encapsulate the options for `helm list`.
+// helm list \
+//   <ReleaseOptions> \
+//   --all-namespaces \
+//   --all \
+//   --filter <Filter> \
+//   --output json
+type ListOptions struct {
+	ReleaseOptions
+	AllNamespaces bool   // --all-namespaces. list the releases of all namespaces instead of Namespace
+	All           bool   // --all. list releases of any status instead of only deployed and failed releases
+	Filter        string // --filter. regular expression the release names must match
+}
+
+// Release is a single entry from the output of `helm list --output json`.
+type Release struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Revision   int    `json:"revision,string"`
+	Updated    string `json:"updated"` // e.g: "2021-10-14 10:10:10.123456 +0000 UTC"
+	Status     string `json:"status"`  // e.g: StatusDeployed
+	Chart      string `json:"chart"`   // <chart>-<version>. e.g: "ingress-nginx-4.0.6"
+	AppVersion string `json:"app_version"`
+}
+
+// ChartName returns the name of the chart of the release. e.g: "ingress-nginx"
+func (r Release) ChartName() string {
+	name, _ := splitReleaseChart(r.Chart)
+	return name
+}
+
+// ChartVersion returns the version of the chart of the release. e.g: "4.0.6"
+func (r Release) ChartVersion() string {
+	_, version := splitReleaseChart(r.Chart)
+	return version
+}
+
+// splitReleaseChart splits the <chart>-<version> of a release at the first
+// "-" followed by a version (a digit, optionally prefixed with "v").
+func splitReleaseChart(chart string) (string, string) {
+	for idx := strings.Index(chart, "-"); idx >= 0; {
+		version := strings.TrimPrefix(chart[idx+1:], "v")
+		if version != "" && version[0] >= '0' && version[0] <= '9' {
+			return chart[:idx], chart[idx+1:]
+		}
+		next := strings.Index(chart[idx+1:], "-")
+		if next < 0 {
+			break
+		}
+		idx += next + 1
+	}
+	return chart, ""
+}
+
+// ReleaseInfo is the deployment information of a ReleaseStatus.
+type ReleaseInfo struct {
+	FirstDeployed time.Time `json:"first_deployed"`
+	LastDeployed  time.Time `json:"last_deployed"`
+	Deleted       time.Time `json:"deleted"`
+	Description   string    `json:"description"` // e.g: "Install complete"
+	Status        string    `json:"status"`      // e.g: StatusDeployed
+	Notes         string    `json:"notes"`       // rendered NOTES.txt of the chart
+}
+
+// ReleaseStatus is the output of `helm status --output json`.
+type ReleaseStatus struct {
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	Revision  int         `json:"version"`
+	Info      ReleaseInfo `json:"info"`
+	Chart     struct {
+		Metadata ChartMetadata `json:"metadata"`
+	} `json:"chart"`
+	Config   map[string]interface{} `json:"config"` // the values supplied by the user, excluding the defaults of the chart
+	Manifest string                 `json:"manifest"`
+}
+
+// ReleaseRevision is a single entry from the output of
+// `helm history --output json`.
+type ReleaseRevision struct {
+	Revision    int       `json:"revision"`
+	Updated     time.Time `json:"updated"`
+	Status      string    `json:"status"` // e.g: StatusSuperseded
+	Chart       string    `json:"chart"`  // <chart>-<version>. e.g: "ingress-nginx-4.0.6"
+	AppVersion  string    `json:"app_version"`
+	Description string    `json:"description"` // e.g: "Upgrade complete"
+}
+
+// List lists the releases of the cluster, e.g. to determine whether a chart is
+// already installed at a version:
+//   releases, err := helm.List(helm.ListOptions{ReleaseOptions: helm.ReleaseOptions{Namespace: "ingress"}})
+//   for _, release := range releases {
+//     if release.Name == "ingress" && release.ChartVersion() == "4.0.6" { ... }
+//   }
+func List(opts ListOptions) ([]Release, error) {
+	listArgs := append([]string{"list", "--output", "json"}, opts.ReleaseOptions.args()...)
+	if opts.AllNamespaces {
+		listArgs = append(listArgs, "--all-namespaces")
+	}
+	if opts.All {
+		listArgs = append(listArgs, "--all")
+	}
+	if opts.Filter != "" {
+		listArgs = append(listArgs, "--filter", opts.Filter)
+	}
+	var releases []Release
+	if err := runJSON(listArgs, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// Status returns the status of the latest revision of release. Errors wrap
+// ErrReleaseNotFound if release is not installed.
+func Status(release string, opts ReleaseOptions) (ReleaseStatus, error) {
+	var status ReleaseStatus
+	if err := runJSON(append([]string{"status", release, "--output", "json"}, opts.args()...), &status); err != nil {
+		return ReleaseStatus{}, err
+	}
+	return status, nil
+}
+
+// History returns the revisions of release, oldest first. Errors wrap
+// ErrReleaseNotFound if release is not installed.
+func History(release string, opts ReleaseOptions) ([]ReleaseRevision, error) {
+	var revisions []ReleaseRevision
+	if err := runJSON(append([]string{"history", release, "--output", "json"}, opts.args()...), &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// runJSON runs helm with args, unmarshalling its JSON output into v.
+func runJSON(args []string, v interface{}) error {
+	cmd := helmCommand(args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return newCommandError(cmd, stderr.String(), err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf(`parsing output of "%s": %w`, cmd, err)
+	}
+	return nil
+}
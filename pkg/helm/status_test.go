@@ -0,0 +1,80 @@
+package helm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	useFakeHelm(t, `[ "$*" = "list --output json --namespace ingress --all --filter ^ingress" ] || { echo "unexpected args: $*" >&2; exit 1; }
+echo '[{"name":"ingress","namespace":"ingress","revision":"3","updated":"2021-10-14 10:10:10.123456 +0000 UTC","status":"deployed","chart":"ingress-nginx-4.0.6","app_version":"1.0.4"}]'`)
+	releases, err := List(ListOptions{ReleaseOptions: ReleaseOptions{Namespace: "ingress"}, All: true, Filter: "^ingress"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []Release{{
+		Name:       "ingress",
+		Namespace:  "ingress",
+		Revision:   3,
+		Updated:    "2021-10-14 10:10:10.123456 +0000 UTC",
+		Status:     StatusDeployed,
+		Chart:      "ingress-nginx-4.0.6",
+		AppVersion: "1.0.4",
+	}}
+	if !reflect.DeepEqual(releases, want) {
+		t.Errorf("List() = %+v, want %+v", releases, want)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	useFakeHelm(t, `case "$2" in
+ingress) echo '{"name":"ingress","namespace":"ingress","version":2,"info":{"first_deployed":"2021-10-14T10:10:10Z","last_deployed":"2021-10-15T10:10:10Z","deleted":"0001-01-01T00:00:00Z","description":"Upgrade complete","status":"deployed"},"chart":{"metadata":{"name":"ingress-nginx","version":"4.0.6","appVersion":"1.0.4","apiVersion":"v2"}},"config":{"controller":{"replicaCount":2}},"manifest":"---"}' ;;
+*) echo 'Error: release: not found' >&2; exit 1 ;;
+esac`)
+	status, err := Status("ingress", ReleaseOptions{KubeContext: "prod"})
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Revision != 2 || status.Info.Status != StatusDeployed || status.Chart.Metadata.Version != "4.0.6" || status.Chart.Metadata.AppVersion != "1.0.4" {
+		t.Errorf("Status() = %+v, want revision 2 of ingress-nginx 4.0.6 deployed", status)
+	}
+	if status.Info.LastDeployed.Day() != 15 {
+		t.Errorf("Status().Info.LastDeployed = %s, want 2021-10-15", status.Info.LastDeployed)
+	}
+	if _, err := Status("missing", ReleaseOptions{}); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Status() error = %v, want ErrReleaseNotFound", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	useFakeHelm(t, `echo '[{"revision":1,"updated":"2021-10-14T10:10:10Z","status":"superseded","chart":"ingress-nginx-4.0.5","app_version":"1.0.3","description":"Install complete"},{"revision":2,"updated":"2021-10-15T10:10:10Z","status":"deployed","chart":"ingress-nginx-4.0.6","app_version":"1.0.4","description":"Upgrade complete"}]'`)
+	revisions, err := History("ingress", ReleaseOptions{Namespace: "ingress"})
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Status != StatusSuperseded || revisions[1].Revision != 2 || revisions[1].Description != "Upgrade complete" {
+		t.Errorf("History() = %+v, want revisions 1 and 2", revisions)
+	}
+}
+
+func Test_splitReleaseChart(t *testing.T) {
+	tests := []struct {
+		chart       string
+		wantName    string
+		wantVersion string
+	}{
+		{"ingress-nginx-4.0.6", "ingress-nginx", "4.0.6"},
+		{"cert-manager-v1.5.4", "cert-manager", "v1.5.4"},
+		{"nginx-1.0.0-rc.1", "nginx", "1.0.0-rc.1"},
+		{"nginx", "nginx", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chart, func(t *testing.T) {
+			name, version := splitReleaseChart(tt.chart)
+			if name != tt.wantName || version != tt.wantVersion {
+				t.Errorf("splitReleaseChart() = %s, %s, want %s, %s", name, version, tt.wantName, tt.wantVersion)
+			}
+		})
+	}
+}
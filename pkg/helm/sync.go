@@ -1,5 +1,27 @@
 package helm
 
-import "sync"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/evanlouie/go/pkg/filelock"
+)
 
 var lock sync.RWMutex
+
+// lockHostConfig acquires the file lock serializing mutations of the host helm
+// configuration (repositories and registry logins) between processes, in the
+// user cache directory. Callers also hold lock, which serializes goroutines.
+func lockHostConfig() (*filelock.Lock, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	configLock, err := filelock.Acquire(filepath.Join(dir, "evanlouie", "helm", "config.lock"))
+	if err != nil {
+		return nil, fmt.Errorf(`locking host helm configuration: %w`, err)
+	}
+	return configLock, nil
+}
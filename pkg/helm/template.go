@@ -1,16 +1,24 @@
 package helm
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net/http"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/evanlouie/go/pkg/cache"
+	"github.com/evanlouie/go/pkg/manifest"
 	yamlPlus "github.com/evanlouie/go/pkg/yaml"
 	"gopkg.in/yaml.v3"
 )
@@ -19,18 +27,130 @@ import (
 // helm template \
 //   --repo <Repo> \
 //   --version <Version> \
+//   --devel \
 //   --namespace <Namespace> --create-namespace \
 //   --values <Values[0]> --values <Value[1]> ... \
 //   --set <Set[0]> --set <Set[1]> ... \
+//   --values <tmp file of ValuesMap[0]> --values <tmp file of ValuesMap[1]> ... \
+//   --kube-version <KubeVersion> \
+//   --api-versions <APIVersions[0]> --api-versions <APIVersions[1]> ... \
+//   --include-crds \
+//   --no-hooks \
+//   --show-only <ShowOnly[0]> --show-only <ShowOnly[1]> ... \
+//   --post-renderer <PostRenderer> \
+//   --post-renderer-args <PostRendererArgs[0]> --post-renderer-args <PostRendererArgs[1]> ... \
+//   --username <Username> --password <Password> --pass-credentials \
+//   --ca-file <CAFile> --cert-file <CertFile> --key-file <KeyFile> \
+//   --insecure-skip-tls-verify \
 //   <Release> <Chart>
 type TemplateOptions struct {
 	Release   string   // [NAME]
-	Chart     string   // [CHART]
-	Repo      string   // --repo
-	Version   string   // --version
+	Chart     string   // [CHART]. a chart reference, or the path of a local chart directory or chart archive (.tgz)
+	Repo      string   // --repo. may be an oci:// registry URL in which case the chart is referenced as <Repo>/<Chart>
+	Version   string   // --version. may be a semantic version constraint (e.g. ">=4.0.0 <5.0.0"), see ResolveVersion
+	Devel     bool     // --devel. use pre-release versions: the newest version when Version is empty and pre-releases matching a Version constraint (e.g. "^2.0.0" matches 2.1.0-beta.1)
 	Namespace string   // --namespace flag. implies --create-namespace
-	Values    []string // "--value" flags. e.g.: ["foo/bar.yaml", "/etc/my/values.yaml"] == "--values foo/bar.yaml -- values /et/my/values.yaml"
+	Values    []string // "--value" flags. e.g.: ["foo/bar.yaml", "/etc/my/values.yaml"] == "--values foo/bar.yaml -- values /et/my/values.yaml". may be http(s):// URLs, see ValuesHeaders
 	Set       []string // "--set" flags. e.g: ["foo=bar", "baz=123"] == "--set foo=bar --set baz=123"
+
+	GitURL   string    // git repository the chart is checked out from (shallow, at GitRef) instead of Chart, for charts which are not published to a repository. e.g: "https://github.com/my-org/charts.git"
+	GitRef   string    // branch, tag or commit of GitURL. defaults to the default branch
+	GitPath  string    // path of the chart within GitURL. e.g: "charts/my-chart". defaults to the repository root
+	GitCache *GitCache // caches checkouts of GitURL across renders. when nil, the repository is checked out into a temporary directory on every render
+
+	ValuesHeaders map[string]string // headers sent when downloading Values which are http(s):// URLs. e.g: {"Authorization": "Bearer <token>"}. URLs ending in "#sha256=<hex digest>" are checked against the digest
+	ValuesClient  *http.Client      // client downloading Values URLs. defaults to http.DefaultClient
+
+	ValuesMap       []map[string]interface{} // in-memory values written to temporary files and passed as "--values" flags after Values
+	ValuesResolvers []ValuesResolver         // applied in order to each of ValuesMap before templating. e.g: a VaultResolver to inject secrets
+	ValuesTemplate  *ValuesTemplateData      // when set, Values files ending in ValuesTemplateSuffix are rendered as Go templates against it before templating. see RenderValuesTemplate
+
+	KubeVersion string   // --kube-version. kubernetes version used for .Capabilities.KubeVersion. e.g: "v1.20.0"
+	APIVersions []string // "--api-versions" flags. used for .Capabilities.APIVersions. e.g: ["monitoring.coreos.com/v1", "networking.k8s.io/v1/Ingress"]
+	IncludeCRDs bool     // --include-crds. requires helm >= v3.1.0
+
+	CapabilitiesFile string // snapshot of a cluster written by ClusterCapabilities.WriteFile providing KubeVersion and APIVersions when they are not set
+
+	NoHooks    bool     // --no-hooks. resources annotated as helm hooks are not rendered
+	HookFilter []string // helm hook types (e.g. TestHooks) whose resources are removed from the output of TemplateWithCRDs
+
+	ShowOnly []string // "--show-only" flags. only render the given templates. e.g: ["templates/deployment.yaml"]
+
+	Transformers []manifest.Transformer // applied in order to the output of TemplateWithCRDs after hooks are removed. e.g: a SealedSecretTransformer
+	Plugins      []string               // names of transformers registered via manifest.RegisterTransformer or manifest.LoadPlugins, applied in order after Transformers
+
+	ReleaseMetadata bool // add the labels and annotations `helm install` adds (e.g. meta.helm.sh/release-name) to the output of TemplateWithCRDs so resources can be adopted by `helm upgrade`
+
+	Labels      map[string]string // added to the metadata of every manifest outputted by TemplateWithCRDs before Transformers are applied. e.g: {"app.kubernetes.io/part-of": "my-platform"}
+	Annotations map[string]string // added to the metadata of every manifest outputted by TemplateWithCRDs before Transformers are applied. e.g: {"example.com/rendered-at": "2021-06-01T00:00:00Z"}
+
+	DependencyUpdate bool // run `helm dependency update` on a local Chart before templating. e.g: for charts with local path dependencies
+
+	PostRenderer     string                       // --post-renderer. path to an executable receiving the rendered manifests on stdin and writing the modified manifests to stdout
+	PostRendererArgs []string                     // "--post-renderer-args" flags. requires helm >= v3.7.0
+	PostRenderFunc   func([]byte) ([]byte, error) // applied to the output of `helm template` after any PostRenderer. e.g: to run kustomize-style patches in-process
+
+	Events func(Event) // called with progress events while templating. see EventChannel to receive events on a channel
+
+	StrictStderr bool // fail on any output of helm to stderr, including benign warnings (e.g. deprecation notices) which are otherwise returned by TemplateWithWarnings
+
+	Limits OutputLimits // maximum number and size of documents outputted by Template
+
+	Output *manifest.OutputSpec // when set, TemplateWithCRDs also writes its output as a single file, directory tree or gzipped bundle
+
+	Username        string // --username. chart repository username
+	Password        string // --password. chart repository password
+	PassCredentials bool   // --pass-credentials. pass credentials to all domains
+
+	Credentials CredentialProvider // resolved when the command runs to set Username and Password if neither is set
+
+	ResolveVersion bool // resolve a Version constraint (e.g. "~1.2.x") to the newest matching version in the repository index before templating, so the chart and its output can be cached and the version rendered is returned by TemplateWithVersion. helm resolves constraints itself otherwise
+
+	ChartCache    *ChartCache // when set, charts of exact versions are pulled into and templated from this cache instead of downloaded on every render
+	TemplateCache cache.Cache // when set, the output of `helm template` for charts of exact versions in repositories is memoized in this cache. see Template
+	Provenance    *Provenance // when set, the source, version, digest and license of every remote chart templated is recorded in it
+
+	Sandbox *Sandbox // when set, `helm template` runs within its time, CPU, memory and file size limits. e.g: for untrusted charts
+
+	IsolatedConfig bool // run helm with a temporary config home, repository config and repository cache so the host helm client is neither used nor modified. repositories of the host are not searched and registry logins are only available via an explicitly set HELM_REGISTRY_CONFIG
+
+	CAFile                string // --ca-file. verify certificates of HTTPS-enabled servers using this CA bundle
+	CertFile              string // --cert-file. identify HTTPS client using this SSL certificate file
+	KeyFile               string // --key-file. identify HTTPS client using this SSL key file
+	InsecureSkipTLSVerify bool   // --insecure-skip-tls-verify. skip tls certificate checks for the chart download
+}
+
+// pullOptions returns the options to pull the chart of opts.
+func (opts TemplateOptions) pullOptions() PullOptions {
+	return PullOptions{
+		RepoURL:         opts.Repo,
+		Chart:           opts.Chart,
+		Version:         opts.Version,
+		Devel:           opts.Devel,
+		Username:        opts.Username,
+		Password:        opts.Password,
+		PassCredentials: opts.PassCredentials,
+		Credentials:     opts.Credentials,
+		ResolveVersion:  opts.ResolveVersion,
+		IsolatedConfig:  opts.IsolatedConfig,
+
+		CAFile:                opts.CAFile,
+		CertFile:              opts.CertFile,
+		KeyFile:               opts.KeyFile,
+		InsecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
+	}
+}
+
+func (opts TemplateOptions) repoAuth() repoAuth {
+	return repoAuth{
+		username:              opts.Username,
+		password:              opts.Password,
+		passCredentials:       opts.PassCredentials,
+		caFile:                opts.CAFile,
+		certFile:              opts.CertFile,
+		keyFile:               opts.KeyFile,
+		insecureSkipTLSVerify: opts.InsecureSkipTLSVerify,
+	}
 }
 
 // TemplateWithCRDs will `helm template` the target chart as well as ensure
@@ -42,35 +162,243 @@ type TemplateOptions struct {
 // and holds CRD YAMLs which are not templated -- thus not outputted from
 // `helm template` -- but installed to the cluster via `helm install`. This
 // function is useful to get a complete YAML output for the entire chart.
+//
+// When the host helm client supports it (>= v3.1.0), `helm template
+// --include-crds` is used to output the CRDs. Older clients fall back to
+// reading the "crds" directory of the chart from the filesystem.
 func TemplateWithCRDs(opts TemplateOptions) ([]map[string]interface{}, error) {
-	// interpertet the chart path based on if a repo-url was provided
-	var chartPath, crdPath string
-	if opts.Repo != "" {
-		tmpDir, err := os.MkdirTemp("", "fabrikate")
+	opts, err := opts.withResolvedVersion()
+	if err != nil {
+		return nil, err
+	}
+	opts, removeCheckout, err := opts.withGitChart()
+	if err != nil {
+		return nil, err
+	}
+	defer removeCheckout()
+	opts, removeExtracted, err := opts.withChartArchive()
+	if err != nil {
+		return nil, err
+	}
+	defer removeExtracted()
+	var crds []string    // list of crd yaml <strings>
+	templateOpts := opts // inherit all the initial settings
+	if v, err := Version(); err == nil && newCapabilitySet(v).Supports(FeatureIncludeCRDs) {
+		templateOpts.IncludeCRDs = true
+	} else {
+		// interpertet the chart path based on if a repo-url was provided
+		var chartPath string
+		if opts.ChartCache != nil && (opts.Repo != "" || IsOCI(opts.Chart)) {
+			cachedPath, err := opts.ChartCache.Pull(opts.pullOptions())
+			if err != nil && !errors.Is(err, ErrNotCacheable) {
+				return nil, err
+			}
+			chartPath = cachedPath
+		}
+		if chartPath != "" {
+			// the chart has already been pulled into the chart cache
+		} else if opts.Repo != "" || IsOCI(opts.Chart) {
+			tmpDir, err := mkdirTemp("fabrikate")
+			if err != nil {
+				return nil, fmt.Errorf(`creating temporary directory to pull helm chart %s@%s from %s: %w`, opts.Chart, opts.Version, opts.Repo, err)
+			}
+			defer removeTemp(tmpDir)
+			pullOpts := opts.pullOptions()
+			pullOpts.Into = tmpDir
+			pullStart := time.Now()
+			opts.emit(Event{Type: PullStarted, Time: pullStart})
+			err = PullWithOptions(pullOpts)
+			opts.emit(Event{Type: PullFinished, Duration: time.Since(pullStart), Err: err})
+			if err != nil {
+				return nil, fmt.Errorf(`pulling helm chart %s@%s from %s: %w`, opts.Chart, opts.Version, opts.Repo, err)
+			}
+			chartName := opts.Chart
+			if IsOCI(opts.Repo) || IsOCI(opts.Chart) {
+				chartName = ociChartName(opts.Chart)
+			}
+			chartPath = filepath.Join(tmpDir, chartName)
+		} else {
+			chartPath = opts.Chart
+			if opts.DependencyUpdate {
+				// dependencies must be present in "charts" to read their CRDs
+				isolated, err := openIsolatedConfig(opts.IsolatedConfig)
+				if err != nil {
+					return nil, err
+				}
+				err = dependencyUpdate(isolated, chartPath)
+				isolated.Close()
+				if err != nil {
+					return nil, fmt.Errorf(`updating dependencies of helm chart %s: %w`, chartPath, err)
+				}
+				templateOpts.DependencyUpdate = false
+			}
+		}
+
+		// walk the "crds" dir of the chart and its subcharts to collect all the yaml strings
+		crds, err = readChartCRDs(longPath(chartPath))
+		if err != nil {
+			return nil, err
+		}
+
+		templateOpts.Repo = ""         // zero out so it wont attempt to lookup the repo
+		templateOpts.Version = ""      // zero out as the chart has already been pulled at the target version
+		templateOpts.Chart = chartPath // manually set the path of the chart to the downloaded chart
+	}
+
+	// run `helm template` to get the contents of the chart
+	template, err := Template(templateOpts)
+	if err != nil {
+		return nil, fmt.Errorf(`templating helm chart at %s: %w`, templateOpts.Chart, err)
+	}
+
+	// join all the yaml together with "---"
+	allYAMLEntries := append(crds, template)
+	unifiedYAMLString := strings.TrimSpace(strings.Join(allYAMLEntries, "\n---\n"))
+
+	// convert to maps and remove all nils
+	var maps, noNils []map[string]interface{}
+	sanitized, _, err := Sanitize(unifiedYAMLString)
+	if err != nil {
+		return nil, fmt.Errorf(`parsing output of "helm template": %w`, err)
+	}
+	maps, err = yamlPlus.DecodeMaps([]byte(sanitized))
+	if err != nil {
+		return nil, fmt.Errorf(`parsing output of "helm template": %w`, err)
+	}
+	for _, m := range maps {
+		if m != nil {
+			noNils = append(noNils, m)
+		}
+	}
+	if opts.NoHooks {
+		noNils = RemoveHooks(noNils)
+	} else if len(opts.HookFilter) > 0 {
+		noNils = RemoveHooks(noNils, opts.HookFilter...)
+	}
+	if len(opts.Labels) > 0 || len(opts.Annotations) > 0 {
+		noNils = AddMetadata(noNils, opts.Labels, opts.Annotations)
+	}
+	if len(opts.Transformers) > 0 {
+		transformed, err := manifest.Transform(manifest.FromMaps(noNils), opts.Transformers...)
 		if err != nil {
-			return nil, fmt.Errorf(`creating temporary directory to pull helm chart %s@%s from %s: %w`, opts.Chart, opts.Version, opts.Repo, err)
+			return nil, fmt.Errorf(`transforming output of helm chart %s: %w`, opts.Chart, err)
 		}
-		defer os.RemoveAll(tmpDir)
-		if err := Pull(opts.Repo, opts.Chart, opts.Version, tmpDir); err != nil {
-			return nil, fmt.Errorf(`pulling helm chart %s@%s from %s: %w`, opts.Chart, opts.Version, opts.Repo, err)
+		noNils = manifest.ToMaps(transformed)
+	}
+	if len(opts.Plugins) > 0 {
+		transformed, err := manifest.TransformWith(manifest.FromMaps(noNils), opts.Plugins...)
+		if err != nil {
+			return nil, fmt.Errorf(`transforming output of helm chart %s: %w`, opts.Chart, err)
+		}
+		noNils = manifest.ToMaps(transformed)
+	}
+	if opts.ReleaseMetadata {
+		noNils = AddReleaseMetadata(noNils, opts.Release, opts.Namespace)
+	}
+	if opts.Output != nil {
+		if err := manifest.WriteOutput(manifest.FromMaps(noNils), *opts.Output); err != nil {
+			return nil, fmt.Errorf(`writing output of helm chart %s: %w`, opts.Chart, err)
 		}
-		chartPath = filepath.Join(tmpDir, opts.Chart)
-	} else {
-		chartPath = opts.Chart
 	}
-	crdPath = filepath.Join(chartPath, "crds")
 
-	// walk the "crds" dir to collect all the yaml strings
-	var crds []string // list of crd yaml <strings>
+	return noNils, nil
+}
+
+// Manifest is a single Kubernetes resource outputted by templating a chart.
+type Manifest = manifest.Manifest
+
+// TemplateManifests is the same as TemplateWithCRDs but returns the output as
+// typed Manifests so callers can filter and group them by kind, name,
+// namespace and labels.
+func TemplateManifests(opts TemplateOptions) ([]Manifest, error) {
+	maps, err := TemplateWithCRDs(opts)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.FromMaps(maps), nil
+}
+
+// TemplateWithVersion is the same as TemplateWithCRDs but also returns the
+// version of the chart rendered, for recording in a lockfile or an
+// ArtifactStore: the exact version a Version constraint resolved to when
+// ResolveVersion is set, otherwise Version unchanged.
+func TemplateWithVersion(opts TemplateOptions) (string, []map[string]interface{}, error) {
+	opts, err := opts.withResolvedVersion()
+	if err != nil {
+		return "", nil, err
+	}
+	maps, err := TemplateWithCRDs(opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return opts.Version, maps, nil
+}
+
+// TemplateToDirectory runs TemplateManifests and writes each manifest to its
+// own file in dir, laid out as <namespace>/<kind>-<name>.yaml by default (see
+// manifest.DefaultFileName) or as named by fileName if provided. This allows
+// GitOps repositories to be materialized directly from a chart.
+func TemplateToDirectory(opts TemplateOptions, dir string, fileName func(m Manifest) string) ([]Manifest, error) {
+	manifests, err := TemplateManifests(opts)
+	if err != nil {
+		return nil, err
+	}
+	spec := manifest.OutputSpec{Format: manifest.OutputDirectory, Path: dir, FileName: fileName}
+	if err := manifest.WriteOutput(manifests, spec); err != nil {
+		return nil, fmt.Errorf(`writing output of helm chart %s to %s: %w`, opts.Chart, dir, err)
+	}
+	return manifests, nil
+}
+
+// readChartCRDs collects the contents of all yaml files in the "crds"
+// directory of the chart at chartPath as well as the "crds" directories of its
+// subcharts in "charts" -- both unpacked directories and .tgz archives. CRDs
+// of the parent chart come first, followed by subcharts in lexical order.
+func readChartCRDs(chartPath string) ([]string, error) {
+	crds, err := readCRDs(filepath.Join(chartPath, "crds"))
+	if err != nil {
+		return nil, err
+	}
+
+	subchartsPath := filepath.Join(chartPath, "charts")
+	entries, err := os.ReadDir(subchartsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return crds, nil
+	} else if err != nil {
+		return nil, fmt.Errorf(`reading helm chart subchart directory %s: %w`, subchartsPath, err)
+	}
+	for _, entry := range entries {
+		subchartPath := filepath.Join(subchartsPath, entry.Name())
+		var subchartCRDs []string
+		switch {
+		case entry.IsDir():
+			subchartCRDs, err = readChartCRDs(subchartPath)
+		case strings.HasSuffix(strings.ToLower(entry.Name()), ".tgz"):
+			subchartCRDs, err = readArchiveCRDs(subchartPath)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`reading CRDs of subchart %s: %w`, subchartPath, err)
+		}
+		crds = append(crds, subchartCRDs...)
+	}
+
+	return crds, nil
+}
+
+// readCRDs walks the "crds" directory of a chart at crdPath and returns the
+// contents of all yaml files found. A missing directory has no CRDs.
+func readCRDs(crdPath string) ([]string, error) {
+	var crds []string
 	if info, err := os.Stat(crdPath); err == nil {
 		if info.IsDir() {
 			err := filepath.Walk(crdPath, func(path string, info fs.FileInfo, err error) error {
 				if err != nil {
 					return fmt.Errorf(`walking path %s: %w`, path, err)
 				}
-				extension := strings.ToLower(filepath.Ext(info.Name()))
 				// track all yaml files
-				if !info.IsDir() && extension == ".yaml" {
+				if !info.IsDir() && isYAMLFile(info.Name()) {
 					crd, err := os.ReadFile(path)
 					if err != nil {
 						return fmt.Errorf("reading CRD file %s: %w", path, err)
@@ -87,209 +415,510 @@ func TemplateWithCRDs(opts TemplateOptions) ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf(`reading helm chart CRD directory %s: %w`, crdPath, err)
 	}
 
-	// run `helm template` to get the contents of the pulled chart
-	templateOpts := opts           // inherit all the initial settings
-	templateOpts.Repo = ""         // zero out so it wont attempt to lookup the repo
-	templateOpts.Chart = chartPath // manually set the path of the chart to the downloaded chart
-	template, err := Template(templateOpts)
+	return crds, nil
+}
+
+// readArchiveCRDs collects the contents of all yaml files in the "crds"
+// directories of a packaged chart (.tgz), including those of subcharts
+// unpacked within the archive, in lexical order.
+func readArchiveCRDs(archivePath string) ([]string, error) {
+	f, err := os.Open(archivePath)
 	if err != nil {
-		return nil, fmt.Errorf(`templating helm chart at %s: %w`, templateOpts.Chart, err)
+		return nil, fmt.Errorf(`opening chart archive %s: %w`, archivePath, err)
 	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf(`creating gzip reader for chart archive %s: %w`, archivePath, err)
+	}
+	defer gzr.Close()
 
-	// join all the yaml together with "---"
-	allYAMLEntries := append(crds, template)
-	unifiedYAMLString := strings.TrimSpace(strings.Join(allYAMLEntries, "\n---\n"))
+	// archive paths are of the form <chart>/crds/... or <chart>/charts/<subchart>/crds/...
+	crdsByPath := map[string]string{}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf(`reading chart archive %s: %w`, archivePath, err)
+		}
+		segments := strings.Split(path.Clean(header.Name), "/")
+		if header.Typeflag != tar.TypeReg || !isYAMLFile(header.Name) || !isArchiveCRDPath(segments) {
+			continue
+		}
+		crd, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf(`reading %s from chart archive %s: %w`, header.Name, archivePath, err)
+		}
+		crdsByPath[header.Name] = string(crd)
+	}
 
-	// convert to maps and remove all nils
-	var maps, noNils []map[string]interface{}
-	maps, err = yamlPlus.DecodeMaps([]byte(unifiedYAMLString))
-	if err != nil {
-		return nil, fmt.Errorf(`parsing output of "helm template": %w`, err)
+	var paths, crds []string
+	for crdPath := range crdsByPath {
+		paths = append(paths, crdPath)
 	}
-	for _, m := range maps {
-		if m != nil {
-			noNils = append(noNils, m)
+	sort.Strings(paths)
+	for _, crdPath := range paths {
+		crds = append(crds, crdsByPath[crdPath])
+	}
+
+	return crds, nil
+}
+
+// isArchiveCRDPath determines if the path segments of a file in a chart
+// archive are within a "crds" directory of the chart or one of its subcharts.
+// e.g: [<chart> crds foo.yaml] or [<chart> charts <subchart> crds foo.yaml]
+func isArchiveCRDPath(segments []string) bool {
+	for idx := 1; idx < len(segments)-1; idx += 2 {
+		switch segments[idx] {
+		case "crds":
+			return true
+		case "charts":
+			continue
+		default:
+			return false
 		}
 	}
+	return false
+}
 
-	return noNils, nil
+// isYAMLFile determines if the file name has a yaml extension.
+func isYAMLFile(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	return extension == ".yaml" || extension == ".yml"
 }
 
 // Template runs `helm template` on the chart specified by opts.
 // Returns the string output of stdout for `helm template`.
 // Will have a non-nil error if an error occurs when running the command or the
-// command outputs ANYTHING to stdout.
+// command outputs anything to stderr other than benign warnings (or anything
+// at all when opts.StrictStderr is set). Warnings are emitted as
+// TemplateWarning events; see TemplateWithWarnings to receive them directly.
 //
 // NOTE in Helm 3, CRDs in the "crds" directory of the chart are not outputted
 // from `helm template` but are installed via `helm install`
 func Template(opts TemplateOptions) (string, error) {
-	templateArgs := []string{"template"}
-	if opts.Repo != "" {
-		// if an existing helm repo exists on the helm client, use that for templating
-		existingRepo, err := FindRepoNameByURL(opts.Repo)
+	_, output, err := TemplateWithWarnings(opts)
+	return output, err
+}
+
+// TemplateWithWarnings is the same as Template but also returns the benign
+// warnings helm wrote to stderr (e.g. "WARNING: This chart is deprecated").
+func TemplateWithWarnings(opts TemplateOptions) ([]Warning, string, error) {
+	end, err := beginOperation()
+	if err != nil {
+		return nil, "", fmt.Errorf(`templating helm chart %s: %w`, opts.Chart, err)
+	}
+	defer end()
+	start := time.Now()
+	opts.emit(Event{Type: TemplateStarted, Time: start})
+	warnings, output, err := runTemplate(opts)
+	for _, warning := range warnings {
+		opts.emit(Event{Type: TemplateWarning, Message: warning.Message})
+	}
+	opts.emit(Event{Type: TemplateFinished, Duration: time.Since(start), Err: err})
+	return warnings, output, err
+}
+
+// runTemplate runs `helm template` for TemplateWithWarnings.
+func runTemplate(opts TemplateOptions) ([]Warning, string, error) {
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.Repo, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return nil, "", err
+	}
+	opts, err := opts.withCapabilitiesFile()
+	if err != nil {
+		return nil, "", err
+	}
+	if opts, err = opts.withResolvedVersion(); err != nil {
+		return nil, "", err
+	}
+	opts, removeCheckout, err := opts.withGitChart()
+	if err != nil {
+		return nil, "", err
+	}
+	defer removeCheckout()
+	opts, removeExtracted, err := opts.withChartArchive()
+	if err != nil {
+		return nil, "", err
+	}
+	defer removeExtracted()
+	if len(remoteValues(opts.Values)) > 0 || len(opts.valuesTemplates()) > 0 {
+		valuesDir, err := mkdirTemp("fabrikate")
 		if err != nil {
-			return "", fmt.Errorf(`searching existing helm repositories for %s: %w`, opts.Repo, err)
+			return nil, "", fmt.Errorf(`creating temporary directory for values URLs and templates: %w`, err)
 		}
-		if existingRepo != "" {
-			opts.Chart = existingRepo + "/" + opts.Chart
-		} else {
-			// if an existing repo is not found, use the --repo option to pull from network
-			templateArgs = append(templateArgs, "--repo", opts.Repo)
+		defer removeTemp(valuesDir)
+		if opts.Values, err = opts.downloadValues(valuesDir); err != nil {
+			return nil, "", err
 		}
 	}
-	if opts.Namespace != "" {
-		templateArgs = append(templateArgs, "--create-namespace", "--namespace", opts.Namespace)
+	memoized := opts // the chart of opts before it is resolved, identifying it in the template cache
+	if opts.ChartCache != nil && (opts.Repo != "" || IsOCI(opts.Chart)) {
+		chartPath, err := opts.ChartCache.Pull(opts.pullOptions())
+		if err == nil {
+			if err := opts.recordCachedChart(chartPath); err != nil {
+				return nil, "", err
+			}
+			opts.Repo, opts.Chart, opts.Version = "", chartPath, ""
+		} else if !errors.Is(err, ErrNotCacheable) {
+			return nil, "", err
+		}
 	}
-	for _, set := range opts.Set {
-		templateArgs = append(templateArgs, "--set", set)
+	opts, removePulled, err := opts.withProvenance()
+	if err != nil {
+		return nil, "", err
 	}
-	for _, yamlPath := range opts.Values {
-		templateArgs = append(templateArgs, "--values", yamlPath)
+	defer removePulled()
+	if err := requireFeatures(opts.requiredFeatures()); err != nil {
+		opts.emit(Event{Type: ValidationFailed, Err: err})
+		return nil, "", fmt.Errorf(`templating helm chart %s: %w`, opts.Chart, err)
 	}
+	isolated, err := openIsolatedConfig(opts.IsolatedConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	defer isolated.Close()
 
-	// a helm release [NAME] is specified as an optional leading parameter to the [CHART]
-	if opts.Release != "" {
-		templateArgs = append(templateArgs, opts.Release)
+	// dependencies of remote charts are packaged with the chart
+	if opts.DependencyUpdate && opts.Repo == "" && !IsOCI(opts.Chart) {
+		if err := dependencyUpdate(isolated, opts.Chart); err != nil {
+			return nil, "", fmt.Errorf(`updating dependencies of helm chart %s: %w`, opts.Chart, err)
+		}
 	}
-	templateArgs = append(templateArgs, opts.Chart)
 
-	templateCmd := exec.Command("helm", templateArgs...)
-	var stdout, stderr bytes.Buffer
-	templateCmd.Stdout = &stdout
-	templateCmd.Stderr = &stderr
+	repo, chart, version, err := resolveChart(opts.Repo, opts.Chart, opts.Version, opts.repoAuth(), isolated.searchHost())
+	if err != nil {
+		return nil, "", err
+	}
+	opts.Repo, opts.Chart, opts.Version = repo, chart, version
+	opts.emit(Event{Type: ChartResolved})
+	valuesMaps, err := resolveValues(opts.ValuesMap, opts.ValuesResolvers)
+	if err != nil {
+		return nil, "", err
+	}
+	var valuesPaths []string
+	if len(valuesMaps) > 0 {
+		valuesDir, err := mkdirTemp("fabrikate")
+		if err != nil {
+			return nil, "", fmt.Errorf(`creating temporary directory for in-memory values: %w`, err)
+		}
+		defer removeTemp(valuesDir)
+		if valuesPaths, err = writeValuesFiles(valuesDir, valuesMaps); err != nil {
+			return nil, "", err
+		}
+	}
+	opts, valuesPaths, sandboxDir, removeSandbox, err := opts.Sandbox.withReadOnlyDir(opts, valuesPaths)
+	if err != nil {
+		return nil, "", err
+	}
+	defer removeSandbox()
+	templateArgs := opts.args(valuesPaths)
+	templateCmd := isolated.command(templateArgs...)
+	if sandboxDir != "" {
+		templateCmd.Dir = sandboxDir
+	}
 
-	if err := templateCmd.Run(); err != nil {
-		return "", fmt.Errorf(`running "%s": %v: %v`, templateCmd, err, stderr.String())
+	var memo templateMemo
+	var key string
+	cached := false
+	if opts.TemplateCache != nil && memoized.memoizable() {
+		if key, err = memoized.memoKey(valuesMaps); err != nil {
+			return nil, "", err
+		}
+		if cached, err = getTemplateMemo(opts.TemplateCache, key, &memo); err != nil {
+			return nil, "", fmt.Errorf(`reading output of helm chart %s from template cache: %w`, opts.Chart, err)
+		}
 	}
-	if stderr.Len() != 0 {
-		return "", fmt.Errorf(`"%s" exited with output to stderr: %s`, templateCmd, stderr.String())
+	if !cached {
+		var stdout, stderr bytes.Buffer
+		templateCmd.Stdout = &stdout
+		templateCmd.Stderr = &stderr
+
+		if err := opts.Sandbox.run(templateCmd, &stderr); errors.Is(err, ErrSandboxLimit) {
+			return nil, "", err
+		} else if err != nil {
+			return nil, "", newCommandError(templateCmd, stderr.String(), err)
+		}
+		warnings, unrecognized := classifyStderrWarnings(stderr.String())
+		if len(unrecognized) > 0 || (opts.StrictStderr && len(warnings) > 0) {
+			return nil, "", fmt.Errorf(`"%s" exited with output to stderr: %s`, redactCommand(templateCmd), stderr.String())
+		}
+		memo = templateMemo{Output: stdout.String(), Warnings: warnings}
+		if key != "" {
+			if err := setTemplateMemo(opts.TemplateCache, key, memo); err != nil {
+				return nil, "", fmt.Errorf(`caching output of helm chart %s: %w`, opts.Chart, err)
+			}
+		}
 	}
 
-	return stdout.String(), nil
+	output := memo.Output
+	if opts.PostRenderFunc != nil {
+		rendered, err := opts.PostRenderFunc([]byte(memo.Output))
+		if err != nil {
+			return nil, "", fmt.Errorf(`post-rendering output of "%s": %w`, redactCommand(templateCmd), err)
+		}
+		output = string(rendered)
+	}
+	if err := opts.Limits.enforce(opts, output); err != nil {
+		return nil, "", fmt.Errorf(`templating helm chart %s: %w`, opts.Chart, err)
+	}
+
+	return memo.Warnings, output, nil
+}
+
+// templateMemo is the output of `helm template` stored in a template cache.
+type templateMemo struct {
+	Output   string    `json:"output"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// memoizable determines if the output of `helm template` for opts can be
+// memoized: only charts of exact versions in repositories render the same
+// output for the same options. Local charts change and post-renderers are
+// arbitrary executables.
+func (opts TemplateOptions) memoizable() bool {
+	return (opts.Repo != "" || IsOCI(opts.Chart)) && isCacheable(opts.Version) && opts.PostRenderer == ""
+}
+
+// memoKey returns the template cache key of the output of `helm template` for
+// opts (before the chart is resolved) with the resolved in-memory values. The
+// key covers the version of helm, the chart version, the flags of opts except
+// credentials and the contents of all values files.
+func (opts TemplateOptions) memoKey(valuesMaps []map[string]interface{}) (string, error) {
+	v, err := Version()
+	if err != nil {
+		return "", err
+	}
+	opts.Username, opts.Password, opts.PassCredentials = "", "", false
+	opts.CAFile, opts.CertFile, opts.KeyFile, opts.InsecureSkipTLSVerify = "", "", "", false
+	parts := append([]string{"template", v.Version}, opts.args(nil)...)
+	for _, valuesPath := range opts.Values {
+		content, err := os.ReadFile(valuesPath)
+		if err != nil {
+			return "", fmt.Errorf(`reading values file %s: %w`, valuesPath, err)
+		}
+		parts = append(parts, string(content))
+	}
+	for _, valueMap := range valuesMaps {
+		content, err := yaml.Marshal(valueMap)
+		if err != nil {
+			return "", fmt.Errorf(`marshalling values %+v: %w`, valueMap, err)
+		}
+		parts = append(parts, string(content))
+	}
+	return cache.Key(parts...), nil
 }
 
-func injectNamespace(manifest map[string]interface{}, namespace string) (map[string]interface{}, error) {
-	if manifest == nil {
-		return nil, nil
+// getTemplateMemo reads the memoized output of key from c into memo,
+// returning false if it is not cached.
+func getTemplateMemo(c cache.Cache, key string, memo *templateMemo) (bool, error) {
+	content, err := c.Get(key)
+	if errors.Is(err, cache.ErrMiss) {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
-	// inject the metadata map if it is not present
-	if _, ok := manifest["metadata"]; !ok {
-		manifest["metadata"] = map[string]interface{}{}
+	if err := json.Unmarshal(content, memo); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setTemplateMemo stores memo in c as key.
+func setTemplateMemo(c cache.Cache, key string, memo templateMemo) error {
+	content, err := json.Marshal(memo)
+	if err != nil {
+		return err
 	}
+	return c.Set(key, content, 0)
+}
 
-	metadata, ok := manifest["metadata"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf(`reflecting metadata of yaml manifest: %+v`, manifest)
+// ResolveTemplateVersion returns opts with a Version constraint (e.g.
+// "~1.2.x" or ">=4.0.0 <5.0.0") of a chart in an http(s) repository resolved
+// to the newest matching version in the repository index, as TemplateWithCRDs
+// and Template do when ResolveVersion is set. The index is downloaded with the
+// credentials and TLS options of opts, or those the repository was added to
+// the host helm client with. Resolving before pulling allows constraints to be
+// cached by ChartCache and TemplateCache. Exact versions, local charts and
+// charts of OCI registries are returned unchanged. See TemplateWithVersion to
+// get the version rendered alongside the output.
+func ResolveTemplateVersion(opts TemplateOptions) (TemplateOptions, error) {
+	if !isVersionConstraint(opts.Repo, opts.Chart, opts.Version) {
+		return opts, nil
 	}
-	if metadata["namespace"] != nil {
-		return nil, fmt.Errorf(`existing namespace found in yaml: %+v`, manifest)
+	auth := opts.repoAuth()
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.Repo, opts.Chart), &auth.username, &auth.password); err != nil {
+		return opts, err
+	}
+	version, err := resolveVersionConstraint(opts.Repo, opts.Chart, opts.Version, auth, !opts.IsolatedConfig, opts.Devel)
+	if err != nil {
+		return opts, err
 	}
-	metadata["namespace"] = namespace
+	opts.Version, opts.ResolveVersion = version, false
+	return opts, nil
+}
 
-	return manifest, nil
+// withResolvedVersion returns opts with a Version constraint resolved to an
+// exact version when ResolveVersion is set.
+func (opts TemplateOptions) withResolvedVersion() (TemplateOptions, error) {
+	if !opts.ResolveVersion {
+		return opts, nil
+	}
+	return ResolveTemplateVersion(opts)
 }
 
-func injectNamespaceBack(unifiedManifest string, namespace string) (string, error) {
-	manifests, err := yamlPlus.DecodeMaps([]byte(unifiedManifest))
+// TemplateCommand returns the arguments `helm template` would be executed
+// with for opts, without running it. Useful for debugging, auditing and
+// asserting on the generated command in tests.
+// The chart is resolved the same way as Template (which may list the
+// repositories on the host helm client) but nothing is pulled or cached and
+// DependencyUpdate is not run. In-memory values (ValuesMap) are written to
+// temporary files when templating, so they are represented by the
+// placeholders "<ValuesMap[0]>", "<ValuesMap[1]>", etc. and charts of a
+// GitURL by "<GitURL@GitRef>/GitPath". Passwords are redacted.
+func TemplateCommand(opts TemplateOptions) ([]string, error) {
+	if err := resolveCredentials(opts.Credentials, credentialURL(opts.Repo, opts.Chart), &opts.Username, &opts.Password); err != nil {
+		return nil, err
+	}
+	opts, err := opts.withCapabilitiesFile()
+	if err != nil {
+		return nil, err
+	}
+	if opts.GitURL != "" {
+		// the checkout of the repository is represented by a placeholder
+		opts.Chart = strings.TrimSuffix(fmt.Sprintf("<%s@%s>/%s", opts.GitURL, opts.GitRef, opts.GitPath), "/")
+		opts.Repo, opts.Version = "", ""
+	}
+	repo, chart, version, err := resolveChart(opts.Repo, opts.Chart, opts.Version, opts.repoAuth(), !opts.IsolatedConfig)
 	if err != nil {
-		return "", fmt.Errorf(`unmarshalling yaml into []map[string]interface{}: %s: %w`, unifiedManifest, err)
+		return nil, err
 	}
+	opts.Repo, opts.Chart, opts.Version = repo, chart, version
 
-	// add namespace to manifest metdata ONLY if it does not already have one
-	var withInjectedNS []string
-	for _, manifest := range manifests {
-		// nil? create the metadata map
-		if manifest["metadata"] == nil {
-			manifest["metadata"] = map[string]interface{}{
-				"namespace": namespace,
-			}
-		} else {
-			metadata, ok := manifest["metadata"].(map[string]interface{})
-			// only add the namespace if the "metadata" is a map and namespace is not set
-			if !ok {
-				// its not a map[string]interface{}? error!
-				return "", fmt.Errorf(`"metadata" of manifest is not a map[string]interface{}: %+v`, manifest)
-			} else if ok && metadata["namespace"] == nil || metadata["namespace"] == "" {
-				// is it a map[string]interface{} with a zero value for "namespace"? set the namespace
-				metadata["namespace"] = namespace
-			}
-		}
+	valuesPaths := make([]string, len(opts.ValuesMap))
+	for idx := range opts.ValuesMap {
+		valuesPaths[idx] = fmt.Sprintf("<ValuesMap[%d]>", idx)
+	}
+	return redactArgs(opts.args(valuesPaths)), nil
+}
 
-		marshalBytes, err := yaml.Marshal(manifest)
-		if err != nil {
-			return "", fmt.Errorf(`marshalling yaml for %+v: %w`, manifest, err)
+// args builds the arguments for `helm template` from opts, which must already
+// have the chart resolved (see resolveChart). valuesPaths are the files the
+// in-memory values were written to.
+func (opts TemplateOptions) args(valuesPaths []string) []string {
+	templateArgs := []string{"template"}
+	if opts.Repo != "" {
+		templateArgs = append(templateArgs, "--repo", opts.Repo)
+	}
+	templateArgs = append(templateArgs, opts.repoAuth().args()...)
+	if opts.Version != "" {
+		templateArgs = append(templateArgs, "--version", opts.Version)
+	}
+	if opts.Devel {
+		templateArgs = append(templateArgs, "--devel")
+	}
+	if opts.Namespace != "" {
+		templateArgs = append(templateArgs, "--create-namespace", "--namespace", opts.Namespace)
+	}
+	for _, set := range opts.Set {
+		templateArgs = append(templateArgs, "--set", set)
+	}
+	for _, yamlPath := range opts.Values {
+		templateArgs = append(templateArgs, "--values", yamlPath)
+	}
+	for _, yamlPath := range valuesPaths {
+		templateArgs = append(templateArgs, "--values", yamlPath)
+	}
+	if opts.KubeVersion != "" {
+		templateArgs = append(templateArgs, "--kube-version", opts.KubeVersion)
+	}
+	for _, apiVersion := range opts.APIVersions {
+		templateArgs = append(templateArgs, "--api-versions", apiVersion)
+	}
+	if opts.IncludeCRDs {
+		templateArgs = append(templateArgs, "--include-crds")
+	}
+	if opts.NoHooks {
+		templateArgs = append(templateArgs, "--no-hooks")
+	}
+	for _, template := range opts.ShowOnly {
+		templateArgs = append(templateArgs, "--show-only", template)
+	}
+	if opts.PostRenderer != "" {
+		templateArgs = append(templateArgs, "--post-renderer", opts.PostRenderer)
+		for _, arg := range opts.PostRendererArgs {
+			templateArgs = append(templateArgs, "--post-renderer-args", arg)
 		}
-		withInjectedNS = append(withInjectedNS, string(marshalBytes))
 	}
 
-	return strings.Join(withInjectedNS, "\n---\n"), nil
-
-	// split the unified manifest string by "---"
-	dividerRgx := regexp.MustCompile(`^---$`)
-	manifestStrings := dividerRgx.Split(unifiedManifest, -1)
+	// a helm release [NAME] is specified as an optional leading parameter to the [CHART]
+	if opts.Release != "" {
+		templateArgs = append(templateArgs, opts.Release)
+	}
+	return append(templateArgs, opts.Chart)
+}
 
-	// parse and inject the namespace into the parsed map
-	var injectedManifests []string
-	for _, entry := range manifestStrings {
-		var m map[interface{}]interface{}
-		if err := yaml.Unmarshal([]byte(entry), &m); err != nil {
-			return "", fmt.Errorf(`unmarshalling YAML string %s: %w`, entry, err)
-		}
-		if m["metadata"] != nil {
-			metadata, ok := m["metadata"].(map[string]interface{})
-			if !ok {
-				return "", fmt.Errorf(`reflecting metadata of yaml manifest: %+v`, m)
-			}
-			if metadata["namespace"] == nil {
-				metadata["namespace"] = namespace
-			}
+// resolveChart resolves the chart reference and --repo passed to helm
+// commands for chart in repo:
+//   - charts in OCI registries are referenced directly (oci://...) without --repo
+//   - charts in a repository already added to the host helm client are
+//     referenced as <repo name>/<chart> without --repo, unless auth is set as
+//     credentials and TLS options are only used with --repo, or searchHost is
+//     false (e.g. when using an isolated helm config)
+//   - otherwise --repo is used to pull from the network
+// The returned repo is empty if --repo should not be used.
+func resolveChart(repo string, chart string, version string, auth repoAuth, searchHost bool) (string, string, string, error) {
+	if IsOCI(repo) || IsOCI(chart) {
+		// OCI registries cannot be added as helm repos; reference the chart directly
+		ref, ociVersion, err := ociChartRef(repo, chart, version)
+		if err != nil {
+			return "", "", "", err
 		}
-		asBytes, err := yaml.Marshal(m)
+		return "", ref, ociVersion, nil
+	}
+	if repo != "" && !auth.isSet() && searchHost {
+		// if an existing helm repo exists on the helm client, use that
+		existingRepo, err := FindRepoNameByURL(repo)
 		if err != nil {
-			return "", fmt.Errorf(`marshalling namespace injected YAML %+v: %w`, m, err)
+			return "", "", "", fmt.Errorf(`searching existing helm repositories for %s: %w`, repo, err)
+		}
+		if existingRepo != "" {
+			return "", existingRepo + "/" + chart, version, nil
 		}
-		injectedManifests = append(injectedManifests, string(asBytes))
 	}
-
-	// re-join the strings with "---"
-	withNS := strings.TrimSpace(strings.Join(injectedManifests, "\n---\n"))
-
-	return strings.TrimSpace(withNS), nil
+	return repo, chart, version, nil
 }
 
-// cleanManifest parses either a yaml document (or list of documents delimitted
-// by "---") and removes entries that are not of type map[string]interface{}.
-//
-// TODO find out if this is needed in helm 3
-func cleanManifest(manifest string) (string, error) {
-	// split based on yaml divider
-	manifests := strings.Split(manifest, "\n---")
-
-	// remove all invalid yaml
-	var cleaned []string
-	for _, entry := range manifests {
-		var m map[string]interface{}
-		// if it doesn't unmarshal properly, do not add
-		if err := yaml.Unmarshal([]byte(entry), &m); err != nil {
-			continue
-		}
-		// only append documents with a non-empty body
-		if len(strings.TrimSpace(entry)) > 0 {
-			cleaned = append(cleaned, entry)
+// resolveValues applies resolvers in order to each of values.
+func resolveValues(values []map[string]interface{}, resolvers []ValuesResolver) ([]map[string]interface{}, error) {
+	resolved := make([]map[string]interface{}, len(values))
+	for idx, valueMap := range values {
+		for _, resolver := range resolvers {
+			var err error
+			if valueMap, err = resolver.ResolveValues(valueMap); err != nil {
+				return nil, fmt.Errorf(`resolving values: %w`, err)
+			}
 		}
+		resolved[idx] = valueMap
 	}
-
-	// re-join based on yaml divider
-	joined := strings.TrimSpace(strings.Join(cleaned, "\n---"))
-	return joined, nil
+	return resolved, nil
 }
 
-func createNamespace(name string) map[string]interface{} {
-	return map[string]interface{}{
-		"apiVersion": "v1",
-		"kind":       "Namespace",
-		"metadata": map[string]interface{}{
-			"name": name,
-		},
+// writeValuesFiles marshals each of values to a yaml file in dir and returns
+// the paths of the written files in the same order.
+func writeValuesFiles(dir string, values []map[string]interface{}) ([]string, error) {
+	var paths []string
+	for idx, valueMap := range values {
+		valuesBytes, err := yaml.Marshal(valueMap)
+		if err != nil {
+			return nil, fmt.Errorf(`marshalling values %+v: %w`, valueMap, err)
+		}
+		valuesPath := filepath.Join(dir, fmt.Sprintf("values-%d.yaml", idx))
+		if err := os.WriteFile(valuesPath, valuesBytes, 0600); err != nil {
+			return nil, fmt.Errorf(`writing values file %s: %w`, valuesPath, err)
+		}
+		paths = append(paths, valuesPath)
 	}
+	return paths, nil
 }
@@ -1,12 +1,19 @@
 package helm
 
 import (
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/evanlouie/go/pkg/cache"
+	"github.com/evanlouie/go/pkg/helm/helmtest"
+	"github.com/evanlouie/go/pkg/manifest"
 )
 
 var (
@@ -50,122 +57,16 @@ var (
 	}
 )
 
-func Test_injectNamespace(t *testing.T) {
-	type args struct {
-		manifest  map[string]interface{}
-		namespace string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    map[string]interface{}
-		wantErr bool
-	}{
-		{
-			name:    "empty",
-			args:    args{},
-			want:    nil,
-			wantErr: false,
-		},
-		{
-			name: "empty-map",
-			args: args{
-				manifest:  map[string]interface{}{},
-				namespace: "foo",
-			},
-			want: map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"namespace": "foo",
-				},
-			},
-			wantErr: false,
-		},
-
-		{
-			name: "with-metadata-no-namespace",
-			args: args{
-				manifest: map[string]interface{}{
-					"metadata": map[string]interface{}{
-						"name": "nginx-deployment",
-						"labels": map[string]interface{}{
-							"app": "nginx",
-						},
-					},
-				},
-				namespace: "foo",
-			},
-			want: map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"name": "nginx-deployment",
-					"labels": map[string]interface{}{
-						"app": "nginx",
-					},
-					"namespace": "foo",
-				},
-			},
-			wantErr: false,
-		},
-
-		{
-			name: "with-metadata-with-namespace",
-			args: args{
-				manifest: map[string]interface{}{
-					"metadata": map[string]interface{}{
-						"name": "nginx-deployment",
-						"labels": map[string]interface{}{
-							"app": "nginx",
-						},
-						"namespace": "already has a NS",
-					},
-				},
-				namespace: "foo",
-			},
-			want:    nil,
-			wantErr: true,
-		},
-
-		{
-			name: "with-invalid-metadata-type",
-			args: args{
-				manifest: map[string]interface{}{
-					"metadata": []map[string]interface{}{
-						{
-							"name": "nginx-deployment",
-							"labels": map[string]interface{}{
-								"app": "nginx",
-							},
-						},
-					},
-				},
-				namespace: "foo",
-			},
-			want:    nil,
-			wantErr: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := injectNamespace(tt.args.manifest, tt.args.namespace)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("injectNamespace() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("injectNamespace() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func Test_cleanManifest(t *testing.T) {
+func TestSanitize(t *testing.T) {
 	type args struct {
 		manifest string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
+		name        string
+		args        args
+		want        string
+		wantRemoved int
+		wantErr     bool
 	}{
 		{
 			name:    "empty",
@@ -207,18 +108,46 @@ bar: I am valid
 ---
 baz: I am valid
 `),
-			wantErr: false,
+			wantRemoved: 3,
+			wantErr:     false,
+		},
+		{
+			name: "separators",
+			args: args{
+				manifest: `# leading comment
+--- {foo: inline}
+---
+---
+bar: baz
+...
+`,
+			},
+			want:        "{foo: inline}\n---\nbar: baz",
+			wantRemoved: 1,
+			wantErr:     false,
+		},
+		{
+			name: "invalid-yaml",
+			args: args{
+				manifest: `
+foo: [
+`,
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := cleanManifest(tt.args.manifest)
+			got, removed, err := Sanitize(tt.args.manifest)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("cleanManifest() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Sanitize() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("cleanManifest() = %v, want %v", got, tt.want)
+				t.Errorf("Sanitize() = %v, want %v", got, tt.want)
+			}
+			if len(removed) != tt.wantRemoved {
+				t.Errorf("Sanitize() removed = %v, want %d removed", removed, tt.wantRemoved)
 			}
 		})
 	}
@@ -231,7 +160,7 @@ func TestTemplateWithCRDs(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    []interface{}
+		want    []map[string]interface{}
 		wantErr bool
 	}{
 		{
@@ -247,8 +176,8 @@ func TestTemplateWithCRDs(t *testing.T) {
 				Release: "random-chart",
 				Set:     []string{"testValue=foobar"},
 			}},
-			[]interface{}{
-				map[string]interface{}{
+			[]map[string]interface{}{
+				{
 					"apiVersion": "apiextensions.k8s.io/v1beta1",
 					"kind":       "CustomResourceDefinition",
 					"metadata": map[string]interface{}{
@@ -265,7 +194,7 @@ func TestTemplateWithCRDs(t *testing.T) {
 						"scope": "Namespaced",
 					},
 				},
-				map[string]interface{}{
+				{
 					"apiVersion": "apiextensions.k8s.io/v1beta1",
 					"kind":       "CustomResourceDefinition",
 					"metadata": map[string]interface{}{
@@ -282,7 +211,7 @@ func TestTemplateWithCRDs(t *testing.T) {
 						"scope": "Namespaced",
 					},
 				},
-				map[string]interface{}{
+				{
 					"apiVersion": "v1",
 					"kind":       "Service",
 					"metadata": map[string]interface{}{
@@ -292,7 +221,7 @@ func TestTemplateWithCRDs(t *testing.T) {
 						"testValue": "foobar",
 					},
 				},
-				map[string]interface{}{
+				{
 					"apiVersion": "apps/v1",
 					"kind":       "Deployment",
 					"metadata": map[string]interface{}{
@@ -321,3 +250,352 @@ func TestTemplateWithCRDs(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateManifests(t *testing.T) {
+	crd := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.stable.example.com
+`
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: apps
+`
+	testHook := `apiVersion: v1
+kind: Pod
+metadata:
+  name: app-test
+  annotations:
+    helm.sh/hook: test
+`
+	tests := []struct {
+		name    string
+		version string // version of the fake helm
+		chart   helmtest.Chart
+		opts    TemplateOptions
+		output  string // printed by `helm template` after the CRDs
+		stderr  string // fails the template if set
+		want    []string
+		wantErr string
+	}{
+		{
+			"crds from --include-crds",
+			"v3.7.1",
+			helmtest.Chart{CRDs: map[string]string{"crontab.yaml": crd}},
+			TemplateOptions{},
+			configMap,
+			"",
+			[]string{"CustomResourceDefinition//crontabs.stable.example.com", "ConfigMap/apps/app"},
+			"",
+		},
+		{
+			"crds read from chart",
+			"v3.0.3",
+			helmtest.Chart{CRDs: map[string]string{"crontab.yaml": crd}},
+			TemplateOptions{},
+			configMap,
+			"",
+			[]string{"CustomResourceDefinition//crontabs.stable.example.com", "ConfigMap/apps/app"},
+			"",
+		},
+		{"empty documents", "v3.7.1", helmtest.Chart{}, TemplateOptions{}, "---\n---\n" + configMap + "---\n", "", []string{"ConfigMap/apps/app"}, ""},
+		{"test hooks", "v3.7.1", helmtest.Chart{}, TemplateOptions{HookFilter: TestHooks}, configMap + "---\n" + testHook, "", []string{"ConfigMap/apps/app"}, ""},
+		{"no output", "v3.7.1", helmtest.Chart{}, TemplateOptions{}, "", "", nil, ""},
+		{"malformed", "v3.7.1", helmtest.Chart{}, TemplateOptions{}, "kind: [ConfigMap\n", "", nil, "parsing output"},
+		{"helm error", "v3.7.1", helmtest.Chart{}, TemplateOptions{}, "", "Error: template: app/templates/configmap.yaml:4:3: executing", nil, "configmap.yaml:4:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the fake helm prints the CRDs of the chart, the last argument, only
+			// with --include-crds like helm does
+			useFakeHelm(t, `case "$1" in
+version) echo 'version.BuildInfo{Version:"`+tt.version+`", GitCommit:"abc123", GitTreeState:"clean", GoVersion:"go1.16.9"}' ;;
+template)
+	for chart; do :; done
+	case "$*" in *--include-crds*) [ -d "$chart/crds" ] && cat "$chart"/crds/* && echo '---' ;; esac
+	printf '%s' '`+tt.output+`'
+	[ -z '`+tt.stderr+`' ] || { echo '`+tt.stderr+`' >&2; exit 1; } ;;
+esac`)
+			opts := tt.opts
+			opts.Chart = helmtest.NewChart(t, tt.chart)
+			got, err := TemplateManifests(opts)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("TemplateManifests() error = %v, want %q", err, tt.wantErr)
+			}
+			var ids []string
+			for _, m := range got {
+				ids = append(ids, m.Kind()+"/"+m.Namespace()+"/"+m.Name())
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("TemplateManifests() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+}
+
+func Test_readChartCRDs(t *testing.T) {
+	chartPath := helmtest.NewChart(t, helmtest.Chart{
+		Name: "parent",
+		CRDs: map[string]string{"parent.yaml": "kind: Parent"},
+		Subcharts: []helmtest.Chart{{
+			Name:      "dir-chart",
+			Templates: map[string]string{"x.yaml": "kind: NotACRD"},
+			CRDs:      map[string]string{"dir.yml": "kind: Dir", "README.md": "not yaml"},
+		}},
+	})
+
+	// packaged subchart with its own nested subchart
+	_, err := helmtest.PackageChart(filepath.Join(chartPath, "charts"), helmtest.Chart{
+		Name:      "tgz-chart",
+		Templates: map[string]string{"crds/not-a-crd.yaml": "kind: NotACRD"},
+		CRDs:      map[string]string{"tgz.yaml": "kind: Tgz"},
+		Subcharts: []helmtest.Chart{{
+			Name: "nested",
+			CRDs: map[string]string{"nested.yaml": "kind: Nested"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readChartCRDs(chartPath)
+	if err != nil {
+		t.Fatalf("readChartCRDs() error = %v", err)
+	}
+	want := []string{"kind: Parent", "kind: Dir", "kind: Nested", "kind: Tgz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readChartCRDs() = %v, want %v", got, want)
+	}
+}
+
+func TestTemplateCommand(t *testing.T) {
+	type args struct {
+		opts TemplateOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "local-chart",
+			args: args{
+				opts: TemplateOptions{Release: "my-release", Chart: "./charts/my-chart"},
+			},
+			want: []string{"template", "my-release", "./charts/my-chart"},
+		},
+		{
+			name: "all-options",
+			args: args{
+				opts: TemplateOptions{
+					Release:          "my-release",
+					Chart:            "oci://ghcr.io/my-org/charts/my-chart:1.2.3",
+					Devel:            true,
+					Namespace:        "my-namespace",
+					Values:           []string{"values.yaml"},
+					Set:              []string{"foo=bar"},
+					ValuesMap:        []map[string]interface{}{{"foo": "bar"}, {"bar": "baz"}},
+					KubeVersion:      "1.22.0",
+					APIVersions:      []string{"monitoring.coreos.com/v1"},
+					IncludeCRDs:      true,
+					NoHooks:          true,
+					ShowOnly:         []string{"templates/deployment.yaml"},
+					PostRenderer:     "kustomize",
+					PostRendererArgs: []string{"build"},
+					Username:         "user",
+					Password:         "hunter2",
+				},
+			},
+			want: []string{
+				"template",
+				"--username", "user", "--password", "REDACTED",
+				"--version", "1.2.3",
+				"--devel",
+				"--create-namespace", "--namespace", "my-namespace",
+				"--set", "foo=bar",
+				"--values", "values.yaml",
+				"--values", "<ValuesMap[0]>", "--values", "<ValuesMap[1]>",
+				"--kube-version", "1.22.0",
+				"--api-versions", "monitoring.coreos.com/v1",
+				"--include-crds",
+				"--no-hooks",
+				"--show-only", "templates/deployment.yaml",
+				"--post-renderer", "kustomize", "--post-renderer-args", "build",
+				"my-release", "oci://ghcr.io/my-org/charts/my-chart",
+			},
+		},
+		{
+			name: "conflicting-oci-tag",
+			args: args{
+				opts: TemplateOptions{Chart: "oci://ghcr.io/my-org/charts/my-chart:1.2.3", Version: "2.0.0"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TemplateCommand(tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TemplateCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TemplateCommand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplate_templateCache(t *testing.T) {
+	// the fake chart renders a ConfigMap with the number of times it was templated
+	count := filepath.Join(t.TempDir(), "count")
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template)
+  echo x >> `+count+`
+  printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: render-%s\n' "$(wc -l < `+count+` | tr -d ' ')"
+  ;;
+esac`)
+	templateCache := &cache.Memory{}
+	base := TemplateOptions{Repo: "https://charts.example.com", Chart: "nginx", Version: "1.2.3", IsolatedConfig: true, TemplateCache: templateCache}
+
+	tests := []struct {
+		name   string
+		modify func(*TemplateOptions)
+		want   string
+	}{
+		{"miss", func(*TemplateOptions) {}, "render-1"},
+		{"hit", func(*TemplateOptions) {}, "render-1"},
+		{"hit-ignores-credentials", func(opts *TemplateOptions) { opts.Username, opts.Password = "user", "pass" }, "render-1"},
+		{"values-change-key", func(opts *TemplateOptions) { opts.ValuesMap = []map[string]interface{}{{"a": 1}} }, "render-2"},
+		{"local-chart-not-memoized", func(opts *TemplateOptions) { opts.Repo, opts.Version = "", "" }, "render-3"},
+		{"local-chart-not-memoized-again", func(opts *TemplateOptions) { opts.Repo, opts.Version = "", "" }, "render-4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := base
+			tt.modify(&opts)
+			got, err := Template(opts)
+			if err != nil {
+				t.Fatalf("Template() error = %v", err)
+			}
+			if !strings.Contains(got, "name: "+tt.want) {
+				t.Errorf("Template() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// newIndexServer starts an https chart repository serving index to clients
+// authenticating as user:pass, and returns its URL and the path of a CA
+// bundle of its certificate.
+func newIndexServer(t *testing.T, index string) (string, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, index)
+	}))
+	t.Cleanup(server.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL, caFile
+}
+
+func TestResolveTemplateVersion(t *testing.T) {
+	repo, caFile := newIndexServer(t, "apiVersion: v1\nentries:\n  web:\n    - {name: web, version: 5.0.0}\n    - {name: web, version: 4.3.0-beta.1}\n    - {name: web, version: 4.2.1}\n    - {name: web, version: 4.0.0}\n")
+	// the repository is added to the host helm client with its credentials
+	config := filepath.Join(t.TempDir(), "repositories.yaml")
+	if err := os.WriteFile(config, []byte(`repositories:
+  - name: web-repo
+    url: `+repo+`
+    username: user
+    password: pass
+    caFile: `+caFile+`
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+env) echo 'HELM_REPOSITORY_CONFIG="`+config+`"' ;;
+esac`)
+
+	auth := TemplateOptions{Repo: repo, Chart: "web", Username: "user", Password: "pass", CAFile: caFile, IsolatedConfig: true}
+	withVersion := func(opts TemplateOptions, version string, devel bool) TemplateOptions {
+		opts.Version, opts.Devel = version, devel
+		return opts
+	}
+	tests := []struct {
+		name    string
+		opts    TemplateOptions
+		want    string
+		wantErr bool
+	}{
+		{"range", withVersion(auth, ">=4.0.0 <5.0.0", false), "4.2.1", false},
+		{"devel", withVersion(auth, "^4", true), "4.3.0-beta.1", false},
+		{"exact", withVersion(auth, "4.0.0", false), "4.0.0", false},
+		{"local", TemplateOptions{Chart: "./web", Version: "^4"}, "^4", false},
+		{"host repository credentials", TemplateOptions{Repo: repo, Chart: "web", Version: "~4.0"}, "4.0.0", false},
+		{"isolated without credentials", TemplateOptions{Repo: repo, Chart: "web", Version: "~4.0", CAFile: caFile, IsolatedConfig: true}, "", true},
+		{"untrusted certificate", TemplateOptions{Repo: repo, Chart: "web", Version: "~4.0", Username: "user", Password: "pass", IsolatedConfig: true}, "", true},
+		{"unsatisfiable", withVersion(auth, "^6", false), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := ResolveTemplateVersion(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTemplateVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && resolved.Version != tt.want {
+				t.Errorf("ResolveTemplateVersion() version = %v, want %v", resolved.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateWithVersion(t *testing.T) {
+	repo, caFile := newIndexServer(t, "apiVersion: v1\nentries:\n  web:\n    - {name: web, version: 5.0.0}\n    - {name: web, version: 4.2.1}\n")
+	// the fake helm renders a ConfigMap named after the --version it is passed
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template)
+  while [ $# -gt 0 ]; do
+    if [ "$1" = "--version" ]; then printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "web-%s"\n' "$2"; fi
+    shift
+  done
+  ;;
+esac`)
+
+	tests := []struct {
+		name    string
+		resolve bool
+		want    string
+	}{
+		{"resolved", true, "4.2.1"},
+		{"resolved by helm", false, "^4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TemplateOptions{Repo: repo, Chart: "web", Version: "^4", ResolveVersion: tt.resolve, Username: "user", Password: "pass", CAFile: caFile, IsolatedConfig: true}
+			version, manifests, err := TemplateWithVersion(opts)
+			if err != nil {
+				t.Fatalf("TemplateWithVersion() error = %v", err)
+			}
+			if version != tt.want {
+				t.Errorf("TemplateWithVersion() version = %v, want %v", version, tt.want)
+			}
+			if got := manifest.FromMaps(manifests); len(got) != 1 || got[0].Name() != "web-"+tt.want {
+				t.Errorf("TemplateWithVersion() = %v, want a ConfigMap rendered from version %s", manifests, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// TemplateResult is the result of templating a single chart via TemplateAll.
+type TemplateResult struct {
+	Release   string                   // the release name of Options
+	Options   TemplateOptions          // the options the chart was templated with
+	Version   string                   // the version of the chart rendered. see TemplateWithVersion
+	Manifests []map[string]interface{} // the output of TemplateWithCRDs
+	Err       error                    // the error templating the chart, if any
+}
+
+// TemplateResults are the results of TemplateAll in the same order as the
+// options provided.
+type TemplateResults []TemplateResult
+
+// ByRelease returns the results keyed by release name.
+func (results TemplateResults) ByRelease() map[string]TemplateResult {
+	byRelease := make(map[string]TemplateResult, len(results))
+	for _, result := range results {
+		byRelease[result.Release] = result
+	}
+	return byRelease
+}
+
+// TemplateAllError is returned by TemplateAll when any chart fails to template.
+type TemplateAllError struct {
+	Failed []TemplateResult // the failed results in the order of the options provided
+}
+
+func (e *TemplateAllError) Error() string {
+	messages := make([]string, len(e.Failed))
+	for idx, result := range e.Failed {
+		messages[idx] = fmt.Sprintf("%s: %v", result.Release, result.Err)
+	}
+	return fmt.Sprintf(`templating %d helm chart(s) failed: %s`, len(e.Failed), strings.Join(messages, "; "))
+}
+
+// TemplateAll runs TemplateWithCRDs for every options in opts using a pool of
+// at most concurrency workers (runtime.NumCPU() if concurrency <= 0).
+// All charts are templated even if some fail. The results are returned in the
+// same order as opts along with a *TemplateAllError listing every failure.
+// Release names must be unique so results can be keyed by release; an empty
+// release is treated as helm's default "release-name".
+func TemplateAll(opts []TemplateOptions, concurrency int) (TemplateResults, error) {
+	results := make(TemplateResults, len(opts))
+	seen := map[string]bool{}
+	for idx, opt := range opts {
+		release := opt.Release
+		if release == "" {
+			release = defaultReleaseName
+		}
+		if seen[release] {
+			return nil, fmt.Errorf(`templating helm charts: duplicate release name "%s"`, release)
+		}
+		seen[release] = true
+		results[idx] = TemplateResult{Release: release, Options: opt}
+	}
+
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(opts); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				// each worker writes only to its own index of results
+				results[idx].Version, results[idx].Manifests, results[idx].Err = TemplateWithVersion(results[idx].Options)
+			}
+		}()
+	}
+	for idx := range opts {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed []TemplateResult
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &TemplateAllError{Failed: failed}
+	}
+	return results, nil
+}
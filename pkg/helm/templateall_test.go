@@ -0,0 +1,39 @@
+package helm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTemplateAll(t *testing.T) {
+	t.Run("duplicate releases", func(t *testing.T) {
+		_, err := TemplateAll([]TemplateOptions{{Chart: "foo"}, {Chart: "bar"}}, 2)
+		if err == nil {
+			t.Errorf("TemplateAll() error = nil, want error")
+		}
+	})
+
+	t.Run("failures are aggregated in order", func(t *testing.T) {
+		opts := []TemplateOptions{
+			{Release: "a", Chart: "testdata/template/does-not-exist-a"},
+			{Release: "b", Chart: "testdata/template/does-not-exist-b"},
+			{Release: "c", Chart: "testdata/template/does-not-exist-c"},
+		}
+		results, err := TemplateAll(opts, 2)
+		var allErr *TemplateAllError
+		if !errors.As(err, &allErr) {
+			t.Fatalf("TemplateAll() error = %v, want *TemplateAllError", err)
+		}
+		if len(allErr.Failed) != len(opts) {
+			t.Errorf("TemplateAll() failed = %d, want %d", len(allErr.Failed), len(opts))
+		}
+		for idx, result := range results {
+			if result.Release != opts[idx].Release || result.Err == nil {
+				t.Errorf("TemplateAll() result %d = %+v, want failed release %s", idx, result, opts[idx].Release)
+			}
+		}
+		if _, ok := results.ByRelease()["b"]; !ok {
+			t.Errorf("ByRelease() missing release b")
+		}
+	})
+}
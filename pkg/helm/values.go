@@ -0,0 +1,233 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	"github.com/evanlouie/go/pkg/manifest"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// MergeValues computes the effective values of a chart the way helm does for
+// multiple --values files, so callers can precompute what a chart will be
+// rendered with. Later maps take precedence (see yaml.Merge): maps are merged
+// recursively and any other value replaces the earlier one. A null value
+// deletes the key, which is how a values file removes a default of the chart.
+// Pass the defaults of the chart (e.g. from ShowValues) first:
+//   defaults, err := helm.ShowValues(helm.ShowOptions{Repo: repo, Chart: "nginx"})
+//   effective := helm.MergeValues(defaults, prodValues, overrides)
+// The merged maps are not modified. To write merged values back to a values
+// file which still removes chart defaults, use yaml.Merge which keeps nulls.
+func MergeValues(maps ...map[string]interface{}) map[string]interface{} {
+	merged := yamlPlus.Merge(maps...)
+	removeNulls(merged)
+	return merged
+}
+
+// removeNulls deletes the keys with null values of m and its nested maps.
+func removeNulls(m map[string]interface{}) {
+	for key, value := range m {
+		switch v := value.(type) {
+		case nil:
+			delete(m, key)
+		case map[string]interface{}:
+			removeNulls(v)
+		}
+	}
+}
+
+// ValuesSchemaFile is the JSON schema of the values of a chart, next to its
+// Chart.yaml.
+const ValuesSchemaFile = "values.schema.json"
+
+// ValuesError is returned by ValidateValues when values do not conform to the
+// values.schema.json of a chart.
+type ValuesError struct {
+	Chart  string                 // path of the chart
+	Errors []manifest.SchemaError // the violations, with JSON pointers to the violating values
+}
+
+// Code returns errcode.HelmValuesInvalid.
+func (e *ValuesError) Code() errcode.Code {
+	return errcode.HelmValuesInvalid
+}
+
+func (e *ValuesError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "values of helm chart %s do not conform to %s:", e.Chart, ValuesSchemaFile)
+	for _, err := range e.Errors {
+		b.WriteString("\n  - " + err.Error())
+	}
+	return b.String()
+}
+
+// ValidateValues validates values against the values.schema.json of the
+// chart at chartPath before templating, so invalid overrides are reported
+// with the path of each violation (e.g. "/image/tag: expected string, got
+// integer") rather than as a failed helm command. As helm does, the values are
+// merged over the defaults of the chart's values.yaml before validation.
+// Charts without a values.schema.json accept any values. Violations are
+// returned as a *ValuesError.
+func ValidateValues(chartPath string, values map[string]interface{}) error {
+	schemaPath := filepath.Join(chartPath, ValuesSchemaFile)
+	content, err := os.ReadFile(schemaPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf(`reading values schema of helm chart %s: %w`, chartPath, err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		return fmt.Errorf(`parsing values schema %s: %w`, schemaPath, err)
+	}
+
+	var defaults map[string]interface{}
+	defaultsPath := filepath.Join(chartPath, "values.yaml")
+	content, err = os.ReadFile(defaultsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(`reading values of helm chart %s: %w`, chartPath, err)
+	}
+	if err := yaml.Unmarshal(content, &defaults); err != nil {
+		return fmt.Errorf(`parsing values %s: %w`, defaultsPath, err)
+	}
+
+	effective := MergeValues(defaults, values)
+	if violations := manifest.ValidateJSONSchema(effective, schema, false); len(violations) > 0 {
+		return &ValuesError{Chart: chartPath, Errors: violations}
+	}
+	return nil
+}
+
+// isValuesURL determines if a Values entry of TemplateOptions is an http(s)
+// URL rather than a file path.
+func isValuesURL(valuesPath string) bool {
+	return strings.HasPrefix(valuesPath, "https://") || strings.HasPrefix(valuesPath, "http://")
+}
+
+// remoteValues returns the Values entries which are URLs.
+func remoteValues(values []string) []string {
+	var urls []string
+	for _, valuesPath := range values {
+		if isValuesURL(valuesPath) {
+			urls = append(urls, valuesPath)
+		}
+	}
+	return urls
+}
+
+// isValuesTemplate determines if a Values entry of opts is rendered as a Go
+// template.
+func (opts TemplateOptions) isValuesTemplate(valuesPath string) bool {
+	if idx := strings.Index(valuesPath, "#"); idx >= 0 && isValuesURL(valuesPath) {
+		valuesPath = valuesPath[:idx]
+	}
+	return opts.ValuesTemplate != nil && strings.HasSuffix(valuesPath, ValuesTemplateSuffix)
+}
+
+// valuesTemplates returns the Values entries of opts which are rendered as Go
+// templates.
+func (opts TemplateOptions) valuesTemplates() []string {
+	var templates []string
+	for _, valuesPath := range opts.Values {
+		if opts.isValuesTemplate(valuesPath) {
+			templates = append(templates, valuesPath)
+		}
+	}
+	return templates
+}
+
+// readValues returns the contents of a Values entry of opts, downloading it if
+// it is a URL and rendering it if it is a template.
+func (opts TemplateOptions) readValues(valuesPath string) ([]byte, error) {
+	var content []byte
+	var err error
+	if isValuesURL(valuesPath) {
+		if content, err = opts.fetchValues(valuesPath); err != nil {
+			return nil, err
+		}
+	} else if content, err = os.ReadFile(valuesPath); err != nil {
+		return nil, fmt.Errorf(`reading values file %s: %w`, valuesPath, err)
+	}
+	if opts.isValuesTemplate(valuesPath) {
+		return RenderValuesTemplate(valuesPath, content, *opts.ValuesTemplate)
+	}
+	return content, nil
+}
+
+// fetchValues downloads the values file at valuesURL with ValuesHeaders,
+// verifying it against the digest pinned by a "#sha256=<hex>" fragment.
+func (opts TemplateOptions) fetchValues(valuesURL string) ([]byte, error) {
+	location, fragment := valuesURL, ""
+	if idx := strings.Index(valuesURL, "#"); idx >= 0 {
+		location, fragment = valuesURL[:idx], valuesURL[idx+1:]
+	}
+	expected := ""
+	if fragment != "" {
+		if !strings.HasPrefix(fragment, "sha256=") {
+			return nil, fmt.Errorf(`values URL %s: unsupported fragment "%s", expected "sha256=<hex digest>"`, location, fragment)
+		}
+		expected = strings.TrimPrefix(fragment, "sha256=")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf(`creating request for values URL %s: %w`, location, err)
+	}
+	for name, value := range opts.ValuesHeaders {
+		req.Header.Set(name, value)
+	}
+	client := opts.ValuesClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf(`downloading values %s: %w`, location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(`downloading values %s: unexpected status %s`, location, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf(`reading values %s: %w`, location, err)
+	}
+
+	sum := sha256.Sum256(content)
+	if digest := hex.EncodeToString(sum[:]); expected != "" && !strings.EqualFold(expected, digest) {
+		return nil, errcode.Wrap(errcode.HelmDigestMismatch, fmt.Errorf(`digest of values %s does not match: expected sha256:%s, got sha256:%s`, location, expected, digest))
+	}
+	return content, nil
+}
+
+// downloadValues downloads the Values entries of opts which are URLs and
+// renders those which are templates into dir, returning Values with them
+// replaced by the written files so helm is only passed local, static paths.
+func (opts TemplateOptions) downloadValues(dir string) ([]string, error) {
+	values := make([]string, len(opts.Values))
+	for idx, valuesPath := range opts.Values {
+		values[idx] = valuesPath
+		if !isValuesURL(valuesPath) && !opts.isValuesTemplate(valuesPath) {
+			continue
+		}
+		content, err := opts.readValues(valuesPath)
+		if err != nil {
+			return nil, err
+		}
+		values[idx] = filepath.Join(dir, fmt.Sprintf("values-%d.yaml", idx))
+		if err := os.WriteFile(values[idx], content, 0600); err != nil {
+			return nil, fmt.Errorf(`writing values file %s: %w`, values[idx], err)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,193 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeValues(t *testing.T) {
+	defaults := map[string]interface{}{
+		"replicaCount": 1,
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.21", "pullPolicy": "IfNotPresent"},
+		"resources":    map[string]interface{}{"limits": map[string]interface{}{"cpu": "100m"}},
+		"tolerations":  []interface{}{"a"},
+	}
+
+	tests := []struct {
+		name string
+		maps []map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "precedence",
+			maps: []map[string]interface{}{
+				defaults,
+				{"replicaCount": 2, "image": map[string]interface{}{"tag": "1.22"}},
+				{"replicaCount": 3, "tolerations": []interface{}{"b", "c"}},
+			},
+			want: map[string]interface{}{
+				"replicaCount": 3,
+				"image":        map[string]interface{}{"repository": "nginx", "tag": "1.22", "pullPolicy": "IfNotPresent"},
+				"resources":    map[string]interface{}{"limits": map[string]interface{}{"cpu": "100m"}},
+				"tolerations":  []interface{}{"b", "c"},
+			},
+		},
+		{
+			name: "null-deletes",
+			maps: []map[string]interface{}{
+				defaults,
+				{"resources": nil, "image": map[string]interface{}{"pullPolicy": nil}},
+			},
+			want: map[string]interface{}{
+				"replicaCount": 1,
+				"image":        map[string]interface{}{"repository": "nginx", "tag": "1.21"},
+				"tolerations":  []interface{}{"a"},
+			},
+		},
+		{
+			name: "null-overridden",
+			maps: []map[string]interface{}{
+				{"resources": nil},
+				{"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "50m"}}},
+			},
+			want: map[string]interface{}{"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "50m"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeValues(tt.maps...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, ok := defaults["resources"]; !ok {
+		t.Errorf("MergeValues() modified its input")
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	chart := t.TempDir()
+	schema := `{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {"repository": {"type": "string"}, "tag": {"type": "string"}}
+    }
+  }
+}`
+	if err := os.WriteFile(filepath.Join(chart, ValuesSchemaFile), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chart, "values.yaml"), []byte("replicaCount: 1\nimage:\n  repository: nginx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		chartPath string
+		values    map[string]interface{}
+		want      []string
+	}{
+		{
+			name:      "valid",
+			chartPath: chart,
+			values:    map[string]interface{}{"image": map[string]interface{}{"tag": "1.21"}},
+		},
+		{
+			name:      "violations",
+			chartPath: chart,
+			values:    map[string]interface{}{"replicaCount": 0, "image": map[string]interface{}{"tag": 1.21}},
+			want:      []string{"/image/tag: expected string, got number", "/replicaCount: 0 is less than the minimum of 1"},
+		},
+		{
+			name:      "default-removed",
+			chartPath: chart,
+			values:    map[string]interface{}{"image": map[string]interface{}{"repository": nil}},
+			want:      []string{`/image: missing required field "repository"`},
+		},
+		{
+			name:      "no-schema",
+			chartPath: t.TempDir(),
+			values:    map[string]interface{}{"replicaCount": "many"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateValues(tt.chartPath, tt.values)
+			var got []string
+			var valuesErr *ValuesError
+			if errors.As(err, &valuesErr) {
+				for _, violation := range valuesErr.Errors {
+					got = append(got, violation.Error())
+				}
+			} else if err != nil {
+				t.Fatalf("ValidateValues() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateValues() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplate_valuesURL(t *testing.T) {
+	// the fake helm outputs the contents of the values files it is passed
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template)
+  while [ $# -gt 0 ]; do
+    if [ "$1" = "--values" ]; then cat "$2"; fi
+    shift
+  done
+  ;;
+esac`)
+	base := "replicaCount: 2\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, base)
+	}))
+	defer server.Close()
+	sum := sha256.Sum256([]byte(base))
+	digest := hex.EncodeToString(sum[:])
+	headers := map[string]string{"Authorization": "Bearer token"}
+
+	tests := []struct {
+		name    string
+		values  string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"download", server.URL + "/base.yaml", headers, false},
+		{"pinned", server.URL + "/base.yaml#sha256=" + digest, headers, false},
+		{"digest-mismatch", server.URL + "/base.yaml#sha256=" + strings.Repeat("0", 64), headers, true},
+		{"unsupported-fragment", server.URL + "/base.yaml#md5=abc", headers, true},
+		{"unauthorized", server.URL + "/base.yaml", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Template(TemplateOptions{Chart: t.TempDir(), Values: []string{tt.values}, ValuesHeaders: tt.headers})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Template() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != base {
+				t.Errorf("Template() = %q, want %q", got, base)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesTemplateSuffix is the suffix of values files rendered as Go templates
+// when TemplateOptions.ValuesTemplate is set. e.g: "values/ingress.yaml.gotmpl"
+const ValuesTemplateSuffix = ".gotmpl"
+
+// MaxValuesTemplateSize is the largest output of a values template in bytes.
+const MaxValuesTemplateSize = 1 << 20
+
+// ValuesTemplateData is the data values templates are rendered against. e.g:
+//   controller:
+//     replicaCount: {{ if eq .Release.Labels.env "prod" }}3{{ else }}1{{ end }}
+//     image:
+//       tag: {{ env "INGRESS_TAG" | default "v1.0.4" | quote }}
+//   fullnameOverride: {{ .Release.Name }}-{{ .Release.Namespace }}
+type ValuesTemplateData struct {
+	Release      ValuesTemplateRelease
+	Environments []string // environments of the render. e.g: the Environments of a component render
+
+	// Env are the environment variables templates may read via env and
+	// requiredEnv. Templates cannot read any other variable of the process.
+	// e.g: {"INGRESS_TAG": os.Getenv("INGRESS_TAG")}
+	Env map[string]string
+}
+
+// ValuesTemplateRelease is the metadata of the release a values template is
+// rendered for.
+type ValuesTemplateRelease struct {
+	Name      string
+	Namespace string
+	Chart     string
+	Version   string
+	Labels    map[string]string
+}
+
+// ReleaseValuesTemplate returns a copy of the ValuesTemplate of opts (or
+// empty data if unset) describing the release of opts.
+func ReleaseValuesTemplate(opts TemplateOptions, labels map[string]string) *ValuesTemplateData {
+	var data ValuesTemplateData
+	if opts.ValuesTemplate != nil {
+		data = *opts.ValuesTemplate
+	}
+	data.Release = ValuesTemplateRelease{
+		Name:      opts.Release,
+		Namespace: opts.Namespace,
+		Chart:     opts.Chart,
+		Version:   opts.Version,
+		Labels:    labels,
+	}
+	return &data
+}
+
+// RenderValuesTemplate renders the values template content named name
+// against data. Templates are sandboxed: only a subset of the sprig functions
+// of helm charts is available, none of which read files, run commands or read
+// environment variables other than those of data.Env, and the output is
+// limited to MaxValuesTemplateSize. Available functions:
+//   default, empty, coalesce, required, ternary, env, requiredEnv,
+//   quote, squote, upper, lower, title, trim, trimPrefix, trimSuffix,
+//   replace, contains, hasPrefix, hasSuffix, splitList, join, indent,
+//   nindent, toString, toYaml, toJson, b64enc, b64dec, list, dict
+func RenderValuesTemplate(name string, content []byte, data ValuesTemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(valuesTemplateFuncs(data)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf(`parsing values template %s: %w`, name, err)
+	}
+	var output bytes.Buffer
+	if err := tmpl.Execute(&limitedWriter{w: &output, remaining: MaxValuesTemplateSize}, data); err != nil {
+		return nil, fmt.Errorf(`rendering values template %s: %w`, name, err)
+	}
+	return output.Bytes(), nil
+}
+
+// errValuesTemplateSize is returned when the output of a values template
+// exceeds MaxValuesTemplateSize.
+var errValuesTemplateSize = fmt.Errorf(`output exceeds %d bytes`, MaxValuesTemplateSize)
+
+// limitedWriter fails writes beyond remaining bytes.
+type limitedWriter struct {
+	w         *bytes.Buffer
+	remaining int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > l.remaining {
+		return 0, errValuesTemplateSize
+	}
+	l.remaining -= len(p)
+	return l.w.Write(p)
+}
+
+// valuesTemplateFuncs returns the functions available to values templates
+// rendered against data.
+func valuesTemplateFuncs(data ValuesTemplateData) template.FuncMap {
+	return template.FuncMap{
+		"default": func(fallback interface{}, value ...interface{}) interface{} {
+			if len(value) == 0 || isEmpty(value[0]) {
+				return fallback
+			}
+			return value[0]
+		},
+		"empty": isEmpty,
+		"coalesce": func(values ...interface{}) interface{} {
+			for _, value := range values {
+				if !isEmpty(value) {
+					return value
+				}
+			}
+			return nil
+		},
+		"required": func(message string, value interface{}) (interface{}, error) {
+			if isEmpty(value) {
+				return nil, errors.New(message)
+			}
+			return value, nil
+		},
+		"ternary": func(whenTrue interface{}, whenFalse interface{}, condition bool) interface{} {
+			if condition {
+				return whenTrue
+			}
+			return whenFalse
+		},
+		"env": func(name string) string { return data.Env[name] },
+		"requiredEnv": func(name string) (string, error) {
+			if data.Env[name] == "" {
+				return "", fmt.Errorf(`required environment variable %s is not set`, name)
+			}
+			return data.Env[name], nil
+		},
+		"quote":      func(value interface{}) string { return fmt.Sprintf("%q", toString(value)) },
+		"squote":     func(value interface{}) string { return "'" + toString(value) + "'" },
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    replace,
+		"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+		"splitList":  func(sep string, s string) []string { return strings.Split(s, sep) },
+		"join": func(sep string, values interface{}) string {
+			var parts []string
+			v := reflect.ValueOf(values)
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				return toString(values)
+			}
+			for idx := 0; idx < v.Len(); idx++ {
+				parts = append(parts, toString(v.Index(idx).Interface()))
+			}
+			return strings.Join(parts, sep)
+		},
+		"indent": indent,
+		"nindent": func(spaces int, s string) (string, error) {
+			indented, err := indent(spaces, s)
+			return "\n" + indented, err
+		},
+		"toString": toString,
+		"toYaml": func(value interface{}) (string, error) {
+			var b bytes.Buffer
+			encoder := yaml.NewEncoder(&b)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(value); err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(b.String(), "\n"), encoder.Close()
+		},
+		"toJson": func(value interface{}) (string, error) {
+			content, err := json.Marshal(value)
+			return string(content), err
+		},
+		"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec": func(s string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(s)
+			return string(decoded), err
+		},
+		"list": func(values ...interface{}) []interface{} { return values },
+		"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+			if len(pairs)%2 != 0 {
+				return nil, fmt.Errorf(`dict expects key and value pairs, got %d arguments`, len(pairs))
+			}
+			dict := map[string]interface{}{}
+			for idx := 0; idx < len(pairs); idx += 2 {
+				dict[toString(pairs[idx])] = pairs[idx+1]
+			}
+			return dict, nil
+		},
+	}
+}
+
+// isEmpty determines if value is the zero value of its type, or an empty
+// slice or map, as sprig's empty does.
+func isEmpty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// toString formats value as a string. nil is the empty string.
+func toString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// indent prefixes each line of s with spaces. The indented string is
+// checked against MaxValuesTemplateSize before it is allocated, as it could
+// never be written.
+func indent(spaces int, s string) (string, error) {
+	if spaces < 0 {
+		return "", fmt.Errorf(`indent expects a positive number of spaces, got %d`, spaces)
+	}
+	if spaces > MaxValuesTemplateSize || len(s)+spaces*(strings.Count(s, "\n")+1) > MaxValuesTemplateSize {
+		return "", errValuesTemplateSize
+	}
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad), nil
+}
+
+// replace replaces all occurrences of old in s with new. As with indent, the
+// result is checked against MaxValuesTemplateSize before it is allocated.
+func replace(old string, new string, s string) (string, error) {
+	matches := strings.Count(s, old)
+	if old == "" {
+		matches = utf8.RuneCountInString(s) + 1
+	}
+	if len(new) > MaxValuesTemplateSize || len(s)+matches*(len(new)-len(old)) > MaxValuesTemplateSize {
+		return "", errValuesTemplateSize
+	}
+	return strings.ReplaceAll(s, old, new), nil
+}
+
+// title upper-cases the first letter of each word of s, as sprig's title
+// does. Words are separated as by the deprecated strings.Title.
+func title(s string) string {
+	previous := ' '
+	return strings.Map(func(r rune) rune {
+		separated := isWordSeparator(previous)
+		previous = r
+		if separated {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// isWordSeparator determines if r separates words: ASCII characters other than
+// letters, digits and underscores, and unicode spaces.
+func isWordSeparator(r rune) bool {
+	if r <= unicode.MaxASCII {
+		switch {
+		case '0' <= r && r <= '9', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '_':
+			return false
+		}
+		return true
+	}
+	if unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return false
+	}
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,89 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderValuesTemplate(t *testing.T) {
+	data := ValuesTemplateData{
+		Release: ValuesTemplateRelease{Name: "ingress", Namespace: "ingress", Labels: map[string]string{"env": "prod"}},
+		Env:     map[string]string{"TAG": "v1.2.3"},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{name: "release", template: `name: {{ .Release.Name }}-{{ .Release.Namespace }}`, want: "name: ingress-ingress"},
+		{name: "labels", template: `replicas: {{ if eq .Release.Labels.env "prod" }}3{{ else }}1{{ end }}`, want: "replicas: 3"},
+		{name: "missing label", template: `tier: {{ .Release.Labels.tier | default "backend" }}`, want: "tier: backend"},
+		{name: "env", template: `tag: {{ env "TAG" | quote }}`, want: `tag: "v1.2.3"`},
+		{name: "env not allowed", template: `home: {{ env "HOME" | default "none" }}`, want: "home: none"},
+		{name: "required env", template: `{{ requiredEnv "HOME" }}`, wantErr: "required environment variable HOME is not set"},
+		{name: "required", template: `{{ required "image.tag is required" .Release.Version }}`, wantErr: "image.tag is required"},
+		{name: "toYaml", template: `labels:{{ dict "app" "web" "tier" (list "a" "b") | toYaml | nindent 2 }}`, want: "labels:\n  app: web\n  tier:\n    - a\n    - b"},
+		{name: "strings", template: `{{ "web-app" | trimPrefix "web-" | upper }} {{ splitList "," "a,b" | join "+" }} {{ "x" | b64enc }}`, want: "APP a+b eA=="},
+		{name: "unknown function", template: `{{ readFile "/etc/passwd" }}`, wantErr: `function "readFile" not defined`},
+		{name: "output limit", template: `{{ range $i := list 1 2 }}{{ printf "%1048576s" "" }}{{ end }}`, wantErr: "output exceeds"},
+		{name: "indent", template: `{{ "a\nb" | indent 2 }}`, want: "  a\n  b"},
+		{name: "indent limit", template: `{{ "a" | indent 2000000000 }}`, wantErr: "output exceeds"},
+		{name: "nindent limit", template: `{{ "a\nb\nc" | nindent 400000 }}`, wantErr: "output exceeds"},
+		{name: "negative indent", template: `{{ "a" | indent -1 }}`, wantErr: "positive number of spaces"},
+		{name: "replace", template: `{{ "a.b.c" | replace "." "-" }}`, want: "a-b-c"},
+		{name: "replace limit", template: `{{ printf "%1000s" "" | replace " " (printf "%2000s" "") }}`, wantErr: "output exceeds"},
+		{name: "title", template: `{{ "hello web-app_v2 élan" | title }}`, want: "Hello Web-App_v2 Élan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderValuesTemplate("values.yaml.gotmpl", []byte(tt.template), data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenderValuesTemplate() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderValuesTemplate() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RenderValuesTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateOptions_downloadValues_templates(t *testing.T) {
+	dir := t.TempDir()
+	static := filepath.Join(dir, "values.yaml")
+	templated := filepath.Join(dir, "values.yaml.gotmpl")
+	if err := os.WriteFile(static, []byte("a: {{ .Release.Name }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(templated, []byte("a: {{ .Release.Name }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := TemplateOptions{Release: "web", Values: []string{static, templated}}
+	if templates := opts.valuesTemplates(); len(templates) != 0 {
+		t.Errorf("valuesTemplates() = %v without ValuesTemplate, want none", templates)
+	}
+	opts.ValuesTemplate = ReleaseValuesTemplate(opts, nil)
+	values, err := opts.downloadValues(t.TempDir())
+	if err != nil {
+		t.Fatalf("downloadValues() error = %v", err)
+	}
+	if values[0] != static {
+		t.Errorf("downloadValues()[0] = %s, want %s", values[0], static)
+	}
+	content, err := os.ReadFile(values[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "a: web\n" {
+		t.Errorf("rendered values = %q, want %q", content, "a: web\n")
+	}
+}
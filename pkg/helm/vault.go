@@ -0,0 +1,163 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ValuesResolver transforms in-memory values before they are passed to helm.
+// e.g: to replace placeholders with secrets resolved at render time.
+type ValuesResolver interface {
+	ResolveValues(values map[string]interface{}) (map[string]interface{}, error)
+}
+
+// VaultPlaceholderPrefix prefixes string values resolved by VaultResolver.
+// Placeholders are of the form vault:<secret path>#<key>. e.g:
+// "vault:secret/data/my-app#password"
+const VaultPlaceholderPrefix = "vault:"
+
+// VaultResolver is a ValuesResolver replacing vault:<path>#<key> placeholders
+// in values with secrets read from HashiCorp Vault via its HTTP API, so
+// secrets can be referenced declaratively in values without committing them.
+// Both KV version 1 and version 2 (paths containing "/data/") secrets engines
+// are supported. Each secret path is read at most once per resolver.
+type VaultResolver struct {
+	Address   string       // address of the vault server. e.g: "https://vault.example.com:8200"
+	Token     string       // vault token used to read secrets
+	Namespace string       // vault enterprise namespace. optional
+	Client    *http.Client // defaults to http.DefaultClient
+
+	mu      sync.Mutex
+	secrets map[string]map[string]interface{} // secret data keyed by path
+}
+
+// NewVaultResolverFromEnv creates a VaultResolver configured via the standard
+// VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE environment variables.
+func NewVaultResolverFromEnv() *VaultResolver {
+	return &VaultResolver{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+}
+
+// ResolveValues returns a copy of values with all vault placeholders replaced
+// by the referenced secrets. values is not modified.
+func (v *VaultResolver) ResolveValues(values map[string]interface{}) (map[string]interface{}, error) {
+	resolved, err := v.resolve(values)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+// resolve recursively copies value, replacing vault placeholders.
+func (v *VaultResolver) resolve(value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			resolvedChild, err := v.resolve(child)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = resolvedChild
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(typed))
+		for idx, child := range typed {
+			resolvedChild, err := v.resolve(child)
+			if err != nil {
+				return nil, err
+			}
+			resolved[idx] = resolvedChild
+		}
+		return resolved, nil
+	case string:
+		if !strings.HasPrefix(typed, VaultPlaceholderPrefix) {
+			return typed, nil
+		}
+		return v.lookup(strings.TrimPrefix(typed, VaultPlaceholderPrefix))
+	default:
+		return value, nil
+	}
+}
+
+// lookup resolves the <path>#<key> reference of a placeholder.
+func (v *VaultResolver) lookup(reference string) (interface{}, error) {
+	idx := strings.LastIndex(reference, "#")
+	if idx < 0 {
+		return nil, fmt.Errorf(`invalid vault placeholder "%s%s": expected the form vault:<path>#<key>`, VaultPlaceholderPrefix, reference)
+	}
+	secretPath, key := strings.Trim(reference[:idx], "/"), reference[idx+1:]
+	secret, err := v.readSecret(secretPath)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret[key]
+	if !ok {
+		return nil, fmt.Errorf(`vault secret %s has no key "%s"`, secretPath, key)
+	}
+	return value, nil
+}
+
+// readSecret reads the data of the secret at path, caching the result.
+func (v *VaultResolver) readSecret(path string) (map[string]interface{}, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if secret, ok := v.secrets[path]; ok {
+		return secret, nil
+	}
+
+	if v.Address == "" {
+		return nil, fmt.Errorf(`reading vault secret %s: no vault address configured`, path)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(v.Address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf(`creating request for vault secret %s: %w`, path, err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf(`reading vault secret %s: %w`, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf(`reading vault secret %s: %w`, path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(`reading vault secret %s: unexpected status %s: %s`, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf(`parsing vault secret %s: %w`, path, err)
+	}
+	secret := response.Data
+	// KV version 2 nests the secret data under data.data
+	if nested, ok := secret["data"].(map[string]interface{}); ok && strings.Contains(path, "/data/") {
+		secret = nested
+	}
+
+	if v.secrets == nil {
+		v.secrets = map[string]map[string]interface{}{}
+	}
+	v.secrets[path] = secret
+	return secret, nil
+}
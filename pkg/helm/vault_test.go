@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVaultResolver_ResolveValues(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "my-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"data": {"data": {"password": "hunter2", "port": 5432}, "metadata": {"version": 1}}}`))
+		case "/v1/kv/app":
+			w.Write([]byte(`{"data": {"token": "abc"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	resolver := &VaultResolver{Address: server.URL, Token: "my-token"}
+	values := map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": "vault:secret/data/app#password",
+			"port":     "vault:secret/data/app#port",
+			"host":     "localhost",
+		},
+		"tokens": []interface{}{"vault:kv/app#token"},
+	}
+	want := map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": "hunter2",
+			"port":     float64(5432),
+			"host":     "localhost",
+		},
+		"tokens": []interface{}{"abc"},
+	}
+	got, err := resolver.ResolveValues(values)
+	if err != nil {
+		t.Fatalf("ResolveValues() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveValues() = %v, want %v", got, want)
+	}
+	if requests != 2 {
+		t.Errorf("ResolveValues() made %d requests, want 2", requests)
+	}
+	if values["db"].(map[string]interface{})["password"] != "vault:secret/data/app#password" {
+		t.Errorf("ResolveValues() modified values")
+	}
+
+	for _, placeholder := range []string{"vault:secret/data/app#missing", "vault:secret/data/missing#key", "vault:no-key"} {
+		if _, err := resolver.ResolveValues(map[string]interface{}{"value": placeholder}); err == nil {
+			t.Errorf("ResolveValues() %s error = nil, want error", placeholder)
+		}
+	}
+}
@@ -1,11 +1,8 @@
 package helm
 
 import (
-	"bytes"
 	"fmt"
-	"os/exec"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -17,24 +14,22 @@ type BuildInfo struct {
 	GoVersion    string
 }
 
-// Version runs `helm version` and parses the output.
+// Version runs `helm version` and parses the output. The output is memoized
+// per helm binary and environment, so Version is cheap to call per render.
 func Version() (v BuildInfo, err error) {
 	// Run `helm version` and capture the output
-	cmd := exec.Command("helm", "version")
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return v, fmt.Errorf(`running %s: %s: %w`, cmd, stderr.String(), err)
+	cmd, stdout, stderr, err := runProbe("version")
+	if err != nil {
+		return v, err
 	}
-	if stderr.String() != "" {
-		return v, fmt.Errorf(`running %s: %s`, cmd, stderr.String())
+	if stderr != "" {
+		return v, fmt.Errorf(`running %s: %s`, cmd, stderr)
 	}
 
 	// capture against stdout
 	rgx := regexp.MustCompile(`(?i)Version:"(?P<Version>v\d+\.\d+\.\d+)".*GitCommit:"(?P<GitCommit>[^"]+)".*GitTreeState:"(?P<GitTreeState>[^"]+)".*GoVersion:"(?P<GoVersion>[^"]+)"`)
 	matchNames := rgx.SubexpNames()
-	for idx, matchValue := range rgx.FindStringSubmatch(stdout.String()) {
+	for idx, matchValue := range rgx.FindStringSubmatch(stdout) {
 		switch matchNames[idx] {
 		case "Version":
 			v.Version = matchValue
@@ -50,46 +45,46 @@ func Version() (v BuildInfo, err error) {
 	return v, err
 }
 
-// parse a semantic version the output of the BuildInfo outputted from
-// `helm template` into struct of ints.
-func (v BuildInfo) parse() (parsed struct{ major, minor, fix int }, err error) {
-	// regex capture group names
-	const (
-		major = "Major"
-		minor = "Minor"
-		fix   = "Fix"
-	)
-	// build the regex string and compile
-	rgxStr := fmt.Sprintf(`(?i)v(?P<%s>\d+)\.(?P<%s>\d+)\.(?P<%s>\d+)`, major, minor, fix)
-	semVerRgx := regexp.MustCompile(rgxStr)
-
-	// iterate over captures and assign values to parsed
-	for idx, value := range semVerRgx.FindStringSubmatch(v.Version) {
-		captureName := semVerRgx.SubexpNames()[idx]
-		var valueAsInt int
-		// atoi the value if it is in a named capture group ("Major", "Minor", "Fix")
-		// WARN should never reach error case unless additional capture groups are
-		// added to the regex which try to capture non-atoi-able strings.
-		if captureName != "" {
-			var err error
-			valueAsInt, err = strconv.Atoi(value)
-			if err != nil {
-				return parsed, fmt.Errorf(`parsing version string %s as int: %w`, value, err)
-			}
-		}
+// SemVer returns the major, minor and patch components of the version. e.g:
+// 3, 7, 1 for "v3.7.1"
+func (v BuildInfo) SemVer() (major int, minor int, patch int, err error) {
+	parsed, err := parseSemVer(v.Version)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf(`parsing helm version: %w`, err)
+	}
+	return parsed.major, parsed.minor, parsed.patch, nil
+}
 
-		// assign based on the capture group name
-		switch captureName {
-		case major:
-			parsed.major = valueAsInt
-		case minor:
-			parsed.minor = valueAsInt
-		case fix:
-			parsed.fix = valueAsInt
-		}
+// AtLeast determines if the version is at least major.minor.patch, e.g. to
+// gate a flag on the helm version supporting it:
+//   if v.AtLeast(3, 1, 0) { args = append(args, "--include-crds") }
+// Pre-releases of a version count as that version. A version which cannot be
+// parsed is never at least any version.
+func (v BuildInfo) AtLeast(major int, minor int, patch int) bool {
+	parsed, err := parseSemVer(v.Version)
+	if err != nil {
+		return false
 	}
+	parsed.prerelease, parsed.metadata = "", ""
+	return parsed.compare(semVer{major: major, minor: minor, patch: patch}) >= 0
+}
 
-	return parsed, nil
+// Compare returns -1, 0 or 1 if the version is less than, equal to or greater
+// than the version of other following semver precedence rules. Versions which
+// cannot be parsed are less than all versions which can and equal to each
+// other.
+func (v BuildInfo) Compare(other BuildInfo) int {
+	parsed, err := parseSemVer(v.Version)
+	otherParsed, otherErr := parseSemVer(other.Version)
+	switch {
+	case err != nil && otherErr != nil:
+		return 0
+	case err != nil:
+		return -1
+	case otherErr != nil:
+		return 1
+	}
+	return parsed.compare(otherParsed)
 }
 
 // IsHelm3 determines if the provided Helm version is major version 3.
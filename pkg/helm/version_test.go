@@ -0,0 +1,69 @@
+package helm
+
+import "testing"
+
+func TestBuildInfo_SemVer(t *testing.T) {
+	tests := []struct {
+		version             string
+		major, minor, patch int
+		wantErr             bool
+	}{
+		{version: "v3.7.1", major: 3, minor: 7, patch: 1},
+		{version: "v2.17.0", major: 2, minor: 17},
+		{version: "v3.8.0-rc.1", major: 3, minor: 8},
+		{version: "", wantErr: true},
+		{version: "unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			major, minor, patch, err := BuildInfo{Version: tt.version}.SemVer()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SemVer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if major != tt.major || minor != tt.minor || patch != tt.patch {
+				t.Errorf("SemVer() = %d, %d, %d, want %d, %d, %d", major, minor, patch, tt.major, tt.minor, tt.patch)
+			}
+		})
+	}
+}
+
+func TestBuildInfo_AtLeast(t *testing.T) {
+	tests := []struct {
+		version             string
+		major, minor, patch int
+		want                bool
+	}{
+		{version: "v3.7.1", major: 3, minor: 1, want: true},
+		{version: "v3.7.1", major: 3, minor: 7, patch: 1, want: true},
+		{version: "v3.7.1", major: 3, minor: 7, patch: 2, want: false},
+		{version: "v3.10.0", major: 3, minor: 9, want: true},
+		{version: "v2.17.0", major: 3, want: false},
+		{version: "v3.8.0-rc.1", major: 3, minor: 8, want: true},
+		{version: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		if got := (BuildInfo{Version: tt.version}).AtLeast(tt.major, tt.minor, tt.patch); got != tt.want {
+			t.Errorf("BuildInfo{%s}.AtLeast(%d, %d, %d) = %v, want %v", tt.version, tt.major, tt.minor, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestBuildInfo_Compare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "v3.7.1", b: "v3.7.1", want: 0},
+		{a: "v3.7.1", b: "v3.10.0", want: -1},
+		{a: "v3.10.0", b: "v3.7.1", want: 1},
+		{a: "v3.8.0-rc.1", b: "v3.8.0", want: -1},
+		{a: "unknown", b: "v2.0.0", want: -1},
+		{a: "v2.0.0", b: "", want: 1},
+		{a: "", b: "unknown", want: 0},
+	}
+	for _, tt := range tests {
+		if got := (BuildInfo{Version: tt.a}).Compare(BuildInfo{Version: tt.b}); got != tt.want {
+			t.Errorf("BuildInfo{%s}.Compare(BuildInfo{%s}) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package helm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Warning is a benign message helm wrote to stderr while running a command.
+// e.g: a deprecated chart or a symbolic link in the chart directory.
+type Warning struct {
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
+
+// warningPatterns match lines of helm stderr output known to be benign.
+var warningPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^WARNING: `),                     // e.g. "WARNING: This chart is deprecated" or "WARNING: Kubernetes configuration file is group-readable"
+	regexp.MustCompile(`(?i)^(\S+\.go:\d+: )?warning: `), // e.g. "coalesce.go:220: warning: cannot overwrite table with non table for ..."
+	regexp.MustCompile(`(?i)symbolic link`),              // e.g. "walk.go:74: found symbolic link in path: ..."
+	regexp.MustCompile(`(?i)symlink`),                    // e.g. "skipping unreadable symlink ..."
+	regexp.MustCompile(`(?i)deprecat`),                   // e.g. "Chart.yaml: apiVersion v1 is deprecated"
+}
+
+// classifyStderrWarnings splits the stderr output of helm into the lines
+// recognized as benign warnings and the remaining, unrecognized lines. Blank
+// lines are dropped.
+func classifyStderrWarnings(stderr string) (warnings []Warning, unrecognized []string) {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		benign := false
+		for _, pattern := range warningPatterns {
+			if pattern.MatchString(line) {
+				benign = true
+				break
+			}
+		}
+		if benign {
+			warnings = append(warnings, Warning{Message: line})
+		} else {
+			unrecognized = append(unrecognized, line)
+		}
+	}
+	return warnings, unrecognized
+}
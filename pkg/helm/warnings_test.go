@@ -0,0 +1,85 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_classifyStderrWarnings(t *testing.T) {
+	tests := []struct {
+		name             string
+		stderr           string
+		wantWarnings     []Warning
+		wantUnrecognized []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "benign",
+			stderr: `WARNING: This chart is deprecated
+walk.go:74: found symbolic link in path: /charts/my-chart/templates/link.yaml resolves to /shared/link.yaml
+
+coalesce.go:220: warning: cannot overwrite table with non table for foo (map[bar:baz])
+`,
+			wantWarnings: []Warning{
+				{Message: "WARNING: This chart is deprecated"},
+				{Message: "walk.go:74: found symbolic link in path: /charts/my-chart/templates/link.yaml resolves to /shared/link.yaml"},
+				{Message: "coalesce.go:220: warning: cannot overwrite table with non table for foo (map[bar:baz])"},
+			},
+		},
+		{
+			name:             "unrecognized",
+			stderr:           "WARNING: Kubernetes configuration file is group-readable\nError: something went wrong\n",
+			wantWarnings:     []Warning{{Message: "WARNING: Kubernetes configuration file is group-readable"}},
+			wantUnrecognized: []string{"Error: something went wrong"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotWarnings, gotUnrecognized := classifyStderrWarnings(tt.stderr)
+			if !reflect.DeepEqual(gotWarnings, tt.wantWarnings) {
+				t.Errorf("classifyStderrWarnings() warnings = %v, want %v", gotWarnings, tt.wantWarnings)
+			}
+			if !reflect.DeepEqual(gotUnrecognized, tt.wantUnrecognized) {
+				t.Errorf("classifyStderrWarnings() unrecognized = %v, want %v", gotUnrecognized, tt.wantUnrecognized)
+			}
+		})
+	}
+}
+
+func TestTemplateWithWarnings(t *testing.T) {
+	useFakeHelm(t, `case "$1" in
+version) echo '`+fakeHelmVersion+`' ;;
+template) echo 'WARNING: This chart is deprecated' >&2; echo 'kind: ConfigMap' ;;
+esac`)
+	chart := t.TempDir()
+
+	var events []Event
+	warnings, output, err := TemplateWithWarnings(TemplateOptions{
+		Chart:  chart,
+		Events: func(event Event) { events = append(events, event) },
+	})
+	if err != nil {
+		t.Fatalf("TemplateWithWarnings() error = %v", err)
+	}
+	if want := []Warning{{Message: "WARNING: This chart is deprecated"}}; !reflect.DeepEqual(warnings, want) {
+		t.Errorf("TemplateWithWarnings() warnings = %v, want %v", warnings, want)
+	}
+	if want := "kind: ConfigMap\n"; output != want {
+		t.Errorf("TemplateWithWarnings() output = %q, want %q", output, want)
+	}
+	var warningEvents []string
+	for _, event := range events {
+		if event.Type == TemplateWarning {
+			warningEvents = append(warningEvents, event.Message)
+		}
+	}
+	if want := []string{"WARNING: This chart is deprecated"}; !reflect.DeepEqual(warningEvents, want) {
+		t.Errorf("TemplateWithWarnings() emitted warnings %v, want %v", warningEvents, want)
+	}
+
+	if _, err := Template(TemplateOptions{Chart: chart, StrictStderr: true}); err == nil {
+		t.Errorf("Template() with StrictStderr expected error for output to stderr")
+	}
+}
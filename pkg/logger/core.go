@@ -3,8 +3,10 @@
 package logger
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -28,6 +30,33 @@ func SetLevelInfo() {
 	logrus.SetLevel(logrus.InfoLevel)
 }
 
+// BindFlags registers the logging flags on fs:
+//   --debug  set the standard logger level to Debug
+// The level is set as soon as the flags are parsed.
+func BindFlags(fs *flag.FlagSet) {
+	fs.Var(debugFlag{}, "debug", "enable debug logging")
+}
+
+// debugFlag is a boolean flag.Value toggling the Debug level.
+type debugFlag struct{}
+
+func (debugFlag) String() string { return "false" }
+
+func (debugFlag) IsBoolFlag() bool { return true }
+
+func (debugFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		SetLevelDebug()
+	} else {
+		SetLevelInfo()
+	}
+	return nil
+}
+
 // Trace logs a message at level Trace to stdout.
 func Trace(args ...interface{}) {
 	lock.Lock()
@@ -216,6 +245,16 @@ func Echo(level int, message interface{}) {
 	Info(formattedMessage)
 }
 
+// Flush waits for in-progress log writes and commits stdout and stderr to
+// stable storage when they are files, e.g. before the process exits.
+// Terminals and pipes cannot be synced and are ignored.
+func Flush() {
+	lock.Lock()
+	defer lock.Unlock()
+	os.Stdout.Sync()
+	os.Stderr.Sync()
+}
+
 func init() {
 	// Setup logger defaults
 	formatter := new(logrus.TextFormatter)
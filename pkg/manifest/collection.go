@@ -0,0 +1,98 @@
+package manifest
+
+// Key uniquely identifies a resource within a render.
+type Key struct {
+	GVK       GroupVersionKind `json:"gvk"`
+	Namespace string           `json:"namespace,omitempty"`
+	Name      string           `json:"name"`
+}
+
+// KeyOf returns the Key identifying m.
+func KeyOf(m Manifest) Key {
+	return Key{GVK: m.GVK(), Namespace: m.Namespace(), Name: m.Name()}
+}
+
+// Collection is a set of manifests indexed by GroupVersionKind, kind,
+// namespace and name so that lookups do not require scanning every manifest
+// in a render.
+// The zero value is not usable; create collections with NewCollection.
+type Collection struct {
+	manifests   []Manifest
+	byKey       map[Key]int
+	byGVK       map[GroupVersionKind][]int
+	byKind      map[string][]int
+	byNamespace map[string][]int
+}
+
+// NewCollection creates a Collection indexing the provided manifests. The
+// order of the manifests is preserved.
+func NewCollection(manifests []Manifest) *Collection {
+	c := &Collection{
+		byKey:       map[Key]int{},
+		byGVK:       map[GroupVersionKind][]int{},
+		byKind:      map[string][]int{},
+		byNamespace: map[string][]int{},
+	}
+	for _, m := range manifests {
+		c.Add(m)
+	}
+	return c
+}
+
+// Add appends m to the collection and indexes it. If a manifest with the same
+// Key is already present, Lookup will return the last one added.
+func (c *Collection) Add(m Manifest) {
+	idx := len(c.manifests)
+	c.manifests = append(c.manifests, m)
+
+	key := KeyOf(m)
+	c.byKey[key] = idx
+	c.byGVK[key.GVK] = append(c.byGVK[key.GVK], idx)
+	c.byKind[key.GVK.Kind] = append(c.byKind[key.GVK.Kind], idx)
+	c.byNamespace[key.Namespace] = append(c.byNamespace[key.Namespace], idx)
+}
+
+// Len returns the number of manifests in the collection.
+func (c *Collection) Len() int {
+	return len(c.manifests)
+}
+
+// Items returns all manifests in the collection in the order they were added.
+func (c *Collection) Items() []Manifest {
+	return c.manifests
+}
+
+// Lookup finds the manifest identified by gvk, namespace and name.
+// Cluster-scoped resources have an empty namespace.
+func (c *Collection) Lookup(gvk GroupVersionKind, namespace string, name string) (Manifest, bool) {
+	idx, ok := c.byKey[Key{GVK: gvk, Namespace: namespace, Name: name}]
+	if !ok {
+		return nil, false
+	}
+	return c.manifests[idx], true
+}
+
+// ByGVK returns all manifests of the provided GroupVersionKind.
+func (c *Collection) ByGVK(gvk GroupVersionKind) []Manifest {
+	return c.collect(c.byGVK[gvk])
+}
+
+// ByKind returns all manifests of the provided kind regardless of their
+// apiVersion.
+func (c *Collection) ByKind(kind string) []Manifest {
+	return c.collect(c.byKind[kind])
+}
+
+// ByNamespace returns all manifests in the provided namespace. Manifests
+// without a namespace are returned for the empty namespace.
+func (c *Collection) ByNamespace(namespace string) []Manifest {
+	return c.collect(c.byNamespace[namespace])
+}
+
+func (c *Collection) collect(indices []int) []Manifest {
+	var manifests []Manifest
+	for _, idx := range indices {
+		manifests = append(manifests, c.manifests[idx])
+	}
+	return manifests
+}
@@ -0,0 +1,84 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	sampleDeployment = Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "web",
+		},
+	}
+	sampleService = Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "nginx",
+			"namespace": "web",
+		},
+	}
+	sampleClusterRole = Manifest{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "ClusterRole",
+		"metadata": map[string]interface{}{
+			"name": "nginx",
+		},
+	}
+)
+
+func TestParseGVK(t *testing.T) {
+	tests := []struct {
+		apiVersion string
+		kind       string
+		want       GroupVersionKind
+	}{
+		{"v1", "Service", GroupVersionKind{Version: "v1", Kind: "Service"}},
+		{"apps/v1", "Deployment", GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		{"", "", GroupVersionKind{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.apiVersion, func(t *testing.T) {
+			got := ParseGVK(tt.apiVersion, tt.kind)
+			if got != tt.want {
+				t.Errorf("ParseGVK() = %v, want %v", got, tt.want)
+			}
+			if got.APIVersion() != tt.apiVersion {
+				t.Errorf("GroupVersionKind.APIVersion() = %v, want %v", got.APIVersion(), tt.apiVersion)
+			}
+		})
+	}
+}
+
+func TestCollection(t *testing.T) {
+	c := NewCollection([]Manifest{sampleDeployment, sampleService, sampleClusterRole})
+
+	if c.Len() != 3 {
+		t.Errorf("Collection.Len() = %v, want %v", c.Len(), 3)
+	}
+	if got, ok := c.Lookup(ParseGVK("v1", "Service"), "web", "nginx"); !ok || !reflect.DeepEqual(got, sampleService) {
+		t.Errorf("Collection.Lookup() = %v, %v, want %v, %v", got, ok, sampleService, true)
+	}
+	if got, ok := c.Lookup(ParseGVK("v1", "Service"), "other", "nginx"); ok {
+		t.Errorf("Collection.Lookup() = %v, %v, want %v, %v", got, ok, nil, false)
+	}
+	if got, ok := c.Lookup(ParseGVK("rbac.authorization.k8s.io/v1", "ClusterRole"), "", "nginx"); !ok || !reflect.DeepEqual(got, sampleClusterRole) {
+		t.Errorf("Collection.Lookup() = %v, %v, want %v, %v", got, ok, sampleClusterRole, true)
+	}
+	if got, want := c.ByKind("Deployment"), []Manifest{sampleDeployment}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Collection.ByKind() = %v, want %v", got, want)
+	}
+	if got, want := c.ByGVK(ParseGVK("apps/v1", "Deployment")), []Manifest{sampleDeployment}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Collection.ByGVK() = %v, want %v", got, want)
+	}
+	if got, want := c.ByNamespace("web"), []Manifest{sampleDeployment, sampleService}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Collection.ByNamespace() = %v, want %v", got, want)
+	}
+	if got := c.ByKind("Secret"); got != nil {
+		t.Errorf("Collection.ByKind() = %v, want %v", got, nil)
+	}
+}
@@ -0,0 +1,223 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// chartLabel is the label helm charts conventionally set to <name>-<version>
+// of the chart rendering a resource.
+const chartLabel = "helm.sh/chart"
+
+// Pricing is a table of monthly rates used by EstimateCost, e.g. the
+// on-demand prices of a cloud provider or the internal chargeback rates of a
+// platform team. Rates which are not set are free.
+type Pricing struct {
+	Currency       string             `yaml:"currency"`       // e.g: "USD". only used for display
+	CPU            float64            `yaml:"cpu"`            // per requested core per month
+	Memory         float64            `yaml:"memory"`         // per requested GiB of memory per month
+	Storage        float64            `yaml:"storage"`        // per GiB of persistent volume claims per month
+	StorageClasses map[string]float64 `yaml:"storageClasses"` // per GiB of persistent volume claims of a storage class per month, overriding Storage. e.g: {"premium-ssd": 0.17}
+	LoadBalancer   float64            `yaml:"loadBalancer"`   // per Service of type LoadBalancer per month
+}
+
+// LoadPricing reads a Pricing table from a yaml file. e.g:
+//   currency: USD
+//   cpu: 24.82
+//   memory: 3.33
+//   storage: 0.10
+//   storageClasses:
+//     premium-ssd: 0.17
+//   loadBalancer: 18.25
+func LoadPricing(path string) (Pricing, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Pricing{}, fmt.Errorf(`reading pricing %s: %w`, path, err)
+	}
+	var pricing Pricing
+	if err := yaml.Unmarshal(content, &pricing); err != nil {
+		return Pricing{}, fmt.Errorf(`parsing pricing %s: %w`, path, err)
+	}
+	return pricing, nil
+}
+
+// CostOptions configure EstimateCost.
+type CostOptions struct {
+	Pricing Pricing
+	// Namespace is the namespace of namespaced resources which do not set one.
+	// Defaults to "default".
+	Namespace string
+	// DaemonSetNodes is the number of nodes the pods of DaemonSets are
+	// projected to run on. Defaults to 1.
+	DaemonSetNodes int
+}
+
+// CostEstimate is the estimated monthly cost of the resources of a chart in
+// a namespace.
+type CostEstimate struct {
+	Chart         string  // helm.sh/chart label of the resources. e.g: "ingress-nginx-4.0.6". empty for resources without it
+	Namespace     string  // namespace of the resources
+	CPU           float64 // requested cores
+	Memory        float64 // requested bytes of memory
+	Storage       float64 // bytes of persistent volume claims
+	LoadBalancers int     // Services of type LoadBalancer
+	Monthly       float64 // estimated cost per month
+}
+
+// EstimateCost estimates the monthly cost of manifests per chart and
+// namespace by applying the rates of opts.Pricing to their aggregate resource
+// requests and storage, so the cost impact of a change can be reviewed in the
+// pull request making it. Estimates are sorted by namespace and chart.
+//
+// Pods are projected from the static replica count of their workloads as by
+// SimulateQuotas, with the requests of their containers defaulted by the
+// LimitRanges of the render. Usage beyond requests, autoscaling and
+// node-level overhead are not estimated.
+func EstimateCost(manifests []Manifest, opts CostOptions) ([]CostEstimate, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.DaemonSetNodes <= 0 {
+		opts.DaemonSetNodes = 1
+	}
+	namespaceOf := func(m Manifest) string {
+		if namespace := m.Namespace(); namespace != "" {
+			return namespace
+		}
+		return opts.Namespace
+	}
+	limitRanges := map[string][]Manifest{}
+	for _, m := range manifests {
+		if m.GVK().GroupKind() == (GroupKind{Kind: "LimitRange"}) {
+			limitRanges[namespaceOf(m)] = append(limitRanges[namespaceOf(m)], m)
+		}
+	}
+
+	type group struct{ chart, namespace string }
+	estimates := map[group]*CostEstimate{}
+	for _, m := range manifests {
+		chart := m.Labels()[chartLabel]
+		if chart == "" {
+			chart = m.PodTemplateLabels()[chartLabel]
+		}
+		g := group{chart: chart, namespace: namespaceOf(m)}
+		estimate, ok := estimates[g]
+		if !ok {
+			estimate = &CostEstimate{Chart: g.chart, Namespace: g.namespace}
+			estimates[g] = estimate
+		}
+
+		replicas := podReplicas(m, opts.DaemonSetNodes)
+		if replicas > 0 {
+			pod, err := newPodResources(m.PodSpec(), limitRanges[g.namespace])
+			if err != nil {
+				return nil, fmt.Errorf(`estimating cost of %s %s: %w`, m.GVK(), m.Name(), err)
+			}
+			estimate.CPU += pod.requests["cpu"] * float64(replicas)
+			estimate.Memory += pod.requests["memory"] * float64(replicas)
+			estimate.Monthly += (pod.requests["cpu"]*opts.Pricing.CPU + pod.requests["memory"]/gib*opts.Pricing.Memory) * float64(replicas)
+		}
+
+		claims, err := persistentClaims(m, replicas)
+		if err != nil {
+			return nil, fmt.Errorf(`estimating cost of %s %s: %w`, m.GVK(), m.Name(), err)
+		}
+		for _, claim := range claims {
+			rate, ok := opts.Pricing.StorageClasses[claim.class]
+			if !ok {
+				rate = opts.Pricing.Storage
+			}
+			estimate.Storage += claim.storage * claim.count
+			estimate.Monthly += claim.storage / gib * claim.count * rate
+		}
+
+		if serviceType, _ := yamlPlus.GetString(m, "spec", "type"); m.GVK().GroupKind() == (GroupKind{Kind: "Service"}) && serviceType == "LoadBalancer" {
+			estimate.LoadBalancers++
+			estimate.Monthly += opts.Pricing.LoadBalancer
+		}
+	}
+
+	var sorted []CostEstimate
+	for _, estimate := range estimates {
+		if estimate.CPU == 0 && estimate.Memory == 0 && estimate.Storage == 0 && estimate.LoadBalancers == 0 {
+			continue
+		}
+		sorted = append(sorted, *estimate)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Chart < sorted[j].Chart
+	})
+	return sorted, nil
+}
+
+// gib is the number of bytes of a GiB, the unit of memory and storage rates.
+const gib = 1 << 30
+
+// CostReport formats estimates as a markdown table with a total, suitable
+// for a pull request comment. e.g:
+//   | Namespace | Chart               | CPU  | Memory | Storage | Monthly    |
+//   |-----------|---------------------|------|--------|---------|------------|
+//   | ingress   | ingress-nginx-4.0.6 | 500m | 512Mi  | 0       | 14.08 USD  |
+//   | **Total** |                     | 500m | 512Mi  | 0       | 14.08 USD  |
+func CostReport(estimates []CostEstimate, currency string) string {
+	rows := [][]string{{"Namespace", "Chart", "CPU", "Memory", "Storage", "Monthly"}}
+	var total CostEstimate
+	for _, estimate := range estimates {
+		chart := estimate.Chart
+		if chart == "" {
+			chart = "-"
+		}
+		rows = append(rows, estimate.row(estimate.Namespace, chart, currency))
+		total.CPU += estimate.CPU
+		total.Memory += estimate.Memory
+		total.Storage += estimate.Storage
+		total.Monthly += estimate.Monthly
+	}
+	rows = append(rows, total.row("**Total**", "", currency))
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for idx, cell := range row {
+			if len(cell) > widths[idx] {
+				widths[idx] = len(cell)
+			}
+		}
+	}
+	var report strings.Builder
+	for idx, row := range rows {
+		for column, cell := range row {
+			fmt.Fprintf(&report, "| %-*s ", widths[column], cell)
+		}
+		report.WriteString("|\n")
+		if idx == 0 {
+			for _, width := range widths {
+				report.WriteString("|" + strings.Repeat("-", width+2))
+			}
+			report.WriteString("|\n")
+		}
+	}
+	return report.String()
+}
+
+func (e CostEstimate) row(namespace string, chart string, currency string) []string {
+	monthly := fmt.Sprintf("%.2f", e.Monthly)
+	if currency != "" {
+		monthly += " " + currency
+	}
+	return []string{
+		namespace,
+		chart,
+		formatQuantity("cpu", e.CPU),
+		formatQuantity("memory", e.Memory),
+		formatQuantity("storage", e.Storage),
+		monthly,
+	}
+}
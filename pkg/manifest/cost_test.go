@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	labels := map[string]interface{}{"helm.sh/chart": "web-1.0.0"}
+	manifests := []Manifest{
+		{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "web", "namespace": "web", "labels": labels},
+			"spec": map[string]interface{}{"replicas": 2, "template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "app", "resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "500m", "memory": "1Gi"}}},
+			}}}}},
+		{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web", "namespace": "web", "labels": labels},
+			"spec": map[string]interface{}{"type": "LoadBalancer"}},
+		{"apiVersion": "apps/v1", "kind": "StatefulSet", "metadata": map[string]interface{}{"name": "db", "namespace": "web"},
+			"spec": map[string]interface{}{"replicas": 3, "template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "db", "resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1", "memory": "2Gi"}}},
+			}}},
+				"volumeClaimTemplates": []interface{}{map[string]interface{}{"spec": map[string]interface{}{
+					"storageClassName": "premium", "resources": map[string]interface{}{"requests": map[string]interface{}{"storage": "10Gi"}}}}}}},
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config", "namespace": "other"}},
+	}
+	pricing := Pricing{Currency: "USD", CPU: 20, Memory: 2, Storage: 0.1, StorageClasses: map[string]float64{"premium": 0.2}, LoadBalancer: 15}
+
+	got, err := EstimateCost(manifests, CostOptions{Pricing: pricing})
+	if err != nil {
+		t.Fatalf("EstimateCost() error = %v", err)
+	}
+	want := []CostEstimate{
+		// 3 x (1 core x 20 + 2Gi x 2 requested as the limits) + 3 x 10Gi x 0.2
+		{Chart: "", Namespace: "web", CPU: 3, Memory: 6 << 30, Storage: 30 << 30, Monthly: 78},
+		// 2 x (500m x 20 + 1Gi x 2) + a load balancer
+		{Chart: "web-1.0.0", Namespace: "web", CPU: 1, Memory: 2 << 30, LoadBalancers: 1, Monthly: 39},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EstimateCost() = %+v, want %+v", got, want)
+	}
+
+	wantReport := `| Namespace | Chart     | CPU | Memory | Storage | Monthly    |
+|-----------|-----------|-----|--------|---------|------------|
+| web       | -         | 3   | 6Gi    | 30Gi    | 78.00 USD  |
+| web       | web-1.0.0 | 1   | 2Gi    | 0       | 39.00 USD  |
+| **Total** |           | 4   | 8Gi    | 30Gi    | 117.00 USD |
+`
+	if report := CostReport(got, pricing.Currency); report != wantReport {
+		t.Errorf("CostReport() = \n%s\nwant\n%s", report, wantReport)
+	}
+}
+
+func TestLoadPricing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	if err := os.WriteFile(path, []byte("currency: EUR\ncpu: 20\nstorageClasses:\n  premium: 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPricing(path)
+	if err != nil {
+		t.Fatalf("LoadPricing() error = %v", err)
+	}
+	want := Pricing{Currency: "EUR", CPU: 20, StorageClasses: map[string]float64{"premium": 0.2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadPricing() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"fmt"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+const (
+	// CheckMissingPDB is the check identifier for workloads without a
+	// PodDisruptionBudget.
+	CheckMissingPDB = "missing-pdb"
+	// CheckMissingHPA is the check identifier for workloads scaled beyond the
+	// replica threshold without a HorizontalPodAutoscaler.
+	CheckMissingHPA = "missing-hpa"
+)
+
+// CoverageOptions configure CheckCoverage.
+type CoverageOptions struct {
+	// MinReplicasForPDB is the number of replicas at which a workload requires
+	// a PodDisruptionBudget. Defaults to 2 as a single replica cannot maintain
+	// availability during a disruption anyway.
+	MinReplicasForPDB int
+	// MaxReplicasWithoutHPA is the number of replicas above which a workload
+	// is expected to be autoscaled by a HorizontalPodAutoscaler. 0 disables
+	// the check.
+	MaxReplicasWithoutHPA int
+}
+
+// CheckCoverage flags Deployments and StatefulSets in the render which lack a
+// PodDisruptionBudget selecting their pods or which run more replicas than
+// opts.MaxReplicasWithoutHPA without a HorizontalPodAutoscaler targeting them.
+func CheckCoverage(manifests []Manifest, opts CoverageOptions) []Finding {
+	if opts.MinReplicasForPDB <= 0 {
+		opts.MinReplicasForPDB = 2
+	}
+
+	var pdbs, hpas, workloads []Manifest
+	for _, m := range manifests {
+		switch m.Kind() {
+		case "PodDisruptionBudget":
+			pdbs = append(pdbs, m)
+		case "HorizontalPodAutoscaler":
+			hpas = append(hpas, m)
+		case "Deployment", "StatefulSet":
+			workloads = append(workloads, m)
+		}
+	}
+
+	var findings []Finding
+	for _, workload := range workloads {
+		replicas, ok := yamlPlus.GetInt(workload, "spec", "replicas")
+		if !ok {
+			replicas = 1 // the kubernetes default
+		}
+		hpa := findHPA(hpas, workload)
+
+		// autoscaled workloads may scale beyond their static replica count
+		if replicas >= opts.MinReplicasForPDB || hpa != nil {
+			if !hasPDB(pdbs, workload) {
+				findings = append(findings, Finding{
+					Check:    CheckMissingPDB,
+					Severity: SeverityWarning,
+					Resource: KeyOf(workload),
+					Message:  fmt.Sprintf("%s with %d replicas has no PodDisruptionBudget selecting its pods", workload.Kind(), replicas),
+				})
+			}
+		}
+
+		if opts.MaxReplicasWithoutHPA > 0 && replicas > opts.MaxReplicasWithoutHPA && hpa == nil {
+			findings = append(findings, Finding{
+				Check:    CheckMissingHPA,
+				Severity: SeverityWarning,
+				Resource: KeyOf(workload),
+				Message:  fmt.Sprintf("%s has %d replicas which exceeds %d without a HorizontalPodAutoscaler", workload.Kind(), replicas, opts.MaxReplicasWithoutHPA),
+			})
+		}
+	}
+
+	return findings
+}
+
+// hasPDB determines if any PodDisruptionBudget in the workloads namespace
+// selects the workloads pods.
+func hasPDB(pdbs []Manifest, workload Manifest) bool {
+	labels := workload.PodTemplateLabels()
+	for _, pdb := range pdbs {
+		if pdb.Namespace() != workload.Namespace() {
+			continue
+		}
+		selector, ok := yamlPlus.GetMap(pdb, "spec", "selector")
+		if !ok || len(selector) == 0 {
+			// an empty PDB selector selects nothing in policy/v1beta1
+			continue
+		}
+		if MatchesLabelSelector(selector, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// findHPA returns the HorizontalPodAutoscaler in the workloads namespace whose
+// scaleTargetRef points at the workload.
+func findHPA(hpas []Manifest, workload Manifest) Manifest {
+	for _, hpa := range hpas {
+		if hpa.Namespace() != workload.Namespace() {
+			continue
+		}
+		kind, _ := yamlPlus.GetString(hpa, "spec", "scaleTargetRef", "kind")
+		name, _ := yamlPlus.GetString(hpa, "spec", "scaleTargetRef", "name")
+		if kind == workload.Kind() && name == workload.Name() {
+			return hpa
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func workload(kind string, name string, replicas int, labels map[string]interface{}) Manifest {
+	return Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "web"},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+			},
+		},
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	covered := workload("Deployment", "covered", 3, map[string]interface{}{"app": "covered"})
+	uncovered := workload("StatefulSet", "uncovered", 3, map[string]interface{}{"app": "uncovered"})
+	single := workload("Deployment", "single", 1, map[string]interface{}{"app": "single"})
+	large := workload("Deployment", "large", 10, map[string]interface{}{"app": "large", "tier": "web"})
+	pdb := Manifest{
+		"apiVersion": "policy/v1",
+		"kind":       "PodDisruptionBudget",
+		"metadata":   map[string]interface{}{"name": "covered", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"app": "covered"},
+			},
+		},
+	}
+	largePDB := Manifest{
+		"apiVersion": "policy/v1",
+		"kind":       "PodDisruptionBudget",
+		"metadata":   map[string]interface{}{"name": "large", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchExpressions": []interface{}{
+					map[string]interface{}{"key": "tier", "operator": "In", "values": []interface{}{"web"}},
+				},
+			},
+		},
+	}
+
+	got := CheckCoverage([]Manifest{covered, uncovered, single, large, pdb, largePDB}, CoverageOptions{MaxReplicasWithoutHPA: 5})
+	var gotChecks []string
+	for _, finding := range got {
+		gotChecks = append(gotChecks, finding.Check+":"+finding.Resource.Name)
+	}
+	want := []string{CheckMissingPDB + ":uncovered", CheckMissingHPA + ":large"}
+	if !reflect.DeepEqual(gotChecks, want) {
+		t.Errorf("CheckCoverage() = %v, want %v", got, want)
+	}
+
+	// an HPA targeting the workload silences the HPA finding
+	hpa := Manifest{
+		"apiVersion": "autoscaling/v2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": "large", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"kind": "Deployment", "name": "large"},
+		},
+	}
+	if got := CheckCoverage([]Manifest{large, largePDB, hpa}, CoverageOptions{MaxReplicasWithoutHPA: 5}); len(got) != 0 {
+		t.Errorf("CheckCoverage() = %v, want no findings", got)
+	}
+}
@@ -0,0 +1,181 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeType is the type of a Change between two renders.
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Changed ChangeType = "changed"
+	Removed ChangeType = "removed"
+)
+
+// Change is a single resource which differs between two renders.
+type Change struct {
+	Type     ChangeType
+	Resource Key
+	Before   Manifest // nil when Added
+	After    Manifest // nil when Removed
+}
+
+// Diff is the set of resources which differ between two renders. Each list is
+// sorted by resource.
+type Diff struct {
+	Added   []Change
+	Changed []Change
+	Removed []Change
+}
+
+// Empty determines if the two renders were identical.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Summary returns a human readable summary of the diff, suitable for a CI
+// comment. e.g:
+//   1 added, 1 changed, 0 removed
+//   + apps/v1, Kind=Deployment web/api
+//   ~ v1, Kind=Service web/api
+func (d Diff) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d added, %d changed, %d removed\n", len(d.Added), len(d.Changed), len(d.Removed))
+	for _, section := range []struct {
+		symbol  string
+		changes []Change
+	}{{"+", d.Added}, {"~", d.Changed}, {"-", d.Removed}} {
+		for _, change := range section.changes {
+			fmt.Fprintf(&b, "%s %s %s\n", section.symbol, change.Resource.GVK, resourceName(change.Resource))
+		}
+	}
+	return b.String()
+}
+
+// DiffManifests compares two renders resource by resource. Resources are
+// matched by GroupVersionKind, namespace and name and are considered changed
+// if their yaml representations differ.
+func DiffManifests(before []Manifest, after []Manifest) (Diff, error) {
+	var diff Diff
+	beforeByKey := map[Key]Manifest{}
+	for _, m := range before {
+		beforeByKey[KeyOf(m)] = m
+	}
+	afterByKey := map[Key]Manifest{}
+	for _, m := range after {
+		afterByKey[KeyOf(m)] = m
+	}
+
+	for key, a := range afterByKey {
+		b, existed := beforeByKey[key]
+		if !existed {
+			diff.Added = append(diff.Added, Change{Type: Added, Resource: key, After: a})
+			continue
+		}
+		equal, err := yamlEqual(b, a)
+		if err != nil {
+			return diff, fmt.Errorf(`comparing %s %s: %w`, key.GVK, resourceName(key), err)
+		}
+		if !equal {
+			diff.Changed = append(diff.Changed, Change{Type: Changed, Resource: key, Before: b, After: a})
+		}
+	}
+	for key, b := range beforeByKey {
+		if _, exists := afterByKey[key]; !exists {
+			diff.Removed = append(diff.Removed, Change{Type: Removed, Resource: key, Before: b})
+		}
+	}
+
+	for _, changes := range [][]Change{diff.Added, diff.Changed, diff.Removed} {
+		sort.Slice(changes, func(i, j int) bool {
+			return keyString(changes[i].Resource) < keyString(changes[j].Resource)
+		})
+	}
+
+	return diff, nil
+}
+
+// CompareWithSnapshot diffs manifests against a render previously written to
+// dir (e.g. the output directory committed to a GitOps repository), so CI can
+// report what a change will do to the rendered output.
+func CompareWithSnapshot(dir string, manifests []Manifest) (Diff, error) {
+	snapshot, err := LoadDirectory(dir)
+	if err != nil {
+		return Diff{}, fmt.Errorf(`loading snapshot from %s: %w`, dir, err)
+	}
+	return DiffManifests(snapshot, manifests)
+}
+
+// LoadDirectory decodes all manifests in the yaml files (.yaml and .yml) found
+// recursively in dir. Files are read in lexical order and empty documents are
+// dropped.
+func LoadDirectory(dir string) ([]Manifest, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		extension := strings.ToLower(filepath.Ext(path))
+		if !entry.IsDir() && (extension == ".yaml" || extension == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`walking directory %s: %w`, dir, err)
+	}
+	sort.Strings(files)
+
+	var manifests []Manifest
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf(`reading %s: %w`, file, err)
+		}
+		maps, err := yamlPlus.DecodeMaps(content)
+		if err != nil {
+			return nil, fmt.Errorf(`decoding %s: %w`, file, err)
+		}
+		manifests = append(manifests, FromMaps(maps)...)
+	}
+
+	return manifests, nil
+}
+
+// yamlEqual compares the yaml representation of two manifests, ignoring
+// differences in the Go types used to hold the same values (e.g. int vs
+// float64 or []interface{} vs []map[string]interface{}) and map ordering.
+func yamlEqual(a Manifest, b Manifest) (bool, error) {
+	aBytes, err := yaml.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	bBytes, err := yaml.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aBytes, bBytes), nil
+}
+
+// resourceName formats the namespace and name of a resource as
+// <namespace>/<name> or <name> for cluster-scoped resources.
+func resourceName(key Key) string {
+	if key.Namespace == "" {
+		return key.Name
+	}
+	return key.Namespace + "/" + key.Name
+}
+
+// keyString formats a Key for sorting.
+func keyString(key Key) string {
+	return strings.Join([]string{key.GVK.Group, key.GVK.Kind, key.Namespace, key.Name, key.GVK.Version}, "\x00")
+}
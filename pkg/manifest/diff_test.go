@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareWithSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := `
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+  namespace: web
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+  namespace: web
+`
+	if err := os.MkdirAll(filepath.Join(dir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "all.yaml"), []byte(snapshot), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "deployment.yml"), []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current := []Manifest{
+		// unchanged, but using different go types than the decoded snapshot
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"namespace": "web", "name": "nginx"},
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web"},
+			"spec":       map[string]interface{}{"ports": []map[string]interface{}{{"port": 8080}}},
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "added", "namespace": "web"},
+		},
+	}
+
+	diff, err := CompareWithSnapshot(dir, current)
+	if err != nil {
+		t.Fatalf("CompareWithSnapshot() error = %v", err)
+	}
+	want := `1 added, 1 changed, 1 removed
++ v1, Kind=Secret web/added
+~ v1, Kind=Service web/nginx
+- v1, Kind=ConfigMap web/removed
+`
+	if got := diff.Summary(); got != want {
+		t.Errorf("Diff.Summary() = %v, want %v", got, want)
+	}
+
+	if diff, err := CompareWithSnapshot(dir, current[:0]); err != nil || len(diff.Removed) != 3 {
+		t.Errorf("CompareWithSnapshot() = %v, %v, want 3 removed resources", diff, err)
+	}
+}
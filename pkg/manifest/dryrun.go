@@ -0,0 +1,171 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// CheckAdmission is the check identifier for resources rejected by the API
+// server during a server-side dry-run (e.g. by an admission webhook or
+// validation of the API server).
+const CheckAdmission = "admission"
+
+// ErrClusterUnreachable is wrapped by errors of DryRun when the API server
+// cannot be reached, as opposed to rejecting a resource.
+var ErrClusterUnreachable = errcode.New(errcode.ManifestClusterUnreachable, "cluster unreachable")
+
+// DryRunOptions configures DryRun. The cluster is reached with kubectl and
+// its usual configuration ($KUBECONFIG and the current context) unless
+// overridden.
+type DryRunOptions struct {
+	Kubectl    string   // kubectl binary. defaults to kubectl on $PATH
+	Kubeconfig string   // --kubeconfig
+	Context    string   // --context
+	Namespace  string   // --namespace. namespace of namespaced resources which do not set one
+	Env        []string // added to the environment of kubectl
+
+	Ctx context.Context // kills kubectl when done, e.g. when the process receives SIGTERM. defaults to context.Background()
+}
+
+// DryRunStatus is the outcome of the dry-run of a resource.
+type DryRunStatus string
+
+const (
+	DryRunAccepted DryRunStatus = "accepted"
+	DryRunRejected DryRunStatus = "rejected"
+	DryRunSkipped  DryRunStatus = "skipped" // the resource depends on a Namespace or CustomResourceDefinition of the render which a dry-run does not create
+)
+
+// DryRunResult is the result of the dry-run of a single resource.
+type DryRunResult struct {
+	Resource Key
+	Status   DryRunStatus
+	Message  string // the reason of a rejected or skipped resource. e.g: `admission webhook "validate.kyverno.svc" denied the request: ...`
+}
+
+// unreachablePatterns match kubectl errors caused by the cluster rather than
+// the resource.
+var unreachablePatterns = regexp.MustCompile(`(?i)(unable to connect to the server|connection refused|connection to the server .* was refused|no such host|i/o timeout|the server has asked for the client to provide credentials|no configuration has been provided|current-context is not set|context was not found)`)
+
+// missingNamespaceRgx and missingKindRgx match kubectl errors of resources
+// whose namespace or kind does not exist (yet) in the cluster.
+var (
+	missingNamespaceRgx = regexp.MustCompile(`namespaces "([^"]+)" not found`)
+	missingKindRgx      = regexp.MustCompile(`no matches for kind "([^"]+)" in version "([^"]+)"`)
+)
+
+// DryRun sends each of manifests to the API server as a server-side dry-run
+// apply (dryRun=All): the request passes authentication, defaulting,
+// validation and all admission webhooks (e.g. OPA Gatekeeper or Kyverno
+// policies) but nothing is persisted. This catches rejections static checks
+// such as ValidateSchemas cannot, reporting them per resource.
+//
+// As nothing is persisted, resources in a Namespace, or of a kind defined by a
+// CustomResourceDefinition, created by the same render cannot be dry-run until
+// it is applied and are skipped. An error wrapping ErrClusterUnreachable is
+// returned if the API server cannot be reached.
+func DryRun(manifests []Manifest, opts DryRunOptions) ([]DryRunResult, error) {
+	namespaces, kinds := map[string]bool{}, map[GroupKind]bool{}
+	for _, m := range manifests {
+		switch m.GVK().GroupKind() {
+		case GroupKind{Kind: "Namespace"}:
+			namespaces[m.Name()] = true
+		case GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:
+			group, _ := yamlPlus.GetString(m, "spec", "group")
+			kind, _ := yamlPlus.GetString(m, "spec", "names", "kind")
+			kinds[GroupKind{Group: group, Kind: kind}] = true
+		}
+	}
+
+	var results []DryRunResult
+	for _, m := range manifests {
+		result := DryRunResult{Resource: KeyOf(m), Status: DryRunAccepted}
+		stderr, err := opts.apply(m)
+		if err != nil {
+			switch {
+			case unreachablePatterns.MatchString(stderr):
+				return nil, fmt.Errorf(`dry-running %s %s: %w: %s`, m.GVK(), m.Name(), ErrClusterUnreachable, stderr)
+			case stderr == "":
+				return nil, fmt.Errorf(`dry-running %s %s: %w`, m.GVK(), m.Name(), err)
+			}
+			result.Status, result.Message = DryRunRejected, stderr
+			if match := missingNamespaceRgx.FindStringSubmatch(stderr); match != nil && namespaces[match[1]] {
+				result.Status = DryRunSkipped
+			} else if match := missingKindRgx.FindStringSubmatch(stderr); match != nil && kinds[ParseGVK(match[2], match[1]).GroupKind()] {
+				result.Status = DryRunSkipped
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// apply runs `kubectl apply --dry-run=server` for m, returning the trimmed
+// stderr of kubectl.
+func (opts DryRunOptions) apply(m Manifest) (string, error) {
+	content, err := Encode([]Manifest{m})
+	if err != nil {
+		return "", err
+	}
+	args := []string{"apply", "--dry-run=server", "--filename", "-"}
+	if opts.Namespace != "" {
+		args = append(args, "--namespace", opts.Namespace)
+	}
+	cmd := opts.command(args...)
+	cmd.Stdin = bytes.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	return strings.TrimSpace(stderr.String()), err
+}
+
+// command returns the kubectl command running args against the cluster of
+// opts.
+func (opts DryRunOptions) command(args ...string) *exec.Cmd {
+	kubectl := opts.Kubectl
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	if opts.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", opts.Kubeconfig)
+	}
+	if opts.Context != "" {
+		args = append(args, "--context", opts.Context)
+	}
+	cmd := exec.CommandContext(orBackground(opts.Ctx), kubectl, args...)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	return cmd
+}
+
+// orBackground returns ctx, or context.Background() if ctx is nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// DryRunFindings converts rejected results into Findings.
+func DryRunFindings(results []DryRunResult) []Finding {
+	var findings []Finding
+	for _, result := range results {
+		if result.Status != DryRunRejected {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:    CheckAdmission,
+			Severity: SeverityError,
+			Resource: result.Resource,
+			Message:  result.Message,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,86 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeFakeKubectl writes a shell script receiving the dry-run manifest on
+// stdin as a kubectl binary.
+func writeFakeKubectl(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake kubectl binary is a shell script")
+	}
+	kubectl := filepath.Join(t.TempDir(), "kubectl")
+	if err := os.WriteFile(kubectl, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return kubectl
+}
+
+func TestDryRun(t *testing.T) {
+	kubectl := writeFakeKubectl(t, `manifest=$(cat)
+case "$manifest" in
+*"name: privileged"*) echo 'Error from server: admission webhook "validate.kyverno.svc" denied the request: privileged containers are not allowed' >&2; exit 1 ;;
+*"namespace: team-a"*) echo 'Error from server (NotFound): namespaces "team-a" not found' >&2; exit 1 ;;
+*"namespace: team-b"*) echo 'Error from server (NotFound): namespaces "team-b" not found' >&2; exit 1 ;;
+*"apiVersion: example.com/v1"*) echo 'error: resource mapping not found: no matches for kind "Widget" in version "example.com/v1"' >&2; exit 1 ;;
+esac`)
+	pod := func(name string, namespace string) Manifest {
+		return Manifest{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": name, "namespace": namespace}}
+	}
+	manifests := []Manifest{
+		{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "team-a"}},
+		{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": map[string]interface{}{"name": "widgets.example.com"},
+			"spec": map[string]interface{}{"group": "example.com", "names": map[string]interface{}{"kind": "Widget"}}},
+		pod("web", "default"),
+		pod("privileged", "default"),
+		pod("api", "team-a"),
+		pod("api", "team-b"),
+		{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": map[string]interface{}{"name": "widget"}},
+	}
+
+	results, err := DryRun(manifests, DryRunOptions{Kubectl: kubectl})
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	var got []DryRunStatus
+	for _, result := range results {
+		got = append(got, result.Status)
+	}
+	want := []DryRunStatus{DryRunAccepted, DryRunAccepted, DryRunAccepted, DryRunRejected, DryRunSkipped, DryRunRejected, DryRunSkipped}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DryRun() statuses = %v, want %v", got, want)
+	}
+
+	findings := DryRunFindings(results)
+	if len(findings) != 2 || findings[0].Check != CheckAdmission || findings[0].Resource.Name != "privileged" {
+		t.Errorf("DryRunFindings() = %v, want rejections of privileged and team-b/api", findings)
+	}
+
+	unreachable := writeFakeKubectl(t, `echo 'The connection to the server localhost:8080 was refused - did you specify the right host or port?' >&2; exit 1`)
+	if _, err := DryRun(manifests, DryRunOptions{Kubectl: unreachable}); !errors.Is(err, ErrClusterUnreachable) {
+		t.Errorf("DryRun() unreachable cluster error = %v, want ErrClusterUnreachable", err)
+	}
+}
+
+func TestDryRun_cancelled(t *testing.T) {
+	kubectl := writeFakeKubectl(t, `exec sleep 10`)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	pod := Manifest{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "web"}}
+	if _, err := DryRun([]Manifest{pod}, DryRunOptions{Kubectl: kubectl, Ctx: ctx}); err == nil {
+		t.Errorf("DryRun() error = nil, want kubectl killed")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("DryRun() took %s, want kubectl killed when the context is done", elapsed)
+	}
+}
@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretEncrypter encrypts v1 Secret manifests so rendered output can be
+// safely committed to a GitOps repository.
+type SecretEncrypter interface {
+	EncryptSecret(secret Manifest) (Manifest, error)
+}
+
+// SecretEncrypterFunc adapts a function into a SecretEncrypter.
+type SecretEncrypterFunc func(secret Manifest) (Manifest, error)
+
+// EncryptSecret calls f(secret).
+func (f SecretEncrypterFunc) EncryptSecret(secret Manifest) (Manifest, error) {
+	return f(secret)
+}
+
+// IsSecret determines if m is a core v1 Secret.
+func IsSecret(m Manifest) bool {
+	return m.APIVersion() == "v1" && m.Kind() == "Secret"
+}
+
+// EncryptSecrets returns a copy of manifests with every v1 Secret encrypted by
+// encrypter. Other manifests are returned as-is.
+func EncryptSecrets(manifests []Manifest, encrypter SecretEncrypter) ([]Manifest, error) {
+	encrypted := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		if !IsSecret(m) {
+			encrypted[idx] = m
+			continue
+		}
+		secret, err := encrypter.EncryptSecret(m.DeepCopy())
+		if err != nil {
+			return nil, fmt.Errorf(`encrypting secret %s: %w`, resourceName(KeyOf(m)), err)
+		}
+		encrypted[idx] = secret
+	}
+	return encrypted, nil
+}
+
+// SOPSEncrypter encrypts Secrets in the SOPS format by running the sops CLI
+// (https://github.com/getsops/sops) with age and/or PGP recipients. Only the
+// values of data and stringData are encrypted by default so the resource
+// identity remains readable. The encrypted Secrets can be decrypted by tools
+// such as the Flux kustomize-controller or helm-secrets.
+type SOPSEncrypter struct {
+	AgeRecipients   []string // age public keys. e.g: "age1..."
+	PGPFingerprints []string // PGP key fingerprints
+	EncryptedRegex  string   // --encrypted-regex. defaults to "^(data|stringData)$"
+	Binary          string   // path of the sops binary. defaults to "sops"
+}
+
+// EncryptSecret runs `sops --encrypt` on secret.
+func (s SOPSEncrypter) EncryptSecret(secret Manifest) (Manifest, error) {
+	if len(s.AgeRecipients) == 0 && len(s.PGPFingerprints) == 0 {
+		return nil, fmt.Errorf(`encrypting with sops: no age recipients or PGP fingerprints provided`)
+	}
+	content, err := yaml.Marshal(map[string]interface{}(secret))
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling secret: %w`, err)
+	}
+
+	// sops determines the input format from the file extension
+	tmpDir, err := os.MkdirTemp("", "sops")
+	if err != nil {
+		return nil, fmt.Errorf(`creating temporary directory for sops: %w`, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	secretPath := filepath.Join(tmpDir, "secret.yaml")
+	if err := os.WriteFile(secretPath, content, 0600); err != nil {
+		return nil, fmt.Errorf(`writing secret for sops: %w`, err)
+	}
+
+	binary, encryptedRegex := s.Binary, s.EncryptedRegex
+	if binary == "" {
+		binary = "sops"
+	}
+	if encryptedRegex == "" {
+		encryptedRegex = "^(data|stringData)$"
+	}
+	args := []string{"--encrypt", "--encrypted-regex", encryptedRegex}
+	if len(s.AgeRecipients) > 0 {
+		args = append(args, "--age", strings.Join(s.AgeRecipients, ","))
+	}
+	if len(s.PGPFingerprints) > 0 {
+		args = append(args, "--pgp", strings.Join(s.PGPFingerprints, ","))
+	}
+	args = append(args, secretPath)
+
+	cmd := exec.Command(binary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(`running "%s": %w: %v`, cmd, err, stderr.String())
+	}
+
+	maps, err := yamlPlus.DecodeMaps(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf(`parsing output of sops: %w`, err)
+	}
+	if len(maps) != 1 {
+		return nil, fmt.Errorf(`parsing output of sops: expected 1 document, found %d`, len(maps))
+	}
+	return Manifest(maps[0]), nil
+}
@@ -0,0 +1,31 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncryptSecrets(t *testing.T) {
+	manifests := []Manifest{
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}, "data": map[string]interface{}{"key": "value"}},
+		{"apiVersion": "v1", "kind": "Secret", "metadata": map[string]interface{}{"name": "secret"}, "data": map[string]interface{}{"key": "c2VjcmV0"}},
+	}
+	encrypter := SecretEncrypterFunc(func(secret Manifest) (Manifest, error) {
+		secret["data"] = map[string]interface{}{"key": "ENC[c2VjcmV0]"}
+		return secret, nil
+	})
+
+	got, err := EncryptSecrets(manifests, encrypter)
+	if err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+	if !reflect.DeepEqual(got[0], manifests[0]) {
+		t.Errorf("EncryptSecrets() modified non-secret %v", got[0])
+	}
+	if want := map[string]interface{}{"key": "ENC[c2VjcmV0]"}; !reflect.DeepEqual(got[1]["data"], want) {
+		t.Errorf("EncryptSecrets() secret data = %v, want %v", got[1]["data"], want)
+	}
+	if manifests[1]["data"].(map[string]interface{})["key"] != "c2VjcmV0" {
+		t.Errorf("EncryptSecrets() modified the original secret")
+	}
+}
@@ -0,0 +1,159 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filter selects manifests by kind, name and labels. Empty fields match all
+// manifests. A Filter is a Transformer, so it can be used with
+// TemplateOptions.Transformers to drop resources (e.g. test Pods, Jobs or
+// PodSecurityPolicies) from a render.
+type Filter struct {
+	IncludeKinds []string // only keep manifests of these kinds
+	ExcludeKinds []string // drop manifests of these kinds
+
+	IncludeNames []string // only keep manifests whose name matches one of these patterns (path.Match syntax, e.g. "nginx-*")
+	ExcludeNames []string // drop manifests whose name matches one of these patterns
+
+	// LabelSelector only keeps manifests whose labels match the selector, in
+	// the syntax of `kubectl --selector`. e.g:
+	//   app=nginx,tier!=cache,environment in (staging, production),!legacy
+	LabelSelector string
+}
+
+// Apply returns the manifests matched by the filter, in order.
+func (f Filter) Apply(manifests []Manifest) ([]Manifest, error) {
+	selector, err := ParseLabelSelector(f.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range append(append([]string{}, f.IncludeNames...), f.ExcludeNames...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf(`invalid name pattern "%s": %w`, pattern, err)
+		}
+	}
+
+	var filtered []Manifest
+	for _, m := range manifests {
+		if f.matches(m, selector) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered, nil
+}
+
+// Transform is the same as Apply.
+func (f Filter) Transform(manifests []Manifest) ([]Manifest, error) {
+	return f.Apply(manifests)
+}
+
+func (f Filter) matches(m Manifest, selector map[string]interface{}) bool {
+	if len(f.IncludeKinds) > 0 && !contains(f.IncludeKinds, m.Kind()) {
+		return false
+	}
+	if contains(f.ExcludeKinds, m.Kind()) {
+		return false
+	}
+	if len(f.IncludeNames) > 0 && !matchesAny(f.IncludeNames, m.Name()) {
+		return false
+	}
+	if matchesAny(f.ExcludeNames, m.Name()) {
+		return false
+	}
+	return MatchesLabelSelector(selector, m.Labels())
+}
+
+// matchesAny determines if name matches any of the path.Match patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// setRequirementRgx matches set-based label selector requirements such as
+// "environment in (staging, production)".
+var setRequirementRgx = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// ParseLabelSelector parses a label selector in the syntax of
+// `kubectl --selector` (e.g. "app=nginx,tier!=cache,env in (a, b),!legacy")
+// into a Kubernetes LabelSelector object for use with MatchesLabelSelector.
+// An empty selector matches everything.
+func ParseLabelSelector(selector string) (map[string]interface{}, error) {
+	var expressions []interface{}
+	for _, raw := range splitRequirements(selector) {
+		requirement := strings.TrimSpace(raw)
+		if requirement == "" {
+			continue
+		}
+		var key, operator string
+		var values []interface{}
+		switch {
+		case setRequirementRgx.MatchString(requirement):
+			match := setRequirementRgx.FindStringSubmatch(requirement)
+			key, operator = match[1], "In"
+			if match[2] == "notin" {
+				operator = "NotIn"
+			}
+			for _, value := range strings.Split(match[3], ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+		case strings.Contains(requirement, "!="):
+			parts := strings.SplitN(requirement, "!=", 2)
+			key, operator, values = parts[0], "NotIn", []interface{}{strings.TrimSpace(parts[1])}
+		case strings.Contains(requirement, "=="):
+			parts := strings.SplitN(requirement, "==", 2)
+			key, operator, values = parts[0], "In", []interface{}{strings.TrimSpace(parts[1])}
+		case strings.Contains(requirement, "="):
+			parts := strings.SplitN(requirement, "=", 2)
+			key, operator, values = parts[0], "In", []interface{}{strings.TrimSpace(parts[1])}
+		case strings.HasPrefix(requirement, "!"):
+			key, operator = requirement[1:], "DoesNotExist"
+		default:
+			key, operator = requirement, "Exists"
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, " ()!=") {
+			return nil, fmt.Errorf(`invalid label selector requirement "%s"`, requirement)
+		}
+		expression := map[string]interface{}{"key": key, "operator": operator}
+		if values != nil {
+			expression["values"] = values
+		}
+		expressions = append(expressions, expression)
+	}
+
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+	return map[string]interface{}{"matchExpressions": expressions}, nil
+}
+
+// splitRequirements splits a label selector on the commas which are not
+// within parentheses.
+func splitRequirements(selector string) []string {
+	var requirements []string
+	depth, start := 0, 0
+	for idx, char := range selector {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				requirements = append(requirements, selector[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(requirements, selector[start:])
+}
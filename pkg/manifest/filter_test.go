@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter_Apply(t *testing.T) {
+	resource := func(kind string, name string, labels map[string]interface{}) Manifest {
+		return Manifest{"kind": kind, "metadata": map[string]interface{}{"name": name, "labels": labels}}
+	}
+	web := resource("Deployment", "web", map[string]interface{}{"app": "web", "tier": "frontend", "env": "prod"})
+	cache := resource("StatefulSet", "web-cache", map[string]interface{}{"app": "web", "tier": "cache", "env": "prod"})
+	test := resource("Pod", "web-test-connection", map[string]interface{}{"app": "web"})
+	psp := resource("PodSecurityPolicy", "restricted", nil)
+	manifests := []Manifest{web, cache, test, psp}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    []Manifest
+		wantErr bool
+	}{
+		{"empty", Filter{}, manifests, false},
+		{"include-kinds", Filter{IncludeKinds: []string{"Deployment", "StatefulSet"}}, []Manifest{web, cache}, false},
+		{"exclude-kinds", Filter{ExcludeKinds: []string{"PodSecurityPolicy"}}, []Manifest{web, cache, test}, false},
+		{"include-names", Filter{IncludeNames: []string{"web*"}}, []Manifest{web, cache, test}, false},
+		{"exclude-names", Filter{ExcludeNames: []string{"*-test-*"}}, []Manifest{web, cache, psp}, false},
+		{"equality-selector", Filter{LabelSelector: "app=web,tier!=cache"}, []Manifest{web, test}, false},
+		{"set-selector", Filter{LabelSelector: "env in (prod, staging),tier notin (cache)"}, []Manifest{web}, false},
+		{"existence-selector", Filter{LabelSelector: "app,!tier"}, []Manifest{test}, false},
+		{"combined", Filter{ExcludeKinds: []string{"Pod"}, LabelSelector: "app==web"}, []Manifest{web, cache}, false},
+		{"invalid-selector", Filter{LabelSelector: "app in prod"}, nil, true},
+		{"invalid-pattern", Filter{IncludeNames: []string{"["}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.Apply(manifests)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Filter.Apply() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter.Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package manifest
+
+import "fmt"
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is a single structured result produced by an analyzer or validator
+// run against a render.
+type Finding struct {
+	Check    string   `json:"check"`    // identifier of the check which produced the finding
+	Severity Severity `json:"severity"` // how severe the finding is
+	Resource Key      `json:"resource"` // resource the finding applies to
+	Message  string   `json:"message"`  // human readable description
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s %s/%s: %s", f.Severity, f.Check, f.Resource.GVK.Kind, f.Resource.Namespace, f.Resource.Name, f.Message)
+}
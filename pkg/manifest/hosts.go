@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// HostRewriteTransformer is a Transformer rewriting the hostnames and TLS
+// secret names of Ingresses and Gateway API HTTPRoutes, so the render of an
+// upstream chart can be served from per-environment domains.
+type HostRewriteTransformer struct {
+	// Domains maps domains to their replacement. A host matches a domain if it
+	// is the domain itself or a subdomain (including wildcards) of it; the
+	// longest matching domain wins. e.g: {"example.com": "staging.example.org"}
+	// rewrites "api.example.com" to "api.staging.example.org" and
+	// "*.example.com" to "*.staging.example.org".
+	Domains map[string]string
+	// TLSSecrets maps the secretName of Ingress TLS entries to their
+	// replacement. e.g: {"example-com-tls": "staging-wildcard-tls"}
+	TLSSecrets map[string]string
+}
+
+// Transform rewrites the hosts of the Ingresses and HTTPRoutes in manifests.
+// Manifests are copied before being modified.
+func (t HostRewriteTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	transformed := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		gk := m.GVK().GroupKind()
+		switch {
+		case gk.Kind == "Ingress" && (gk.Group == "networking.k8s.io" || gk.Group == "extensions"):
+			copied := m.DeepCopy()
+			t.rewriteIngress(copied)
+			transformed[idx] = copied
+		case gk == GroupKind{Group: "gateway.networking.k8s.io", Kind: "HTTPRoute"}:
+			copied := m.DeepCopy()
+			if spec, ok := yamlPlus.GetMap(copied, "spec"); ok {
+				t.rewriteHosts(spec, "hostnames")
+			}
+			transformed[idx] = copied
+		default:
+			transformed[idx] = m
+		}
+	}
+	return transformed, nil
+}
+
+// rewriteIngress rewrites spec.rules[].host, spec.tls[].hosts and
+// spec.tls[].secretName of ingress in place.
+func (t HostRewriteTransformer) rewriteIngress(ingress Manifest) {
+	rules, _ := yamlPlus.GetSlice(ingress, "spec", "rules")
+	for _, entry := range rules {
+		if rule, ok := entry.(map[string]interface{}); ok {
+			if host, ok := yamlPlus.GetString(rule, "host"); ok {
+				rule["host"] = t.rewriteHost(host)
+			}
+		}
+	}
+
+	tlsEntries, _ := yamlPlus.GetSlice(ingress, "spec", "tls")
+	for _, entry := range tlsEntries {
+		tls, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t.rewriteHosts(tls, "hosts")
+		if secretName, ok := yamlPlus.GetString(tls, "secretName"); ok {
+			if replacement, ok := t.TLSSecrets[secretName]; ok {
+				tls["secretName"] = replacement
+			}
+		}
+	}
+}
+
+// rewriteHosts rewrites the list of hosts found at m[field].
+func (t HostRewriteTransformer) rewriteHosts(m map[string]interface{}, field string) {
+	hosts, ok := yamlPlus.GetSlice(m, field)
+	if !ok {
+		return
+	}
+	rewritten := make([]interface{}, len(hosts))
+	for idx, entry := range hosts {
+		rewritten[idx] = entry
+		if host, ok := entry.(string); ok {
+			rewritten[idx] = t.rewriteHost(host)
+		}
+	}
+	m[field] = rewritten
+}
+
+// rewriteHost replaces the longest domain of t.Domains matching host.
+func (t HostRewriteTransformer) rewriteHost(host string) string {
+	var matched string
+	for domain := range t.Domains {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(matched) {
+			matched = domain
+		}
+	}
+	if matched == "" {
+		return host
+	}
+	return strings.TrimSuffix(host, matched) + t.Domains[matched]
+}
@@ -0,0 +1,92 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostRewriteTransformer_Transform(t *testing.T) {
+	transformer := HostRewriteTransformer{
+		Domains: map[string]string{
+			"example.com":     "staging.example.org",
+			"api.example.com": "api-staging.example.org",
+		},
+		TLSSecrets: map[string]string{"example-com-tls": "staging-tls"},
+	}
+	ingress := func(apiVersion string, hosts []interface{}, secretName string) Manifest {
+		var rules []interface{}
+		for _, host := range hosts {
+			rules = append(rules, map[string]interface{}{"host": host})
+		}
+		return Manifest{
+			"apiVersion": apiVersion,
+			"kind":       "Ingress",
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec": map[string]interface{}{
+				"rules": rules,
+				"tls":   []interface{}{map[string]interface{}{"hosts": hosts, "secretName": secretName}},
+			},
+		}
+	}
+	httpRoute := func(hostnames ...interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "gateway.networking.k8s.io/v1beta1",
+			"kind":       "HTTPRoute",
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec":       map[string]interface{}{"hostnames": hostnames},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		manifests []Manifest
+		want      []Manifest
+	}{
+		{
+			name: "ingress",
+			manifests: []Manifest{
+				ingress("networking.k8s.io/v1", []interface{}{"www.example.com", "*.example.com", "example.com", "other.net"}, "example-com-tls"),
+			},
+			want: []Manifest{
+				ingress("networking.k8s.io/v1", []interface{}{"www.staging.example.org", "*.staging.example.org", "staging.example.org", "other.net"}, "staging-tls"),
+			},
+		},
+		{
+			name: "longest-domain-wins",
+			manifests: []Manifest{
+				ingress("extensions/v1beta1", []interface{}{"v1.api.example.com", "notapi.example.com"}, "other-tls"),
+			},
+			want: []Manifest{
+				ingress("extensions/v1beta1", []interface{}{"v1.api-staging.example.org", "notapi.staging.example.org"}, "other-tls"),
+			},
+		},
+		{
+			name:      "http-route",
+			manifests: []Manifest{httpRoute("example.com", "shop.example.com")},
+			want:      []Manifest{httpRoute("staging.example.org", "shop.staging.example.org")},
+		},
+		{
+			name:      "other-kinds",
+			manifests: []Manifest{{"apiVersion": "v1", "kind": "Service", "spec": map[string]interface{}{"externalName": "example.com"}}},
+			want:      []Manifest{{"apiVersion": "v1", "kind": "Service", "spec": map[string]interface{}{"externalName": "example.com"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := make([]Manifest, len(tt.manifests))
+			for idx, m := range tt.manifests {
+				original[idx] = m.DeepCopy()
+			}
+			got, err := transformer.Transform(tt.manifests)
+			if err != nil {
+				t.Fatalf("HostRewriteTransformer.Transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HostRewriteTransformer.Transform() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.manifests, original) {
+				t.Errorf("HostRewriteTransformer.Transform() modified its input")
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"sort"
+	"strings"
+)
+
+// Image is a container image referenced by the pods of rendered workloads.
+type Image struct {
+	Reference  string // as referenced by the container. e.g: "ghcr.io/my-org/web:1.2.3@sha256:..."
+	Repository string // e.g: "ghcr.io/my-org/web"
+	Tag        string // empty for references without a tag
+	Digest     string // e.g: "sha256:...". empty for references which are not pinned
+	Resources  []Key  // the workloads referencing the image, in order
+}
+
+// ParseImage splits an image reference into its repository, tag and digest.
+func ParseImage(reference string) Image {
+	image := Image{Reference: reference, Repository: reference}
+	if idx := strings.Index(image.Repository, "@"); idx >= 0 {
+		image.Repository, image.Digest = image.Repository[:idx], image.Repository[idx+1:]
+	}
+	// a ":" after the last "/" separates the tag; before it, the port of the registry
+	if idx := strings.LastIndex(image.Repository, ":"); idx > strings.LastIndex(image.Repository, "/") {
+		image.Repository, image.Tag = image.Repository[:idx], image.Repository[idx+1:]
+	}
+	return image
+}
+
+// Images returns the container images (including those of init and ephemeral
+// containers) referenced by the workloads of manifests, sorted by reference.
+func Images(manifests []Manifest) []Image {
+	images := map[string]*Image{}
+	for _, m := range manifests {
+		for _, container := range m.Containers() {
+			reference, _ := container["image"].(string)
+			if reference == "" {
+				continue
+			}
+			image, ok := images[reference]
+			if !ok {
+				parsed := ParseImage(reference)
+				image = &parsed
+				images[reference] = image
+			}
+			if key := KeyOf(m); len(image.Resources) == 0 || image.Resources[len(image.Resources)-1] != key {
+				image.Resources = append(image.Resources, key)
+			}
+		}
+	}
+	var sorted []Image
+	for _, image := range images {
+		sorted = append(sorted, *image)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Reference < sorted[j].Reference })
+	return sorted
+}
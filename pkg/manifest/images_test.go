@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      Image
+	}{
+		{"nginx", Image{Repository: "nginx"}},
+		{"nginx:1.19", Image{Repository: "nginx", Tag: "1.19"}},
+		{"localhost:5000/web", Image{Repository: "localhost:5000/web"}},
+		{"localhost:5000/web:v2", Image{Repository: "localhost:5000/web", Tag: "v2"}},
+		{"ghcr.io/my-org/web@sha256:abc", Image{Repository: "ghcr.io/my-org/web", Digest: "sha256:abc"}},
+		{"ghcr.io/my-org/web:1.2.3@sha256:abc", Image{Repository: "ghcr.io/my-org/web", Tag: "1.2.3", Digest: "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			tt.want.Reference = tt.reference
+			if got := ParseImage(tt.reference); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseImage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	deployment := func(name string, images ...string) Manifest {
+		var containers []interface{}
+		for _, image := range images {
+			containers = append(containers, map[string]interface{}{"name": "c", "image": image})
+		}
+		return Manifest{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "web"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"initContainers": []interface{}{map[string]interface{}{"name": "init", "image": "busybox"}},
+						"containers":     containers,
+					},
+				},
+			},
+		}
+	}
+	manifests := []Manifest{
+		deployment("web", "nginx:1.19", "busybox"),
+		deployment("api", "ghcr.io/my-org/api:1.0.0"),
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}},
+	}
+	web, api := KeyOf(manifests[0]), KeyOf(manifests[1])
+
+	want := []Image{
+		{Reference: "busybox", Repository: "busybox", Resources: []Key{web, api}},
+		{Reference: "ghcr.io/my-org/api:1.0.0", Repository: "ghcr.io/my-org/api", Tag: "1.0.0", Resources: []Key{api}},
+		{Reference: "nginx:1.19", Repository: "nginx", Tag: "1.19", Resources: []Key{web}},
+	}
+	if got := Images(manifests); !reflect.DeepEqual(got, want) {
+		t.Errorf("Images() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"fmt"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// Labels returns metadata.labels of the manifest.
+func (m Manifest) Labels() map[string]string {
+	labels, _ := yamlPlus.GetMap(m, "metadata", "labels")
+	return toStringMap(labels)
+}
+
+// Annotations returns metadata.annotations of the manifest.
+func (m Manifest) Annotations() map[string]string {
+	annotations, _ := yamlPlus.GetMap(m, "metadata", "annotations")
+	return toStringMap(annotations)
+}
+
+// MatchesLabelSelector determines if labels are selected by a Kubernetes
+// LabelSelector object (a map with "matchLabels" and/or "matchExpressions").
+// A nil or empty selector matches everything.
+func MatchesLabelSelector(selector map[string]interface{}, labels map[string]string) bool {
+	matchLabels, _ := yamlPlus.GetMap(selector, "matchLabels")
+	for key, value := range toStringMap(matchLabels) {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	expressions, _ := yamlPlus.GetSlice(selector, "matchExpressions")
+	for _, entry := range expressions {
+		expression, ok := entry.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		key, _ := yamlPlus.GetString(expression, "key")
+		operator, _ := yamlPlus.GetString(expression, "operator")
+		rawValues, _ := yamlPlus.GetSlice(expression, "values")
+		var values []string
+		for _, value := range rawValues {
+			values = append(values, fmt.Sprint(value))
+		}
+		if !matchesRequirement(labels, key, operator, values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchesSelectorMap determines if labels contain every key/value pair in
+// selector; the semantics of a Service's spec.selector. An empty selector
+// matches nothing as a Service without a selector does not select pods.
+func MatchesSelectorMap(selector map[string]string, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRequirement evaluates a single label selector requirement.
+func matchesRequirement(labels map[string]string, key string, operator string, values []string) bool {
+	value, exists := labels[key]
+	switch operator {
+	case "In", "in", "=", "==":
+		return exists && contains(values, value)
+	case "NotIn", "notin", "!=":
+		return !exists || !contains(values, value)
+	case "Exists", "exists":
+		return exists
+	case "DoesNotExist", "!":
+		return !exists
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toStringMap(m map[string]interface{}) map[string]string {
+	if m == nil {
+		return nil
+	}
+	converted := make(map[string]string, len(m))
+	for key := range m {
+		converted[key], _ = yamlPlus.GetString(m, key)
+	}
+	return converted
+}
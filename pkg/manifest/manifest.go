@@ -0,0 +1,118 @@
+// Package manifest provides types for working with rendered Kubernetes
+// manifests such as the output of `helm template`.
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// Manifest is a single decoded Kubernetes yaml document.
+type Manifest map[string]interface{}
+
+// GroupVersionKind uniquely identifies the type of a Kubernetes resource.
+type GroupVersionKind struct {
+	Group   string `json:"group,omitempty"` // empty for the core group (e.g. "v1" resources)
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// ParseGVK creates a GroupVersionKind from the apiVersion (e.g. "apps/v1" or
+// "v1") and kind of a resource.
+func ParseGVK(apiVersion string, kind string) GroupVersionKind {
+	gvk := GroupVersionKind{Version: apiVersion, Kind: kind}
+	if idx := strings.LastIndex(apiVersion, "/"); idx >= 0 {
+		gvk.Group = apiVersion[:idx]
+		gvk.Version = apiVersion[idx+1:]
+	}
+	return gvk
+}
+
+// APIVersion returns the apiVersion of the GroupVersionKind as it would be
+// written in a manifest (e.g. "apps/v1" or "v1").
+func (gvk GroupVersionKind) APIVersion() string {
+	if gvk.Group == "" {
+		return gvk.Version
+	}
+	return gvk.Group + "/" + gvk.Version
+}
+
+func (gvk GroupVersionKind) String() string {
+	return fmt.Sprintf("%s, Kind=%s", gvk.APIVersion(), gvk.Kind)
+}
+
+// FromMaps converts decoded yaml documents into Manifests. nil documents are
+// dropped.
+func FromMaps(maps []map[string]interface{}) []Manifest {
+	var manifests []Manifest
+	for _, m := range maps {
+		if m != nil {
+			manifests = append(manifests, Manifest(m))
+		}
+	}
+	return manifests
+}
+
+// APIVersion returns the apiVersion of the manifest.
+func (m Manifest) APIVersion() string {
+	apiVersion, _ := yamlPlus.GetString(m, "apiVersion")
+	return apiVersion
+}
+
+// Kind returns the kind of the manifest.
+func (m Manifest) Kind() string {
+	kind, _ := yamlPlus.GetString(m, "kind")
+	return kind
+}
+
+// GVK returns the GroupVersionKind of the manifest.
+func (m Manifest) GVK() GroupVersionKind {
+	return ParseGVK(m.APIVersion(), m.Kind())
+}
+
+// Name returns metadata.name of the manifest.
+func (m Manifest) Name() string {
+	name, _ := yamlPlus.GetString(m, "metadata", "name")
+	return name
+}
+
+// Namespace returns metadata.namespace of the manifest. Empty if not set.
+func (m Manifest) Namespace() string {
+	namespace, _ := yamlPlus.GetString(m, "metadata", "namespace")
+	return namespace
+}
+
+// DeepCopy returns a copy of m which shares no maps or slices with m.
+func (m Manifest) DeepCopy() Manifest {
+	if m == nil {
+		return nil
+	}
+	return Manifest(deepCopy(map[string]interface{}(m)).(map[string]interface{}))
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, entry := range v {
+			copied[key] = deepCopy(entry)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for idx, entry := range v {
+			copied[idx] = deepCopy(entry)
+		}
+		return copied
+	case []map[string]interface{}:
+		copied := make([]interface{}, len(v))
+		for idx, entry := range v {
+			copied[idx] = deepCopy(entry)
+		}
+		return copied
+	default:
+		return v
+	}
+}
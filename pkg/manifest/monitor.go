@@ -0,0 +1,212 @@
+package manifest
+
+import (
+	"fmt"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// Annotations of the prometheus.io scrape convention recognized by
+// GenerateMonitors.
+const (
+	PrometheusScrapeAnnotation = "prometheus.io/scrape"
+	PrometheusPortAnnotation   = "prometheus.io/port"
+	PrometheusPathAnnotation   = "prometheus.io/path"
+	PrometheusSchemeAnnotation = "prometheus.io/scheme"
+)
+
+// DefaultMetricsPortNames are the port names treated as exposing metrics by
+// GenerateMonitors when MonitorOptions.PortNames is not set.
+var DefaultMetricsPortNames = []string{"metrics", "http-metrics"}
+
+// MonitorOptions configure GenerateMonitors.
+type MonitorOptions struct {
+	// DefaultNamespace is the namespace of resources without one (e.g. the
+	// helm release namespace).
+	DefaultNamespace string
+	// PortNames are the names of ports exposing metrics. Defaults to
+	// DefaultMetricsPortNames.
+	PortNames []string
+	// Labels are added to the generated monitors. e.g: the labels matched by
+	// the serviceMonitorSelector of the Prometheus instance.
+	Labels map[string]string
+	// Interval is the scrape interval of the endpoints (e.g. "30s"). Defaults
+	// to the interval of the Prometheus instance.
+	Interval string
+}
+
+// GenerateMonitors creates Prometheus Operator monitors for the metrics
+// exposed by a render:
+//   - a ServiceMonitor for every Service with a port named as a metrics port
+//     or annotated with prometheus.io/scrape: "true"
+//   - a PodMonitor for every workload whose pod template is annotated with
+//     prometheus.io/scrape: "true" and which is not already monitored via a
+//     Service
+// Annotated resources select the port by prometheus.io/port and may override
+// the path and scheme via prometheus.io/path and prometheus.io/scheme.
+// ServiceMonitors select Services by label, so Services without labels are
+// skipped.
+func GenerateMonitors(manifests []Manifest, opts MonitorOptions) []Manifest {
+	if len(opts.PortNames) == 0 {
+		opts.PortNames = DefaultMetricsPortNames
+	}
+	namespaceOf := func(m Manifest) string {
+		if namespace := m.Namespace(); namespace != "" {
+			return namespace
+		}
+		return opts.DefaultNamespace
+	}
+
+	var services, workloads []Manifest
+	for _, m := range manifests {
+		switch {
+		case m.Kind() == "Service":
+			services = append(services, m)
+		case m.IsWorkload():
+			workloads = append(workloads, m)
+		}
+	}
+
+	var monitors []Manifest
+	monitored := map[int]bool{} // indices of workloads selected by a monitored service
+	for _, service := range services {
+		labels, _ := yamlPlus.GetMap(service, "metadata", "labels")
+		endpoints := opts.serviceEndpoints(service)
+		if len(labels) == 0 || len(endpoints) == 0 {
+			continue
+		}
+		namespace := namespaceOf(service)
+		selector, _ := yamlPlus.GetMap(service, "spec", "selector")
+		for idx, workload := range workloads {
+			if namespaceOf(workload) == namespace && MatchesSelectorMap(toStringMap(selector), workload.PodTemplateLabels()) {
+				monitored[idx] = true
+			}
+		}
+		monitors = append(monitors, opts.monitor("ServiceMonitor", service.Name(), namespace, map[string]interface{}{
+			"selector":  map[string]interface{}{"matchLabels": deepCopy(labels)},
+			"endpoints": endpoints,
+		}))
+	}
+
+	for idx, workload := range workloads {
+		podLabels, _ := yamlPlus.GetMap(workload.PodTemplate(), "metadata", "labels")
+		annotations, _ := yamlPlus.GetMap(workload.PodTemplate(), "metadata", "annotations")
+		if monitored[idx] || len(podLabels) == 0 || !isScrapeEnabled(annotations) {
+			continue
+		}
+		endpoints := opts.podEndpoints(workload, annotations)
+		if len(endpoints) == 0 {
+			continue
+		}
+		monitors = append(monitors, opts.monitor("PodMonitor", workload.Name(), namespaceOf(workload), map[string]interface{}{
+			"selector":            map[string]interface{}{"matchLabels": deepCopy(podLabels)},
+			"podMetricsEndpoints": endpoints,
+		}))
+	}
+
+	return monitors
+}
+
+// serviceEndpoints returns the ServiceMonitor endpoints of the metrics ports
+// of service.
+func (opts MonitorOptions) serviceEndpoints(service Manifest) []interface{} {
+	annotations, _ := yamlPlus.GetMap(service, "metadata", "annotations")
+	scrape := isScrapeEnabled(annotations)
+	scrapePort, _ := yamlPlus.GetString(annotations, PrometheusPortAnnotation)
+
+	ports, _ := yamlPlus.GetSlice(service, "spec", "ports")
+	var endpoints []interface{}
+	for _, entry := range ports {
+		port, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := yamlPlus.GetString(port, "name")
+		switch {
+		case contains(opts.PortNames, name):
+		case scrape && (scrapePort == "" || scrapePort == fmt.Sprint(port["port"]) || scrapePort == name):
+		default:
+			continue
+		}
+		endpoint := opts.endpoint(annotations)
+		if name != "" {
+			endpoint["port"] = name
+		} else {
+			// only the port of a single port Service may be unnamed
+			endpoint["targetPort"] = port["port"]
+			if targetPort, ok := port["targetPort"]; ok {
+				endpoint["targetPort"] = targetPort
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// podEndpoints returns the PodMonitor endpoints of the named metrics ports of
+// the containers of workload.
+func (opts MonitorOptions) podEndpoints(workload Manifest, annotations map[string]interface{}) []interface{} {
+	scrapePort, _ := yamlPlus.GetString(annotations, PrometheusPortAnnotation)
+	var endpoints []interface{}
+	for _, container := range workload.Containers() {
+		ports, _ := yamlPlus.GetSlice(container, "ports")
+		for _, entry := range ports {
+			port, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := yamlPlus.GetString(port, "name")
+			if name == "" || !(contains(opts.PortNames, name) || scrapePort == fmt.Sprint(port["containerPort"]) || scrapePort == name) {
+				continue // PodMonitors reference container ports by name
+			}
+			endpoint := opts.endpoint(annotations)
+			endpoint["port"] = name
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// endpoint creates a monitor endpoint with the interval of opts and the path
+// and scheme of the prometheus.io annotations.
+func (opts MonitorOptions) endpoint(annotations map[string]interface{}) map[string]interface{} {
+	endpoint := map[string]interface{}{}
+	if path, ok := yamlPlus.GetString(annotations, PrometheusPathAnnotation); ok && path != "" {
+		endpoint["path"] = path
+	}
+	if scheme, ok := yamlPlus.GetString(annotations, PrometheusSchemeAnnotation); ok && scheme != "" {
+		endpoint["scheme"] = scheme
+	}
+	if opts.Interval != "" {
+		endpoint["interval"] = opts.Interval
+	}
+	return endpoint
+}
+
+// monitor creates a monitoring.coreos.com/v1 resource of kind.
+func (opts MonitorOptions) monitor(kind string, name string, namespace string, spec map[string]interface{}) Manifest {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if len(opts.Labels) > 0 {
+		labels := make(map[string]interface{}, len(opts.Labels))
+		for key, value := range opts.Labels {
+			labels[key] = value
+		}
+		metadata["labels"] = labels
+	}
+	return Manifest{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}
+}
+
+// isScrapeEnabled determines if annotations opt into scraping via the
+// prometheus.io/scrape annotation.
+func isScrapeEnabled(annotations map[string]interface{}) bool {
+	scrape, _ := yamlPlus.GetString(annotations, PrometheusScrapeAnnotation)
+	return scrape == "true"
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateMonitors(t *testing.T) {
+	service := func(name string, annotations map[string]interface{}, ports ...interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"namespace":   "web",
+				"labels":      map[string]interface{}{"app": name},
+				"annotations": annotations,
+			},
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{"app": name},
+				"ports":    ports,
+			},
+		}
+	}
+	podWorkload := func(name string, annotations map[string]interface{}, ports ...interface{}) Manifest {
+		m := workload("Deployment", name, 1, map[string]interface{}{"app": name})
+		template := m.PodTemplate()
+		template["metadata"].(map[string]interface{})["annotations"] = annotations
+		template["spec"] = map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": name, "ports": ports}},
+		}
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		manifests []Manifest
+		opts      MonitorOptions
+		want      []Manifest
+	}{
+		{
+			name: "service-metrics-port",
+			manifests: []Manifest{
+				podWorkload("api", nil, map[string]interface{}{"name": "metrics", "containerPort": 9090}),
+				service("api", nil,
+					map[string]interface{}{"name": "http", "port": 80},
+					map[string]interface{}{"name": "metrics", "port": 9090},
+				),
+			},
+			opts: MonitorOptions{Labels: map[string]string{"release": "prometheus"}, Interval: "30s"},
+			want: []Manifest{
+				{
+					"apiVersion": "monitoring.coreos.com/v1",
+					"kind":       "ServiceMonitor",
+					"metadata": map[string]interface{}{
+						"name":      "api",
+						"namespace": "web",
+						"labels":    map[string]interface{}{"release": "prometheus"},
+					},
+					"spec": map[string]interface{}{
+						"selector":  map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api"}},
+						"endpoints": []interface{}{map[string]interface{}{"port": "metrics", "interval": "30s"}},
+					},
+				},
+			},
+		},
+		{
+			name: "service-annotations",
+			manifests: []Manifest{
+				service("api", map[string]interface{}{
+					PrometheusScrapeAnnotation: "true",
+					PrometheusPortAnnotation:   "8080",
+					PrometheusPathAnnotation:   "/stats",
+				},
+					map[string]interface{}{"name": "http", "port": 80, "targetPort": 8000},
+					map[string]interface{}{"name": "admin", "port": 8080},
+				),
+			},
+			want: []Manifest{
+				{
+					"apiVersion": "monitoring.coreos.com/v1",
+					"kind":       "ServiceMonitor",
+					"metadata":   map[string]interface{}{"name": "api", "namespace": "web"},
+					"spec": map[string]interface{}{
+						"selector":  map[string]interface{}{"matchLabels": map[string]interface{}{"app": "api"}},
+						"endpoints": []interface{}{map[string]interface{}{"port": "admin", "path": "/stats"}},
+					},
+				},
+			},
+		},
+		{
+			name: "pod-annotations",
+			manifests: []Manifest{
+				podWorkload("worker", map[string]interface{}{
+					PrometheusScrapeAnnotation: "true",
+					PrometheusPortAnnotation:   "9102",
+				},
+					map[string]interface{}{"name": "prom", "containerPort": 9102},
+					map[string]interface{}{"containerPort": 9103},
+				),
+				podWorkload("unannotated", nil, map[string]interface{}{"name": "metrics", "containerPort": 9090}),
+			},
+			want: []Manifest{
+				{
+					"apiVersion": "monitoring.coreos.com/v1",
+					"kind":       "PodMonitor",
+					"metadata":   map[string]interface{}{"name": "worker", "namespace": "web"},
+					"spec": map[string]interface{}{
+						"selector":            map[string]interface{}{"matchLabels": map[string]interface{}{"app": "worker"}},
+						"podMetricsEndpoints": []interface{}{map[string]interface{}{"port": "prom"}},
+					},
+				},
+			},
+		},
+		{
+			name: "no-metrics",
+			manifests: []Manifest{
+				service("api", nil, map[string]interface{}{"name": "http", "port": 80}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateMonitors(tt.manifests, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateMonitors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
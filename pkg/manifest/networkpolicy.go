@@ -0,0 +1,148 @@
+package manifest
+
+import (
+	"sort"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// NetworkPolicyOptions configure GenerateNetworkPolicies.
+type NetworkPolicyOptions struct {
+	// DefaultNamespace is the namespace of resources without one (e.g. the
+	// helm release namespace).
+	DefaultNamespace string
+	// AllowFrom are the NetworkPolicyPeers (e.g. a namespaceSelector for the
+	// ingress controller) allowed to reach the ports of ClusterIP Services.
+	// Defaults to all pods in the namespace of the Service.
+	AllowFrom []map[string]interface{}
+	// DenyEgress extends the default-deny policies to egress traffic. DNS
+	// lookups (port 53) remain allowed.
+	DenyEgress bool
+}
+
+// GenerateNetworkPolicies scaffolds NetworkPolicies for a render: a
+// default-deny policy for every namespace with workloads, plus a policy per
+// Service allowing ingress to the ports of the pods it selects. The output is
+// a starting point for security review rather than a complete policy set;
+// traffic not going through a Service (e.g. to metrics ports) is denied.
+// LoadBalancer and NodePort Services are allowed from anywhere as their
+// clients are outside of the cluster.
+func GenerateNetworkPolicies(manifests []Manifest, opts NetworkPolicyOptions) []Manifest {
+	namespaceOf := func(m Manifest) string {
+		if namespace := m.Namespace(); namespace != "" {
+			return namespace
+		}
+		return opts.DefaultNamespace
+	}
+
+	namespaces := map[string]bool{}
+	var services []Manifest
+	for _, m := range manifests {
+		switch {
+		case m.IsWorkload():
+			namespaces[namespaceOf(m)] = true
+		case m.Kind() == "Service":
+			services = append(services, m)
+		}
+	}
+	var sortedNamespaces []string
+	for namespace := range namespaces {
+		sortedNamespaces = append(sortedNamespaces, namespace)
+	}
+	sort.Strings(sortedNamespaces)
+
+	var policies []Manifest
+	for _, namespace := range sortedNamespaces {
+		policyTypes := []interface{}{"Ingress"}
+		if opts.DenyEgress {
+			policyTypes = append(policyTypes, "Egress")
+		}
+		policies = append(policies, networkPolicy("default-deny", namespace, map[string]interface{}{
+			"podSelector": map[string]interface{}{},
+			"policyTypes": policyTypes,
+		}))
+		if opts.DenyEgress {
+			policies = append(policies, networkPolicy("allow-dns", namespace, map[string]interface{}{
+				"podSelector": map[string]interface{}{},
+				"policyTypes": []interface{}{"Egress"},
+				"egress": []interface{}{
+					map[string]interface{}{
+						"ports": []interface{}{
+							map[string]interface{}{"protocol": "UDP", "port": 53},
+							map[string]interface{}{"protocol": "TCP", "port": 53},
+						},
+					},
+				},
+			}))
+		}
+	}
+
+	for _, service := range services {
+		namespace := namespaceOf(service)
+		selector, _ := yamlPlus.GetMap(service, "spec", "selector")
+		if !namespaces[namespace] || len(selector) == 0 {
+			continue // selector-less services do not route to rendered pods
+		}
+		ports := servicePolicyPorts(service)
+		if len(ports) == 0 {
+			continue
+		}
+
+		rule := map[string]interface{}{"ports": ports}
+		serviceType, _ := yamlPlus.GetString(service, "spec", "type")
+		if serviceType != "LoadBalancer" && serviceType != "NodePort" {
+			from := []interface{}{map[string]interface{}{"podSelector": map[string]interface{}{}}}
+			if len(opts.AllowFrom) > 0 {
+				from = deepCopy(opts.AllowFrom).([]interface{})
+			}
+			rule["from"] = from
+		}
+		policies = append(policies, networkPolicy("allow-"+service.Name(), namespace, map[string]interface{}{
+			"podSelector": map[string]interface{}{"matchLabels": deepCopy(selector)},
+			"policyTypes": []interface{}{"Ingress"},
+			"ingress":     []interface{}{rule},
+		}))
+	}
+
+	return policies
+}
+
+// servicePolicyPorts returns the NetworkPolicyPorts of the pods targeted by
+// the ports of service. The targetPort is used as the pods are selected by the
+// policy, falling back to the Service port when not set.
+func servicePolicyPorts(service Manifest) []interface{} {
+	servicePorts, _ := yamlPlus.GetSlice(service, "spec", "ports")
+	var ports []interface{}
+	for _, entry := range servicePorts {
+		servicePort, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		port, ok := servicePort["targetPort"]
+		if !ok {
+			if port, ok = servicePort["port"]; !ok {
+				continue
+			}
+		}
+		protocol, ok := yamlPlus.GetString(servicePort, "protocol")
+		if !ok {
+			protocol = "TCP"
+		}
+		ports = append(ports, map[string]interface{}{"protocol": protocol, "port": port})
+	}
+	return ports
+}
+
+// networkPolicy creates a networking.k8s.io/v1 NetworkPolicy.
+func networkPolicy(name string, namespace string, spec map[string]interface{}) Manifest {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return Manifest{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "NetworkPolicy",
+		"metadata":   metadata,
+		"spec":       spec,
+	}
+}
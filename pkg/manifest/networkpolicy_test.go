@@ -0,0 +1,128 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateNetworkPolicies(t *testing.T) {
+	web := workload("Deployment", "web", 1, map[string]interface{}{"app": "web"})
+	service := func(name string, serviceType string, selector map[string]interface{}, ports ...interface{}) Manifest {
+		spec := map[string]interface{}{"ports": ports}
+		if selector != nil {
+			spec["selector"] = selector
+		}
+		if serviceType != "" {
+			spec["type"] = serviceType
+		}
+		return Manifest{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "web"},
+			"spec":       spec,
+		}
+	}
+	defaultDeny := networkPolicy("default-deny", "web", map[string]interface{}{
+		"podSelector": map[string]interface{}{},
+		"policyTypes": []interface{}{"Ingress"},
+	})
+	ingressController := map[string]interface{}{
+		"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"name": "ingress-nginx"}},
+	}
+
+	tests := []struct {
+		name      string
+		manifests []Manifest
+		opts      NetworkPolicyOptions
+		want      []Manifest
+	}{
+		{
+			name:      "no-workloads",
+			manifests: []Manifest{service("web", "", map[string]interface{}{"app": "web"}, map[string]interface{}{"port": 80})},
+		},
+		{
+			name: "cluster-ip",
+			manifests: []Manifest{
+				web,
+				service("web", "", map[string]interface{}{"app": "web"}, map[string]interface{}{"port": 80, "targetPort": "http"}),
+				service("external", "ExternalName", nil),
+			},
+			want: []Manifest{
+				defaultDeny,
+				networkPolicy("allow-web", "web", map[string]interface{}{
+					"podSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+					"policyTypes": []interface{}{"Ingress"},
+					"ingress": []interface{}{
+						map[string]interface{}{
+							"from":  []interface{}{map[string]interface{}{"podSelector": map[string]interface{}{}}},
+							"ports": []interface{}{map[string]interface{}{"protocol": "TCP", "port": "http"}},
+						},
+					},
+				}),
+			},
+		},
+		{
+			name: "allow-from",
+			manifests: []Manifest{
+				web,
+				service("web", "", map[string]interface{}{"app": "web"}, map[string]interface{}{"port": 53, "protocol": "UDP"}),
+			},
+			opts: NetworkPolicyOptions{AllowFrom: []map[string]interface{}{ingressController}},
+			want: []Manifest{
+				defaultDeny,
+				networkPolicy("allow-web", "web", map[string]interface{}{
+					"podSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+					"policyTypes": []interface{}{"Ingress"},
+					"ingress": []interface{}{
+						map[string]interface{}{
+							"from":  []interface{}{ingressController},
+							"ports": []interface{}{map[string]interface{}{"protocol": "UDP", "port": 53}},
+						},
+					},
+				}),
+			},
+		},
+		{
+			name: "load-balancer-deny-egress",
+			manifests: []Manifest{
+				web,
+				service("web", "LoadBalancer", map[string]interface{}{"app": "web"}, map[string]interface{}{"port": 443, "targetPort": 8443}),
+			},
+			opts: NetworkPolicyOptions{DenyEgress: true},
+			want: []Manifest{
+				networkPolicy("default-deny", "web", map[string]interface{}{
+					"podSelector": map[string]interface{}{},
+					"policyTypes": []interface{}{"Ingress", "Egress"},
+				}),
+				networkPolicy("allow-dns", "web", map[string]interface{}{
+					"podSelector": map[string]interface{}{},
+					"policyTypes": []interface{}{"Egress"},
+					"egress": []interface{}{
+						map[string]interface{}{
+							"ports": []interface{}{
+								map[string]interface{}{"protocol": "UDP", "port": 53},
+								map[string]interface{}{"protocol": "TCP", "port": 53},
+							},
+						},
+					},
+				}),
+				networkPolicy("allow-web", "web", map[string]interface{}{
+					"podSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+					"policyTypes": []interface{}{"Ingress"},
+					"ingress": []interface{}{
+						map[string]interface{}{
+							"ports": []interface{}{map[string]interface{}{"protocol": "TCP", "port": 8443}},
+						},
+					},
+				}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateNetworkPolicies(tt.manifests, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateNetworkPolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat determines how manifests are persisted by WriteOutput.
+type OutputFormat string
+
+const (
+	OutputFile      OutputFormat = "file"      // a single multi-document yaml file
+	OutputDirectory OutputFormat = "directory" // a directory tree of yaml files named via OutputSpec.FileName
+	OutputBundle    OutputFormat = "bundle"    // a gzipped tarball of the directory tree along with an index.yaml listing its contents
+)
+
+// BundleIndexFile is the name of the index in bundles written as OutputBundle.
+const BundleIndexFile = "index.yaml"
+
+// OutputSpec configures where and how WriteOutput persists manifests.
+type OutputSpec struct {
+	Format   OutputFormat
+	Path     string                  // the file written for OutputFile and OutputBundle or the directory written for OutputDirectory
+	FileName func(m Manifest) string // slash separated path of the file holding m relative to the directory or bundle root. defaults to DefaultFileName
+
+	SecretEncrypter SecretEncrypter // when set, v1 Secrets are encrypted (e.g. via a SOPSEncrypter) before being written so the output can be committed safely
+}
+
+// BundleEntry is an entry of the index of a bundle written as OutputBundle.
+type BundleEntry struct {
+	File       string `yaml:"file"`
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Namespace  string `yaml:"namespace,omitempty"`
+	Name       string `yaml:"name"`
+}
+
+// unsafeFileNameRgx matches characters not safe to use in file names on all
+// platforms (e.g. the ":" in "system:controller:...").
+var unsafeFileNameRgx = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// DefaultFileName names the file of a manifest as
+// <namespace>/<kind>-<name>.yaml, or <kind>-<name>.yaml for cluster-scoped
+// resources. Kinds are lowercased and characters not safe for file names are
+// replaced with "_".
+func DefaultFileName(m Manifest) string {
+	file := fmt.Sprintf("%s-%s.yaml", strings.ToLower(m.Kind()), unsafeFileNameRgx.ReplaceAllString(m.Name(), "_"))
+	if m.Namespace() == "" {
+		return file
+	}
+	return path.Join(unsafeFileNameRgx.ReplaceAllString(m.Namespace(), "_"), file)
+}
+
+// Encode marshals manifests into a single multi-document yaml stream.
+func Encode(manifests []Manifest) ([]byte, error) {
+	var buf bytes.Buffer
+	for idx, m := range manifests {
+		content, err := yaml.Marshal(map[string]interface{}(m))
+		if err != nil {
+			return nil, fmt.Errorf(`marshalling %s: %w`, m.Kind()+"/"+m.Name(), err)
+		}
+		if idx > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(content)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteOutput persists manifests as described by spec.
+// Manifests sharing the same file name are written to that file as multiple
+// documents in the order they are provided.
+func WriteOutput(manifests []Manifest, spec OutputSpec) error {
+	if spec.Path == "" {
+		return fmt.Errorf(`writing output: no path provided`)
+	}
+	if spec.SecretEncrypter != nil {
+		encrypted, err := EncryptSecrets(manifests, spec.SecretEncrypter)
+		if err != nil {
+			return fmt.Errorf(`writing output: %w`, err)
+		}
+		manifests = encrypted
+	}
+	switch spec.Format {
+	case OutputFile, "":
+		content, err := Encode(manifests)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(spec.Path), 0755); err != nil {
+			return fmt.Errorf(`creating directory for %s: %w`, spec.Path, err)
+		}
+		if err := os.WriteFile(spec.Path, content, 0644); err != nil {
+			return fmt.Errorf(`writing %s: %w`, spec.Path, err)
+		}
+		return nil
+	case OutputDirectory:
+		files, _, err := spec.files(manifests)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			filePath := filepath.Join(spec.Path, filepath.FromSlash(file.name))
+			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+				return fmt.Errorf(`creating directory for %s: %w`, filePath, err)
+			}
+			if err := os.WriteFile(filePath, file.content, 0644); err != nil {
+				return fmt.Errorf(`writing %s: %w`, filePath, err)
+			}
+		}
+		return nil
+	case OutputBundle:
+		content, err := spec.bundle(manifests)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(spec.Path), 0755); err != nil {
+			return fmt.Errorf(`creating directory for %s: %w`, spec.Path, err)
+		}
+		if err := os.WriteFile(spec.Path, content, 0644); err != nil {
+			return fmt.Errorf(`writing %s: %w`, spec.Path, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf(`writing output: unknown output format "%s"`, spec.Format)
+	}
+}
+
+// outputFile is the encoded content of a file in a directory or bundle.
+type outputFile struct {
+	name    string
+	content []byte
+}
+
+// files groups manifests by their file name, returning the encoded files in
+// order of first appearance along with an index entry for every manifest.
+func (spec OutputSpec) files(manifests []Manifest) ([]outputFile, []BundleEntry, error) {
+	fileName := spec.FileName
+	if fileName == nil {
+		fileName = DefaultFileName
+	}
+
+	var names []string
+	grouped := map[string][]Manifest{}
+	var index []BundleEntry
+	for _, m := range manifests {
+		name := path.Clean(fileName(m))
+		if name == "." || path.IsAbs(name) || strings.HasPrefix(name, "../") || name == ".." {
+			return nil, nil, fmt.Errorf(`invalid file name "%s" for %s/%s: must be a relative path within the output`, name, m.Kind(), m.Name())
+		}
+		if _, ok := grouped[name]; !ok {
+			names = append(names, name)
+		}
+		grouped[name] = append(grouped[name], m)
+		index = append(index, BundleEntry{File: name, APIVersion: m.APIVersion(), Kind: m.Kind(), Namespace: m.Namespace(), Name: m.Name()})
+	}
+
+	var files []outputFile
+	for _, name := range names {
+		content, err := Encode(grouped[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, outputFile{name: name, content: content})
+	}
+	return files, index, nil
+}
+
+// bundle encodes manifests as a gzipped tarball with an index.
+func (spec OutputSpec) bundle(manifests []Manifest) ([]byte, error) {
+	files, index, err := spec.files(manifests)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.name == BundleIndexFile {
+			return nil, fmt.Errorf(`file name "%s" is reserved for the bundle index`, BundleIndexFile)
+		}
+	}
+	indexContent, err := yaml.Marshal(map[string]interface{}{"entries": index})
+	if err != nil {
+		return nil, fmt.Errorf(`marshalling bundle index: %w`, err)
+	}
+	files = append([]outputFile{{name: BundleIndexFile, content: indexContent}}, files...)
+
+	var archive bytes.Buffer
+	gzw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gzw)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf(`writing bundle header for %s: %w`, file.name, err)
+		}
+		if _, err := tw.Write(file.content); err != nil {
+			return nil, fmt.Errorf(`writing %s to bundle: %w`, file.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf(`closing tar writer: %w`, err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, fmt.Errorf(`closing gzip writer: %w`, err)
+	}
+	return archive.Bytes(), nil
+}
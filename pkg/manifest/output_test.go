@@ -0,0 +1,86 @@
+package manifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteOutput(t *testing.T) {
+	manifests := []Manifest{
+		{"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": "web"}},
+		{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "nginx", "namespace": "web"}},
+		{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": map[string]interface{}{"name": "system:nginx"}},
+	}
+
+	t.Run("file", func(t *testing.T) {
+		spec := OutputSpec{Format: OutputFile, Path: filepath.Join(t.TempDir(), "out", "all.yaml")}
+		if err := WriteOutput(manifests, spec); err != nil {
+			t.Fatalf("WriteOutput() error = %v", err)
+		}
+		got, err := LoadDirectory(filepath.Dir(spec.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff, _ := DiffManifests(manifests, got); !diff.Empty() {
+			t.Errorf("WriteOutput() wrote %v", diff.Summary())
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		spec := OutputSpec{Format: OutputDirectory, Path: t.TempDir()}
+		if err := WriteOutput(manifests, spec); err != nil {
+			t.Fatalf("WriteOutput() error = %v", err)
+		}
+		for _, file := range []string{"namespace-web.yaml", "web/deployment-nginx.yaml", "clusterrole-system_nginx.yaml"} {
+			if _, err := os.Stat(filepath.Join(spec.Path, filepath.FromSlash(file))); err != nil {
+				t.Errorf("WriteOutput() did not write %s: %v", file, err)
+			}
+		}
+	})
+
+	t.Run("bundle", func(t *testing.T) {
+		spec := OutputSpec{
+			Format:   OutputBundle,
+			Path:     filepath.Join(t.TempDir(), "bundle.tgz"),
+			FileName: func(m Manifest) string { return "resources.yaml" },
+		}
+		if err := WriteOutput(manifests, spec); err != nil {
+			t.Fatalf("WriteOutput() error = %v", err)
+		}
+		content, err := os.ReadFile(spec.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gzr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		tr := tar.NewReader(gzr)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, header.Name)
+		}
+		if want := []string{BundleIndexFile, "resources.yaml"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("WriteOutput() bundle files = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("escaping file name", func(t *testing.T) {
+		spec := OutputSpec{Format: OutputDirectory, Path: t.TempDir(), FileName: func(m Manifest) string { return "../escape.yaml" }}
+		if err := WriteOutput(manifests, spec); err == nil {
+			t.Errorf("WriteOutput() error = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,443 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// Overlay is a set of patches applied to a render. It is a lightweight
+// alternative to kustomize for small per-environment tweaks.
+type Overlay struct {
+	StrategicMerge []Manifest  // partial manifests merged into the manifest with the same GVK, namespace and name
+	JSON6902       []JSONPatch // RFC 6902 JSON patches applied to their target
+}
+
+// JSONPatch is a list of RFC 6902 operations applied to a single target
+// manifest. In an overlay file it is written as:
+//   target:
+//     kind: Deployment
+//     name: nginx
+//   patch:
+//     - op: replace
+//       path: /spec/replicas
+//       value: 5
+type JSONPatch struct {
+	Target     Target
+	Operations []JSONPatchOperation
+}
+
+// Target selects the manifest a patch is applied to. Empty fields match
+// anything.
+type Target struct {
+	Group     string
+	Version   string
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// JSONPatchOperation is a single RFC 6902 operation.
+type JSONPatchOperation struct {
+	Op    string      // add, remove, replace, move, copy or test
+	Path  string      // JSON pointer to the target location
+	From  string      // JSON pointer to the source location for move and copy
+	Value interface{} // value for add, replace and test
+}
+
+// Matches determines if m is selected by the target.
+func (t Target) Matches(m Manifest) bool {
+	gvk := m.GVK()
+	return (t.Group == "" || t.Group == gvk.Group) &&
+		(t.Version == "" || t.Version == gvk.Version) &&
+		(t.Kind == "" || t.Kind == gvk.Kind) &&
+		(t.Name == "" || t.Name == m.Name()) &&
+		(t.Namespace == "" || t.Namespace == m.Namespace())
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s/%s, Kind=%s %s/%s", t.Group, t.Version, t.Kind, t.Namespace, t.Name)
+}
+
+// LoadOverlay reads all yaml files (.yaml and .yml) in dir in lexical order
+// and parses each document as either a strategic merge patch (a partial
+// manifest with an apiVersion, kind and metadata.name) or a JSON 6902 patch (a
+// document with a "target" and "patch").
+func LoadOverlay(dir string) (Overlay, error) {
+	var overlay Overlay
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return overlay, fmt.Errorf(`reading overlay directory %s: %w`, dir, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (extension == ".yaml" || extension == ".yml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return overlay, fmt.Errorf(`reading overlay file %s: %w`, file, err)
+		}
+		documents, err := yamlPlus.DecodeMaps(content)
+		if err != nil {
+			return overlay, fmt.Errorf(`decoding overlay file %s: %w`, file, err)
+		}
+		for _, document := range documents {
+			if document == nil {
+				continue
+			}
+			if _, isJSONPatch := document["patch"]; isJSONPatch {
+				patch, err := parseJSONPatch(document)
+				if err != nil {
+					return overlay, fmt.Errorf(`parsing JSON 6902 patch in %s: %w`, file, err)
+				}
+				overlay.JSON6902 = append(overlay.JSON6902, patch)
+				continue
+			}
+			patch := Manifest(document)
+			if patch.Kind() == "" || patch.Name() == "" {
+				return overlay, fmt.Errorf(`strategic merge patch in %s must have a kind and metadata.name: %+v`, file, document)
+			}
+			overlay.StrategicMerge = append(overlay.StrategicMerge, patch)
+		}
+	}
+
+	return overlay, nil
+}
+
+func parseJSONPatch(document map[string]interface{}) (JSONPatch, error) {
+	var patch JSONPatch
+	patch.Target.Group, _ = yamlPlus.GetString(document, "target", "group")
+	patch.Target.Version, _ = yamlPlus.GetString(document, "target", "version")
+	patch.Target.Kind, _ = yamlPlus.GetString(document, "target", "kind")
+	patch.Target.Name, _ = yamlPlus.GetString(document, "target", "name")
+	patch.Target.Namespace, _ = yamlPlus.GetString(document, "target", "namespace")
+	if patch.Target == (Target{}) {
+		return patch, fmt.Errorf(`"target" is required: %+v`, document)
+	}
+
+	operations, ok := yamlPlus.GetSlice(document, "patch")
+	if !ok {
+		return patch, fmt.Errorf(`"patch" must be a list of operations: %+v`, document)
+	}
+	for _, entry := range operations {
+		operation, ok := entry.(map[string]interface{})
+		if !ok {
+			return patch, fmt.Errorf(`operation must be a map: %+v`, entry)
+		}
+		var op JSONPatchOperation
+		op.Op, _ = yamlPlus.GetString(operation, "op")
+		op.Path, _ = yamlPlus.GetString(operation, "path")
+		op.From, _ = yamlPlus.GetString(operation, "from")
+		op.Value = operation["value"]
+		if op.Op == "" {
+			return patch, fmt.Errorf(`operation requires an "op": %+v`, operation)
+		}
+		patch.Operations = append(patch.Operations, op)
+	}
+
+	return patch, nil
+}
+
+// Apply applies all patches in the overlay to a copy of manifests, leaving the
+// provided manifests unmodified. Strategic merge patches are applied before
+// JSON 6902 patches. Errors if any patch does not match a manifest.
+func (o Overlay) Apply(manifests []Manifest) ([]Manifest, error) {
+	patched := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		patched[idx] = m.DeepCopy()
+	}
+
+	for _, patch := range o.StrategicMerge {
+		key := KeyOf(patch)
+		found := false
+		for idx, m := range patched {
+			if KeyOf(m) == key {
+				patched[idx] = Manifest(strategicMerge(map[string]interface{}(m), map[string]interface{}(patch.DeepCopy())))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf(`no manifest found matching strategic merge patch for %s %s/%s`, key.GVK, key.Namespace, key.Name)
+		}
+	}
+
+	for _, patch := range o.JSON6902 {
+		found := false
+		for idx, m := range patched {
+			if !patch.Target.Matches(m) {
+				continue
+			}
+			found = true
+			var document interface{} = map[string]interface{}(m)
+			for _, op := range patch.Operations {
+				var err error
+				document, err = applyJSONPatchOperation(document, op)
+				if err != nil {
+					return nil, fmt.Errorf(`applying JSON 6902 %s operation at %s to %s %s/%s: %w`, op.Op, op.Path, m.GVK(), m.Namespace(), m.Name(), err)
+				}
+			}
+			asMap, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf(`JSON 6902 patch replaced %s %s/%s with a non-map value`, m.GVK(), m.Namespace(), m.Name())
+			}
+			patched[idx] = Manifest(asMap)
+		}
+		if !found {
+			return nil, fmt.Errorf(`no manifest found matching JSON 6902 patch target %s`, patch.Target)
+		}
+	}
+
+	return patched, nil
+}
+
+// strategicMerge merges patch into original following a simplified version of
+// the Kubernetes strategic merge patch semantics which does not require the
+// resource schema:
+//   - maps are merged recursively
+//   - null values delete the key
+//   - "$patch: replace" in a map replaces it instead of merging
+//   - lists of maps where every element has a "name" are merged by name and
+//     elements containing "$patch: delete" are removed
+//   - all other lists and values are replaced
+func strategicMerge(original map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	if directive, _ := patch["$patch"].(string); directive == "replace" {
+		delete(patch, "$patch")
+		return patch
+	}
+	if original == nil {
+		original = map[string]interface{}{}
+	}
+	for key, patchValue := range patch {
+		if patchValue == nil {
+			delete(original, key)
+			continue
+		}
+		switch pv := patchValue.(type) {
+		case map[string]interface{}:
+			ov, _ := original[key].(map[string]interface{})
+			original[key] = strategicMerge(ov, pv)
+		case []interface{}:
+			if ov, ok := original[key].([]interface{}); ok && isNamedList(ov) && isNamedList(pv) {
+				original[key] = mergeNamedLists(ov, pv)
+			} else {
+				original[key] = pv
+			}
+		default:
+			original[key] = pv
+		}
+	}
+	return original
+}
+
+// isNamedList determines if every element in list is a map with a "name".
+func isNamedList(list []interface{}) bool {
+	for _, entry := range list {
+		asMap, ok := entry.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := asMap["name"]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeNamedLists(original []interface{}, patch []interface{}) []interface{} {
+	merged := append([]interface{}{}, original...)
+	for _, entry := range patch {
+		patchEntry := entry.(map[string]interface{})
+		matched := -1
+		for idx, existing := range merged {
+			if existing.(map[string]interface{})["name"] == patchEntry["name"] {
+				matched = idx
+				break
+			}
+		}
+		directive, _ := patchEntry["$patch"].(string)
+		switch {
+		case directive == "delete":
+			if matched >= 0 {
+				merged = append(merged[:matched], merged[matched+1:]...)
+			}
+		case matched >= 0:
+			merged[matched] = strategicMerge(merged[matched].(map[string]interface{}), patchEntry)
+		default:
+			merged = append(merged, patchEntry)
+		}
+	}
+	return merged
+}
+
+// applyJSONPatchOperation applies a single RFC 6902 operation to document and
+// returns the resulting document.
+func applyJSONPatchOperation(document interface{}, op JSONPatchOperation) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return setPointer(document, op.Path, deepCopy(op.Value), true)
+	case "replace":
+		if _, err := getPointer(document, op.Path); err != nil {
+			return nil, err
+		}
+		return setPointer(document, op.Path, deepCopy(op.Value), false)
+	case "remove":
+		return removePointer(document, op.Path)
+	case "move":
+		value, err := getPointer(document, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if document, err = removePointer(document, op.From); err != nil {
+			return nil, err
+		}
+		return setPointer(document, op.Path, value, true)
+	case "copy":
+		value, err := getPointer(document, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(document, op.Path, deepCopy(value), true)
+	case "test":
+		value, err := getPointer(document, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if formatScalar(value) != formatScalar(op.Value) {
+			return nil, fmt.Errorf(`test failed: value at %s is %v, not %v`, op.Path, value, op.Value)
+		}
+		return document, nil
+	default:
+		return nil, fmt.Errorf(`unsupported operation "%s"`, op.Op)
+	}
+}
+
+// splitPointer splits a JSON pointer (RFC 6901) into its unescaped tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf(`JSON pointer "%s" must start with "/"`, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for idx, token := range tokens {
+		tokens[idx] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getPointer(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := document
+	for _, token := range tokens {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			value, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf(`path %s does not exist`, pointer)
+			}
+			current = value
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil, fmt.Errorf(`invalid list index "%s" in path %s`, token, pointer)
+			}
+			current = c[idx]
+		default:
+			return nil, fmt.Errorf(`path %s does not exist`, pointer)
+		}
+	}
+	return current, nil
+}
+
+// setPointer sets value at pointer. When insert is true, values are inserted
+// into lists (shifting later elements) rather than replacing the element.
+func setPointer(document interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(document, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+		return document, nil
+	case []interface{}:
+		var list []interface{}
+		if last == "-" {
+			list = append(p, value)
+		} else {
+			idx, err := strconv.Atoi(last)
+			if err != nil || idx < 0 || idx > len(p) || (!insert && idx == len(p)) {
+				return nil, fmt.Errorf(`invalid list index "%s" in path %s`, last, pointer)
+			}
+			if insert {
+				list = append(list, p[:idx]...)
+				list = append(list, value)
+				list = append(list, p[idx:]...)
+			} else {
+				p[idx] = value
+				list = p
+			}
+		}
+		// lists are values; write the updated list back into its parent
+		return setPointer(document, parentPointer, list, false)
+	default:
+		return nil, fmt.Errorf(`parent of path %s is not a map or list`, pointer)
+	}
+}
+
+func removePointer(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf(`cannot remove the whole document`)
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(document, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last]; !ok {
+			return nil, fmt.Errorf(`path %s does not exist`, pointer)
+		}
+		delete(p, last)
+		return document, nil
+	case []interface{}:
+		idx, err := strconv.Atoi(last)
+		if err != nil || idx < 0 || idx >= len(p) {
+			return nil, fmt.Errorf(`invalid list index "%s" in path %s`, last, pointer)
+		}
+		list := append(append([]interface{}{}, p[:idx]...), p[idx+1:]...)
+		return setPointer(document, parentPointer, list, false)
+	default:
+		return nil, fmt.Errorf(`parent of path %s is not a map or list`, pointer)
+	}
+}
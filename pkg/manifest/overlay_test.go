@@ -0,0 +1,153 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadOverlay(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"01-replicas.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: web
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+        - name: nginx
+          image: nginx:1.19
+        - name: sidecar
+          $patch: delete
+`,
+		"02-json.yml": `
+target:
+  kind: Service
+  name: nginx
+patch:
+  - op: add
+    path: /metadata/labels
+    value:
+      tier: frontend
+  - op: replace
+    path: /spec/ports/0/port
+    value: 8080
+`,
+		"README.md": "not an overlay",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	overlay, err := LoadOverlay(dir)
+	if err != nil {
+		t.Fatalf("LoadOverlay() error = %v", err)
+	}
+	if len(overlay.StrategicMerge) != 1 || len(overlay.JSON6902) != 1 {
+		t.Fatalf("LoadOverlay() = %+v, want 1 strategic merge patch and 1 JSON 6902 patch", overlay)
+	}
+
+	deployment := Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"replicas": 1,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "nginx", "image": "nginx:1.14", "ports": []interface{}{80}},
+						map[string]interface{}{"name": "sidecar", "image": "busybox"},
+					},
+				},
+			},
+		},
+	}
+	service := Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": 80}},
+		},
+	}
+	got, err := overlay.Apply([]Manifest{deployment, service})
+	if err != nil {
+		t.Fatalf("Overlay.Apply() error = %v", err)
+	}
+	want := []Manifest{
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web"},
+			"spec": map[string]interface{}{
+				"replicas": 5,
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "nginx", "image": "nginx:1.19", "ports": []interface{}{80}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": "nginx", "namespace": "web", "labels": map[string]interface{}{"tier": "frontend"}},
+			"spec": map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": 8080}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Overlay.Apply() = %v, want %v", got, want)
+	}
+	if replicas := deployment["spec"].(map[string]interface{})["replicas"]; replicas != 1 {
+		t.Errorf("Overlay.Apply() modified the original manifest: replicas = %v", replicas)
+	}
+
+	// patches which do not match anything are errors
+	if _, err := overlay.Apply([]Manifest{service}); err == nil {
+		t.Errorf("Overlay.Apply() error = %v, wantErr %v", err, true)
+	}
+}
+
+func Test_applyJSONPatchOperation(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      JSONPatchOperation
+		want    interface{}
+		wantErr bool
+	}{
+		{"add to list", JSONPatchOperation{Op: "add", Path: "/list/1", Value: "x"}, map[string]interface{}{"a": 1, "list": []interface{}{"a", "x", "b"}}, false},
+		{"append to list", JSONPatchOperation{Op: "add", Path: "/list/-", Value: "x"}, map[string]interface{}{"a": 1, "list": []interface{}{"a", "b", "x"}}, false},
+		{"remove from list", JSONPatchOperation{Op: "remove", Path: "/list/0"}, map[string]interface{}{"a": 1, "list": []interface{}{"b"}}, false},
+		{"move", JSONPatchOperation{Op: "move", From: "/a", Path: "/c~1d"}, map[string]interface{}{"c/d": 1, "list": []interface{}{"a", "b"}}, false},
+		{"copy", JSONPatchOperation{Op: "copy", From: "/a", Path: "/b"}, map[string]interface{}{"a": 1, "b": 1, "list": []interface{}{"a", "b"}}, false},
+		{"test passes", JSONPatchOperation{Op: "test", Path: "/a", Value: 1}, map[string]interface{}{"a": 1, "list": []interface{}{"a", "b"}}, false},
+		{"test fails", JSONPatchOperation{Op: "test", Path: "/a", Value: 2}, nil, true},
+		{"replace missing", JSONPatchOperation{Op: "replace", Path: "/missing", Value: 2}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := map[string]interface{}{"a": 1, "list": []interface{}{"a", "b"}}
+			got, err := applyJSONPatchOperation(document, tt.op)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyJSONPatchOperation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyJSONPatchOperation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
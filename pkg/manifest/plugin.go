@@ -0,0 +1,283 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// Validator checks rendered manifests, reporting its results as Findings.
+// e.g: ValidateReferences or organization specific policy.
+type Validator interface {
+	Validate(manifests []Manifest) ([]Finding, error)
+}
+
+// ValidatorFunc adapts a function into a Validator.
+type ValidatorFunc func(manifests []Manifest) ([]Finding, error)
+
+// Validate calls f(manifests).
+func (f ValidatorFunc) Validate(manifests []Manifest) ([]Finding, error) {
+	return f(manifests)
+}
+
+// Name prefixes of executables discovered as plugins by LoadPlugins.
+const (
+	TransformerPluginPrefix = "transformer-"
+	ValidatorPluginPrefix   = "validator-"
+)
+
+// plugins are the registered transformers and validators by name.
+var plugins = struct {
+	lock         sync.RWMutex
+	transformers map[string]Transformer
+	validators   map[string]Validator
+}{
+	transformers: map[string]Transformer{},
+	validators:   map[string]Validator{},
+}
+
+// RegisterTransformer makes a transformer available by name, so third
+// parties can ship transformers the render pipeline runs by name (e.g. via
+// helm.TemplateOptions.Plugins). It is intended to be called from the init
+// function of the package providing the transformer and panics if name is
+// already registered.
+func RegisterTransformer(name string, t Transformer) {
+	if err := registerTransformer(name, t); err != nil {
+		panic(err.Error())
+	}
+}
+
+func registerTransformer(name string, t Transformer) error {
+	plugins.lock.Lock()
+	defer plugins.lock.Unlock()
+	if _, exists := plugins.transformers[name]; exists {
+		return fmt.Errorf(`transformer "%s" is already registered`, name)
+	}
+	plugins.transformers[name] = t
+	return nil
+}
+
+// RegisterValidator makes a validator available by name. As with
+// RegisterTransformer, it panics if name is already registered.
+func RegisterValidator(name string, v Validator) {
+	if err := registerValidator(name, v); err != nil {
+		panic(err.Error())
+	}
+}
+
+func registerValidator(name string, v Validator) error {
+	plugins.lock.Lock()
+	defer plugins.lock.Unlock()
+	if _, exists := plugins.validators[name]; exists {
+		return fmt.Errorf(`validator "%s" is already registered`, name)
+	}
+	plugins.validators[name] = v
+	return nil
+}
+
+// RegisteredTransformer returns the transformer registered as name.
+func RegisteredTransformer(name string) (Transformer, bool) {
+	plugins.lock.RLock()
+	defer plugins.lock.RUnlock()
+	t, ok := plugins.transformers[name]
+	return t, ok
+}
+
+// RegisteredValidator returns the validator registered as name.
+func RegisteredValidator(name string) (Validator, bool) {
+	plugins.lock.RLock()
+	defer plugins.lock.RUnlock()
+	v, ok := plugins.validators[name]
+	return v, ok
+}
+
+// RegisteredTransformers returns the names of all registered transformers in
+// lexical order.
+func RegisteredTransformers() []string {
+	plugins.lock.RLock()
+	defer plugins.lock.RUnlock()
+	names := make([]string, 0, len(plugins.transformers))
+	for name := range plugins.transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisteredValidators returns the names of all registered validators in
+// lexical order.
+func RegisteredValidators() []string {
+	plugins.lock.RLock()
+	defer plugins.lock.RUnlock()
+	names := make([]string, 0, len(plugins.validators))
+	for name := range plugins.validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TransformWith applies the transformers registered as names to manifests in
+// order. An error is returned if any name is not registered.
+func TransformWith(manifests []Manifest, names ...string) ([]Manifest, error) {
+	for _, name := range names {
+		t, ok := RegisteredTransformer(name)
+		if !ok {
+			return nil, fmt.Errorf(`transformer "%s" is not registered`, name)
+		}
+		var err error
+		if manifests, err = t.Transform(manifests); err != nil {
+			return nil, fmt.Errorf(`running transformer "%s": %w`, name, err)
+		}
+	}
+	return manifests, nil
+}
+
+// ValidateWith runs the validators registered as names against manifests,
+// returning their findings in order. All registered validators are run when no
+// names are given.
+func ValidateWith(manifests []Manifest, names ...string) ([]Finding, error) {
+	if len(names) == 0 {
+		names = RegisteredValidators()
+	}
+	var findings []Finding
+	for _, name := range names {
+		v, ok := RegisteredValidator(name)
+		if !ok {
+			return nil, fmt.Errorf(`validator "%s" is not registered`, name)
+		}
+		validatorFindings, err := v.Validate(manifests)
+		if err != nil {
+			return nil, fmt.Errorf(`running validator "%s": %w`, name, err)
+		}
+		findings = append(findings, validatorFindings...)
+	}
+	return findings, nil
+}
+
+// LoadPlugins registers the executables in dir as exec plugins, so plugins
+// can be added without recompiling:
+//   - transformer-<name> is registered as the transformer <name>
+//   - validator-<name> is registered as the validator <name>
+// Other files are ignored. An error is returned if a plugin has the name of an
+// already registered transformer or validator. See ExecTransformer and
+// ExecValidator for the protocol plugins implement.
+func LoadPlugins(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf(`loading plugins from %s: %w`, dir, err)
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf(`loading plugins from %s: %w`, dir, err)
+		}
+		if !info.Mode().IsRegular() || !isExecutable(entry.Name(), info.Mode()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		name := entry.Name()
+		if runtime.GOOS == "windows" {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		switch {
+		case strings.HasPrefix(name, TransformerPluginPrefix):
+			err = registerTransformer(strings.TrimPrefix(name, TransformerPluginPrefix), ExecTransformer{Path: path})
+		case strings.HasPrefix(name, ValidatorPluginPrefix):
+			err = registerValidator(strings.TrimPrefix(name, ValidatorPluginPrefix), ExecValidator{Path: path})
+		}
+		if err != nil {
+			return fmt.Errorf(`loading plugin %s: %w`, path, err)
+		}
+	}
+	return nil
+}
+
+// isExecutable determines if a file is executable: by its permissions, or on
+// windows by its extension (e.g. transformer-labels.exe).
+func isExecutable(name string, mode os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(name), ".exe")
+	}
+	return mode.Perm()&0111 != 0
+}
+
+// ExecTransformer is a Transformer implemented by an executable. The
+// manifests are written to its stdin as a multi-document yaml stream and the
+// transformed manifests are read from its stdout in the same format. A
+// non-zero exit fails the transformation with its stderr.
+type ExecTransformer struct {
+	Path string
+	Args []string
+	Env  []string // added to the environment of the process
+}
+
+// Transform runs the executable.
+func (t ExecTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	stdout, err := runPlugin(t.Path, t.Args, t.Env, manifests)
+	if err != nil {
+		return nil, err
+	}
+	maps, err := yamlPlus.DecodeMaps(stdout)
+	if err != nil {
+		return nil, fmt.Errorf(`decoding output of plugin %s: %w`, t.Path, err)
+	}
+	return FromMaps(maps), nil
+}
+
+// ExecValidator is a Validator implemented by an executable. The manifests
+// are written to its stdin as a multi-document yaml stream and its findings
+// are read from its stdout as a JSON array of Findings. e.g:
+//   [{"check": "team-label", "severity": "error", "message": "missing team label",
+//     "resource": {"gvk": {"group": "apps", "version": "v1", "kind": "Deployment"}, "namespace": "web", "name": "api"}}]
+// Empty output reports no findings. A non-zero exit fails the validation with
+// its stderr.
+type ExecValidator struct {
+	Path string
+	Args []string
+	Env  []string // added to the environment of the process
+}
+
+// Validate runs the executable.
+func (v ExecValidator) Validate(manifests []Manifest) ([]Finding, error) {
+	stdout, err := runPlugin(v.Path, v.Args, v.Env, manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil, nil
+	}
+	var findings []Finding
+	if err := json.Unmarshal(stdout, &findings); err != nil {
+		return nil, fmt.Errorf(`decoding output of plugin %s: %w`, v.Path, err)
+	}
+	return findings, nil
+}
+
+// runPlugin runs an exec plugin with manifests on its stdin, returning its
+// stdout.
+func runPlugin(path string, args []string, env []string, manifests []Manifest) ([]byte, error) {
+	stdin, err := Encode(manifests)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(`running plugin %s: %w: %s`, path, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestTransformWith(t *testing.T) {
+	RegisterTransformer("test-add-label", TransformerFunc(func(manifests []Manifest) ([]Manifest, error) {
+		var transformed []Manifest
+		for _, m := range manifests {
+			m = m.DeepCopy()
+			m["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{"team": "platform"}
+			transformed = append(transformed, m)
+		}
+		return transformed, nil
+	}))
+	input := []Manifest{{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}}}
+
+	got, err := TransformWith(input, "test-add-label")
+	if err != nil {
+		t.Fatalf("TransformWith() error = %v", err)
+	}
+	if labels := got[0].Labels(); labels["team"] != "platform" {
+		t.Errorf("TransformWith() labels = %v, want team=platform", labels)
+	}
+	if _, err := TransformWith(input, "test-missing"); err == nil {
+		t.Errorf("TransformWith() of unregistered transformer error = nil")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterTransformer() of duplicate name did not panic")
+		}
+	}()
+	RegisterTransformer("test-add-label", TransformerFunc(nil))
+}
+
+func TestLoadPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		// replaces every manifest with a ConfigMap named after the input's kinds
+		"transformer-test-exec": `kinds=$(grep '^kind:' | tr -d ' ' | tr '\n' '-')
+printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n' "$kinds"`,
+		"validator-test-exec": `grep -q 'team: platform' || echo '[{"check": "team-label", "severity": "error", "message": "missing team label", "resource": {"gvk": {"version": "v1", "kind": "ConfigMap"}, "name": "config"}}]'`,
+		"validator-test-failing": `echo "policy server unavailable" >&2; exit 1`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "transformer-not-executable"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPlugins(dir); err != nil {
+		t.Fatalf("LoadPlugins() error = %v", err)
+	}
+	if _, ok := RegisteredTransformer("not-executable"); ok {
+		t.Errorf("LoadPlugins() registered a file which is not executable")
+	}
+	input := []Manifest{
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}},
+		{"apiVersion": "v1", "kind": "Secret", "metadata": map[string]interface{}{"name": "secret"}},
+	}
+
+	transformed, err := TransformWith(input, "test-exec")
+	if err != nil {
+		t.Fatalf("TransformWith() error = %v", err)
+	}
+	want := []Manifest{{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "kind:ConfigMap-kind:Secret-"}}}
+	if !reflect.DeepEqual(transformed, want) {
+		t.Errorf("TransformWith() = %v, want %v", transformed, want)
+	}
+
+	findings, err := ValidateWith(input, "test-exec")
+	if err != nil {
+		t.Fatalf("ValidateWith() error = %v", err)
+	}
+	wantFindings := []Finding{{
+		Check:    "team-label",
+		Severity: SeverityError,
+		Resource: Key{GVK: GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, Name: "config"},
+		Message:  "missing team label",
+	}}
+	if !reflect.DeepEqual(findings, wantFindings) {
+		t.Errorf("ValidateWith() = %v, want %v", findings, wantFindings)
+	}
+	if _, err := ValidateWith(input, "test-failing"); err == nil {
+		t.Errorf("ValidateWith() of failing plugin error = nil")
+	}
+
+	if err := LoadPlugins(dir); err == nil {
+		t.Errorf("LoadPlugins() of already registered plugins error = nil")
+	}
+}
@@ -0,0 +1,82 @@
+package manifest
+
+// PodClassRule sets the priority and runtime class of the pods of matching
+// workloads. An empty field of the rule is not applied.
+type PodClassRule struct {
+	Namespaces []string // namespaces of the workloads the rule applies to. empty matches all namespaces
+	Kinds      []string // kinds of the workloads the rule applies to (e.g. Deployment). empty matches all workload kinds
+
+	PriorityClassName string // spec.priorityClassName of the pod
+	RuntimeClassName  string // spec.runtimeClassName of the pod
+}
+
+// matches determines if the rule applies to the workload m in namespace.
+func (r PodClassRule) matches(m Manifest, namespace string) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, namespace) {
+		return false
+	}
+	return len(r.Kinds) == 0 || contains(r.Kinds, m.Kind())
+}
+
+// PodClassTransformer is a Transformer setting priorityClassName and
+// runtimeClassName on the pod specs of workloads according to Rules, a
+// common platform policy (e.g. critical namespaces get a higher priority or
+// untrusted workloads run with a sandboxed runtime).
+// For each field, the first matching rule setting it wins. Values already set
+// by the chart are kept unless Override is set.
+type PodClassTransformer struct {
+	Rules            []PodClassRule
+	Override         bool   // replace classes already set in the rendered pod specs
+	DefaultNamespace string // namespace of workloads without one (e.g. the helm release namespace)
+}
+
+// Transform sets the pod classes of the workloads in manifests. Manifests are
+// copied before being modified.
+func (t PodClassTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	transformed := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		transformed[idx] = m
+		if m.PodSpec() == nil {
+			continue
+		}
+		namespace := m.Namespace()
+		if namespace == "" {
+			namespace = t.DefaultNamespace
+		}
+
+		var priorityClassName, runtimeClassName string
+		for _, rule := range t.Rules {
+			if !rule.matches(m, namespace) {
+				continue
+			}
+			if priorityClassName == "" {
+				priorityClassName = rule.PriorityClassName
+			}
+			if runtimeClassName == "" {
+				runtimeClassName = rule.RuntimeClassName
+			}
+		}
+		if priorityClassName == "" && runtimeClassName == "" {
+			continue
+		}
+
+		copied := m.DeepCopy()
+		spec := copied.PodSpec()
+		t.set(spec, "priorityClassName", priorityClassName)
+		t.set(spec, "runtimeClassName", runtimeClassName)
+		transformed[idx] = copied
+	}
+	return transformed, nil
+}
+
+// set sets spec[field] to value if value is not empty, only replacing an
+// existing value when t.Override is set.
+func (t PodClassTransformer) set(spec map[string]interface{}, field string, value string) {
+	if value == "" {
+		return
+	}
+	if existing, ok := spec[field]; ok && existing != nil && existing != "" && !t.Override {
+		return
+	}
+	spec[field] = value
+}
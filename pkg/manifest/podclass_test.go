@@ -0,0 +1,128 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPodClassTransformer_Transform(t *testing.T) {
+	deployment := func(namespace string, spec map[string]interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{"spec": spec},
+			},
+		}
+	}
+	cronJob := func(spec map[string]interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "batch/v1",
+			"kind":       "CronJob",
+			"metadata":   map[string]interface{}{"name": "cleanup"},
+			"spec": map[string]interface{}{
+				"jobTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"template": map[string]interface{}{"spec": spec},
+					},
+				},
+			},
+		}
+	}
+	configMap := Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}}
+
+	type fields struct {
+		Rules            []PodClassRule
+		Override         bool
+		DefaultNamespace string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		manifests []Manifest
+		want      []Manifest
+	}{
+		{
+			name:      "no-rules",
+			manifests: []Manifest{deployment("web", map[string]interface{}{})},
+			want:      []Manifest{deployment("web", map[string]interface{}{})},
+		},
+		{
+			name: "matching-namespace",
+			fields: fields{Rules: []PodClassRule{
+				{Namespaces: []string{"kube-system"}, PriorityClassName: "system-cluster-critical"},
+				{PriorityClassName: "default-priority", RuntimeClassName: "gvisor"},
+			}},
+			manifests: []Manifest{
+				deployment("kube-system", map[string]interface{}{}),
+				deployment("web", map[string]interface{}{}),
+				configMap,
+			},
+			want: []Manifest{
+				deployment("kube-system", map[string]interface{}{"priorityClassName": "system-cluster-critical", "runtimeClassName": "gvisor"}),
+				deployment("web", map[string]interface{}{"priorityClassName": "default-priority", "runtimeClassName": "gvisor"}),
+				configMap,
+			},
+		},
+		{
+			name: "matching-kind-and-default-namespace",
+			fields: fields{
+				Rules: []PodClassRule{
+					{Kinds: []string{"CronJob"}, Namespaces: []string{"batch"}, PriorityClassName: "low-priority"},
+				},
+				DefaultNamespace: "batch",
+			},
+			manifests: []Manifest{
+				cronJob(map[string]interface{}{}),
+				deployment("batch", map[string]interface{}{}),
+			},
+			want: []Manifest{
+				cronJob(map[string]interface{}{"priorityClassName": "low-priority"}),
+				deployment("batch", map[string]interface{}{}),
+			},
+		},
+		{
+			name: "keeps-existing",
+			fields: fields{Rules: []PodClassRule{
+				{PriorityClassName: "default-priority", RuntimeClassName: "gvisor"},
+			}},
+			manifests: []Manifest{deployment("web", map[string]interface{}{"priorityClassName": "high-priority"})},
+			want:      []Manifest{deployment("web", map[string]interface{}{"priorityClassName": "high-priority", "runtimeClassName": "gvisor"})},
+		},
+		{
+			name: "override-existing",
+			fields: fields{
+				Rules: []PodClassRule{
+					{PriorityClassName: "default-priority"},
+				},
+				Override: true,
+			},
+			manifests: []Manifest{deployment("web", map[string]interface{}{"priorityClassName": "high-priority"})},
+			want:      []Manifest{deployment("web", map[string]interface{}{"priorityClassName": "default-priority"})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := PodClassTransformer{
+				Rules:            tt.fields.Rules,
+				Override:         tt.fields.Override,
+				DefaultNamespace: tt.fields.DefaultNamespace,
+			}
+			original := make([]Manifest, len(tt.manifests))
+			for idx, m := range tt.manifests {
+				original[idx] = m.DeepCopy()
+			}
+			got, err := tr.Transform(tt.manifests)
+			if err != nil {
+				t.Fatalf("PodClassTransformer.Transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PodClassTransformer.Transform() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.manifests, original) {
+				t.Errorf("PodClassTransformer.Transform() modified its input")
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package manifest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selector matches manifests against a set of conditions parsed from a query
+// string. All conditions must match for a manifest to be selected.
+//
+// A query is a comma separated list of conditions. Each condition is a field
+// optionally followed by an operator and a value:
+//   kind=Deployment, .spec.replicas>3
+//   namespace!=kube-system, .metadata.labels["app.kubernetes.io/name"]=nginx
+//   .spec.template.spec.containers[*].image=nginx:1.14.2
+//   !.spec.replicas
+// Fields are either one of the shorthands kind, apiVersion, name and namespace
+// or a path starting with "." into the manifest. Path segments containing dots
+// can be quoted in brackets (["a.b"]), list elements are selected by index
+// ([0]) or all at once ([*]).
+// Supported operators are =, ==, !=, >, >=, < and <=. A field without an
+// operator checks for its existence; prefixing it with "!" checks for its
+// absence. Ordering operators only match numeric values.
+type Selector struct {
+	conditions []condition
+}
+
+// condition is a single parsed condition of a query.
+type condition struct {
+	path     []pathSegment
+	operator string // empty for existence checks
+	negate   bool   // only used for existence checks
+	value    string
+}
+
+// pathSegment is a single step into a manifest. Exactly one of key, index or
+// wildcard is used.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// fieldShorthands map the short field names usable in queries to their paths.
+var fieldShorthands = map[string]string{
+	"kind":       ".kind",
+	"apiVersion": ".apiVersion",
+	"name":       ".metadata.name",
+	"namespace":  ".metadata.namespace",
+}
+
+// queryOperators in the order they are searched for; longer operators must
+// come before their prefixes.
+var queryOperators = []string{"==", "!=", ">=", "<=", "=", ">", "<"}
+
+// ParseQuery compiles a query string into a Selector.
+func ParseQuery(query string) (Selector, error) {
+	var selector Selector
+	for _, raw := range splitOutsideBrackets(query, ',') {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		cond, err := parseCondition(raw)
+		if err != nil {
+			return Selector{}, fmt.Errorf(`parsing query condition "%s": %w`, raw, err)
+		}
+		selector.conditions = append(selector.conditions, cond)
+	}
+
+	return selector, nil
+}
+
+// Query returns the manifests matching query. See Selector for the query
+// syntax.
+func Query(manifests []Manifest, query string) ([]Manifest, error) {
+	selector, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return selector.Select(manifests), nil
+}
+
+// Select returns the manifests which match the selector.
+func (s Selector) Select(manifests []Manifest) []Manifest {
+	var selected []Manifest
+	for _, m := range manifests {
+		if s.Matches(m) {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+// Matches determines if m satisfies all conditions of the selector. An empty
+// selector matches everything.
+func (s Selector) Matches(m Manifest) bool {
+	for _, cond := range s.conditions {
+		if !cond.matches(m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c condition) matches(m Manifest) bool {
+	values := resolvePath(map[string]interface{}(m), c.path)
+	switch c.operator {
+	case "":
+		return (len(values) > 0) != c.negate
+	case "!=":
+		for _, value := range values {
+			if formatScalar(value) == c.value {
+				return false
+			}
+		}
+		return true
+	case "=", "==":
+		for _, value := range values {
+			if formatScalar(value) == c.value {
+				return true
+			}
+		}
+		return false
+	default:
+		want, err := strconv.ParseFloat(c.value, 64)
+		if err != nil {
+			return false
+		}
+		for _, value := range values {
+			got, err := strconv.ParseFloat(formatScalar(value), 64)
+			if err != nil {
+				continue
+			}
+			if (c.operator == ">" && got > want) ||
+				(c.operator == ">=" && got >= want) ||
+				(c.operator == "<" && got < want) ||
+				(c.operator == "<=" && got <= want) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func parseCondition(raw string) (condition, error) {
+	var cond condition
+	field := raw
+	if idx, op := findOperator(raw); idx >= 0 {
+		field = strings.TrimSpace(raw[:idx])
+		cond.operator = op
+		cond.value = unquote(strings.TrimSpace(raw[idx+len(op):]))
+	} else if strings.HasPrefix(field, "!") {
+		cond.negate = true
+		field = strings.TrimSpace(field[1:])
+	}
+
+	if path, ok := fieldShorthands[field]; ok {
+		field = path
+	}
+	if !strings.HasPrefix(field, ".") {
+		return cond, fmt.Errorf(`field "%s" must be one of kind, apiVersion, name, namespace or a path starting with "."`, field)
+	}
+	path, err := parsePath(field)
+	if err != nil {
+		return cond, err
+	}
+	cond.path = path
+
+	return cond, nil
+}
+
+// findOperator returns the index and value of the first operator in raw which
+// is not within brackets.
+func findOperator(raw string) (int, string) {
+	depth := 0
+	for idx := 0; idx < len(raw); idx++ {
+		switch raw[idx] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		default:
+			if depth > 0 {
+				continue
+			}
+			for _, op := range queryOperators {
+				// a leading "!" is a negated existence check, not "!="
+				if strings.HasPrefix(raw[idx:], op) && !(op == "!=" && idx == 0) {
+					return idx, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+// parsePath parses a path such as .spec.containers[0]["some.key"] into its
+// segments.
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	rest := path
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf(`empty segment in path "%s"`, path)
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf(`unterminated "[" in path "%s"`, path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case strings.HasPrefix(inner, `"`) || strings.HasPrefix(inner, `'`):
+				segments = append(segments, pathSegment{key: unquote(inner)})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf(`invalid index "%s" in path "%s"`, inner, path)
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf(`unexpected character "%c" in path "%s"`, rest[0], path)
+		}
+	}
+
+	return segments, nil
+}
+
+// resolvePath returns all values found at path in value. Multiple values can
+// be returned when the path contains wildcards.
+func resolvePath(value interface{}, path []pathSegment) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	segment, rest := path[0], path[1:]
+
+	switch {
+	case segment.wildcard:
+		var values []interface{}
+		for _, entry := range toSlice(value) {
+			values = append(values, resolvePath(entry, rest)...)
+		}
+		if asMap, ok := value.(map[string]interface{}); ok {
+			for _, entry := range asMap {
+				values = append(values, resolvePath(entry, rest)...)
+			}
+		}
+		return values
+	case segment.isIndex:
+		slice := toSlice(value)
+		if segment.index < 0 || segment.index >= len(slice) {
+			return nil
+		}
+		return resolvePath(slice[segment.index], rest)
+	default:
+		asMap, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		entry, ok := asMap[segment.key]
+		if !ok {
+			return nil
+		}
+		return resolvePath(entry, rest)
+	}
+}
+
+func toSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		slice := make([]interface{}, len(v))
+		for idx, entry := range v {
+			slice[idx] = entry
+		}
+		return slice
+	default:
+		return nil
+	}
+}
+
+// formatScalar formats a decoded yaml scalar for comparison against query
+// values.
+func formatScalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitOutsideBrackets splits s on sep, ignoring separators within brackets.
+func splitOutsideBrackets(s string, sep byte) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for idx := 0; idx < len(s); idx++ {
+		switch s[idx] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,63 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	scaledDeployment := Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "api",
+			"namespace": "web",
+			"labels": map[string]interface{}{
+				"app.kubernetes.io/name": "api",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": 5,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "api", "image": "api:1.0.0"},
+						map[string]interface{}{"name": "proxy", "image": "envoy:1.17"},
+					},
+				},
+			},
+		},
+	}
+	manifests := []Manifest{sampleDeployment, sampleService, sampleClusterRole, scaledDeployment}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []Manifest
+		wantErr bool
+	}{
+		{"empty query", "", manifests, false},
+		{"kind", "kind=Deployment", []Manifest{sampleDeployment, scaledDeployment}, false},
+		{"kind and numeric comparison", "kind=Deployment, .spec.replicas>3", []Manifest{scaledDeployment}, false},
+		{"not equal", "namespace!=web", []Manifest{sampleClusterRole}, false},
+		{"quoted key", `.metadata.labels["app.kubernetes.io/name"]=api`, []Manifest{scaledDeployment}, false},
+		{"wildcard", ".spec.template.spec.containers[*].image==envoy:1.17", []Manifest{scaledDeployment}, false},
+		{"index", ".spec.template.spec.containers[0].name=proxy", nil, false},
+		{"existence", ".spec.replicas", []Manifest{scaledDeployment}, false},
+		{"absence", "!.metadata.namespace", []Manifest{sampleClusterRole}, false},
+		{"invalid field", "replicas>3", nil, true},
+		{"invalid index", ".spec.containers[foo]", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Query(manifests, tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Query() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// CheckQuota is the check identifier for renders exceeding the ResourceQuotas
+// of their namespaces or whose pods would be rejected by them.
+const CheckQuota = "quota"
+
+// QuotaOptions configure SimulateQuotas.
+type QuotaOptions struct {
+	// Quotas are the ResourceQuotas and LimitRanges of the target namespaces,
+	// e.g. loaded with LoadDirectory or ClusterQuotas. Those in the render are
+	// also used and replace quotas of Quotas with the same name, keeping the
+	// usage reported in their status.
+	Quotas []Manifest
+	// Namespace is the namespace of namespaced resources which do not set one.
+	// Defaults to "default".
+	Namespace string
+	// IncludeUsed adds the usage reported by the cluster in status.used of
+	// Quotas to the usage of the render. Resources of the render which already
+	// exist in the cluster (e.g. when upgrading a release) are counted twice.
+	IncludeUsed bool
+	// DaemonSetNodes is the number of nodes the pods of DaemonSets are
+	// projected to run on. Defaults to 1.
+	DaemonSetNodes int
+}
+
+// QuotaUsage is the projected usage of a resource limited by a ResourceQuota.
+// Usage is in the base unit of the resource: cores, bytes or a count.
+type QuotaUsage struct {
+	Quota    Key    // the ResourceQuota
+	Resource string // limited resource. e.g: "requests.cpu", "pods", "count/deployments.apps"
+	Used     float64
+	Hard     float64 // spec.hard of the quota
+	Missing  []Key   // workloads rejected by the quota as their containers do not set the resource (e.g. limits.memory) and no LimitRange provides a default
+}
+
+// Exceeded determines if the projected usage exceeds the limit.
+func (u QuotaUsage) Exceeded() bool {
+	return u.Used > u.Hard
+}
+
+// String formats the usage like `kubectl describe quota`. e.g:
+// "requests.cpu: 2500m/2 (125%)".
+func (u QuotaUsage) String() string {
+	usage := fmt.Sprintf("%s: %s/%s", u.Resource, formatQuantity(u.Resource, u.Used), formatQuantity(u.Resource, u.Hard))
+	if u.Hard > 0 {
+		usage += fmt.Sprintf(" (%.0f%%)", 100*u.Used/u.Hard)
+	}
+	return usage
+}
+
+// SimulateQuotas projects the usage of the ResourceQuotas of each namespace
+// if manifests were applied, returning the usage of every resource limited by
+// a quota in the order of the quotas (opts.Quotas first) and resources.
+//
+// Pods are projected from the static replica count of their workloads (the
+// parallelism of Jobs), with the requests and limits of their containers
+// defaulted by the LimitRanges of their namespace. Persistent volume claims,
+// including the volumeClaimTemplates of StatefulSets, count against storage
+// quotas and every resource against object count quotas (e.g. "services" or
+// "count/deployments.apps"). Quotas with scopes (e.g. BestEffort or a
+// PriorityClass scopeSelector) only count the pods they match.
+func SimulateQuotas(manifests []Manifest, opts QuotaOptions) ([]QuotaUsage, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.DaemonSetNodes <= 0 {
+		opts.DaemonSetNodes = 1
+	}
+
+	var quotas []Manifest
+	quotaIndices := map[Key]int{}
+	limitRanges := map[string][]Manifest{}
+	for _, m := range append(append([]Manifest{}, opts.Quotas...), manifests...) {
+		switch m.GVK().GroupKind() {
+		case GroupKind{Kind: "ResourceQuota"}:
+			key := opts.keyOf(m)
+			if idx, ok := quotaIndices[key]; ok {
+				if _, ok := m["status"]; !ok && quotas[idx]["status"] != nil {
+					m = m.DeepCopy()
+					m["status"] = quotas[idx]["status"]
+				}
+				quotas[idx] = m
+				continue
+			}
+			quotaIndices[key] = len(quotas)
+			quotas = append(quotas, m)
+		case GroupKind{Kind: "LimitRange"}:
+			namespace := opts.namespaceOf(m)
+			limitRanges[namespace] = append(limitRanges[namespace], m)
+		}
+	}
+
+	var consumers []quotaConsumer
+	for _, m := range manifests {
+		consumer, err := opts.consumer(m, limitRanges[opts.namespaceOf(m)])
+		if err != nil {
+			return nil, fmt.Errorf(`simulating quotas of %s %s: %w`, m.GVK(), m.Name(), err)
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	var usages []QuotaUsage
+	for _, quota := range quotas {
+		hard, err := quantities(quota, "spec", "hard")
+		if err != nil {
+			return nil, fmt.Errorf(`simulating quota %s: %w`, quota.Name(), err)
+		}
+		used := map[string]float64{}
+		if opts.IncludeUsed {
+			if used, err = quantities(quota, "status", "used"); err != nil {
+				return nil, fmt.Errorf(`simulating quota %s: %w`, quota.Name(), err)
+			}
+		}
+		resources := make([]string, 0, len(hard))
+		for resource := range hard {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+
+		for _, resource := range resources {
+			usage := QuotaUsage{Quota: opts.keyOf(quota), Resource: resource, Used: used[resource], Hard: hard[resource]}
+			for _, consumer := range consumers {
+				if consumer.namespace != usage.Quota.Namespace || !consumer.inScope(quota) {
+					continue
+				}
+				usage.Used += consumer.usage[quotaResource(resource)]
+				if consumer.pod != nil && consumer.pod.missing(resource) {
+					usage.Missing = append(usage.Missing, consumer.key)
+				}
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+// QuotaFindings converts usages into Findings: an error for each exceeded
+// limit and each workload rejected for not setting a limited resource, and a
+// warning for usage of at least warnAt (e.g. 0.8) of a limit. A warnAt of 0
+// disables warnings.
+func QuotaFindings(usages []QuotaUsage, warnAt float64) []Finding {
+	var findings []Finding
+	for _, usage := range usages {
+		used, hard := formatQuantity(usage.Resource, usage.Used), formatQuantity(usage.Resource, usage.Hard)
+		switch {
+		case usage.Exceeded():
+			findings = append(findings, Finding{
+				Check:    CheckQuota,
+				Severity: SeverityError,
+				Resource: usage.Quota,
+				Message:  fmt.Sprintf("projected %s of %s exceeds the limit of %s", usage.Resource, used, hard),
+			})
+		case warnAt > 0 && usage.Hard > 0 && usage.Used >= warnAt*usage.Hard:
+			findings = append(findings, Finding{
+				Check:    CheckQuota,
+				Severity: SeverityWarning,
+				Resource: usage.Quota,
+				Message:  fmt.Sprintf("projected %s of %s is %.0f%% of the limit of %s", usage.Resource, used, 100*usage.Used/usage.Hard, hard),
+			})
+		}
+		for _, workload := range usage.Missing {
+			findings = append(findings, Finding{
+				Check:    CheckQuota,
+				Severity: SeverityError,
+				Resource: workload,
+				Message:  fmt.Sprintf("containers do not set %s which ResourceQuota %s/%s limits, so its pods are rejected", usage.Resource, usage.Quota.Namespace, usage.Quota.Name),
+			})
+		}
+	}
+	return findings
+}
+
+// ClusterQuotas returns the ResourceQuotas and LimitRanges of the cluster of
+// opts, with their current usage in status.used: of opts.Namespace if set,
+// otherwise of all namespaces.
+func ClusterQuotas(opts DryRunOptions) ([]Manifest, error) {
+	args := []string{"get", "resourcequotas,limitranges", "--output", "yaml"}
+	if opts.Namespace != "" {
+		args = append(args, "--namespace", opts.Namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+	cmd := opts.command(args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if unreachablePatterns.MatchString(message) {
+			return nil, fmt.Errorf(`listing quotas: %w: %s`, ErrClusterUnreachable, message)
+		}
+		return nil, fmt.Errorf(`listing quotas: %w: %s`, err, message)
+	}
+	maps, err := yamlPlus.DecodeMaps(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf(`decoding quotas: %w`, err)
+	} else if len(maps) != 1 {
+		return nil, fmt.Errorf(`decoding quotas: expected a List, found %d documents`, len(maps))
+	}
+	var quotas []Manifest
+	items, _ := yamlPlus.GetSlice(maps[0], "items")
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			quotas = append(quotas, m)
+		}
+	}
+	return quotas, nil
+}
+
+// namespaceOf returns the namespace of m, defaulting to opts.Namespace.
+func (opts QuotaOptions) namespaceOf(m Manifest) string {
+	if namespace := m.Namespace(); namespace != "" {
+		return namespace
+	}
+	return opts.Namespace
+}
+
+func (opts QuotaOptions) keyOf(m Manifest) Key {
+	key := KeyOf(m)
+	key.Namespace = opts.namespaceOf(m)
+	return key
+}
+
+// quotaConsumer is the usage of quota resources by a single manifest.
+type quotaConsumer struct {
+	key       Key
+	namespace string
+	usage     map[string]float64 // by quota resource
+	pod       *podResources      // set for workloads
+}
+
+// consumer returns the usage of quota resources by m.
+func (opts QuotaOptions) consumer(m Manifest, limitRanges []Manifest) (quotaConsumer, error) {
+	consumer := quotaConsumer{key: opts.keyOf(m), namespace: opts.namespaceOf(m), usage: map[string]float64{}}
+	gvk := m.GVK()
+	countResource := "count/" + pluralResource(gvk.Kind)
+	if gvk.Group != "" {
+		countResource += "." + gvk.Group
+	}
+	consumer.usage[countResource]++
+
+	switch gvk.GroupKind() {
+	case GroupKind{Kind: "Service"}:
+		consumer.usage["services"]++
+		serviceType, _ := yamlPlus.GetString(m, "spec", "type")
+		ports, _ := yamlPlus.GetSlice(m, "spec", "ports")
+		switch serviceType {
+		case "LoadBalancer":
+			consumer.usage["services.loadbalancers"]++
+			consumer.usage["services.nodeports"] += float64(len(ports))
+		case "NodePort":
+			consumer.usage["services.nodeports"] += float64(len(ports))
+		}
+	case GroupKind{Kind: "Secret"}:
+		consumer.usage["secrets"]++
+	case GroupKind{Kind: "ConfigMap"}:
+		consumer.usage["configmaps"]++
+	case GroupKind{Kind: "ReplicationController"}:
+		consumer.usage["replicationcontrollers"]++
+	case GroupKind{Kind: "ResourceQuota"}:
+		consumer.usage["resourcequotas"]++
+	}
+
+	claims, err := persistentClaims(m, podReplicas(m, opts.DaemonSetNodes))
+	if err != nil {
+		return consumer, err
+	}
+	for _, claim := range claims {
+		consumer.usage["persistentvolumeclaims"] += claim.count
+		consumer.usage["requests.storage"] += claim.storage * claim.count
+		if claim.class != "" {
+			consumer.usage[claim.class+".storageclass.storage.k8s.io/persistentvolumeclaims"] += claim.count
+			consumer.usage[claim.class+".storageclass.storage.k8s.io/requests.storage"] += claim.storage * claim.count
+		}
+	}
+	if !m.IsWorkload() {
+		return consumer, nil
+	}
+
+	replicas := podReplicas(m, opts.DaemonSetNodes)
+	pod, err := newPodResources(m.PodSpec(), limitRanges)
+	if err != nil {
+		return consumer, err
+	}
+	consumer.pod = pod
+	consumer.usage["pods"] += float64(replicas)
+	if m.Kind() != "Pod" {
+		consumer.usage["count/pods"] += float64(replicas)
+	}
+	for resource, value := range pod.requests {
+		consumer.usage["requests."+resource] += value * float64(replicas)
+	}
+	for resource, value := range pod.limits {
+		consumer.usage["limits."+resource] += value * float64(replicas)
+	}
+	return consumer, nil
+}
+
+// podReplicas returns the number of pods the workload m runs: its static
+// replica count, the parallelism of Jobs or daemonSetNodes for DaemonSets.
+// Resources which are not workloads run none.
+func podReplicas(m Manifest, daemonSetNodes int) int {
+	if !m.IsWorkload() {
+		return 0
+	}
+	replicas := 1
+	switch m.Kind() {
+	case "Deployment", "StatefulSet", "ReplicaSet", "ReplicationController":
+		if value, ok := yamlPlus.GetInt(m, "spec", "replicas"); ok {
+			replicas = value
+		}
+	case "DaemonSet":
+		replicas = daemonSetNodes
+	case "Job", "CronJob":
+		jobSpec, _ := yamlPlus.GetMap(m, "spec")
+		if m.Kind() == "CronJob" {
+			jobSpec, _ = yamlPlus.GetMap(m, "spec", "jobTemplate", "spec")
+		}
+		if value, ok := yamlPlus.GetInt(jobSpec, "parallelism"); ok {
+			replicas = value
+		}
+		if completions, ok := yamlPlus.GetInt(jobSpec, "completions"); ok && completions < replicas {
+			replicas = completions
+		}
+	}
+	return replicas
+}
+
+// persistentClaim is a persistent volume claim created count times.
+type persistentClaim struct {
+	storage float64 // requested bytes
+	class   string  // storageClassName. empty for the default StorageClass
+	count   float64
+}
+
+// persistentClaims returns the persistent volume claims m creates: a
+// PersistentVolumeClaim is a claim itself and StatefulSets create a claim of
+// each of their volumeClaimTemplates per replica.
+func persistentClaims(m Manifest, replicas int) ([]persistentClaim, error) {
+	var templates []interface{}
+	count := 1
+	switch m.GVK().GroupKind() {
+	case GroupKind{Kind: "PersistentVolumeClaim"}:
+		templates = []interface{}{map[string]interface{}(m)}
+	case GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		templates, _ = yamlPlus.GetSlice(m, "spec", "volumeClaimTemplates")
+		count = replicas
+	}
+	var claims []persistentClaim
+	for _, entry := range templates {
+		template, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		storage, _, err := quantityAt(template, "spec", "resources", "requests", "storage")
+		if err != nil {
+			return nil, err
+		}
+		class, _ := yamlPlus.GetString(template, "spec", "storageClassName")
+		claims = append(claims, persistentClaim{storage: storage, class: class, count: float64(count)})
+	}
+	return claims, nil
+}
+
+// inScope determines if the usage of the consumer counts against quota.
+// Quotas with scopes only track pods.
+func (c quotaConsumer) inScope(quota Manifest) bool {
+	scopes, _ := yamlPlus.GetSlice(quota, "spec", "scopes")
+	expressions, _ := yamlPlus.GetSlice(quota, "spec", "scopeSelector", "matchExpressions")
+	if len(scopes) == 0 && len(expressions) == 0 {
+		return true
+	}
+	if c.pod == nil {
+		return false
+	}
+	for _, scope := range scopes {
+		if !c.pod.inScope(fmt.Sprint(scope), "", nil) {
+			return false
+		}
+	}
+	for _, entry := range expressions {
+		expression, _ := entry.(map[string]interface{})
+		scope, _ := yamlPlus.GetString(expression, "scopeName")
+		operator, _ := yamlPlus.GetString(expression, "operator")
+		var values []string
+		entries, _ := yamlPlus.GetSlice(expression, "values")
+		for _, value := range entries {
+			values = append(values, fmt.Sprint(value))
+		}
+		if !c.pod.inScope(scope, operator, values) {
+			return false
+		}
+	}
+	return true
+}
+
+// podResources are the compute resources of a pod after defaults of
+// LimitRanges are applied.
+type podResources struct {
+	requests, limits map[string]float64 // effective resources of the pod by resource name. e.g: "cpu"
+	containers       []containerResources
+	terminating      bool   // spec.activeDeadlineSeconds is set
+	priorityClass    string // spec.priorityClassName
+}
+
+type containerResources struct {
+	requests, limits map[string]float64
+}
+
+// newPodResources returns the resources of spec. As with the scheduler, the
+// effective request of a resource is the sum of the containers or the largest
+// init container, whichever is greater.
+func newPodResources(spec map[string]interface{}, limitRanges []Manifest) (*podResources, error) {
+	pod := &podResources{requests: map[string]float64{}, limits: map[string]float64{}}
+	_, pod.terminating = yamlPlus.Get(spec, "activeDeadlineSeconds")
+	pod.priorityClass, _ = yamlPlus.GetString(spec, "priorityClassName")
+
+	initRequests, initLimits := map[string]float64{}, map[string]float64{}
+	for _, field := range []string{"initContainers", "containers"} {
+		entries, _ := yamlPlus.GetSlice(spec, field)
+		for _, entry := range entries {
+			container, _ := entry.(map[string]interface{})
+			resources, err := newContainerResources(container, limitRanges)
+			if err != nil {
+				return nil, err
+			}
+			pod.containers = append(pod.containers, resources)
+			for _, sum := range []struct{ total, init, container map[string]float64 }{
+				{pod.requests, initRequests, resources.requests},
+				{pod.limits, initLimits, resources.limits},
+			} {
+				for resource, value := range sum.container {
+					if field == "initContainers" {
+						sum.init[resource] = math.Max(sum.init[resource], value)
+					} else {
+						sum.total[resource] += value
+					}
+				}
+			}
+		}
+	}
+	for resource, value := range initRequests {
+		pod.requests[resource] = math.Max(pod.requests[resource], value)
+	}
+	for resource, value := range initLimits {
+		pod.limits[resource] = math.Max(pod.limits[resource], value)
+	}
+	return pod, nil
+}
+
+// newContainerResources returns the requests and limits of container,
+// defaulted as the LimitRanger admission plugin does: missing limits from the
+// default of a LimitRange, missing requests from its defaultRequest, or else
+// from the limit.
+func newContainerResources(container map[string]interface{}, limitRanges []Manifest) (containerResources, error) {
+	requests, err := quantities(container, "resources", "requests")
+	if err != nil {
+		return containerResources{}, err
+	}
+	limits, err := quantities(container, "resources", "limits")
+	if err != nil {
+		return containerResources{}, err
+	}
+	for _, limitRange := range limitRanges {
+		entries, _ := yamlPlus.GetSlice(limitRange, "spec", "limits")
+		for _, entry := range entries {
+			limit, _ := entry.(map[string]interface{})
+			if limitType, _ := yamlPlus.GetString(limit, "type"); limitType != "Container" {
+				continue
+			}
+			for _, defaults := range []struct {
+				field  string
+				target map[string]float64
+			}{{"default", limits}, {"defaultRequest", requests}} {
+				values, err := quantities(limit, defaults.field)
+				if err != nil {
+					return containerResources{}, fmt.Errorf(`LimitRange %s: %w`, limitRange.Name(), err)
+				}
+				for resource, value := range values {
+					if _, ok := defaults.target[resource]; !ok {
+						defaults.target[resource] = value
+					}
+				}
+			}
+		}
+	}
+	for resource, value := range limits {
+		if _, ok := requests[resource]; !ok {
+			requests[resource] = value
+		}
+	}
+	return containerResources{requests: requests, limits: limits}, nil
+}
+
+// missing determines if a container of the pod does not set the cpu or
+// memory resource limited by a quota as resource, which rejects the pod.
+func (p *podResources) missing(resource string) bool {
+	var values func(containerResources) map[string]float64
+	switch quotaResource(resource) {
+	case "requests.cpu", "requests.memory":
+		values = func(c containerResources) map[string]float64 { return c.requests }
+	case "limits.cpu", "limits.memory":
+		values = func(c containerResources) map[string]float64 { return c.limits }
+	default:
+		return false
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(quotaResource(resource), "requests."), "limits.")
+	for _, container := range p.containers {
+		if _, ok := values(container)[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope determines if the pod matches the quota scope. operator and values
+// are those of a scopeSelector expression, empty for spec.scopes.
+func (p *podResources) inScope(scope string, operator string, values []string) bool {
+	bestEffort := len(p.requests) == 0 && len(p.limits) == 0
+	switch scope {
+	case "Terminating":
+		return p.terminating
+	case "NotTerminating":
+		return !p.terminating
+	case "BestEffort":
+		return bestEffort
+	case "NotBestEffort":
+		return !bestEffort
+	case "PriorityClass":
+		switch operator {
+		case "In":
+			return contains(values, p.priorityClass)
+		case "NotIn":
+			return !contains(values, p.priorityClass)
+		case "Exists", "":
+			return p.priorityClass != ""
+		case "DoesNotExist":
+			return p.priorityClass == ""
+		}
+	}
+	return false
+}
+
+// quotaResource normalizes a resource of a quota: "cpu", "memory" and
+// "ephemeral-storage" are shorthands of their requests.
+func quotaResource(resource string) string {
+	switch resource {
+	case "cpu", "memory", "ephemeral-storage":
+		return "requests." + resource
+	}
+	return resource
+}
+
+// pluralResource returns the resource name of kind as used by object count
+// quotas. e.g: "Deployment" -> "deployments", "NetworkPolicy" ->
+// "networkpolicies".
+func pluralResource(kind string) string {
+	resource := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(resource, "s"):
+		return resource + "es"
+	case strings.HasSuffix(resource, "y"):
+		return strings.TrimSuffix(resource, "y") + "ies"
+	default:
+		return resource + "s"
+	}
+}
+
+// quantityRgx matches a Kubernetes resource quantity. e.g: "100m", "1.5Gi",
+// "1e3".
+var quantityRgx = regexp.MustCompile(`^([+-]?(?:\d+\.?\d*|\.\d+))(?:([eE][+-]?\d+)|(Ki|Mi|Gi|Ti|Pi|Ei|n|u|m|k|M|G|T|P|E))?$`)
+
+var quantitySuffixes = map[string]float64{
+	"n": 1e-9, "u": 1e-6, "m": 1e-3, "": 1,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+}
+
+// parseQuantity parses a Kubernetes resource quantity into its value in the
+// base unit. e.g: "100m" -> 0.1, "1Ki" -> 1024.
+func parseQuantity(quantity string) (float64, error) {
+	matches := quantityRgx.FindStringSubmatch(strings.TrimSpace(quantity))
+	if matches == nil {
+		return 0, fmt.Errorf(`invalid quantity "%s"`, quantity)
+	}
+	value, err := strconv.ParseFloat(matches[1]+matches[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid quantity "%s": %w`, quantity, err)
+	}
+	return value * quantitySuffixes[matches[3]], nil
+}
+
+// quantityAt returns the quantity found at path in m.
+func quantityAt(m map[string]interface{}, path ...string) (float64, bool, error) {
+	quantity, ok := yamlPlus.GetString(m, path...)
+	if !ok {
+		return 0, false, nil
+	}
+	value, err := parseQuantity(quantity)
+	if err != nil {
+		return 0, false, fmt.Errorf(`%s: %w`, strings.Join(path, "."), err)
+	}
+	return value, true, nil
+}
+
+// quantities returns the map of quantities found at path in m. e.g: the
+// requests of a container.
+func quantities(m map[string]interface{}, path ...string) (map[string]float64, error) {
+	values := map[string]float64{}
+	entries, _ := yamlPlus.GetMap(m, path...)
+	for resource := range entries {
+		value, _, err := quantityAt(entries, resource)
+		if err != nil {
+			return nil, fmt.Errorf(`%s.%w`, strings.Join(path, "."), err)
+		}
+		values[resource] = value
+	}
+	return values, nil
+}
+
+// formatQuantity formats value of resource as a quantity: cpu in cores or
+// millicores, memory and storage in binary units where exact.
+func formatQuantity(resource string, value float64) string {
+	switch {
+	case strings.HasSuffix(resource, "cpu"):
+		if value != math.Trunc(value) {
+			return fmt.Sprintf("%.0fm", value*1000)
+		}
+	case strings.HasSuffix(resource, "memory") || strings.HasSuffix(resource, "storage"):
+		for _, suffix := range []string{"Ei", "Pi", "Ti", "Gi", "Mi", "Ki"} {
+			unit := quantitySuffixes[suffix]
+			if value >= unit && math.Mod(value, unit) == 0 {
+				return fmt.Sprintf("%.0f%s", value/unit, suffix)
+			}
+		}
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		quantity string
+		want     float64
+		wantErr  bool
+	}{
+		{"2", 2, false},
+		{"250m", 0.25, false},
+		{"1.5", 1.5, false},
+		{"128Mi", 128 << 20, false},
+		{"1G", 1e9, false},
+		{"1e3", 1000, false},
+		{"2E", 2e18, false},
+		{"10Gb", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quantity, func(t *testing.T) {
+			got, err := parseQuantity(tt.quantity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuantity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseQuantity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulateQuotas(t *testing.T) {
+	container := func(name string, requests map[string]interface{}, limits map[string]interface{}) map[string]interface{} {
+		resources := map[string]interface{}{}
+		if requests != nil {
+			resources["requests"] = requests
+		}
+		if limits != nil {
+			resources["limits"] = limits
+		}
+		return map[string]interface{}{"name": name, "resources": resources}
+	}
+	deployment := func(name string, replicas int, containers ...interface{}) Manifest {
+		return Manifest{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": name, "namespace": "team"},
+			"spec": map[string]interface{}{"replicas": replicas, "template": map[string]interface{}{"spec": map[string]interface{}{"containers": containers}}}}
+	}
+	quota := func(name string, spec map[string]interface{}, used map[string]interface{}) Manifest {
+		return Manifest{"apiVersion": "v1", "kind": "ResourceQuota", "metadata": map[string]interface{}{"name": name, "namespace": "team"},
+			"spec": spec, "status": map[string]interface{}{"used": used}}
+	}
+
+	existing := []Manifest{
+		quota("compute", map[string]interface{}{"hard": map[string]interface{}{"requests.cpu": "2", "limits.memory": "4Gi", "pods": 10}},
+			map[string]interface{}{"requests.cpu": "500m", "limits.memory": "1Gi", "pods": 2}),
+		quota("objects", map[string]interface{}{"hard": map[string]interface{}{"services": 1, "count/deployments.apps": 5, "requests.storage": "10Gi"}}, nil),
+		quota("best-effort", map[string]interface{}{"hard": map[string]interface{}{"pods": 1}, "scopes": []interface{}{"BestEffort"}}, nil),
+		{"apiVersion": "v1", "kind": "LimitRange", "metadata": map[string]interface{}{"name": "defaults", "namespace": "team"},
+			"spec": map[string]interface{}{"limits": []interface{}{map[string]interface{}{"type": "Container", "default": map[string]interface{}{"memory": "256Mi"}}}}},
+	}
+	manifests := []Manifest{
+		// 3 x (500m + 100m) cpu, 3 x (512Mi + 256Mi defaulted) memory
+		deployment("web", 3,
+			container("app", map[string]interface{}{"cpu": "500m"}, map[string]interface{}{"memory": "512Mi"}),
+			container("sidecar", map[string]interface{}{"cpu": "100m"}, nil)),
+		deployment("worker", 1, container("worker", nil, nil)),
+		{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web", "namespace": "team"}},
+		{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "worker", "namespace": "team"}},
+		{"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": map[string]interface{}{"name": "data", "namespace": "team"},
+			"spec": map[string]interface{}{"resources": map[string]interface{}{"requests": map[string]interface{}{"storage": "8Gi"}}}},
+		// not in the namespace of the quotas
+		deployment("elsewhere", 10, container("app", map[string]interface{}{"cpu": "4"}, nil)).DeepCopy(),
+	}
+	manifests[len(manifests)-1]["metadata"].(map[string]interface{})["namespace"] = "other"
+
+	usages, err := SimulateQuotas(manifests, QuotaOptions{Quotas: existing})
+	if err != nil {
+		t.Fatalf("SimulateQuotas() error = %v", err)
+	}
+	var got []string
+	for _, usage := range usages {
+		got = append(got, usage.Quota.Name+" "+usage.String())
+	}
+	want := []string{
+		"compute limits.memory: 2560Mi/4Gi (62%)",
+		"compute pods: 4/10 (40%)",
+		"compute requests.cpu: 1800m/2 (90%)",
+		"objects count/deployments.apps: 2/5 (40%)",
+		"objects requests.storage: 8Gi/10Gi (80%)",
+		"objects services: 2/1 (200%)",
+		"best-effort pods: 0/1 (0%)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SimulateQuotas() = %#v, want %#v", got, want)
+	}
+	if missing := usages[2].Missing; len(missing) != 1 || missing[0].Name != "worker" {
+		t.Errorf("SimulateQuotas() requests.cpu missing = %v, want worker", missing)
+	}
+
+	findings := QuotaFindings(usages, 0.8)
+	var messages []string
+	for _, finding := range findings {
+		messages = append(messages, string(finding.Severity)+" "+finding.Resource.Name+": "+finding.Message)
+	}
+	wantMessages := []string{
+		"warning compute: projected requests.cpu of 1800m is 90% of the limit of 2",
+		"error worker: containers do not set requests.cpu which ResourceQuota team/compute limits, so its pods are rejected",
+		"warning objects: projected requests.storage of 8Gi is 80% of the limit of 10Gi",
+		"error objects: projected services of 2 exceeds the limit of 1",
+	}
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Errorf("QuotaFindings() = %#v, want %#v", messages, wantMessages)
+	}
+
+	// the usage reported by the cluster is added to the render
+	usages, err = SimulateQuotas(manifests, QuotaOptions{Quotas: existing, IncludeUsed: true})
+	if err != nil {
+		t.Fatalf("SimulateQuotas() error = %v", err)
+	}
+	if usage := usages[2]; usage.String() != "requests.cpu: 2300m/2 (115%)" || !usage.Exceeded() {
+		t.Errorf("SimulateQuotas() with used = %v, want exceeded requests.cpu 2300m/2", usage)
+	}
+}
@@ -0,0 +1,183 @@
+package manifest
+
+import (
+	"fmt"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+const (
+	// CheckServiceSelector is the check identifier for Services whose selector
+	// matches no rendered pods.
+	CheckServiceSelector = "service-selector"
+	// CheckMissingConfigMap is the check identifier for workloads referencing
+	// ConfigMaps which are not rendered.
+	CheckMissingConfigMap = "missing-configmap"
+	// CheckMissingSecret is the check identifier for workloads referencing
+	// Secrets which are not rendered.
+	CheckMissingSecret = "missing-secret"
+	// CheckMissingServiceAccount is the check identifier for workloads
+	// referencing ServiceAccounts which are not rendered.
+	CheckMissingServiceAccount = "missing-serviceaccount"
+)
+
+// ValidateReferences checks references between resources within a render:
+//   - Services with a selector matching no pods of a rendered workload
+//   - workloads mounting or reading environment variables from ConfigMaps and
+//     Secrets which are not rendered (optional references are skipped)
+//   - workloads running as a ServiceAccount which is not rendered (the
+//     "default" ServiceAccount is skipped)
+// References can only be resolved within the same namespace. Resources which
+// already exist in the cluster will be reported as well; the findings are
+// warnings.
+func ValidateReferences(manifests []Manifest) []Finding {
+	rendered := map[Key]bool{}
+	var services, workloads []Manifest
+	for _, m := range manifests {
+		rendered[Key{GVK: GroupVersionKind{Kind: m.Kind()}, Namespace: m.Namespace(), Name: m.Name()}] = true
+		switch {
+		case m.Kind() == "Service":
+			services = append(services, m)
+		case m.IsWorkload():
+			workloads = append(workloads, m)
+		}
+	}
+	exists := func(kind string, namespace string, name string) bool {
+		return rendered[Key{GVK: GroupVersionKind{Kind: kind}, Namespace: namespace, Name: name}]
+	}
+
+	var findings []Finding
+	for _, service := range services {
+		selectorMap, ok := yamlPlus.GetMap(service, "spec", "selector")
+		if !ok || len(selectorMap) == 0 {
+			continue // selector-less services are managed manually (e.g. ExternalName or custom Endpoints)
+		}
+		selector := toStringMap(selectorMap)
+		matched := false
+		for _, workload := range workloads {
+			if workload.Namespace() == service.Namespace() && MatchesSelectorMap(selector, workload.PodTemplateLabels()) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			findings = append(findings, Finding{
+				Check:    CheckServiceSelector,
+				Severity: SeverityWarning,
+				Resource: KeyOf(service),
+				Message:  fmt.Sprintf("selector %v does not match the pods of any rendered workload", selector),
+			})
+		}
+	}
+
+	for _, workload := range workloads {
+		for _, ref := range podReferences(workload) {
+			if exists(ref.kind, workload.Namespace(), ref.name) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Check:    ref.check,
+				Severity: SeverityWarning,
+				Resource: KeyOf(workload),
+				Message:  fmt.Sprintf("references %s %s via %s which is not rendered", ref.kind, ref.name, ref.source),
+			})
+		}
+	}
+
+	return findings
+}
+
+// podReference is a reference from a pod spec to another resource.
+type podReference struct {
+	check  string
+	kind   string
+	name   string
+	source string // where in the pod spec the reference was found
+}
+
+// podReferences collects the non-optional ConfigMap, Secret and
+// ServiceAccount references of a workload. Each referenced resource is only
+// reported once.
+func podReferences(workload Manifest) []podReference {
+	spec := workload.PodSpec()
+	var refs []podReference
+	seen := map[string]bool{}
+	add := func(kind string, name string, source string, optional bool) {
+		if name == "" || optional || seen[kind+"/"+name] {
+			return
+		}
+		seen[kind+"/"+name] = true
+		check := CheckMissingConfigMap
+		switch kind {
+		case "Secret":
+			check = CheckMissingSecret
+		case "ServiceAccount":
+			check = CheckMissingServiceAccount
+		}
+		refs = append(refs, podReference{check: check, kind: kind, name: name, source: source})
+	}
+
+	if name, ok := yamlPlus.GetString(spec, "serviceAccountName"); ok && name != "default" {
+		add("ServiceAccount", name, "serviceAccountName", false)
+	}
+
+	volumes, _ := yamlPlus.GetSlice(spec, "volumes")
+	for _, entry := range volumes {
+		volume, _ := entry.(map[string]interface{})
+		volumeName, _ := yamlPlus.GetString(volume, "name")
+		source := fmt.Sprintf("volume %s", volumeName)
+		if name, ok := yamlPlus.GetString(volume, "configMap", "name"); ok {
+			optional, _ := yamlPlus.GetBool(volume, "configMap", "optional")
+			add("ConfigMap", name, source, optional)
+		}
+		if name, ok := yamlPlus.GetString(volume, "secret", "secretName"); ok {
+			optional, _ := yamlPlus.GetBool(volume, "secret", "optional")
+			add("Secret", name, source, optional)
+		}
+		sources, _ := yamlPlus.GetSlice(volume, "projected", "sources")
+		for _, entry := range sources {
+			projection, _ := entry.(map[string]interface{})
+			if name, ok := yamlPlus.GetString(projection, "configMap", "name"); ok {
+				optional, _ := yamlPlus.GetBool(projection, "configMap", "optional")
+				add("ConfigMap", name, source, optional)
+			}
+			if name, ok := yamlPlus.GetString(projection, "secret", "name"); ok {
+				optional, _ := yamlPlus.GetBool(projection, "secret", "optional")
+				add("Secret", name, source, optional)
+			}
+		}
+	}
+
+	for _, container := range workload.Containers() {
+		containerName, _ := yamlPlus.GetString(container, "name")
+		envFrom, _ := yamlPlus.GetSlice(container, "envFrom")
+		for _, entry := range envFrom {
+			envSource, _ := entry.(map[string]interface{})
+			source := fmt.Sprintf("envFrom of container %s", containerName)
+			if name, ok := yamlPlus.GetString(envSource, "configMapRef", "name"); ok {
+				optional, _ := yamlPlus.GetBool(envSource, "configMapRef", "optional")
+				add("ConfigMap", name, source, optional)
+			}
+			if name, ok := yamlPlus.GetString(envSource, "secretRef", "name"); ok {
+				optional, _ := yamlPlus.GetBool(envSource, "secretRef", "optional")
+				add("Secret", name, source, optional)
+			}
+		}
+		env, _ := yamlPlus.GetSlice(container, "env")
+		for _, entry := range env {
+			envVar, _ := entry.(map[string]interface{})
+			varName, _ := yamlPlus.GetString(envVar, "name")
+			source := fmt.Sprintf("env %s of container %s", varName, containerName)
+			if name, ok := yamlPlus.GetString(envVar, "valueFrom", "configMapKeyRef", "name"); ok {
+				optional, _ := yamlPlus.GetBool(envVar, "valueFrom", "configMapKeyRef", "optional")
+				add("ConfigMap", name, source, optional)
+			}
+			if name, ok := yamlPlus.GetString(envVar, "valueFrom", "secretKeyRef", "name"); ok {
+				optional, _ := yamlPlus.GetBool(envVar, "valueFrom", "secretKeyRef", "optional")
+				add("Secret", name, source, optional)
+			}
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestValidateReferences(t *testing.T) {
+	deployment := Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "api"}},
+				"spec": map[string]interface{}{
+					"serviceAccountName": "api",
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "api-config"}},
+						map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "api-certs"}},
+						map[string]interface{}{"name": "extra", "secret": map[string]interface{}{"secretName": "extra", "optional": true}},
+					},
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name": "api",
+							"env": []interface{}{
+								map[string]interface{}{
+									"name":      "PASSWORD",
+									"valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "db", "key": "password"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	configMap := Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "api-config", "namespace": "web"}}
+	matchingService := Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "web"},
+		"spec":       map[string]interface{}{"selector": map[string]interface{}{"app": "api"}},
+	}
+	brokenService := Manifest{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "broken", "namespace": "web"},
+		"spec":       map[string]interface{}{"selector": map[string]interface{}{"app": "typo"}},
+	}
+
+	var got []string
+	for _, finding := range ValidateReferences([]Manifest{deployment, configMap, matchingService, brokenService}) {
+		got = append(got, finding.Check+":"+finding.Resource.Name)
+	}
+	sort.Strings(got)
+	want := []string{
+		CheckMissingSecret + ":api", // api-certs
+		CheckMissingSecret + ":api", // db
+		CheckMissingServiceAccount + ":api",
+		CheckServiceSelector + ":broken",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateReferences() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"reflect"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// SchedulingTransformer is a Transformer applying organization-wide scheduling
+// constraints to the pod specs of all workloads, so platform policy (e.g.
+// dedicated node pools or zone spreading) does not have to be patched into
+// each chart individually.
+// Constraints are merged with those set by the chart: node selector keys and
+// the affinity already set are kept unless Override is set, tolerations are
+// only added if not already present and topology spread constraints are only
+// added for topology keys the pod does not already spread over.
+type SchedulingTransformer struct {
+	NodeSelector              map[string]string        // merged into spec.nodeSelector
+	Tolerations               []map[string]interface{} // added to spec.tolerations
+	Affinity                  map[string]interface{}   // spec.affinity
+	TopologySpreadConstraints []map[string]interface{} // added to spec.topologySpreadConstraints. the labels of the pod template are used as the labelSelector of constraints without one
+
+	ExcludeKinds []string // workload kinds left untouched (e.g. DaemonSet)
+	Override     bool     // replace node selector keys and affinity already set in the rendered pod specs
+}
+
+// Transform applies the scheduling constraints to the workloads in manifests.
+// Manifests are copied before being modified.
+func (t SchedulingTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	transformed := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		transformed[idx] = m
+		if m.PodSpec() == nil || contains(t.ExcludeKinds, m.Kind()) {
+			continue
+		}
+
+		copied := m.DeepCopy()
+		spec := copied.PodSpec()
+		t.applyNodeSelector(spec)
+		t.applyTolerations(spec)
+		t.applyAffinity(spec)
+		t.applyTopologySpreadConstraints(spec, copied.PodTemplateLabels())
+		transformed[idx] = copied
+	}
+	return transformed, nil
+}
+
+func (t SchedulingTransformer) applyNodeSelector(spec map[string]interface{}) {
+	if len(t.NodeSelector) == 0 {
+		return
+	}
+	nodeSelector, ok := yamlPlus.GetMap(spec, "nodeSelector")
+	if !ok {
+		nodeSelector = map[string]interface{}{}
+		spec["nodeSelector"] = nodeSelector
+	}
+	for key, value := range t.NodeSelector {
+		if _, exists := nodeSelector[key]; exists && !t.Override {
+			continue
+		}
+		nodeSelector[key] = value
+	}
+}
+
+func (t SchedulingTransformer) applyTolerations(spec map[string]interface{}) {
+	if len(t.Tolerations) == 0 {
+		return
+	}
+	tolerations, _ := yamlPlus.GetSlice(spec, "tolerations")
+	for _, toleration := range t.Tolerations {
+		if !containsValue(tolerations, toleration) {
+			tolerations = append(tolerations, deepCopy(toleration))
+		}
+	}
+	spec["tolerations"] = tolerations
+}
+
+func (t SchedulingTransformer) applyAffinity(spec map[string]interface{}) {
+	if len(t.Affinity) == 0 {
+		return
+	}
+	if existing, ok := yamlPlus.GetMap(spec, "affinity"); ok && len(existing) > 0 && !t.Override {
+		return
+	}
+	spec["affinity"] = deepCopy(t.Affinity)
+}
+
+func (t SchedulingTransformer) applyTopologySpreadConstraints(spec map[string]interface{}, podLabels map[string]string) {
+	if len(t.TopologySpreadConstraints) == 0 {
+		return
+	}
+	constraints, _ := yamlPlus.GetSlice(spec, "topologySpreadConstraints")
+	topologyKeys := map[string]bool{}
+	for _, entry := range constraints {
+		if constraint, ok := entry.(map[string]interface{}); ok {
+			key, _ := yamlPlus.GetString(constraint, "topologyKey")
+			topologyKeys[key] = true
+		}
+	}
+	for _, constraint := range t.TopologySpreadConstraints {
+		key, _ := yamlPlus.GetString(constraint, "topologyKey")
+		if topologyKeys[key] {
+			continue
+		}
+		topologyKeys[key] = true
+		copied := deepCopy(constraint).(map[string]interface{})
+		if _, ok := copied["labelSelector"]; !ok && len(podLabels) > 0 {
+			matchLabels := make(map[string]interface{}, len(podLabels))
+			for label, value := range podLabels {
+				matchLabels[label] = value
+			}
+			copied["labelSelector"] = map[string]interface{}{"matchLabels": matchLabels}
+		}
+		constraints = append(constraints, copied)
+	}
+	spec["topologySpreadConstraints"] = constraints
+}
+
+// containsValue determines if values contains an entry deeply equal to value.
+func containsValue(values []interface{}, value map[string]interface{}) bool {
+	for _, entry := range values {
+		if reflect.DeepEqual(entry, value) {
+			return true
+		}
+	}
+	return false
+}
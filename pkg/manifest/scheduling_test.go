@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchedulingTransformer_Transform(t *testing.T) {
+	workload := func(kind string, spec map[string]interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web"}},
+					"spec":     spec,
+				},
+			},
+		}
+	}
+	toleration := map[string]interface{}{"key": "dedicated", "operator": "Equal", "value": "apps", "effect": "NoSchedule"}
+	affinity := map[string]interface{}{"nodeAffinity": map[string]interface{}{"preferredDuringSchedulingIgnoredDuringExecution": []interface{}{}}}
+	zoneSpread := map[string]interface{}{"topologyKey": "topology.kubernetes.io/zone", "maxSkew": 1, "whenUnsatisfiable": "ScheduleAnyway"}
+
+	tests := []struct {
+		name        string
+		transformer SchedulingTransformer
+		manifests   []Manifest
+		want        []Manifest
+	}{
+		{
+			name: "applies-all",
+			transformer: SchedulingTransformer{
+				NodeSelector:              map[string]string{"pool": "apps"},
+				Tolerations:               []map[string]interface{}{toleration},
+				Affinity:                  affinity,
+				TopologySpreadConstraints: []map[string]interface{}{zoneSpread},
+			},
+			manifests: []Manifest{
+				workload("Deployment", map[string]interface{}{}),
+				{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web"}},
+			},
+			want: []Manifest{
+				workload("Deployment", map[string]interface{}{
+					"nodeSelector": map[string]interface{}{"pool": "apps"},
+					"tolerations":  []interface{}{toleration},
+					"affinity":     affinity,
+					"topologySpreadConstraints": []interface{}{
+						map[string]interface{}{
+							"topologyKey":       "topology.kubernetes.io/zone",
+							"maxSkew":           1,
+							"whenUnsatisfiable": "ScheduleAnyway",
+							"labelSelector":     map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+						},
+					},
+				}),
+				{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web"}},
+			},
+		},
+		{
+			name: "merges-with-existing",
+			transformer: SchedulingTransformer{
+				NodeSelector:              map[string]string{"pool": "apps", "os": "linux"},
+				Tolerations:               []map[string]interface{}{toleration},
+				Affinity:                  affinity,
+				TopologySpreadConstraints: []map[string]interface{}{zoneSpread},
+			},
+			manifests: []Manifest{
+				workload("StatefulSet", map[string]interface{}{
+					"nodeSelector":              map[string]interface{}{"pool": "data"},
+					"tolerations":               []interface{}{toleration},
+					"affinity":                  map[string]interface{}{"podAntiAffinity": map[string]interface{}{}},
+					"topologySpreadConstraints": []interface{}{map[string]interface{}{"topologyKey": "topology.kubernetes.io/zone", "maxSkew": 2}},
+				}),
+			},
+			want: []Manifest{
+				workload("StatefulSet", map[string]interface{}{
+					"nodeSelector":              map[string]interface{}{"pool": "data", "os": "linux"},
+					"tolerations":               []interface{}{toleration},
+					"affinity":                  map[string]interface{}{"podAntiAffinity": map[string]interface{}{}},
+					"topologySpreadConstraints": []interface{}{map[string]interface{}{"topologyKey": "topology.kubernetes.io/zone", "maxSkew": 2}},
+				}),
+			},
+		},
+		{
+			name: "override",
+			transformer: SchedulingTransformer{
+				NodeSelector: map[string]string{"pool": "apps"},
+				Affinity:     affinity,
+				Override:     true,
+			},
+			manifests: []Manifest{
+				workload("Deployment", map[string]interface{}{
+					"nodeSelector": map[string]interface{}{"pool": "data"},
+					"affinity":     map[string]interface{}{"podAntiAffinity": map[string]interface{}{}},
+				}),
+			},
+			want: []Manifest{
+				workload("Deployment", map[string]interface{}{
+					"nodeSelector": map[string]interface{}{"pool": "apps"},
+					"affinity":     affinity,
+				}),
+			},
+		},
+		{
+			name: "excluded-kind",
+			transformer: SchedulingTransformer{
+				NodeSelector: map[string]string{"pool": "apps"},
+				ExcludeKinds: []string{"DaemonSet"},
+			},
+			manifests: []Manifest{workload("DaemonSet", map[string]interface{}{})},
+			want:      []Manifest{workload("DaemonSet", map[string]interface{}{})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := make([]Manifest, len(tt.manifests))
+			for idx, m := range tt.manifests {
+				original[idx] = m.DeepCopy()
+			}
+			got, err := tt.transformer.Transform(tt.manifests)
+			if err != nil {
+				t.Fatalf("SchedulingTransformer.Transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SchedulingTransformer.Transform() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.manifests, original) {
+				t.Errorf("SchedulingTransformer.Transform() modified its input")
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evanlouie/go/pkg/errcode"
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// CheckSchema is the check identifier for resources which do not conform to
+// the JSON schema of their kind.
+const CheckSchema = "schema"
+
+// DefaultSchemaURL is the base URL of the standalone strict JSON schemas of
+// the built-in Kubernetes kinds, as consumed by kubeconform.
+const DefaultSchemaURL = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/master/v1.22.0-standalone-strict"
+
+// ErrSchemaNotFound is wrapped by the errors of a SchemaSource for kinds it
+// has no schema for (e.g. custom resources).
+var ErrSchemaNotFound = errcode.New(errcode.ManifestSchemaNotFound, "schema not found")
+
+// SchemaSource provides the JSON schemas of Kubernetes kinds.
+type SchemaSource interface {
+	// Schema returns the JSON schema of gvk or an error wrapping
+	// ErrSchemaNotFound.
+	Schema(gvk GroupVersionKind) (map[string]interface{}, error)
+}
+
+// SchemaFileName names the schema file of gvk as kubeconform does:
+// <kind>-<group>-<version>.json, or <kind>-<version>.json for the core group,
+// lowercased and using only the first segment of the group. e.g:
+// "deployment-apps-v1.json" or "ingress-networking-v1.json".
+func SchemaFileName(gvk GroupVersionKind) string {
+	name := gvk.Kind
+	if gvk.Group != "" {
+		name += "-" + strings.Split(gvk.Group, ".")[0]
+	}
+	return strings.ToLower(name+"-"+gvk.Version) + ".json"
+}
+
+// FSSchemaSource reads schemas named via SchemaFileName from a file system,
+// e.g. os.DirFS of a directory of schemas or schemas bundled into a binary
+// via an embed.FS.
+type FSSchemaSource struct {
+	FS fs.FS
+}
+
+// Schema reads the schema of gvk from the file system.
+func (s FSSchemaSource) Schema(gvk GroupVersionKind) (map[string]interface{}, error) {
+	name := SchemaFileName(gvk)
+	content, err := fs.ReadFile(s.FS, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf(`reading schema of %s: %w`, gvk, ErrSchemaNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`reading schema %s: %w`, name, err)
+	}
+	return parseSchema(name, content)
+}
+
+// URLSchemaSource downloads schemas named via SchemaFileName from BaseURL
+// (e.g. DefaultSchemaURL). Downloaded schemas are kept in memory so each
+// schema is only downloaded once.
+type URLSchemaSource struct {
+	BaseURL string
+	Client  *http.Client // defaults to http.DefaultClient
+
+	lock    sync.Mutex
+	schemas map[string]map[string]interface{} // by file name. nil when not found
+}
+
+// Schema downloads the schema of gvk.
+func (s *URLSchemaSource) Schema(gvk GroupVersionKind) (map[string]interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	name := SchemaFileName(gvk)
+	if schema, ok := s.schemas[name]; ok {
+		if schema == nil {
+			return nil, fmt.Errorf(`downloading schema of %s: %w`, gvk, ErrSchemaNotFound)
+		}
+		return schema, nil
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	schemaURL := strings.TrimSuffix(s.BaseURL, "/") + "/" + name
+	resp, err := client.Get(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf(`downloading schema %s: %w`, schemaURL, err)
+	}
+	defer resp.Body.Close()
+	var schema map[string]interface{}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf(`reading schema %s: %w`, schemaURL, err)
+		}
+		if schema, err = parseSchema(schemaURL, content); err != nil {
+			return nil, err
+		}
+	case http.StatusNotFound:
+	default:
+		return nil, fmt.Errorf(`downloading schema %s: unexpected status %s`, schemaURL, resp.Status)
+	}
+
+	if s.schemas == nil {
+		s.schemas = map[string]map[string]interface{}{}
+	}
+	s.schemas[name] = schema
+	if schema == nil {
+		return nil, fmt.Errorf(`downloading schema of %s: %w`, gvk, ErrSchemaNotFound)
+	}
+	return schema, nil
+}
+
+// parseSchema decodes a JSON schema. Schemas are decoded as yaml so their
+// values have the same Go types as decoded manifests.
+func parseSchema(name string, content []byte) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal(content, &schema); err != nil {
+		return nil, fmt.Errorf(`parsing schema %s: %w`, name, err)
+	}
+	return schema, nil
+}
+
+// SchemaOptions configure ValidateSchemas.
+type SchemaOptions struct {
+	Source SchemaSource
+
+	// Strict rejects fields not declared by the schema of an object even when
+	// the schema does not forbid additional properties, so typos are caught
+	// with non-strict schemas as well. Objects marked with
+	// x-kubernetes-preserve-unknown-fields are exempt.
+	Strict bool
+
+	// RejectMissingSchemas reports resources without a schema as invalid
+	// rather than skipping them.
+	RejectMissingSchemas bool
+}
+
+// SchemaStatus is the outcome of validating a resource against its schema.
+type SchemaStatus string
+
+const (
+	SchemaValid   SchemaStatus = "valid"
+	SchemaInvalid SchemaStatus = "invalid"
+	SchemaSkipped SchemaStatus = "skipped" // no schema was found for the kind of the resource
+)
+
+// SchemaResult is the result of validating a single resource.
+type SchemaResult struct {
+	Resource Key
+	Status   SchemaStatus
+	Errors   []string // e.g: `spec.replicas: expected integer, got string`
+}
+
+// ValidateSchemas checks each manifest against the JSON schema of its kind
+// without requiring a cluster, as kubeconform does, reporting unknown fields,
+// type errors, missing required fields and values not in an enum. Results
+// are returned in the order of manifests. An error is only returned if a
+// schema cannot be loaded.
+// Schemas are expected to be standalone: only references within the schema
+// itself (e.g. "#/definitions/...") are resolved.
+func ValidateSchemas(manifests []Manifest, opts SchemaOptions) ([]SchemaResult, error) {
+	schemas := map[GroupVersionKind]map[string]interface{}{}
+	var results []SchemaResult
+	for _, m := range manifests {
+		gvk := m.GVK()
+		schema, loaded := schemas[gvk]
+		if !loaded {
+			var err error
+			schema, err = opts.Source.Schema(gvk)
+			if err != nil && !errors.Is(err, ErrSchemaNotFound) {
+				return nil, err
+			}
+			schemas[gvk] = schema
+		}
+
+		result := SchemaResult{Resource: KeyOf(m), Status: SchemaValid}
+		switch {
+		case schema == nil && opts.RejectMissingSchemas:
+			result.Status = SchemaInvalid
+			result.Errors = []string{fmt.Sprintf("no schema found for %s", gvk)}
+		case schema == nil:
+			result.Status = SchemaSkipped
+		default:
+			for _, violation := range ValidateJSONSchema(map[string]interface{}(m), schema, opts.Strict) {
+				result.Errors = append(result.Errors, violation.dotted())
+			}
+			if len(result.Errors) > 0 {
+				result.Status = SchemaInvalid
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SchemaFindings converts the errors of invalid results into Findings.
+func SchemaFindings(results []SchemaResult) []Finding {
+	var findings []Finding
+	for _, result := range results {
+		for _, message := range result.Errors {
+			findings = append(findings, Finding{
+				Check:    CheckSchema,
+				Severity: SeverityError,
+				Resource: result.Resource,
+				Message:  message,
+			})
+		}
+	}
+	return findings
+}
+
+// SchemaError is a violation of a JSON schema.
+type SchemaError struct {
+	Pointer string // JSON pointer (RFC 6901) to the violating value. e.g: "/spec/containers/0/image". empty for the root
+	Message string // e.g: "expected string, got integer"
+
+	path []interface{} // the keys (strings) and indices (ints) of Pointer
+}
+
+func (e SchemaError) Error() string {
+	if e.Pointer == "" {
+		return "(root): " + e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// dotted formats the error with the path as dotted keys and indices, as
+// kubectl explain paths are written. e.g: "spec.containers[0].image: ..."
+func (e SchemaError) dotted() string {
+	var b strings.Builder
+	for _, segment := range e.path {
+		switch s := segment.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", s)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, s)
+		}
+	}
+	if b.Len() == 0 {
+		return "(root): " + e.Message
+	}
+	return b.String() + ": " + e.Message
+}
+
+// ValidateJSONSchema validates a decoded yaml or JSON value against a JSON
+// schema, supporting the keywords used by Kubernetes schemas and chart
+// values.schema.json files: type, enum, const, properties, required,
+// additionalProperties, items, allOf, anyOf, oneOf, local $refs, the bounds
+// of numbers, strings and arrays, pattern and the x-kubernetes extensions.
+// strict rejects fields not declared by an object schema even when it does not
+// forbid additional properties.
+func ValidateJSONSchema(value interface{}, schema map[string]interface{}, strict bool) []SchemaError {
+	validator := schemaValidator{root: schema, strict: strict}
+	return validator.validate(value, schema, nil)
+}
+
+// schemaValidator validates values against the subset of JSON schema used by
+// Kubernetes schemas.
+type schemaValidator struct {
+	root   map[string]interface{} // the schema local references are resolved against
+	strict bool
+}
+
+// violation creates a SchemaError at path.
+func violation(path []interface{}, format string, args ...interface{}) SchemaError {
+	var pointer strings.Builder
+	for _, segment := range path {
+		token := strings.ReplaceAll(strings.ReplaceAll(fmt.Sprint(segment), "~", "~0"), "/", "~1")
+		pointer.WriteString("/" + token)
+	}
+	return SchemaError{Pointer: pointer.String(), Message: fmt.Sprintf(format, args...), path: path}
+}
+
+// child returns the path of the entry key (a string or int) of the value at
+// path.
+func child(path []interface{}, key interface{}) []interface{} {
+	return append(append([]interface{}{}, path...), key)
+}
+
+// validate returns the errors of value against schema. path is the location
+// of value in the validated document.
+func (v schemaValidator) validate(value interface{}, schema map[string]interface{}, path []interface{}) []SchemaError {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, ok := v.resolve(ref)
+		if !ok {
+			return nil // external references cannot be validated
+		}
+		return v.validate(value, resolved, path)
+	}
+	if intOrString, _ := schema["x-kubernetes-int-or-string"].(bool); intOrString {
+		if actual := jsonType(value); actual != "integer" && actual != "string" {
+			return []SchemaError{violation(path, "expected integer or string, got %s", actual)}
+		}
+		return nil
+	}
+
+	if types := schemaTypes(schema); len(types) > 0 && !matchesType(types, value) {
+		return []SchemaError{violation(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		return []SchemaError{violation(path, "value %v is not one of %v", value, enum)}
+	}
+	if constant, ok := schema["const"]; ok && !inEnum([]interface{}{constant}, value) {
+		return []SchemaError{violation(path, "value %v is not %v", value, constant)}
+	}
+
+	errs := v.validateBounds(value, schema, path)
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				errs = append(errs, v.validate(value, subSchema, path)...)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range alternatives {
+			if subSchema, ok := sub.(map[string]interface{}); ok && len(v.validate(value, subSchema, path)) == 0 {
+				matched++
+			}
+		}
+		switch {
+		case matched == 0:
+			errs = append(errs, violation(path, "does not match any schema of %s", keyword))
+		case keyword == "oneOf" && matched > 1:
+			errs = append(errs, violation(path, "matches more than one schema of oneOf"))
+		}
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, v.validateObject(typed, schema, path)...)
+	case []interface{}, []map[string]interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		list, _ := yamlPlus.GetSlice(map[string]interface{}{"list": typed}, "list")
+		for idx, item := range list {
+			errs = append(errs, v.validate(item, items, child(path, idx))...)
+		}
+	}
+	return errs
+}
+
+// validateBounds validates the bounds of numbers, the length and pattern of
+// strings and the length of arrays.
+func (v schemaValidator) validateBounds(value interface{}, schema map[string]interface{}, path []interface{}) []SchemaError {
+	var errs []SchemaError
+	if number, ok := toFloat(value); ok {
+		if minimum, ok := toFloat(schema["minimum"]); ok && number < minimum {
+			errs = append(errs, violation(path, "%v is less than the minimum of %v", value, schema["minimum"]))
+		}
+		if maximum, ok := toFloat(schema["maximum"]); ok && number > maximum {
+			errs = append(errs, violation(path, "%v is greater than the maximum of %v", value, schema["maximum"]))
+		}
+		if minimum, ok := toFloat(schema["exclusiveMinimum"]); ok && number <= minimum {
+			errs = append(errs, violation(path, "%v must be greater than %v", value, schema["exclusiveMinimum"]))
+		}
+		if maximum, ok := toFloat(schema["exclusiveMaximum"]); ok && number >= maximum {
+			errs = append(errs, violation(path, "%v must be less than %v", value, schema["exclusiveMaximum"]))
+		}
+	}
+	if str, ok := value.(string); ok {
+		length := float64(len([]rune(str)))
+		if minLength, ok := toFloat(schema["minLength"]); ok && length < minLength {
+			errs = append(errs, violation(path, "length %v is less than the minimum of %v", length, schema["minLength"]))
+		}
+		if maxLength, ok := toFloat(schema["maxLength"]); ok && length > maxLength {
+			errs = append(errs, violation(path, "length %v is greater than the maximum of %v", length, schema["maxLength"]))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if rgx, err := regexp.Compile(pattern); err == nil && !rgx.MatchString(str) {
+				errs = append(errs, violation(path, "%q does not match pattern %q", str, pattern))
+			}
+		}
+	}
+	if list, ok := yamlPlus.GetSlice(map[string]interface{}{"list": value}, "list"); ok {
+		length := float64(len(list))
+		if minItems, ok := toFloat(schema["minItems"]); ok && length < minItems {
+			errs = append(errs, violation(path, "%v items is less than the minimum of %v", length, schema["minItems"]))
+		}
+		if maxItems, ok := toFloat(schema["maxItems"]); ok && length > maxItems {
+			errs = append(errs, violation(path, "%v items is greater than the maximum of %v", length, schema["maxItems"]))
+		}
+	}
+	return errs
+}
+
+// validateObject validates the required fields and properties of an object.
+func (v schemaValidator) validateObject(object map[string]interface{}, schema map[string]interface{}, path []interface{}) []SchemaError {
+	var errs []SchemaError
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, field := range required {
+			if _, ok := object[fmt.Sprint(field)]; !ok {
+				errs = append(errs, violation(path, "missing required field \"%v\"", field))
+			}
+		}
+	}
+
+	properties, hasProperties := schema["properties"].(map[string]interface{})
+	preserveUnknown, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool)
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fieldPath := child(path, key)
+		if property, ok := properties[key].(map[string]interface{}); ok {
+			errs = append(errs, v.validate(object[key], property, fieldPath)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case map[string]interface{}:
+			errs = append(errs, v.validate(object[key], additional, fieldPath)...)
+		case bool:
+			if !additional {
+				errs = append(errs, violation(fieldPath, "unknown field"))
+			}
+		case nil:
+			if v.strict && hasProperties && !preserveUnknown {
+				errs = append(errs, violation(fieldPath, "unknown field"))
+			}
+		}
+	}
+	return errs
+}
+
+// resolve resolves a local reference (e.g. "#/definitions/io.k8s.api.core.v1.PodSpec")
+// against the root schema.
+func (v schemaValidator) resolve(ref string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+	var current interface{} = v.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = object[token]
+	}
+	resolved, ok := current.(map[string]interface{})
+	return resolved, ok
+}
+
+// schemaTypes returns the types allowed by schema. e.g: ["string", "null"]
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, entry := range t {
+			types = append(types, fmt.Sprint(entry))
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+// matchesType determines if value is of one of the JSON types. Integers are
+// also numbers.
+func matchesType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON type of a decoded yaml value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32:
+		if float64(v) == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "object"
+	case []interface{}, []map[string]interface{}, []string:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// inEnum determines if value is one of the values of enum.
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, entry := range enum {
+		if jsonType(entry) == jsonType(value) && fmt.Sprint(entry) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat converts a decoded number to a float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
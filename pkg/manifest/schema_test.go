@@ -0,0 +1,236 @@
+package manifest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// deploymentSchema is a trimmed down standalone schema of apps/v1 Deployments.
+const deploymentSchema = `{
+  "type": "object",
+  "required": ["apiVersion", "kind"],
+  "properties": {
+    "apiVersion": {"type": ["string", "null"]},
+    "kind": {"type": "string", "enum": ["Deployment"]},
+    "metadata": {"$ref": "#/definitions/ObjectMeta"},
+    "spec": {
+      "type": "object",
+      "properties": {
+        "replicas": {"type": "integer"},
+        "strategy": {
+          "type": "object",
+          "properties": {
+            "maxSurge": {"x-kubernetes-int-or-string": true}
+          },
+          "additionalProperties": false
+        },
+        "template": {
+          "type": "object",
+          "x-kubernetes-preserve-unknown-fields": true,
+          "properties": {}
+        },
+        "containers": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "required": ["name"],
+            "properties": {"name": {"type": "string"}, "image": {"type": "string"}}
+          }
+        }
+      }
+    }
+  },
+  "definitions": {
+    "ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func TestValidateSchemas(t *testing.T) {
+	source := FSSchemaSource{FS: fstest.MapFS{"deployment-apps-v1.json": {Data: []byte(deploymentSchema)}}}
+	deployment := func(spec map[string]interface{}) Manifest {
+		return Manifest{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "labels": map[string]interface{}{"app": "web"}},
+			"spec":       spec,
+		}
+	}
+	configMap := Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}}
+
+	tests := []struct {
+		name      string
+		manifests []Manifest
+		opts      SchemaOptions
+		want      []SchemaResult
+	}{
+		{
+			name: "valid",
+			manifests: []Manifest{deployment(map[string]interface{}{
+				"replicas":   2,
+				"strategy":   map[string]interface{}{"maxSurge": "25%"},
+				"template":   map[string]interface{}{"anything": true},
+				"containers": []map[string]interface{}{{"name": "web", "image": "nginx"}},
+			})},
+			want: []SchemaResult{{Resource: KeyOf(deployment(nil)), Status: SchemaValid}},
+		},
+		{
+			name: "invalid",
+			manifests: []Manifest{deployment(map[string]interface{}{
+				"replicas":   "2",
+				"strategy":   map[string]interface{}{"maxSurge": true, "maxSurgee": 1},
+				"containers": []interface{}{map[string]interface{}{"image": 1}},
+			})},
+			want: []SchemaResult{{Resource: KeyOf(deployment(nil)), Status: SchemaInvalid, Errors: []string{
+				"spec.containers[0]: missing required field \"name\"",
+				"spec.containers[0].image: expected string, got integer",
+				"spec.replicas: expected integer, got string",
+				"spec.strategy.maxSurge: expected integer or string, got boolean",
+				"spec.strategy.maxSurgee: unknown field",
+			}}},
+		},
+		{
+			name:      "strict",
+			manifests: []Manifest{deployment(map[string]interface{}{"replica": 2, "template": map[string]interface{}{"anything": true}})},
+			opts:      SchemaOptions{Strict: true},
+			want: []SchemaResult{{Resource: KeyOf(deployment(nil)), Status: SchemaInvalid, Errors: []string{
+				"spec.replica: unknown field",
+			}}},
+		},
+		{
+			name:      "missing-schema",
+			manifests: []Manifest{configMap},
+			want:      []SchemaResult{{Resource: KeyOf(configMap), Status: SchemaSkipped}},
+		},
+		{
+			name:      "reject-missing-schema",
+			manifests: []Manifest{configMap},
+			opts:      SchemaOptions{RejectMissingSchemas: true},
+			want:      []SchemaResult{{Resource: KeyOf(configMap), Status: SchemaInvalid, Errors: []string{"no schema found for v1, Kind=ConfigMap"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Source = source
+			got, err := ValidateSchemas(tt.manifests, tt.opts)
+			if err != nil {
+				t.Fatalf("ValidateSchemas() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateSchemas() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"image"},
+		"properties": map[string]interface{}{
+			"replicaCount": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+			"image": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tag":        map[string]interface{}{"type": "string", "pattern": "^v?[0-9.]+$"},
+					"pullPolicy": map[string]interface{}{"enum": []interface{}{"Always", "IfNotPresent"}},
+				},
+			},
+			"hosts":       map[string]interface{}{"type": "array", "minItems": 1, "items": map[string]interface{}{"type": "string", "maxLength": 5}},
+			"annotations": map[string]interface{}{"type": "object", "additionalProperties": false},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		want  []string
+	}{
+		{
+			name:  "valid",
+			value: map[string]interface{}{"replicaCount": 3, "image": map[string]interface{}{"tag": "v1.2"}, "hosts": []interface{}{"a"}},
+		},
+		{
+			name:  "missing-required",
+			value: map[string]interface{}{},
+			want:  []string{`(root): missing required field "image"`},
+		},
+		{
+			name: "bounds",
+			value: map[string]interface{}{
+				"replicaCount": 11,
+				"image":        map[string]interface{}{"tag": "latest", "pullPolicy": "Never"},
+				"hosts":        []interface{}{},
+			},
+			want: []string{
+				"/hosts: 0 items is less than the minimum of 1",
+				"/image/pullPolicy: value Never is not one of [Always IfNotPresent]",
+				`/image/tag: "latest" does not match pattern "^v?[0-9.]+$"`,
+				"/replicaCount: 11 is greater than the maximum of 10",
+			},
+		},
+		{
+			name: "pointer-escaping",
+			value: map[string]interface{}{
+				"image":       map[string]interface{}{"tag": 1},
+				"hosts":       []interface{}{"a", "toolong"},
+				"annotations": map[string]interface{}{"example.com/a~b": "x"},
+			},
+			want: []string{
+				"/annotations/example.com~1a~0b: unknown field",
+				"/hosts/1: length 7 is greater than the maximum of 5",
+				"/image/tag: expected string, got integer",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateJSONSchema(tt.value, schema, false) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateJSONSchema() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLSchemaSource_Schema(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/schemas/deployment-apps-v1.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(deploymentSchema))
+	}))
+	defer server.Close()
+	source := &URLSchemaSource{BaseURL: server.URL + "/schemas/"}
+
+	for i := 0; i < 2; i++ {
+		schema, err := source.Schema(GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+		if err != nil {
+			t.Fatalf("URLSchemaSource.Schema() error = %v", err)
+		}
+		if schema["type"] != "object" {
+			t.Errorf("URLSchemaSource.Schema() = %v, want the deployment schema", schema)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("URLSchemaSource.Schema() made %d requests, want 1", requests)
+	}
+	if _, err := source.Schema(GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}); !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("URLSchemaSource.Schema() error = %v, want ErrSchemaNotFound", err)
+	}
+}
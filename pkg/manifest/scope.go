@@ -0,0 +1,95 @@
+package manifest
+
+import (
+	"sync"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// GroupKind identifies the type of a Kubernetes resource independently of
+// its API version.
+type GroupKind struct {
+	Group string // empty for the core group
+	Kind  string
+}
+
+// GroupKind returns the group and kind of the GroupVersionKind.
+func (gvk GroupVersionKind) GroupKind() GroupKind {
+	return GroupKind{Group: gvk.Group, Kind: gvk.Kind}
+}
+
+var (
+	scopeLock sync.RWMutex
+	// clusterScoped are the kinds which are not namespaced. Initialized with
+	// the built-in Kubernetes kinds; see RegisterClusterScoped.
+	clusterScoped = map[GroupKind]bool{
+		{Group: "", Kind: "ComponentStatus"}:                                              true,
+		{Group: "", Kind: "Namespace"}:                                                    true,
+		{Group: "", Kind: "Node"}:                                                         true,
+		{Group: "", Kind: "PersistentVolume"}:                                             true,
+		{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:     true,
+		{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicy"}:        true,
+		{Group: "admissionregistration.k8s.io", Kind: "ValidatingAdmissionPolicyBinding"}: true,
+		{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}:   true,
+		{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:                 true,
+		{Group: "apiregistration.k8s.io", Kind: "APIService"}:                             true,
+		{Group: "certificates.k8s.io", Kind: "CertificateSigningRequest"}:                 true,
+		{Group: "flowcontrol.apiserver.k8s.io", Kind: "FlowSchema"}:                       true,
+		{Group: "flowcontrol.apiserver.k8s.io", Kind: "PriorityLevelConfiguration"}:       true,
+		{Group: "networking.k8s.io", Kind: "IngressClass"}:                                true,
+		{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                      true,
+		{Group: "policy", Kind: "PodSecurityPolicy"}:                                      true,
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                         true,
+		{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                  true,
+		{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                               true,
+		{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                      true,
+		{Group: "storage.k8s.io", Kind: "CSINode"}:                                        true,
+		{Group: "storage.k8s.io", Kind: "StorageClass"}:                                   true,
+		{Group: "storage.k8s.io", Kind: "VolumeAttachment"}:                               true,
+	}
+)
+
+// RegisterClusterScoped registers kinds as cluster-scoped. Use this for the
+// cluster-scoped custom resources (e.g. cert-manager ClusterIssuers) of CRDs
+// which are installed separately from the render.
+func RegisterClusterScoped(kinds ...GroupKind) {
+	scopeLock.Lock()
+	defer scopeLock.Unlock()
+	for _, kind := range kinds {
+		clusterScoped[kind] = true
+	}
+}
+
+// IsClusterScoped determines if kind is a built-in cluster-scoped kind or was
+// registered via RegisterClusterScoped.
+func IsClusterScoped(kind GroupKind) bool {
+	scopeLock.RLock()
+	defer scopeLock.RUnlock()
+	return clusterScoped[kind]
+}
+
+// IsClusterScoped determines if the manifest is of a cluster-scoped kind. See
+// IsClusterScoped.
+func (m Manifest) IsClusterScoped() bool {
+	return IsClusterScoped(m.GVK().GroupKind())
+}
+
+// ClusterScopedCRDKinds returns the kinds defined by the cluster-scoped
+// CustomResourceDefinitions (spec.scope: Cluster) in manifests, so custom
+// resources rendered alongside their CRD are recognized without being
+// registered.
+func ClusterScopedCRDKinds(manifests []Manifest) []GroupKind {
+	var kinds []GroupKind
+	for _, m := range manifests {
+		if m.GVK().GroupKind() != (GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+			continue
+		}
+		if scope, _ := yamlPlus.GetString(m, "spec", "scope"); scope != "Cluster" {
+			continue
+		}
+		group, _ := yamlPlus.GetString(m, "spec", "group")
+		kind, _ := yamlPlus.GetString(m, "spec", "names", "kind")
+		kinds = append(kinds, GroupKind{Group: group, Kind: kind})
+	}
+	return kinds
+}
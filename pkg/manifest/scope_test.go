@@ -0,0 +1,52 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsClusterScoped(t *testing.T) {
+	RegisterClusterScoped(GroupKind{Group: "cert-manager.io", Kind: "ClusterIssuer"})
+	tests := []struct {
+		name string
+		m    Manifest
+		want bool
+	}{
+		{"namespace", Manifest{"apiVersion": "v1", "kind": "Namespace"}, true},
+		{"cluster-role", Manifest{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole"}, true},
+		{"crd", Manifest{"apiVersion": "apiextensions.k8s.io/v1beta1", "kind": "CustomResourceDefinition"}, true},
+		{"registered", Manifest{"apiVersion": "cert-manager.io/v1", "kind": "ClusterIssuer"}, true},
+		{"deployment", Manifest{"apiVersion": "apps/v1", "kind": "Deployment"}, false},
+		{"same-kind-other-group", Manifest{"apiVersion": "example.com/v1", "kind": "Node"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.IsClusterScoped(); got != tt.want {
+				t.Errorf("Manifest.IsClusterScoped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterScopedCRDKinds(t *testing.T) {
+	crd := func(group string, kind string, scope string) Manifest {
+		return Manifest{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"spec": map[string]interface{}{
+				"group": group,
+				"scope": scope,
+				"names": map[string]interface{}{"kind": kind},
+			},
+		}
+	}
+	manifests := []Manifest{
+		crd("example.com", "ClusterFoo", "Cluster"),
+		crd("example.com", "Foo", "Namespaced"),
+		{"apiVersion": "v1", "kind": "ConfigMap"},
+	}
+	want := []GroupKind{{Group: "example.com", Kind: "ClusterFoo"}}
+	if got := ClusterScopedCRDKinds(manifests); !reflect.DeepEqual(got, want) {
+		t.Errorf("ClusterScopedCRDKinds() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package manifest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+)
+
+// SealingScope determines which Secrets a SealedSecret can be unsealed as.
+// See https://github.com/bitnami-labs/sealed-secrets#scopes
+type SealingScope string
+
+const (
+	ScopeStrict        SealingScope = "strict"         // the secret can only be unsealed with the same name and namespace
+	ScopeNamespaceWide SealingScope = "namespace-wide" // the secret can be renamed within its namespace
+	ScopeClusterWide   SealingScope = "cluster-wide"   // the secret can be unsealed in any namespace with any name
+)
+
+// SealedSecretTransformer is a Transformer converting v1 Secrets into Bitnami
+// SealedSecrets encrypted with the public certificate of a sealed-secrets
+// controller, so the output can be committed and consumed directly by
+// clusters running the controller.
+// Secrets are encrypted the same way as `kubeseal`.
+type SealedSecretTransformer struct {
+	PublicKey        *rsa.PublicKey
+	Scope            SealingScope // defaults to ScopeStrict
+	DefaultNamespace string       // namespace of Secrets without one (e.g. the helm release namespace). required for strict and namespace-wide scopes
+
+	Rand io.Reader // source of randomness. defaults to crypto/rand.Reader
+}
+
+// NewSealedSecretTransformer creates a SealedSecretTransformer from the PEM
+// encoded certificate of a sealed-secrets controller (e.g. the output of
+// `kubeseal --fetch-cert`).
+func NewSealedSecretTransformer(certPEM []byte, scope SealingScope, defaultNamespace string) (*SealedSecretTransformer, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf(`parsing sealed-secrets certificate: no PEM data found`)
+	}
+	var publicKey interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf(`parsing sealed-secrets certificate: %w`, err)
+		}
+		publicKey = cert.PublicKey
+	case "PUBLIC KEY":
+		var err error
+		if publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf(`parsing sealed-secrets public key: %w`, err)
+		}
+	default:
+		return nil, fmt.Errorf(`parsing sealed-secrets certificate: unexpected PEM block type "%s"`, block.Type)
+	}
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`parsing sealed-secrets certificate: expected an RSA public key, found %T`, publicKey)
+	}
+	return &SealedSecretTransformer{PublicKey: rsaKey, Scope: scope, DefaultNamespace: defaultNamespace}, nil
+}
+
+// Transform replaces every v1 Secret in manifests with a SealedSecret.
+func (t *SealedSecretTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	transformed := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		if !IsSecret(m) {
+			transformed[idx] = m
+			continue
+		}
+		sealed, err := t.seal(m)
+		if err != nil {
+			return nil, fmt.Errorf(`sealing secret %s: %w`, resourceName(KeyOf(m)), err)
+		}
+		transformed[idx] = sealed
+	}
+	return transformed, nil
+}
+
+// seal converts secret into a SealedSecret.
+func (t *SealedSecretTransformer) seal(secret Manifest) (Manifest, error) {
+	if t.PublicKey == nil {
+		return nil, fmt.Errorf(`no sealed-secrets public key provided`)
+	}
+	scope := t.Scope
+	if scope == "" {
+		scope = ScopeStrict
+	}
+	name, namespace := secret.Name(), secret.Namespace()
+	if namespace == "" {
+		namespace = t.DefaultNamespace
+	}
+	var label []byte
+	annotations := map[string]interface{}{}
+	switch scope {
+	case ScopeStrict:
+		label = []byte(namespace + "/" + name)
+	case ScopeNamespaceWide:
+		label = []byte(namespace)
+		annotations["sealedsecrets.bitnami.com/namespace-wide"] = "true"
+	case ScopeClusterWide:
+		annotations["sealedsecrets.bitnami.com/cluster-wide"] = "true"
+	default:
+		return nil, fmt.Errorf(`unknown sealing scope "%s"`, scope)
+	}
+	if namespace == "" && scope != ScopeClusterWide {
+		return nil, fmt.Errorf(`a namespace is required to seal with the %s scope`, scope)
+	}
+
+	// decode the values of data and stringData into the plaintext to encrypt
+	plaintexts := map[string][]byte{}
+	data, _ := secret["data"].(map[string]interface{})
+	for key, value := range data {
+		encoded, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf(`data value of key %s is a %T, not a base64 string`, key, value)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf(`decoding data value of key %s: %w`, key, err)
+		}
+		plaintexts[key] = decoded
+	}
+	stringData, _ := secret["stringData"].(map[string]interface{})
+	for key, value := range stringData {
+		plaintexts[key] = []byte(fmt.Sprint(value))
+	}
+
+	encryptedData := map[string]interface{}{}
+	for key, plaintext := range plaintexts {
+		ciphertext, err := t.hybridEncrypt(plaintext, label)
+		if err != nil {
+			return nil, fmt.Errorf(`encrypting value of key %s: %w`, key, err)
+		}
+		encryptedData[key] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	// the template holds everything of the Secret except its data
+	templateMetadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		templateMetadata["namespace"] = namespace
+	}
+	secretMetadata, _ := secret.DeepCopy()["metadata"].(map[string]interface{})
+	for _, field := range []string{"labels", "annotations"} {
+		if value, ok := secretMetadata[field]; ok {
+			templateMetadata[field] = value
+		}
+	}
+	template := map[string]interface{}{"metadata": templateMetadata}
+	for _, field := range []string{"type", "immutable"} {
+		if value, ok := secret[field]; ok {
+			template[field] = value
+		}
+	}
+
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	return Manifest{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"encryptedData": encryptedData,
+			"template":      template,
+		},
+	}, nil
+}
+
+// hybridEncrypt encrypts plaintext the same way as the sealed-secrets
+// controller: a random AES-256-GCM session key encrypts the plaintext and is
+// itself encrypted with RSA-OAEP (SHA-256) using label. The output is the
+// 2 byte big-endian length of the RSA ciphertext, the RSA ciphertext and the
+// AES ciphertext.
+func (t *SealedSecretTransformer) hybridEncrypt(plaintext []byte, label []byte) ([]byte, error) {
+	rnd := t.Rand
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	sessionKey := make([]byte, 32)
+	if _, err := io.ReadFull(rnd, sessionKey); err != nil {
+		return nil, fmt.Errorf(`generating session key: %w`, err)
+	}
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rnd, t.PublicKey, sessionKey, label)
+	if err != nil {
+		return nil, fmt.Errorf(`encrypting session key: %w`, err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// the session key is only ever used once so a zero nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+
+	ciphertext := make([]byte, 2, 2+len(rsaCiphertext)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+	return aead.Seal(ciphertext, nonce, plaintext, nil), nil
+}
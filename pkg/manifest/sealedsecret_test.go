@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+func TestSealedSecretTransformer_Transform(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	// decrypt reverses hybridEncrypt with the private key
+	decrypt := func(encoded string, label string) string {
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaLength := int(binary.BigEndian.Uint16(ciphertext))
+		sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, ciphertext[2:2+rsaLength], []byte(label))
+		if err != nil {
+			t.Fatalf("decrypting session key: %v", err)
+		}
+		block, _ := aes.NewCipher(sessionKey)
+		aead, _ := cipher.NewGCM(block)
+		plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[2+rsaLength:], nil)
+		if err != nil {
+			t.Fatalf("decrypting value: %v", err)
+		}
+		return string(plaintext)
+	}
+
+	manifests := []Manifest{
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config"}},
+		{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"type":       "Opaque",
+			"metadata":   map[string]interface{}{"name": "db", "labels": map[string]interface{}{"app": "db"}},
+			"data":       map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("hunter2"))},
+			"stringData": map[string]interface{}{"username": "admin"},
+		},
+	}
+
+	tests := []struct {
+		scope SealingScope
+		label string
+	}{
+		{ScopeStrict, "web/db"},
+		{ScopeNamespaceWide, "web"},
+		{ScopeClusterWide, ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scope), func(t *testing.T) {
+			transformer, err := NewSealedSecretTransformer(certPEM, tt.scope, "web")
+			if err != nil {
+				t.Fatalf("NewSealedSecretTransformer() error = %v", err)
+			}
+			got, err := transformer.Transform(manifests)
+			if err != nil {
+				t.Fatalf("Transform() error = %v", err)
+			}
+			if got[0].Kind() != "ConfigMap" {
+				t.Errorf("Transform() modified %s", got[0].Kind())
+			}
+			sealed := got[1]
+			if sealed.Kind() != "SealedSecret" || sealed.Namespace() != "web" {
+				t.Fatalf("Transform() = %v, want SealedSecret in namespace web", sealed)
+			}
+			for key, want := range map[string]string{"password": "hunter2", "username": "admin"} {
+				encrypted, _ := yamlPlus.GetString(sealed, "spec", "encryptedData", key)
+				if got := decrypt(encrypted, tt.label); got != want {
+					t.Errorf("decrypted %s = %v, want %v", key, got, want)
+				}
+			}
+			if label, _ := yamlPlus.GetString(sealed, "spec", "template", "metadata", "labels", "app"); label != "db" {
+				t.Errorf("Transform() template labels not preserved: %v", sealed["spec"])
+			}
+		})
+	}
+}
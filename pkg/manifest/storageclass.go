@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// legacyStorageClassAnnotation is the annotation used to select the
+// StorageClass of a PersistentVolumeClaim before spec.storageClassName.
+const legacyStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+
+// StorageClassTransformer is a Transformer rewriting the storageClassName of
+// PersistentVolumeClaims and StatefulSet volumeClaimTemplates, so the same
+// render can target clusters with different storage offerings.
+// Claims without a storage class (i.e. using the default StorageClass of the
+// cluster) are left untouched.
+type StorageClassTransformer struct {
+	Mapping map[string]string // maps storage class names to their replacement. e.g: {"standard": "managed-premium"}
+}
+
+// Transform rewrites the storage classes of the claims in manifests.
+// Manifests are copied before being modified.
+func (t StorageClassTransformer) Transform(manifests []Manifest) ([]Manifest, error) {
+	transformed := make([]Manifest, len(manifests))
+	for idx, m := range manifests {
+		transformed[idx] = m
+		switch m.GVK().GroupKind() {
+		case GroupKind{Kind: "PersistentVolumeClaim"}:
+			copied := m.DeepCopy()
+			t.rewriteClaim(copied)
+			transformed[idx] = copied
+		case GroupKind{Group: "apps", Kind: "StatefulSet"}:
+			copied := m.DeepCopy()
+			templates, _ := yamlPlus.GetSlice(copied, "spec", "volumeClaimTemplates")
+			for _, entry := range templates {
+				if claim, ok := entry.(map[string]interface{}); ok {
+					t.rewriteClaim(claim)
+				}
+			}
+			transformed[idx] = copied
+		}
+	}
+	return transformed, nil
+}
+
+// rewriteClaim rewrites spec.storageClassName and the legacy storage class
+// annotation of claim in place.
+func (t StorageClassTransformer) rewriteClaim(claim map[string]interface{}) {
+	if spec, ok := yamlPlus.GetMap(claim, "spec"); ok {
+		if class, ok := yamlPlus.GetString(spec, "storageClassName"); ok {
+			if replacement, ok := t.Mapping[class]; ok {
+				spec["storageClassName"] = replacement
+			}
+		}
+	}
+	if annotations, ok := yamlPlus.GetMap(claim, "metadata", "annotations"); ok {
+		if class, ok := yamlPlus.GetString(annotations, legacyStorageClassAnnotation); ok {
+			if replacement, ok := t.Mapping[class]; ok {
+				annotations[legacyStorageClassAnnotation] = replacement
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStorageClassTransformer_Transform(t *testing.T) {
+	transformer := StorageClassTransformer{Mapping: map[string]string{"standard": "managed-premium"}}
+	claim := func(class interface{}) map[string]interface{} {
+		spec := map[string]interface{}{"accessModes": []interface{}{"ReadWriteOnce"}}
+		if class != nil {
+			spec["storageClassName"] = class
+		}
+		return map[string]interface{}{"metadata": map[string]interface{}{"name": "data"}, "spec": spec}
+	}
+	pvc := func(class interface{}) Manifest {
+		m := Manifest(claim(class))
+		m["apiVersion"] = "v1"
+		m["kind"] = "PersistentVolumeClaim"
+		return m
+	}
+	statefulSet := func(classes ...interface{}) Manifest {
+		var templates []interface{}
+		for _, class := range classes {
+			templates = append(templates, claim(class))
+		}
+		return Manifest{
+			"apiVersion": "apps/v1",
+			"kind":       "StatefulSet",
+			"metadata":   map[string]interface{}{"name": "db"},
+			"spec":       map[string]interface{}{"volumeClaimTemplates": templates},
+		}
+	}
+	legacyPVC := Manifest{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolumeClaim",
+		"metadata": map[string]interface{}{
+			"name":        "legacy",
+			"annotations": map[string]interface{}{legacyStorageClassAnnotation: "standard"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		manifests []Manifest
+		want      []Manifest
+	}{
+		{
+			name:      "pvc",
+			manifests: []Manifest{pvc("standard"), pvc("fast"), pvc(nil)},
+			want:      []Manifest{pvc("managed-premium"), pvc("fast"), pvc(nil)},
+		},
+		{
+			name:      "stateful-set",
+			manifests: []Manifest{statefulSet("standard", "fast")},
+			want:      []Manifest{statefulSet("managed-premium", "fast")},
+		},
+		{
+			name:      "legacy-annotation",
+			manifests: []Manifest{legacyPVC},
+			want: []Manifest{{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"metadata": map[string]interface{}{
+					"name":        "legacy",
+					"annotations": map[string]interface{}{legacyStorageClassAnnotation: "managed-premium"},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := make([]Manifest, len(tt.manifests))
+			for idx, m := range tt.manifests {
+				original[idx] = m.DeepCopy()
+			}
+			got, err := transformer.Transform(tt.manifests)
+			if err != nil {
+				t.Fatalf("StorageClassTransformer.Transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StorageClassTransformer.Transform() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.manifests, original) {
+				t.Errorf("StorageClassTransformer.Transform() modified its input")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package manifest
+
+// Transformer modifies rendered manifests. e.g: to apply organization-wide
+// policy or per-environment customizations after templating.
+type Transformer interface {
+	Transform(manifests []Manifest) ([]Manifest, error)
+}
+
+// TransformerFunc adapts a function into a Transformer.
+type TransformerFunc func(manifests []Manifest) ([]Manifest, error)
+
+// Transform calls f(manifests).
+func (f TransformerFunc) Transform(manifests []Manifest) ([]Manifest, error) {
+	return f(manifests)
+}
+
+// Transform applies transformers to manifests in order.
+func Transform(manifests []Manifest, transformers ...Transformer) ([]Manifest, error) {
+	for _, transformer := range transformers {
+		var err error
+		if manifests, err = transformer.Transform(manifests); err != nil {
+			return nil, err
+		}
+	}
+	return manifests, nil
+}
+
+// ToMaps converts manifests back to plain maps.
+func ToMaps(manifests []Manifest) []map[string]interface{} {
+	maps := make([]map[string]interface{}, len(manifests))
+	for idx, m := range manifests {
+		maps[idx] = m
+	}
+	return maps
+}
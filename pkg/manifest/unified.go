@@ -0,0 +1,205 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnifiedContext is the number of unchanged lines around each hunk of a
+// unified diff.
+const UnifiedContext = 3
+
+// UnifiedDiff returns the unified diff of the yaml representation of the
+// resource before and after the change. e.g:
+//   --- apps/v1, Kind=Deployment web/api (before)
+//   +++ apps/v1, Kind=Deployment web/api (after)
+//   @@ -6,3 +6,3 @@
+//    spec:
+//   -  replicas: 1
+//   +  replicas: 2
+func (c Change) UnifiedDiff() (string, error) {
+	before, err := yamlLines(c.Before)
+	if err != nil {
+		return "", fmt.Errorf(`diffing %s %s: %w`, c.Resource.GVK, resourceName(c.Resource), err)
+	}
+	after, err := yamlLines(c.After)
+	if err != nil {
+		return "", fmt.Errorf(`diffing %s %s: %w`, c.Resource.GVK, resourceName(c.Resource), err)
+	}
+	resource := fmt.Sprintf("%s %s", c.Resource.GVK, resourceName(c.Resource))
+	return unifiedDiff(resource+" (before)", resource+" (after)", before, after, UnifiedContext), nil
+}
+
+// UnifiedDiff returns the unified diffs of all changes, in the order added,
+// changed, removed.
+func (d Diff) UnifiedDiff() (string, error) {
+	var b strings.Builder
+	for _, changes := range [][]Change{d.Added, d.Changed, d.Removed} {
+		for _, change := range changes {
+			unified, err := change.UnifiedDiff()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(unified)
+		}
+	}
+	return b.String(), nil
+}
+
+// yamlLines returns the lines of the yaml representation of m. A nil
+// manifest has no lines.
+func yamlLines(m Manifest) ([]string, error) {
+	if m == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"), nil
+}
+
+// lineEdit is a single line of an edit script: kept (' '), removed ('-') or
+// inserted ('+').
+type lineEdit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script turning a into b, computed with
+// the Myers diff algorithm.
+func diffLines(a []string, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack through the furthest reaching paths of each round
+	var edits []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, lineEdit{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, lineEdit{'+', b[y-1]})
+			} else {
+				edits = append(edits, lineEdit{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// unifiedDiff formats the differences between a and b as a unified diff with
+// context lines around each hunk. Identical inputs produce an empty diff.
+func unifiedDiff(fromName string, toName string, a []string, b []string, context int) string {
+	edits := diffLines(a, b)
+	var changed []int
+	for idx, edit := range edits {
+		if edit.op != ' ' {
+			changed = append(changed, idx)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(changed); {
+		// extend the hunk while the next change is within the context of the last
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*context {
+			end++
+		}
+		first := changed[start] - context
+		if first < 0 {
+			first = 0
+		}
+		last := changed[end] + context + 1
+		if last > len(edits) {
+			last = len(edits)
+		}
+
+		aLine, bLine := 1, 1
+		for _, edit := range edits[:first] {
+			if edit.op != '+' {
+				aLine++
+			}
+			if edit.op != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, edit := range edits[first:last] {
+			if edit.op != '+' {
+				aCount++
+			}
+			if edit.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, edit := range edits[first:last] {
+			out.WriteByte(edit.op)
+			out.WriteString(edit.line)
+			out.WriteByte('\n')
+		}
+		start = end + 1
+	}
+	return out.String()
+}
+
+// hunkRange formats the line range of a hunk. Empty ranges start at the line
+// before the hunk and the count of single line ranges is omitted.
+func hunkRange(line int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprint(line)
+	default:
+		return fmt.Sprintf("%d,%d", line, count)
+	}
+}
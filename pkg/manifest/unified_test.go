@@ -0,0 +1,84 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	lines := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: "",
+		},
+		{
+			name: "change",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8",
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate-hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n",
+		},
+		{
+			name: "added",
+			a:    "",
+			b:    "a\nb",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed",
+			a:    "a",
+			b:    "",
+			want: "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", lines(tt.a), lines(tt.b), UnifiedContext); got != tt.want {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChange_UnifiedDiff(t *testing.T) {
+	before := Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config", "namespace": "web"}, "data": map[string]interface{}{"key": "old"}}
+	after := Manifest{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "config", "namespace": "web"}, "data": map[string]interface{}{"key": "new"}}
+	change := Change{Type: Changed, Resource: KeyOf(after), Before: before, After: after}
+
+	got, err := change.UnifiedDiff()
+	if err != nil {
+		t.Fatalf("Change.UnifiedDiff() error = %v", err)
+	}
+	want := `--- v1, Kind=ConfigMap web/config (before)
++++ v1, Kind=ConfigMap web/config (after)
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-    key: old
++    key: new
+ kind: ConfigMap
+ metadata:
+     name: config
+`
+	if got != want {
+		t.Errorf("Change.UnifiedDiff() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CheckVulnerability is the check identifier for known vulnerabilities of the
+// container images referenced by rendered workloads.
+const CheckVulnerability = "vulnerability"
+
+// Vulnerability is a known vulnerability of a package of a container image.
+type Vulnerability struct {
+	ID               string // e.g: "CVE-2021-3711"
+	Severity         string // severity as reported by the scanner, upper-cased. e.g: "CRITICAL", "HIGH", "MEDIUM", "LOW" or "UNKNOWN"
+	Package          string // e.g: "openssl"
+	InstalledVersion string
+	FixedVersion     string // empty if no fix is available
+	Title            string
+}
+
+// ImageScanner scans container images for known vulnerabilities.
+type ImageScanner interface {
+	ScanImage(reference string) ([]Vulnerability, error)
+}
+
+// severityRanks orders the severities of vulnerabilities. Unknown severities
+// rank lowest.
+var severityRanks = map[string]int{"NEGLIGIBLE": 1, "LOW": 2, "MEDIUM": 3, "HIGH": 4, "CRITICAL": 5}
+
+// ScanOptions configure ScanImages.
+type ScanOptions struct {
+	Scanner ImageScanner
+	// MinSeverity is the lowest severity of the vulnerabilities reported.
+	// e.g: "HIGH" reports HIGH and CRITICAL vulnerabilities. Defaults to all.
+	MinSeverity string
+	// IgnoreIDs are vulnerabilities which are not reported (e.g. accepted
+	// risks). e.g: ["CVE-2021-3711"]
+	IgnoreIDs []string
+}
+
+// ScanImages scans each container image referenced by the workloads of
+// manifests (see Images) with opts.Scanner, reporting a Finding per
+// vulnerability and workload referencing the image, so renders can be gated
+// on known CVEs. CRITICAL and HIGH vulnerabilities are errors, MEDIUM
+// vulnerabilities warnings and others info.
+func ScanImages(manifests []Manifest, opts ScanOptions) ([]Finding, error) {
+	ignored := map[string]bool{}
+	for _, id := range opts.IgnoreIDs {
+		ignored[id] = true
+	}
+	var findings []Finding
+	for _, image := range Images(manifests) {
+		vulnerabilities, err := opts.Scanner.ScanImage(image.Reference)
+		if err != nil {
+			return nil, fmt.Errorf(`scanning image %s: %w`, image.Reference, err)
+		}
+		for _, vulnerability := range vulnerabilities {
+			if ignored[vulnerability.ID] || severityRanks[vulnerability.Severity] < severityRanks[strings.ToUpper(opts.MinSeverity)] {
+				continue
+			}
+			message := fmt.Sprintf("%s: %s (%s) in %s %s", image.Reference, vulnerability.ID, vulnerability.Severity, vulnerability.Package, vulnerability.InstalledVersion)
+			if vulnerability.FixedVersion != "" {
+				message += ", fixed in " + vulnerability.FixedVersion
+			}
+			if vulnerability.Title != "" {
+				message += ": " + vulnerability.Title
+			}
+			for _, resource := range image.Resources {
+				findings = append(findings, Finding{
+					Check:    CheckVulnerability,
+					Severity: vulnerabilitySeverity(vulnerability.Severity),
+					Resource: resource,
+					Message:  message,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// vulnerabilitySeverity returns the Severity of findings of vulnerabilities
+// of severity.
+func vulnerabilitySeverity(severity string) Severity {
+	switch rank := severityRanks[severity]; {
+	case rank >= severityRanks["HIGH"]:
+		return SeverityError
+	case rank == severityRanks["MEDIUM"]:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// TrivyScanner is an ImageScanner running the trivy CLI
+// (https://github.com/aquasecurity/trivy).
+type TrivyScanner struct {
+	Binary string   // path of the trivy binary. defaults to "trivy"
+	Args   []string // added to `trivy image`. e.g: ["--ignore-unfixed"]
+
+	Ctx context.Context // kills trivy when done, e.g. when the process receives SIGTERM. defaults to context.Background()
+}
+
+// ScanImage runs `trivy image --format json` on reference.
+func (s TrivyScanner) ScanImage(reference string) ([]Vulnerability, error) {
+	args := append([]string{"image", "--quiet", "--format", "json"}, s.Args...)
+	output, err := runScanner(s.Ctx, s.Binary, "trivy", append(args, reference)...)
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				FixedVersion     string
+				Severity         string
+				Title            string
+			}
+		}
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf(`parsing output of trivy: %w`, err)
+	}
+	var vulnerabilities []Vulnerability
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			vulnerabilities = append(vulnerabilities, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Severity:         strings.ToUpper(v.Severity),
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Title:            v.Title,
+			})
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// GrypeScanner is an ImageScanner running the grype CLI
+// (https://github.com/anchore/grype).
+type GrypeScanner struct {
+	Binary string   // path of the grype binary. defaults to "grype"
+	Args   []string // added to `grype`. e.g: ["--only-fixed"]
+
+	Ctx context.Context // kills grype when done, e.g. when the process receives SIGTERM. defaults to context.Background()
+}
+
+// ScanImage runs `grype --output json` on reference.
+func (s GrypeScanner) ScanImage(reference string) ([]Vulnerability, error) {
+	args := append([]string{"--quiet", "--output", "json"}, s.Args...)
+	output, err := runScanner(s.Ctx, s.Binary, "grype", append(args, reference)...)
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf(`parsing output of grype: %w`, err)
+	}
+	var vulnerabilities []Vulnerability
+	for _, match := range report.Matches {
+		vulnerabilities = append(vulnerabilities, Vulnerability{
+			ID:               match.Vulnerability.ID,
+			Severity:         strings.ToUpper(match.Vulnerability.Severity),
+			Package:          match.Artifact.Name,
+			InstalledVersion: match.Artifact.Version,
+			FixedVersion:     strings.Join(match.Vulnerability.Fix.Versions, ", "),
+			Title:            match.Vulnerability.Description,
+		})
+	}
+	return vulnerabilities, nil
+}
+
+// runScanner runs binary (or defaultBinary when empty) with args until ctx is
+// done, returning its stdout.
+func runScanner(ctx context.Context, binary string, defaultBinary string, args ...string) ([]byte, error) {
+	if binary == "" {
+		binary = defaultBinary
+	}
+	cmd := exec.CommandContext(orBackground(ctx), binary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(`running "%s": %w: %v`, cmd, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
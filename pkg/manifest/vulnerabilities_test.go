@@ -0,0 +1,90 @@
+package manifest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScanImages(t *testing.T) {
+	// the fake scanners report a vulnerability of each severity for nginx and
+	// none for other images
+	trivy := writeFakeKubectl(t, `for arg; do image="$arg"; done
+case "$image" in
+nginx:*) echo '{"Results": [{"Vulnerabilities": [
+  {"VulnerabilityID": "CVE-1", "PkgName": "openssl", "InstalledVersion": "1.1.1", "FixedVersion": "1.1.1k", "Severity": "CRITICAL", "Title": "buffer overflow"},
+  {"VulnerabilityID": "CVE-2", "PkgName": "curl", "InstalledVersion": "7.0", "Severity": "MEDIUM"},
+  {"VulnerabilityID": "CVE-3", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "LOW"}
+]}]}' ;;
+*) echo '{"Results": []}' ;;
+esac`)
+	grype := writeFakeKubectl(t, `for arg; do image="$arg"; done
+case "$image" in
+nginx:*) echo '{"matches": [
+  {"vulnerability": {"id": "CVE-1", "severity": "Critical", "description": "buffer overflow", "fix": {"versions": ["1.1.1k"]}}, "artifact": {"name": "openssl", "version": "1.1.1"}},
+  {"vulnerability": {"id": "CVE-2", "severity": "Medium", "fix": {"versions": []}}, "artifact": {"name": "curl", "version": "7.0"}},
+  {"vulnerability": {"id": "CVE-3", "severity": "Low", "fix": {"versions": []}}, "artifact": {"name": "zlib", "version": "1.2"}}
+]}' ;;
+*) echo '{"matches": []}' ;;
+esac`)
+	deployment := Manifest{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "web"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.19"},
+						map[string]interface{}{"name": "sidecar", "image": "busybox"},
+					},
+				},
+			},
+		},
+	}
+	web := KeyOf(deployment)
+	critical := Finding{Check: CheckVulnerability, Severity: SeverityError, Resource: web, Message: "nginx:1.19: CVE-1 (CRITICAL) in openssl 1.1.1, fixed in 1.1.1k: buffer overflow"}
+	medium := Finding{Check: CheckVulnerability, Severity: SeverityWarning, Resource: web, Message: "nginx:1.19: CVE-2 (MEDIUM) in curl 7.0"}
+	low := Finding{Check: CheckVulnerability, Severity: SeverityInfo, Resource: web, Message: "nginx:1.19: CVE-3 (LOW) in zlib 1.2"}
+
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []Finding
+	}{
+		{"trivy", ScanOptions{Scanner: TrivyScanner{Binary: trivy}}, []Finding{critical, medium, low}},
+		{"grype", ScanOptions{Scanner: GrypeScanner{Binary: grype}}, []Finding{critical, medium, low}},
+		{"min-severity", ScanOptions{Scanner: TrivyScanner{Binary: trivy}, MinSeverity: "medium"}, []Finding{critical, medium}},
+		{"ignored", ScanOptions{Scanner: TrivyScanner{Binary: trivy}, IgnoreIDs: []string{"CVE-1"}}, []Finding{medium, low}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScanImages([]Manifest{deployment}, tt.opts)
+			if err != nil {
+				t.Fatalf("ScanImages() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	failing := writeFakeKubectl(t, `echo "FATAL: image not found" >&2; exit 1`)
+	if _, err := ScanImages([]Manifest{deployment}, ScanOptions{Scanner: TrivyScanner{Binary: failing}}); err == nil {
+		t.Error("ScanImages() error = nil, want the scanner error")
+	}
+}
+
+func TestTrivyScanner_cancelled(t *testing.T) {
+	trivy := writeFakeKubectl(t, `exec sleep 10`)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := (TrivyScanner{Binary: trivy, Ctx: ctx}).ScanImage("nginx:1.19"); err == nil {
+		t.Errorf("ScanImage() error = nil, want trivy killed")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ScanImage() took %s, want trivy killed when the context is done", elapsed)
+	}
+}
@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	yamlPlus "github.com/evanlouie/go/pkg/yaml"
+)
+
+// workloadKinds are the kinds which run pods, mapped to the path of their pod
+// template within the manifest. Pods are their own template.
+var workloadKinds = map[string][]string{
+	"Pod":                   {},
+	"Deployment":            {"spec", "template"},
+	"StatefulSet":           {"spec", "template"},
+	"DaemonSet":             {"spec", "template"},
+	"ReplicaSet":            {"spec", "template"},
+	"ReplicationController": {"spec", "template"},
+	"Job":                   {"spec", "template"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template"},
+}
+
+// IsWorkload determines if the manifest is of a kind which runs pods (e.g.
+// Deployment, StatefulSet, CronJob).
+func (m Manifest) IsWorkload() bool {
+	_, ok := workloadKinds[m.Kind()]
+	return ok
+}
+
+// PodTemplate returns the pod template of a workload (e.g.
+// spec.template of a Deployment). A Pod is its own template.
+// Returns nil if the manifest is not a workload.
+func (m Manifest) PodTemplate() map[string]interface{} {
+	path, ok := workloadKinds[m.Kind()]
+	if !ok {
+		return nil
+	}
+	if len(path) == 0 {
+		return m
+	}
+	template, _ := yamlPlus.GetMap(m, path...)
+	return template
+}
+
+// PodSpec returns the pod spec of a workload (e.g. spec.template.spec of a
+// Deployment). Returns nil if the manifest is not a workload.
+func (m Manifest) PodSpec() map[string]interface{} {
+	spec, _ := yamlPlus.GetMap(m.PodTemplate(), "spec")
+	return spec
+}
+
+// PodTemplateLabels returns the labels of the pods created by a workload
+// (e.g. spec.template.metadata.labels of a Deployment).
+func (m Manifest) PodTemplateLabels() map[string]string {
+	labels, _ := yamlPlus.GetMap(m.PodTemplate(), "metadata", "labels")
+	return toStringMap(labels)
+}
+
+// Containers returns all containers of a workload's pod spec, including init
+// and ephemeral containers.
+func (m Manifest) Containers() []map[string]interface{} {
+	spec := m.PodSpec()
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		entries, _ := yamlPlus.GetSlice(spec, field)
+		for _, entry := range entries {
+			if container, ok := entry.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
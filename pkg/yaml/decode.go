@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/evanlouie/go/pkg/errcode"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,7 +24,7 @@ func Decode(doc []byte) ([]interface{}, error) {
 			return values, nil
 		case err != nil:
 			// error case: return the error
-			return nil, fmt.Errorf(`decoding yaml in yaml document %s`, string(doc))
+			return nil, errcode.Wrap(errcode.YAMLDecode, fmt.Errorf(`decoding yaml in yaml document %s`, string(doc)))
 		default:
 			// recursive case: append the decoded value
 			values = append(values, value)
@@ -50,7 +51,7 @@ func DecodeMaps(doc []byte) ([]map[string]interface{}, error) {
 			var ok bool
 			decoded, ok = value.(map[string]interface{})
 			if !ok {
-				return nil, fmt.Errorf(`unable to reflect value %+v as a map[string]interface{}`, value)
+				return nil, errcode.Wrap(errcode.YAMLNonMapDocument, fmt.Errorf(`unable to reflect value %+v as a map[string]interface{}`, value))
 			}
 		}
 		maps = append(maps, decoded)
@@ -58,3 +59,166 @@ func DecodeMaps(doc []byte) ([]map[string]interface{}, error) {
 
 	return maps, nil
 }
+
+// Document is a single decoded yaml document along with its location in the
+// original (possibly multi-document) body.
+type Document struct {
+	Value     interface{} // the decoded value of the document
+	Offset    int         // byte offset of the start of the document (inclusive)
+	End       int         // byte offset of the end of the document (exclusive)
+	StartLine int         // 1-indexed line the document starts on
+	EndLine   int         // 1-indexed line the document ends on
+}
+
+// DecodeDocumentsWithOffsets decodes a single or multi-document yaml body the
+// same way as Decode but additionally returns the byte offsets and line range
+// of each document within doc.
+// This is useful for pointing users at the exact location of a document in a
+// large multi-document file (e.g. in error messages or diffs).
+// Will error if any document in the body is unable to be parsed.
+func DecodeDocumentsWithOffsets(doc []byte) ([]Document, error) {
+	var documents []Document
+	for _, chunk := range splitDocuments(doc) {
+		value, err := decodeFirst(doc[chunk.Offset:chunk.End])
+		if err != nil {
+			return nil, errcode.Wrap(errcode.YAMLDecode, fmt.Errorf(`decoding yaml document at lines %d-%d: %w`, chunk.StartLine, chunk.EndLine, err))
+		}
+		chunk.Value = value
+		documents = append(documents, chunk)
+	}
+
+	return documents, nil
+}
+
+// SplitDocuments splits a single or multi-document yaml body into the byte
+// offsets and line ranges of each document without decoding them; Value of
+// each Document is nil.
+// Unlike the decoding functions, SplitDocuments never fails, making it
+// suitable for cheaply inspecting large or malformed bodies.
+func SplitDocuments(doc []byte) []Document {
+	return splitDocuments(doc)
+}
+
+// decodeFirst decodes the first yaml document found in doc. An empty document
+// decodes to nil.
+func decodeFirst(doc []byte) (interface{}, error) {
+	var value interface{}
+	if err := yaml.NewDecoder(bytes.NewReader(doc)).Decode(&value); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return value, nil
+}
+
+// splitDocuments splits a multi-document yaml body into the byte/line ranges
+// of each document without decoding them. Leading content containing only
+// comments and whitespace is not treated as a document, mirroring the
+// behavior of the yaml decoder.
+func splitDocuments(doc []byte) []Document {
+	var (
+		documents  []Document
+		current    Document
+		explicit   bool // document was started with a "---" marker
+		hasContent bool // document contains a non-comment, non-blank line
+	)
+	current.StartLine = 1
+
+	// emit appends the current document if it would be decoded as a document
+	emit := func(end int, endLine int) {
+		if explicit || hasContent {
+			current.End = end
+			current.EndLine = endLine
+			documents = append(documents, current)
+		}
+	}
+
+	offset, lineNumber := 0, 0
+	for offset < len(doc) {
+		lineNumber++
+		lineEnd := bytes.IndexByte(doc[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(doc)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := bytes.TrimRight(doc[offset:lineEnd], "\r\n")
+		trimmed := bytes.TrimSpace(line)
+
+		switch {
+		case isMarker(line, "---"):
+			// a document start marker ends the previous document
+			emit(offset, lineNumber-1)
+			current = Document{Offset: offset, StartLine: lineNumber}
+			explicit, hasContent = true, len(bytes.TrimSpace(line[3:])) > 0
+		case isMarker(line, "..."):
+			// a document end marker ends the current document inclusively
+			emit(lineEnd, lineNumber)
+			current = Document{Offset: lineEnd, StartLine: lineNumber + 1}
+			explicit, hasContent = false, false
+		case len(trimmed) > 0 && trimmed[0] != '#':
+			hasContent = true
+		}
+		offset = lineEnd
+	}
+	emit(len(doc), lineNumber)
+
+	return documents
+}
+
+// isMarker checks if line is the yaml document marker (e.g. "---" or "...")
+// optionally followed by whitespace and content.
+func isMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	return len(line) == len(marker) || line[len(marker)] == ' ' || line[len(marker)] == '\t'
+}
+
+// DocumentError is an error encountered decoding a single document of a
+// multi-document yaml body.
+type DocumentError struct {
+	Index     int // 0-indexed position of the document in the body
+	StartLine int // 1-indexed line the document starts on
+	EndLine   int // 1-indexed line the document ends on
+	Err       error
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf(`decoding yaml document %d at lines %d-%d: %v`, e.Index, e.StartLine, e.EndLine, e.Err)
+}
+
+// Code returns errcode.YAMLDecode.
+func (e *DocumentError) Code() errcode.Code {
+	return errcode.YAMLDecode
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeCollect decodes every document in a single or multi-document yaml body
+// that it can, returning both the successfully decoded documents and an error
+// for each document which could not be decoded.
+// Unlike Decode, a malformed document does not abort decoding of the
+// documents after it.
+func DecodeCollect(doc []byte) ([]Document, []*DocumentError) {
+	var (
+		documents []Document
+		errs      []*DocumentError
+	)
+	for idx, chunk := range splitDocuments(doc) {
+		value, err := decodeFirst(doc[chunk.Offset:chunk.End])
+		if err != nil {
+			errs = append(errs, &DocumentError{
+				Index:     idx,
+				StartLine: chunk.StartLine,
+				EndLine:   chunk.EndLine,
+				Err:       err,
+			})
+			continue
+		}
+		chunk.Value = value
+		documents = append(documents, chunk)
+	}
+
+	return documents, errs
+}
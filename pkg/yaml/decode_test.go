@@ -183,3 +183,141 @@ foo:
 		})
 	}
 }
+
+func TestDecodeDocumentsWithOffsets(t *testing.T) {
+	type args struct {
+		doc []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []Document
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			args:    args{},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "single doc without header",
+			args: args{[]byte("foo: bar\n")},
+			want: []Document{
+				{Value: map[string]interface{}{"foo": "bar"}, Offset: 0, End: 9, StartLine: 1, EndLine: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "leading comment is not a document",
+			args: args{[]byte("# comment\n---\nfoo: 1")},
+			want: []Document{
+				{Value: map[string]interface{}{"foo": 1}, Offset: 10, End: 20, StartLine: 2, EndLine: 3},
+			},
+			wantErr: false,
+		},
+		{
+			name: "multi doc with trailing header",
+			args: args{[]byte("---\nfoo: 1\n---\nbar: 2\n---\n")},
+			want: []Document{
+				{Value: map[string]interface{}{"foo": 1}, Offset: 0, End: 11, StartLine: 1, EndLine: 2},
+				{Value: map[string]interface{}{"bar": 2}, Offset: 11, End: 22, StartLine: 3, EndLine: 4},
+				{Value: nil, Offset: 22, End: 26, StartLine: 5, EndLine: 5},
+			},
+			wantErr: false,
+		},
+		{
+			name: "document end marker",
+			args: args{[]byte("a: 1\n...\n--- b\n")},
+			want: []Document{
+				{Value: map[string]interface{}{"a": 1}, Offset: 0, End: 9, StartLine: 1, EndLine: 2},
+				{Value: "b", Offset: 9, End: 15, StartLine: 3, EndLine: 3},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid document",
+			args:    args{[]byte("foo: 1\n---\na: [\n")},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeDocumentsWithOffsets(tt.args.doc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodeDocumentsWithOffsets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeDocumentsWithOffsets() = %+v, want %+v", got, tt.want)
+			}
+			// the number of documents must always agree with Decode
+			if values, err := Decode(tt.args.doc); err == nil && len(values) != len(got) {
+				t.Errorf("DecodeDocumentsWithOffsets() returned %d documents, Decode() returned %d", len(got), len(values))
+			}
+		})
+	}
+}
+
+func TestDecodeCollect(t *testing.T) {
+	type args struct {
+		doc []byte
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantValues []interface{}
+		wantErrs   []int // indices of the documents expected to fail
+	}{
+		{
+			name:       "empty",
+			args:       args{},
+			wantValues: nil,
+			wantErrs:   nil,
+		},
+		{
+			name:       "all valid",
+			args:       args{[]byte("foo: 1\n---\nbar: 2")},
+			wantValues: []interface{}{map[string]interface{}{"foo": 1}, map[string]interface{}{"bar": 2}},
+			wantErrs:   nil,
+		},
+		{
+			name: "malformed documents in the middle",
+			args: args{[]byte(`foo: 1
+---
+a: [
+---
+bar: 2
+---
+b: "unterminated
+---
+baz: 3`)},
+			wantValues: []interface{}{
+				map[string]interface{}{"foo": 1},
+				map[string]interface{}{"bar": 2},
+				map[string]interface{}{"baz": 3},
+			},
+			wantErrs: []int{1, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := DecodeCollect(tt.args.doc)
+			var gotValues []interface{}
+			for _, document := range got {
+				gotValues = append(gotValues, document.Value)
+			}
+			if !reflect.DeepEqual(gotValues, tt.wantValues) {
+				t.Errorf("DecodeCollect() values = %v, want %v", gotValues, tt.wantValues)
+			}
+			var gotErrs []int
+			for _, err := range errs {
+				gotErrs = append(gotErrs, err.Index)
+			}
+			if !reflect.DeepEqual(gotErrs, tt.wantErrs) {
+				t.Errorf("DecodeCollect() errors = %v, want errors for documents %v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Get walks the nested maps in m along path and returns the value found at the
+// end of it.
+// Path is provided as separate keys rather than a dotted string as keys in
+// Kubernetes yaml commonly contain dots (e.g. "app.kubernetes.io/name"):
+//   Get(m, "metadata", "labels", "app.kubernetes.io/name")
+// Returns false if any key along the path is not present or is not a map.
+func Get(m map[string]interface{}, path ...string) (interface{}, bool) {
+	var current interface{} = m
+	for _, key := range path {
+		asMap, ok := toMap(current)
+		if !ok {
+			return nil, false
+		}
+		current, ok = asMap[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// GetMap returns the map found at path in m.
+// Maps decoded with interface{} keys are converted to string keys.
+func GetMap(m map[string]interface{}, path ...string) (map[string]interface{}, bool) {
+	value, ok := Get(m, path...)
+	if !ok {
+		return nil, false
+	}
+	return toMap(value)
+}
+
+// GetSlice returns the list found at path in m.
+func GetSlice(m map[string]interface{}, path ...string) ([]interface{}, bool) {
+	value, ok := Get(m, path...)
+	if !ok {
+		return nil, false
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		slice := make([]interface{}, len(v))
+		for idx, entry := range v {
+			slice[idx] = entry
+		}
+		return slice, true
+	case []string:
+		slice := make([]interface{}, len(v))
+		for idx, entry := range v {
+			slice[idx] = entry
+		}
+		return slice, true
+	default:
+		return nil, false
+	}
+}
+
+// GetString returns the value found at path in m as a string.
+// Scalars which yaml decoded as a non-string type (e.g. a version of 1.10
+// decoded as a float or a tag of 123 decoded as an int) are formatted as
+// strings.
+func GetString(m map[string]interface{}, path ...string) (string, bool) {
+	value, ok := Get(m, path...)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprint(v), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// GetInt returns the value found at path in m as an int.
+// Integral floats (e.g. 3.0) and numeric strings (e.g. "3") are converted.
+func GetInt(m map[string]interface{}, path ...string) (int, bool) {
+	value, ok := Get(m, path...)
+	if !ok {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float32:
+		return floatToInt(float64(v))
+	case float64:
+		return floatToInt(v)
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return floatToInt(f)
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+}
+
+// GetBool returns the value found at path in m as a bool.
+// Strings such as "true", "false", "1" and "0" are converted.
+func GetBool(m map[string]interface{}, path ...string) (bool, bool) {
+	value, ok := Get(m, path...)
+	if !ok {
+		return false, false
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+// floatToInt converts f to an int only if it has no fractional component.
+func floatToInt(f float64) (int, bool) {
+	if f != math.Trunc(f) || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// toMap reflects value as a map[string]interface{}, converting maps with
+// interface{} keys.
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, entry := range v {
+			converted[fmt.Sprint(key)] = entry
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,108 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+var sampleDecoded = map[string]interface{}{
+	"metadata": map[string]interface{}{
+		"name": "my-app",
+		"labels": map[string]interface{}{
+			"app.kubernetes.io/name": "my-app",
+		},
+	},
+	"spec": map[string]interface{}{
+		"replicas":   3,
+		"scale":      2.0,
+		"ratio":      0.5,
+		"version":    1.10,
+		"tag":        123,
+		"enabled":    "true",
+		"paused":     false,
+		"ports":      []interface{}{80, 443},
+		"legacy":     map[interface{}]interface{}{"key": "value"},
+		"numericStr": "42",
+	},
+}
+
+func TestGetString(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   []string
+		want   string
+		wantOk bool
+	}{
+		{"string", []string{"metadata", "name"}, "my-app", true},
+		{"dotted key", []string{"metadata", "labels", "app.kubernetes.io/name"}, "my-app", true},
+		{"float", []string{"spec", "version"}, "1.1", true},
+		{"int", []string{"spec", "tag"}, "123", true},
+		{"interface keyed map", []string{"spec", "legacy", "key"}, "value", true},
+		{"missing", []string{"spec", "missing"}, "", false},
+		{"through non-map", []string{"metadata", "name", "foo"}, "", false},
+		{"non-scalar", []string{"spec", "ports"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetString(sampleDecoded, tt.path...)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("GetString() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGetInt(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   []string
+		want   int
+		wantOk bool
+	}{
+		{"int", []string{"spec", "replicas"}, 3, true},
+		{"integral float", []string{"spec", "scale"}, 2, true},
+		{"fractional float", []string{"spec", "ratio"}, 0, false},
+		{"numeric string", []string{"spec", "numericStr"}, 42, true},
+		{"non-numeric string", []string{"metadata", "name"}, 0, false},
+		{"missing", []string{"spec", "missing"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetInt(sampleDecoded, tt.path...)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("GetInt() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGetBool(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   []string
+		want   bool
+		wantOk bool
+	}{
+		{"bool", []string{"spec", "paused"}, false, true},
+		{"string", []string{"spec", "enabled"}, true, true},
+		{"non-bool string", []string{"metadata", "name"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetBool(sampleDecoded, tt.path...)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("GetBool() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGetSlice(t *testing.T) {
+	got, ok := GetSlice(sampleDecoded, "spec", "ports")
+	if want := []interface{}{80, 443}; !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSlice() = %v, %v, want %v, %v", got, ok, want, true)
+	}
+	if got, ok := GetSlice(sampleDecoded, "metadata", "name"); ok {
+		t.Errorf("GetSlice() = %v, %v, want %v, %v", got, ok, nil, false)
+	}
+}
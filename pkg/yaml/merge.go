@@ -0,0 +1,56 @@
+package yaml
+
+// Merge deep merges maps into a new map, with later maps taking precedence
+// over earlier ones. This is how helm merges multiple values files:
+//   - maps are merged recursively (maps with interface{} keys are converted)
+//   - any other value, including lists, replaces the earlier value
+//   - null values are kept, so they still remove the key from the defaults
+//     of a chart when the merged map is passed as values
+// The merged maps are not modified; nested maps and lists are copied.
+func Merge(maps ...map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for _, m := range maps {
+		mergeInto(merged, m)
+	}
+	return merged
+}
+
+// mergeInto deep merges src into dst.
+func mergeInto(dst map[string]interface{}, src map[string]interface{}) {
+	for key, value := range src {
+		if srcMap, ok := toMap(value); ok {
+			if dstMap, ok := dst[key].(map[string]interface{}); ok {
+				mergeInto(dstMap, srcMap)
+				continue
+			}
+		}
+		dst[key] = copyValue(value)
+	}
+}
+
+// copyValue deep copies the maps and lists of a decoded yaml value.
+func copyValue(value interface{}) interface{} {
+	if m, ok := toMap(value); ok {
+		copied := make(map[string]interface{}, len(m))
+		for key, entry := range m {
+			copied[key] = copyValue(entry)
+		}
+		return copied
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for idx, entry := range v {
+			copied[idx] = copyValue(entry)
+		}
+		return copied
+	case []map[string]interface{}:
+		copied := make([]interface{}, len(v))
+		for idx, entry := range v {
+			copied[idx] = copyValue(entry)
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,68 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		maps []map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "none",
+			want: map[string]interface{}{},
+		},
+		{
+			name: "nested",
+			maps: []map[string]interface{}{
+				{"image": map[string]interface{}{"repository": "nginx", "tag": "1.0"}, "replicas": 1},
+				{"image": map[string]interface{}{"tag": "2.0"}},
+				{"replicas": 3},
+			},
+			want: map[string]interface{}{"image": map[string]interface{}{"repository": "nginx", "tag": "2.0"}, "replicas": 3},
+		},
+		{
+			name: "lists-replaced",
+			maps: []map[string]interface{}{
+				{"hosts": []interface{}{"a", "b"}},
+				{"hosts": []interface{}{"c"}},
+			},
+			want: map[string]interface{}{"hosts": []interface{}{"c"}},
+		},
+		{
+			name: "null-kept",
+			maps: []map[string]interface{}{
+				{"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}}},
+				{"resources": nil},
+			},
+			want: map[string]interface{}{"resources": nil},
+		},
+		{
+			name: "map-replaces-scalar",
+			maps: []map[string]interface{}{
+				{"service": "ClusterIP"},
+				{"service": map[interface{}]interface{}{"type": "NodePort"}},
+			},
+			want: map[string]interface{}{"service": map[string]interface{}{"type": "NodePort"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.maps...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge_doesNotModifyInputs(t *testing.T) {
+	base := map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}
+	merged := Merge(base, map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}})
+	merged["image"].(map[string]interface{})["repository"] = "nginx"
+	if want := map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}; !reflect.DeepEqual(base, want) {
+		t.Errorf("Merge() modified input: %v", base)
+	}
+}